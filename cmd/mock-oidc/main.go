@@ -0,0 +1,46 @@
+// Command mock-oidc is a minimal OpenID Connect provider for trying external
+// sign-in locally. It signs every visitor in as one configured user without
+// asking, so never expose it.
+//
+//	MOCK_OIDC_ADDR=:9000 MOCK_OIDC_EMAIL=jane@example.com go run ./cmd/mock-oidc
+//
+// and point the service at it:
+//
+//	IDP_PROVIDERS=mock IDP_MOCK_ISSUER=http://localhost:9000 IDP_MOCK_CLIENT_ID=user-auth-service
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"mikhailjbs/user-auth-service/internal/infra/idp/mockoidc"
+)
+
+func main() {
+	addr := getEnv("MOCK_OIDC_ADDR", ":9000")
+	verified, _ := strconv.ParseBool(getEnv("MOCK_OIDC_EMAIL_VERIFIED", "true"))
+	issuer := getEnv("MOCK_OIDC_ISSUER", "http://localhost"+addr)
+
+	p, err := mockoidc.New(issuer, map[string]interface{}{
+		"sub":                getEnv("MOCK_OIDC_SUBJECT", "mock-user-1"),
+		"email":              getEnv("MOCK_OIDC_EMAIL", "mock.user@example.com"),
+		"email_verified":     verified,
+		"name":               getEnv("MOCK_OIDC_NAME", "Mock User"),
+		"preferred_username": getEnv("MOCK_OIDC_USERNAME", "mockuser"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("mock OIDC provider %s listening on %s", issuer, addr)
+	log.Fatal(http.ListenAndServe(addr, p))
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
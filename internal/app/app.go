@@ -1,27 +1,57 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"mikhailjbs/user-auth-service/internal/config"
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
 	authdomain "mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/password"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/replay"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
 	sessiondomain "mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
 	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
 	"mikhailjbs/user-auth-service/internal/infra/http"
 	"mikhailjbs/user-auth-service/internal/infra/http/handlers"
+	"mikhailjbs/user-auth-service/internal/infra/idp"
 	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
 	"mikhailjbs/user-auth-service/internal/infra/middleware"
 	"mikhailjbs/user-auth-service/internal/infra/repository"
 	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+	apikeyusecase "mikhailjbs/user-auth-service/internal/usecase/apikey"
 	authusecase "mikhailjbs/user-auth-service/internal/usecase/auth"
+	identityusecase "mikhailjbs/user-auth-service/internal/usecase/identity"
+	mfausecase "mikhailjbs/user-auth-service/internal/usecase/mfa"
+	oauthusecase "mikhailjbs/user-auth-service/internal/usecase/oauth"
+	passkeyusecase "mikhailjbs/user-auth-service/internal/usecase/passkey"
 	usecase "mikhailjbs/user-auth-service/internal/usecase/user"
 )
 
+// shutdownTimeout bounds both closing open connections and draining queued
+// mail after a stop signal.
+const shutdownTimeout = 20 * time.Second
+
 func Run() {
 	// 1. Load Config
 	cfg := config.Load()
@@ -55,54 +85,308 @@ func Run() {
 	}
 
 	// Auto Migrate (for development simplicity, usually done via migration tools)
-	if err := db.AutoMigrate(&user.User{}, &sessiondomain.Session{}); err != nil {
+	if err := db.AutoMigrate(&user.User{}, &sessiondomain.Session{}, &signingkey.Key{}, &revocation.RevokedToken{}, &securityevent.Event{}, &verification.Token{}, &mfa.TOTPFactor{}, &mfa.RecoveryCode{}, &passkey.Credential{}, &identity.ExternalIdentity{}, &oauth.Client{}, &oauth.AuthorizationCode{}, &oauth.Consent{}, &apikey.APIKey{}, &lockout.Lockout{}, &ratelimit.Bucket{}, &replay.UsedNonce{}); err != nil {
 		logger.Log.Fatalf("Failed to migrate database: %v", err)
 	}
 
 	// 4. Init Repository
 	userRepo := repository.NewUserRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	securityEventRepo := repository.NewSecurityEventRepository(db)
+	verificationTokenRepo := repository.NewVerificationTokenRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	passkeyRepo := repository.NewPasskeyRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	lockoutRepo := repository.NewLockoutRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	var revokedTokenRepo revocation.Repository
+	switch cfg.RevocationStore {
+	case "memory":
+		revokedTokenRepo = repository.NewMemoryRevokedTokenRepository()
+	default:
+		revokedTokenRepo = repository.NewRevokedTokenRepository(db)
+	}
+	var rateLimitRepo ratelimit.Repository
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitRepo = repository.NewMemoryRateLimitRepository()
+	default:
+		rateLimitRepo = repository.NewRateLimitRepository(db)
+	}
+	var replayRepo replay.Repository
+	switch cfg.ReplayStore {
+	case "memory":
+		replayRepo = repository.NewMemoryReplayRepository()
+	default:
+		replayRepo = repository.NewReplayRepository(db)
+	}
 
 	// 5. Init Service (Domain)
-	userService := user.NewService(userRepo)
+	var breachCorpus password.BreachChecker
+	if cfg.BreachCorpusFile != "" {
+		corpus, err := security.LoadBreachCorpus(cfg.BreachCorpusFile)
+		if err != nil {
+			logger.Log.Fatalf("Failed to load breached password corpus: %v", err)
+		}
+		logger.Log.Infof("Loaded %d breached password hashes", corpus.Len())
+		breachCorpus = corpus
+	}
+	passwordPolicy, err := password.NewPolicy(password.Rules{
+		MinLength:       cfg.PasswordMinLength,
+		MaxBytes:        cfg.PasswordMaxBytes,
+		RequiredClasses: cfg.PasswordClasses,
+		DenyIdentity:    cfg.PasswordDenyID,
+	}, breachCorpus)
+	if err != nil {
+		logger.Log.Fatalf("Invalid password policy: %v", err)
+	}
+	passwordHasher, err := security.NewPasswordHasher(security.PasswordHashConfig{
+		Algorithm:  cfg.PasswordHashAlg,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: security.Argon2Params{
+			Memory:      uint32(cfg.Argon2MemoryKiB),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+	})
+	if err != nil {
+		logger.Log.Fatalf("Invalid password hashing config: %v", err)
+	}
+	userService := user.NewService(userRepo, passwordPolicy, passwordHasher)
 	sessionService := sessiondomain.NewService(sessionRepo)
-	authService := authdomain.NewService(userService, sessionService, userRepo)
+	revocationService := revocation.NewService(revokedTokenRepo)
+	securityEventService := securityevent.NewService(securityEventRepo)
+	verificationService := verification.NewService(verificationTokenRepo)
+	passkeyService := passkey.NewService(passkeyRepo)
+	apiKeyService := apikey.NewService(apiKeyRepo)
+	rateLimitService := ratelimit.NewService(rateLimitRepo)
+	replayService := replay.NewService(replayRepo)
+	lockoutService := lockout.NewService(lockoutRepo, cfg.LockoutThreshold, time.Duration(cfg.LockoutMins)*time.Minute)
+	identityService := identity.NewService(identityRepo)
+	oauthService := oauth.NewService(oauthRepo)
+	authService := authdomain.NewService(userService, sessionService, userRepo, passwordHasher, cfg.RequireVerified)
 
 	// 6. Init UseCases
+	var mail mailer.Mailer
+	switch cfg.MailDriver {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	case "memory":
+		mail = mailer.NewMemoryMailer()
+	default:
+		if mail, err = mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom); err != nil {
+			logger.Log.Fatalf("Failed to initialize mailer: %v", err)
+		}
+	}
+
+	var asyncMail *mailer.AsyncMailer
+	if cfg.AntiEnumeration {
+		// sending mail only for known addresses must not show in the timing
+		asyncMail = mailer.NewAsyncMailer(mail)
+		mail = asyncMail
+	}
+
 	createUserUC := usecase.NewCreateUserUseCase(userService)
 	getUsersUC := usecase.NewGetUsersUseCase(userService)
 	getUserUC := usecase.NewGetUserUseCase(userService)
-	updateUserUC := usecase.NewUpdateUserUseCase(userService)
+	sendVerificationUC := authusecase.NewSendVerificationUseCase(verificationService, mail, cfg.AppBaseURL, time.Duration(cfg.VerificationHours)*time.Hour)
+	updateUserUC := usecase.NewUpdateUserUseCase(userService, sendVerificationUC)
 	deleteUserUC := usecase.NewDeleteUserUseCase(userService)
-	registerAuthUC := authusecase.NewRegisterUseCase(authService)
-	loginAuthUC := authusecase.NewLoginUseCase(authService)
+	registerAuthUC := authusecase.NewRegisterUseCase(authService, userService, sendVerificationUC, mail, cfg.AppBaseURL, cfg.AntiEnumeration)
+	verifyEmailUC := authusecase.NewVerifyEmailUseCase(verificationService, userService)
+	resendVerificationUC := authusecase.NewResendVerificationUseCase(userService, verificationService, sendVerificationUC, time.Duration(cfg.VerificationHours)*time.Hour)
+	forgotPasswordUC := authusecase.NewForgotPasswordUseCase(userService, verificationService, mail, cfg.AppBaseURL, time.Duration(cfg.PasswordResetMins)*time.Minute)
+	resetPasswordUC := authusecase.NewResetPasswordUseCase(verificationService, userService, sessionService, securityEventService, lockoutService)
+	magicLinkTTL := time.Duration(cfg.MagicLinkMins) * time.Minute
+	requestMagicLinkUC := authusecase.NewRequestMagicLinkUseCase(userService, verificationService, mail, cfg.AppBaseURL, magicLinkTTL, cfg.MagicLinkBind)
+	consumeMagicLinkUC := authusecase.NewConsumeMagicLinkUseCase(verificationService, userService, authService)
 	meAuthUC := authusecase.NewGetMeUseCase(authService)
 
 	// 7. Init Handlers
 	userHandler := handlers.NewUserHandler(createUserUC, getUsersUC, getUserUC, updateUserUC, deleteUserUC)
 	accessTTL := time.Duration(cfg.AccessTokenMinutes) * time.Minute
 	refreshTTL := time.Duration(cfg.RefreshTokenDays) * 24 * time.Hour
-	tokenManager, err := security.NewTokenManager(cfg.JWTSecret, cfg.JWTRefreshSecret, accessTTL, refreshTTL, logger.Log)
+	var privateKeyPEM []byte
+	if cfg.JWTPrivateKeyFile != "" {
+		privateKeyPEM, err = os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			logger.Log.Fatalf("Failed to read JWT private key: %v", err)
+		}
+	}
+	var dataCipher *security.Cipher
+	if cfg.DataEncryptionKey != "" {
+		dataCipher, err = security.NewCipherFromBase64(cfg.DataEncryptionKey)
+		if err != nil {
+			logger.Log.Fatalf("Invalid DATA_ENCRYPTION_KEY: %v", err)
+		}
+	} else {
+		logger.Log.Warn("DATA_ENCRYPTION_KEY not set, signing keys are stored unencrypted and MFA enrollment is disabled")
+	}
+	mfaService := mfa.NewService(mfaRepo, dataCipher)
+	loginAuthUC := authusecase.NewLoginUseCase(authService, userService, mfaService, lockoutService, securityEventService, rateLimitService)
+	tokenManager, err := security.NewTokenManager(security.TokenConfig{
+		Algorithm:     cfg.JWTAlgorithm,
+		AccessSecret:  cfg.JWTSecret,
+		PrivateKeyPEM: privateKeyPEM,
+		RefreshSecret: cfg.JWTRefreshSecret,
+		AccessTTL:     accessTTL,
+		RefreshTTL:    refreshTTL,
+		KeyStore:      signingKeyRepo,
+		KeyCipher:     dataCipher,
+		Issuer:        cfg.OIDCIssuer,
+		Audience:      cfg.JWTAudience,
+		Audiences:     cfg.JWTAudiences,
+	}, logger.Log)
 	if err != nil {
 		logger.Log.Fatalf("Failed to initialize token manager: %v", err)
 	}
-	authHandler := handlers.NewAuthHandler(registerAuthUC, loginAuthUC, meAuthUC, sessionService, tokenManager, cfg.CookieDomain)
+	dpopVerifier := security.NewDPoPVerifier(cfg.OIDCIssuer, time.Duration(cfg.DPoPWindowSecs)*time.Second, replayService)
+	reportRecoveryUC := mfausecase.NewReportRecoveryCodeUseCase(userService, securityEventService, mail)
+	verifyMFAUC := authusecase.NewVerifyMFAUseCase(tokenManager, mfaService, userService, lockoutService, reportRecoveryUC, rateLimitService, replayService)
+	recoveryLoginUC := authusecase.NewRecoveryLoginUseCase(userService, mfaService, sessionService, tokenManager, reportRecoveryUC, rateLimitService)
+	completeRecoveryUC := authusecase.NewCompleteRecoveryUseCase(userService, mfaService, sessionService, securityEventService, lockoutService)
+	webauthnOrigins := cfg.WebAuthnOrigins
+	if len(webauthnOrigins) == 0 {
+		webauthnOrigins = []string{cfg.AppBaseURL}
+	}
+	relyingParty := webauthn.New(webauthn.Config{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: webauthnOrigins,
+		Timeout: passkeyusecase.CeremonyTTL,
+	})
+	passkeyBeginLoginUC := passkeyusecase.NewBeginLoginUseCase(tokenManager, relyingParty)
+	passkeyFinishLoginUC := passkeyusecase.NewFinishLoginUseCase(passkeyService, userService, authService, securityEventService, tokenManager, relyingParty, replayService)
+	var externalProviders []idp.Provider
+	for _, p := range cfg.IdentityProviders {
+		provider, err := idp.New(idp.Config{
+			Name:         p.Name,
+			Type:         p.Type,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Issuer:       p.Issuer,
+			Scopes:       p.Scopes,
+			AuthURL:      p.AuthURL,
+			TokenURL:     p.TokenURL,
+			UserInfoURL:  p.UserInfoURL,
+		}, nil)
+		if err != nil {
+			logger.Log.Fatalf("Failed to configure identity provider: %v", err)
+		}
+		externalProviders = append(externalProviders, provider)
+	}
+	identityProviders := idp.NewRegistry(externalProviders...)
+	idpCallbackBase := cfg.IdPCallbackBaseURL
+	if idpCallbackBase == "" {
+		idpCallbackBase = cfg.OIDCIssuer
+	}
+	idpStartLoginUC := identityusecase.NewStartLoginUseCase(identityProviders, tokenManager, idpCallbackBase)
+	idpCompleteLoginUC := identityusecase.NewCompleteLoginUseCase(identityProviders, identityService, userService, authService, securityEventService, tokenManager, idpCallbackBase, replayService)
+	refreshGrace := time.Duration(cfg.RefreshGraceSecs) * time.Second
+	authHandler := handlers.NewAuthHandler(registerAuthUC, loginAuthUC, meAuthUC, verifyEmailUC, resendVerificationUC, forgotPasswordUC, resetPasswordUC, verifyMFAUC, mfaService, passkeyBeginLoginUC, passkeyFinishLoginUC, recoveryLoginUC, completeRecoveryUC, requestMagicLinkUC, consumeMagicLinkUC, idpStartLoginUC, idpCompleteLoginUC, sessionService, revocationService, securityEventService, tokenManager, dpopVerifier, oauthService, cfg.CookieDomain, cfg.OIDCClientID, refreshGrace, magicLinkTTL, cfg.AntiEnumeration)
+	wellKnownHandler := handlers.NewWellKnownHandler(tokenManager)
+	adminHandler := handlers.NewAdminHandler(tokenManager, revocationService, sessionService, securityEventService, oauthService, userService, lockoutService)
+	oidcHandler := handlers.NewOIDCHandler(getUserUC)
+	introspectUC := oauthusecase.NewIntrospectUseCase(tokenManager, sessionService, revocationService, userService)
+	tokenExchangeUC := oauthusecase.NewTokenExchangeUseCase(tokenManager, sessionService, revocationService, userService, time.Duration(cfg.ImpersonationMins)*time.Minute)
+	oauthLoginURL := cfg.OAuthLoginURL
+	if oauthLoginURL == "" {
+		oauthLoginURL = strings.TrimRight(cfg.AppBaseURL, "/") + "/login"
+	}
+	oauthConsentURL := cfg.OAuthConsentURL
+	if oauthConsentURL == "" {
+		oauthConsentURL = strings.TrimRight(cfg.AppBaseURL, "/") + "/consent"
+	}
+	oauthHandler := handlers.NewOAuthHandler(
+		introspectUC,
+		tokenExchangeUC,
+		oauthusecase.NewAuthorizeUseCase(oauthService, tokenManager),
+		oauthusecase.NewConsentUseCase(oauthService, tokenManager, replayService),
+		oauthusecase.NewAuthorizationCodeGrantUseCase(oauthService, sessionService, userService, tokenManager),
+		oauthusecase.NewRefreshTokenGrantUseCase(oauthService, sessionService, userService, securityEventService, tokenManager, refreshGrace),
+		oauthusecase.NewClientCredentialsGrantUseCase(oauthService, tokenManager),
+		dpopVerifier,
+		cfg.OIDCIssuer,
+		oauthLoginURL,
+		oauthConsentURL,
+	)
+	mfaHandler := handlers.NewMFAHandler(
+		mfausecase.NewEnrollTOTPUseCase(mfaService, userService, cfg.TOTPIssuer),
+		mfausecase.NewConfirmTOTPUseCase(mfaService, securityEventService, rateLimitService),
+		mfausecase.NewDisableTOTPUseCase(mfaService, securityEventService, reportRecoveryUC, rateLimitService),
+		mfausecase.NewGenerateRecoveryCodesUseCase(mfaService, securityEventService),
+	)
+	passkeyHandler := handlers.NewPasskeyHandler(
+		passkeyusecase.NewBeginRegistrationUseCase(passkeyService, userService, tokenManager, relyingParty),
+		passkeyusecase.NewFinishRegistrationUseCase(passkeyService, securityEventService, tokenManager, relyingParty, replayService),
+		passkeyusecase.NewListCredentialsUseCase(passkeyService),
+		passkeyusecase.NewDeleteCredentialUseCase(passkeyService, securityEventService),
+	)
+	apiKeyHandler := handlers.NewAPIKeyHandler(
+		apikeyusecase.NewCreateKeyUseCase(apiKeyService, userService, securityEventService),
+		apikeyusecase.NewListKeysUseCase(apiKeyService),
+		apikeyusecase.NewRevokeKeyUseCase(apiKeyService, securityEventService),
+	)
 	authzMiddleware := middleware.NewAuthMiddleware(middleware.Config{
 		TokenManager:      tokenManager,
 		AccessTokenCookie: middleware.DefaultAccessTokenCookie,
 		ContextKey:        middleware.DefaultClaimsContextKey,
+		Denylist:          revocationService,
+		Sessions:          sessionService,
+		SessionCacheTTL:   time.Duration(cfg.SessionCacheSecs) * time.Second,
+		DPoP:              dpopVerifier,
+		Audiences:         []string{cfg.JWTAudience},
+		APIKeys:           apikeyusecase.NewAuthenticateUseCase(apiKeyService, userService),
 	})
 
-	// 8. Init Server
-	app := http.NewServer()
+	// 8. Start Background Jobs
+	// ctx ends on SIGINT or SIGTERM, which stops the jobs and the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go tokenManager.RunKeyMaintenance(
+		ctx,
+		time.Duration(cfg.JWTRotationHours)*time.Hour,
+		time.Duration(cfg.JWTKeySyncSeconds)*time.Second,
+	)
+	go runEvery(ctx, time.Minute, "purge-revoked-tokens", revocationService.Purge)
+	go runEvery(ctx, time.Hour, "purge-verification-tokens", verificationService.Purge)
+	go runEvery(ctx, time.Hour, "purge-login-lockouts", lockoutService.Purge)
+	go runEvery(ctx, time.Minute, "purge-rate-limits", rateLimitService.Purge)
+	go runEvery(ctx, time.Minute, "purge-used-nonces", replayService.Purge)
 
-	// 9. Register Routes
-	http.RegisterRoutes(app, userHandler, authHandler, authzMiddleware)
+	// 9. Init Server
+	app := http.NewServer(cfg.TrustedProxies, cfg.ProxyHeader)
 
-	// 10. Start Server
+	// 10. Register Routes
+	http.RegisterRoutes(app, userHandler, authHandler, wellKnownHandler, adminHandler, oidcHandler, oauthHandler, mfaHandler, passkeyHandler, apiKeyHandler, authzMiddleware, middleware.NewRateLimiter(rateLimitService))
+
+	// 11. Start Server
 	addr := fmt.Sprintf(":%s", cfg.Port)
 	logger.Log.Infof("Server listening on %s", addr)
+	go func() {
+		<-ctx.Done()
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			logger.Log.WithError(err).Error("server shutdown failed")
+		}
+	}()
 	if err := app.Listen(addr); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
+
+	// 12. Drain queued mail
+	if asyncMail != nil {
+		drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := asyncMail.Close(drainCtx); err != nil {
+			logger.Log.WithError(err).Error("emails lost at shutdown")
+		}
+	}
 }
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// runEvery calls fn on every tick until ctx is done, logging failures.
+func runEvery(ctx context.Context, interval time.Duration, name string, fn func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(); err != nil {
+				logger.Log.WithError(err).WithField("job", name).Warn("background job failed")
+			}
+		}
+	}
+}
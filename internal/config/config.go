@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -17,12 +18,79 @@ type Config struct {
 	Port               string
 	JWTSecret          string
 	JWTRefreshSecret   string
+	JWTAlgorithm       string
+	JWTPrivateKeyFile  string
+	JWTRotationHours   int
+	JWTKeySyncSeconds  int
+	DataEncryptionKey  string
+	OIDCIssuer         string
+	OIDCClientID       string
+	JWTAudience        string
+	JWTAudiences       []string
+	RevocationStore    string
+	RateLimitStore     string
+	ReplayStore        string
+	TrustedProxies     []string
+	ProxyHeader        string
+	SessionCacheSecs   int
+	ImpersonationMins  int
+	RefreshGraceSecs   int
+	DPoPWindowSecs     int
 	CookieDomain       string
+	AppBaseURL         string
+	RequireVerified    bool
+	VerificationHours  int
+	PasswordResetMins  int
+	TOTPIssuer         string
+	WebAuthnRPID       string
+	WebAuthnRPName     string
+	WebAuthnOrigins    []string
+	MagicLinkMins      int
+	MagicLinkBind      bool
+	LockoutThreshold   int
+	LockoutMins        int
+	PasswordMinLength  int
+	PasswordMaxBytes   int
+	PasswordClasses    []string
+	PasswordDenyID     bool
+	BreachCorpusFile   string
+	PasswordHashAlg    string
+	BcryptCost         int
+	Argon2MemoryKiB    int
+	Argon2Iterations   int
+	Argon2Parallelism  int
+	AntiEnumeration    bool
+	IdentityProviders  []IdentityProvider
+	IdPCallbackBaseURL string
+	OAuthLoginURL      string
+	OAuthConsentURL    string
+	MailDriver         string
+	MailFrom           string
+	MailDir            string
+	SMTPHost           string
+	SMTPPort           int
+	SMTPUsername       string
+	SMTPPassword       string
 	SessionExpiryHours int
 	AccessTokenMinutes int
 	RefreshTokenDays   int
 }
 
+// IdentityProvider configures sign-in with an external identity provider.
+// Providers are listed in IDP_PROVIDERS and configured with IDP_<NAME>_*
+// variables.
+type IdentityProvider struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	Scopes       []string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+}
+
 func Load() *Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found or error loading it, using system environment variables")
@@ -37,7 +105,59 @@ func Load() *Config {
 		Port:               getEnv("PORT", "3000"),
 		JWTSecret:          getEnv("JWT_SECRET", ""),
 		JWTRefreshSecret:   getEnv("JWT_REFRESH_SECRET", ""),
+		JWTAlgorithm:       getEnv("JWT_ALGORITHM", "HS256"),
+		JWTPrivateKeyFile:  getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTRotationHours:   getEnvAsInt("JWT_ROTATION_INTERVAL_HOURS", 0),
+		JWTKeySyncSeconds:  getEnvAsInt("JWT_KEY_SYNC_SECONDS", 60),
+		DataEncryptionKey:  getEnv("DATA_ENCRYPTION_KEY", ""),
+		OIDCIssuer:         getEnv("OIDC_ISSUER", "http://localhost:3000"),
+		OIDCClientID:       getEnv("OIDC_CLIENT_ID", "user-auth-service"),
+		JWTAudience:        getEnv("JWT_AUDIENCE", "user-auth-service"),
+		JWTAudiences:       getEnvAsList("JWT_ALLOWED_AUDIENCES"),
+		RevocationStore:    getEnv("REVOCATION_STORE", "postgres"),
+		RateLimitStore:     getEnv("RATE_LIMIT_STORE", "postgres"),
+		ReplayStore:        getEnv("REPLAY_STORE", "postgres"),
+		TrustedProxies:     getEnvAsList("TRUSTED_PROXIES"),
+		ProxyHeader:        getEnv("PROXY_HEADER", "X-Forwarded-For"),
+		SessionCacheSecs:   getEnvAsInt("SESSION_CACHE_TTL_SECONDS", 5),
+		ImpersonationMins:  getEnvAsInt("IMPERSONATION_TTL_MINUTES", 15),
+		RefreshGraceSecs:   getEnvAsInt("REFRESH_GRACE_SECONDS", 10),
+		DPoPWindowSecs:     getEnvAsInt("DPOP_PROOF_WINDOW_SECONDS", 60),
 		CookieDomain:       getEnv("AUTH_COOKIE_DOMAIN", "localhost"),
+		AppBaseURL:         getEnv("APP_BASE_URL", "http://localhost:3000"),
+		RequireVerified:    getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		VerificationHours:  getEnvAsInt("EMAIL_VERIFICATION_TTL_HOURS", 24),
+		PasswordResetMins:  getEnvAsInt("PASSWORD_RESET_TTL_MINUTES", 30),
+		TOTPIssuer:         getEnv("TOTP_ISSUER", "user-auth-service"),
+		WebAuthnRPID:       getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:     getEnv("WEBAUTHN_RP_NAME", "user-auth-service"),
+		WebAuthnOrigins:    getEnvAsList("WEBAUTHN_ORIGINS"),
+		MagicLinkMins:      getEnvAsInt("MAGIC_LINK_TTL_MINUTES", 15),
+		MagicLinkBind:      getEnvAsBool("MAGIC_LINK_SAME_BROWSER", true),
+		LockoutThreshold:   getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockoutMins:        getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+		PasswordMinLength:  getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxBytes:   getEnvAsInt("PASSWORD_MAX_BYTES", 72),
+		PasswordClasses:    getEnvAsList("PASSWORD_REQUIRED_CLASSES"),
+		PasswordDenyID:     getEnvAsBool("PASSWORD_DENY_IDENTITY", true),
+		BreachCorpusFile:   getEnv("PASSWORD_BREACH_CORPUS_FILE", ""),
+		PasswordHashAlg:    getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:         getEnvAsInt("BCRYPT_COST", 10),
+		Argon2MemoryKiB:    getEnvAsInt("ARGON2_MEMORY_KIB", 19456),
+		Argon2Iterations:   getEnvAsInt("ARGON2_ITERATIONS", 2),
+		Argon2Parallelism:  getEnvAsInt("ARGON2_PARALLELISM", 1),
+		AntiEnumeration:    getEnvAsBool("ANTI_ENUMERATION", false),
+		IdentityProviders:  getIdentityProviders(),
+		IdPCallbackBaseURL: getEnv("IDP_CALLBACK_BASE_URL", ""),
+		OAuthLoginURL:      getEnv("OAUTH_LOGIN_URL", ""),
+		OAuthConsentURL:    getEnv("OAUTH_CONSENT_URL", ""),
+		MailDriver:         getEnv("MAIL_DRIVER", "file"),
+		MailFrom:           getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailDir:            getEnv("MAIL_DIR", "tmp/mail"),
+		SMTPHost:           getEnv("SMTP_HOST", ""),
+		SMTPPort:           getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
 		SessionExpiryHours: getEnvAsInt("SESSION_EXPIRY_HOURS", 72),
 		AccessTokenMinutes: getEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 30),
 		RefreshTokenDays:   getEnvAsInt("REFRESH_TOKEN_TTL_DAYS", 30),
@@ -58,3 +178,53 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsList splits a comma separated variable, dropping empty entries.
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getIdentityProviders reads the providers named in IDP_PROVIDERS. "google"
+// and "github" need only client credentials.
+func getIdentityProviders() []IdentityProvider {
+	var providers []IdentityProvider
+	for _, name := range getEnvAsList("IDP_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "IDP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		p := IdentityProvider{
+			Name:         name,
+			Type:         getEnv(prefix+"TYPE", "oidc"),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			Scopes:       getEnvAsList(prefix + "SCOPES"),
+			AuthURL:      getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:     getEnv(prefix+"TOKEN_URL", ""),
+			UserInfoURL:  getEnv(prefix+"USERINFO_URL", ""),
+		}
+		switch name {
+		case "google":
+			if p.Issuer == "" {
+				p.Issuer = "https://accounts.google.com"
+			}
+		case "github":
+			p.Type = getEnv(prefix+"TYPE", "github")
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
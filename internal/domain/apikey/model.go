@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey is a long-lived personal credential for scripts and CI jobs. Only a
+// hash of the key is stored; Prefix is kept so users can tell keys apart.
+type APIKey struct {
+	ID      string `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID  string `json:"user_id" bson:"user_id" gorm:"index;not null"`
+	Name    string `json:"name" bson:"name" gorm:"not null"`
+	Prefix  string `json:"prefix" bson:"prefix" gorm:"not null"`
+	KeyHash string `json:"-" bson:"key_hash" gorm:"uniqueIndex;not null"`
+	// Scopes is space separated.
+	Scopes     string     `json:"scope" bson:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" bson:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty" bson:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// IsActive reports whether the key may still be used at now.
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
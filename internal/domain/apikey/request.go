@@ -0,0 +1,17 @@
+package apikey
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"omitempty"`
+	// ExpiresAt is optional; keys without it live until revoked.
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty"`
+}
+
+// CreatedAPIKey is returned once, at creation; the key cannot be retrieved
+// later.
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,205 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const (
+	// KeyPrefix starts every API key, so they are recognisable in headers,
+	// logs and secret scanners.
+	KeyPrefix = "uas_"
+
+	// ScopeAdmin must be granted explicitly for an admin's key to carry the
+	// admin role.
+	ScopeAdmin = "admin"
+
+	keyBytes = 32
+	// displayPrefixLen is how much of the key is kept in clear for display.
+	displayPrefixLen = len(KeyPrefix) + 8
+	maxNameLength    = 64
+	maxScopes        = 20
+	maxKeysPerUser   = 25
+	// lastUsedGranularity limits last-used bookkeeping to one write per key
+	// per minute.
+	lastUsedGranularity = time.Minute
+)
+
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrInvalidScope = errors.New("invalid api key scope")
+	ErrInvalidName  = errors.New("api key name is required")
+	ErrExpiry       = errors.New("api key expiry must be in the future")
+	ErrTooManyKeys  = errors.New("too many api keys")
+)
+
+// keyPattern is the full syntax of a key we issue.
+var keyPattern = regexp.MustCompile(`^` + KeyPrefix + `[A-Za-z0-9_-]{43}$`)
+
+// scopePattern is deliberately narrower than RFC 6749 scope tokens.
+var scopePattern = regexp.MustCompile(`^[a-z][a-z0-9_.:-]{0,63}$`)
+
+type Repository interface {
+	Create(k *APIKey) error
+	// GetByHash returns nil without an error when nothing matches.
+	GetByHash(hash string) (*APIKey, error)
+	// ListByUser returns the user's keys that have not been revoked.
+	ListByUser(userID string) ([]APIKey, error)
+	// Revoke sets revoked_at on one of the user's unrevoked keys, reporting
+	// whether it did.
+	Revoke(userID, id string, now time.Time) (bool, error)
+	Touch(id string, now time.Time, ip string) error
+}
+
+type Service interface {
+	Create(userID string, req *CreateAPIKeyRequest) (*CreatedAPIKey, error)
+	ListByUser(userID string) ([]APIKey, error)
+	Revoke(userID, id string) error
+	// Authenticate returns the active key matching raw and records its use.
+	Authenticate(raw, ip string) (*APIKey, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+// LooksLikeKey reports whether s is in the API key format, as opposed to a
+// JWT.
+func LooksLikeKey(s string) bool {
+	return strings.HasPrefix(s, KeyPrefix)
+}
+
+func (s *service) Create(userID string, req *CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	if r := []rune(name); len(r) > maxNameLength {
+		name = string(r[:maxNameLength])
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, ErrExpiry
+		}
+		exp := req.ExpiresAt.UTC()
+		expiresAt = &exp
+	}
+
+	existing, err := s.repo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxKeysPerUser {
+		return nil, fmt.Errorf("%w: revoke one of your %d keys first", ErrTooManyKeys, len(existing))
+	}
+
+	raw, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	k := &APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:displayPrefixLen],
+		KeyHash:   security.HashToken(raw),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(k); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: k, Key: raw}, nil
+}
+
+func (s *service) ListByUser(userID string) ([]APIKey, error) {
+	return s.repo.ListByUser(userID)
+}
+
+func (s *service) Revoke(userID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+	ok, err := s.repo.Revoke(userID, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *service) Authenticate(raw, ip string) (*APIKey, error) {
+	if !keyPattern.MatchString(raw) {
+		return nil, ErrInvalidKey
+	}
+	k, err := s.repo.GetByHash(security.HashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if k == nil || !k.IsActive(now) {
+		return nil, ErrInvalidKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= lastUsedGranularity {
+		if err := s.repo.Touch(k.ID, now, ip); err != nil {
+			return nil, err
+		}
+		k.LastUsedAt, k.LastUsedIP = &now, ip
+	}
+	return k, nil
+}
+
+func normalizeScopes(requested []string) ([]string, error) {
+	var scopes []string
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if !scopePattern.MatchString(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if scope == security.ScopeAccountRecovery {
+			return nil, fmt.Errorf("%w: %s is reserved", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) > maxScopes {
+		return nil, fmt.Errorf("%w: at most %d scopes", ErrInvalidScope, maxScopes)
+	}
+	return scopes, nil
+}
+
+func newKey() (string, error) {
+	b := make([]byte, keyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,234 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestCreate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		req        CreateAPIKeyRequest
+		wantScopes string
+		wantErr    error
+	}{
+		{name: "no scopes", req: CreateAPIKeyRequest{Name: "ci"}},
+		{name: "scopes are trimmed and deduplicated", req: CreateAPIKeyRequest{Name: "ci", Scopes: []string{" read ", "write", "read"}}, wantScopes: "read write"},
+		{name: "expiry", req: CreateAPIKeyRequest{Name: "ci", ExpiresAt: &future}},
+		{name: "blank name", req: CreateAPIKeyRequest{Name: "  "}, wantErr: ErrInvalidName},
+		{name: "expiry in the past", req: CreateAPIKeyRequest{Name: "ci", ExpiresAt: &past}, wantErr: ErrExpiry},
+		{name: "malformed scope", req: CreateAPIKeyRequest{Name: "ci", Scopes: []string{"Read Write"}}, wantErr: ErrInvalidScope},
+		{name: "reserved scope", req: CreateAPIKeyRequest{Name: "ci", Scopes: []string{security.ScopeAccountRecovery}}, wantErr: ErrInvalidScope},
+		{name: "too many scopes", req: CreateAPIKeyRequest{Name: "ci", Scopes: manyScopes(maxScopes + 1)}, wantErr: ErrInvalidScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepo()
+			created, err := NewService(repo).Create("user-1", &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(repo.keys) != 0 {
+					t.Error("a rejected key was stored")
+				}
+				return
+			}
+			if !keyPattern.MatchString(created.Key) || !strings.HasPrefix(created.Key, created.Prefix) {
+				t.Errorf("Create() key = %q, prefix %q", created.Key, created.Prefix)
+			}
+			if created.KeyHash == created.Key || created.KeyHash != security.HashToken(created.Key) {
+				t.Error("stored hash does not match the key")
+			}
+			if created.Scopes != tt.wantScopes {
+				t.Errorf("Scopes = %q, want %q", created.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestCreateLimitsKeysPerUser(t *testing.T) {
+	svc := NewService(newMemoryRepo())
+	for i := 0; i < maxKeysPerUser; i++ {
+		if _, err := svc.Create("user-1", &CreateAPIKeyRequest{Name: "ci"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.Create("user-1", &CreateAPIKeyRequest{Name: "ci"}); !errors.Is(err, ErrTooManyKeys) {
+		t.Fatalf("Create() error = %v, want %v", err, ErrTooManyKeys)
+	}
+	if _, err := svc.Create("user-2", &CreateAPIKeyRequest{Name: "ci"}); err != nil {
+		t.Fatalf("Create() for another user error = %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     func(created *CreatedAPIKey) string
+		mutate  func(k *APIKey)
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "jwt", raw: func(*CreatedAPIKey) string { return "eyJhbGciOiJIUzI1NiJ9.e30.sig" }, wantErr: ErrInvalidKey},
+		{name: "unknown key", raw: func(*CreatedAPIKey) string { return KeyPrefix + strings.Repeat("A", 43) }, wantErr: ErrInvalidKey},
+		{name: "truncated key", raw: func(c *CreatedAPIKey) string { return c.Key[:len(c.Key)-1] }, wantErr: ErrInvalidKey},
+		{name: "revoked", mutate: func(k *APIKey) { now := time.Now(); k.RevokedAt = &now }, wantErr: ErrInvalidKey},
+		{name: "expired", mutate: func(k *APIKey) { past := time.Now().Add(-time.Second); k.ExpiresAt = &past }, wantErr: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepo()
+			svc := NewService(repo)
+			created, err := svc.Create("user-1", &CreateAPIKeyRequest{Name: "ci"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.mutate != nil {
+				tt.mutate(repo.keys[created.ID])
+			}
+			raw := created.Key
+			if tt.raw != nil {
+				raw = tt.raw(created)
+			}
+
+			k, err := svc.Authenticate(raw, "203.0.113.7")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (k.ID != created.ID || k.LastUsedIP != "203.0.113.7") {
+				t.Errorf("Authenticate() = %+v", k)
+			}
+		})
+	}
+}
+
+func TestAuthenticateTouchesOncePerMinute(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	created, err := svc.Create("user-1", &CreateAPIKeyRequest{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Authenticate(created.Key, "203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.touches != 1 {
+		t.Fatalf("Touch() called %d times, want 1", repo.touches)
+	}
+
+	stale := time.Now().Add(-lastUsedGranularity)
+	repo.keys[created.ID].LastUsedAt = &stale
+	if _, err := svc.Authenticate(created.Key, "198.51.100.1"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.touches != 2 || repo.keys[created.ID].LastUsedIP != "198.51.100.1" {
+		t.Fatalf("Touch() called %d times, last IP %q", repo.touches, repo.keys[created.ID].LastUsedIP)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	created, err := svc.Create("user-1", &CreateAPIKeyRequest{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		id      string
+		wantErr error
+	}{
+		{name: "not a uuid", userID: "user-1", id: "1 OR 1=1", wantErr: ErrNotFound},
+		{name: "unknown id", userID: "user-1", id: uuid.NewString(), wantErr: ErrNotFound},
+		{name: "another user's key", userID: "user-2", id: created.ID, wantErr: ErrNotFound},
+		{name: "own key", userID: "user-1", id: created.ID},
+		{name: "already revoked", userID: "user-1", id: created.ID, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.Revoke(tt.userID, tt.id); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Revoke() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := svc.Authenticate(created.Key, ""); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Authenticate() with a revoked key error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func manyScopes(n int) []string {
+	scopes := make([]string, n)
+	for i := range scopes {
+		scopes[i] = fmt.Sprintf("scope%d", i)
+	}
+	return scopes
+}
+
+type memoryRepo struct {
+	keys    map[string]*APIKey
+	touches int
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{keys: map[string]*APIKey{}}
+}
+
+func (m *memoryRepo) Create(k *APIKey) error {
+	c := *k
+	m.keys[k.ID] = &c
+	return nil
+}
+
+func (m *memoryRepo) GetByHash(hash string) (*APIKey, error) {
+	for _, k := range m.keys {
+		if k.KeyHash == hash {
+			c := *k
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRepo) ListByUser(userID string) ([]APIKey, error) {
+	var out []APIKey
+	for _, k := range m.keys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			out = append(out, *k)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRepo) Revoke(userID, id string, now time.Time) (bool, error) {
+	k, ok := m.keys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil {
+		return false, nil
+	}
+	k.RevokedAt = &now
+	return true, nil
+}
+
+func (m *memoryRepo) Touch(id string, now time.Time, ip string) error {
+	m.touches++
+	m.keys[id].LastUsedAt, m.keys[id].LastUsedIP = &now, ip
+	return nil
+}
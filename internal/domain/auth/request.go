@@ -13,6 +13,78 @@ type LoginRequest struct {
 	Password  string `json:"password" binding:"required"`
 	IPAddress string `json:"ip_address" binding:"required"`
 	UserAgent string `json:"user_agent" binding:"required"`
+	// ClientID is the OIDC client the ID token is issued to (optional).
+	ClientID string `json:"client_id" binding:"omitempty"`
+	Nonce    string `json:"nonce" binding:"omitempty"`
+	// Audience is the API the access token is issued for; defaults to ours.
+	Audience string `json:"audience" binding:"omitempty"`
+	// RemoteIP is the address the request came from. Unlike IPAddress, which
+	// a frontend may fill in, it cannot be chosen by the caller, so attempt
+	// limits key on it.
+	RemoteIP string `json:"-"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// VerifyMFARequest completes a login with the challenge token returned by
+// the password step and a second factor code.
+// Either Code (authenticator app) or RecoveryCode must be set.
+type VerifyMFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" binding:"omitempty"`
+	IPAddress    string `json:"-"`
+	UserAgent    string `json:"-"`
+}
+
+// RecoveryLoginRequest signs in with a recovery code when the password,
+// email or second factor is lost. It only yields a restricted session.
+type RecoveryLoginRequest struct {
+	Email        string `json:"email" binding:"required,email"`
+	RecoveryCode string `json:"recovery_code" binding:"required"`
+	IPAddress    string `json:"-"`
+	UserAgent    string `json:"-"`
+}
+
+// CompleteRecoveryRequest is the one thing a recovery session may do: set a
+// new password and, optionally, drop second factors the user lost.
+type CompleteRecoveryRequest struct {
+	Password  string `json:"password" binding:"required"`
+	ResetMFA  bool   `json:"reset_mfa"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ConsumeMagicLinkRequest is read from the query on GET and the body on
+// POST. The optional fields mean the same as on LoginRequest.
+type ConsumeMagicLinkRequest struct {
+	Token    string `json:"token" query:"token" binding:"required"`
+	ClientID string `json:"client_id" query:"client_id" binding:"omitempty"`
+	Nonce    string `json:"nonce" query:"nonce" binding:"omitempty"`
+	Audience string `json:"audience" query:"audience" binding:"omitempty"`
+	// Binding is the browser binding cookie, when links are bound.
+	Binding string `json:"-" query:"-"`
 }
 
 type RefreshTokenRequest struct {
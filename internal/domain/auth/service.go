@@ -2,9 +2,13 @@ package auth
 
 import (
 	"errors"
+	"sync"
+
+	"github.com/google/uuid"
 
 	"mikhailjbs/user-auth-service/internal/domain/session"
 	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
 	"mikhailjbs/user-auth-service/internal/infra/security"
 )
 
@@ -13,6 +17,8 @@ var (
 	ErrTokenExpired       = errors.New("token has expired")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrSessionNotFound    = errors.New("session not found")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrTooManyAttempts    = errors.New("too many attempts, please log in again")
 )
 
 type Repository interface {
@@ -22,22 +28,34 @@ type Repository interface {
 type Service interface {
 	RegisterUser(r *RegisterRequest) (*user.User, error)
 	LoginUser(r *LoginRequest) (*user.User, error)
+	// CheckLoginAllowed applies the account checks every login method shares,
+	// once the user has proven who they are.
+	CheckLoginAllowed(u *user.User) error
 	ValidateToken(token string) (*session.Session, error)
 	InvalidateSession(sessionID string) error
 	GetMe(token string) (*user.User, error)
 }
 
 type service struct {
-	userService    user.Service
-	sessionService session.Service
-	userRepo       user.Repository
+	userService          user.Service
+	sessionService       session.Service
+	userRepo             user.Repository
+	hasher               security.PasswordHasher
+	requireVerifiedEmail bool
+
+	dummyOnce sync.Once
+	dummyHash string
 }
 
-func NewService(uSvc user.Service, sSvc session.Service, uRepo user.Repository) Service {
+// NewService creates the auth service. With requireVerifiedEmail set, users
+// cannot log in before confirming their email address.
+func NewService(uSvc user.Service, sSvc session.Service, uRepo user.Repository, hasher security.PasswordHasher, requireVerifiedEmail bool) Service {
 	return &service{
-		userService:    uSvc,
-		sessionService: sSvc,
-		userRepo:       uRepo,
+		userService:          uSvc,
+		sessionService:       sSvc,
+		userRepo:             uRepo,
+		hasher:               hasher,
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
@@ -64,16 +82,67 @@ func (s *service) LoginUser(r *LoginRequest) (*user.User, error) {
 	}
 
 	if existingUser == nil {
+		// compare anyway so unknown emails take as long as wrong passwords
+		_ = s.hasher.Compare(s.dummyPasswordHash(), r.Password)
 		return nil, ErrInvalidCredentials
 	}
 
-	if err := security.ComparePassword(existingUser.PasswordHash, r.Password); err != nil {
+	if err := s.hasher.Compare(existingUser.PasswordHash, r.Password); err != nil {
 		return nil, ErrInvalidCredentials
 	}
+	if s.hasher.NeedsRehash(existingUser.PasswordHash) {
+		s.rehash(existingUser, r.Password)
+	}
+
+	// checked after the password so it does not reveal which emails exist
+	if err := s.CheckLoginAllowed(existingUser); err != nil {
+		return nil, err
+	}
 
 	return existingUser, nil
 }
 
+// dummyPasswordHash is a hash of a random password with the current
+// algorithm and cost, for comparisons that must fail.
+func (s *service) dummyPasswordHash() string {
+	s.dummyOnce.Do(func() {
+		hash, err := s.hasher.Hash(uuid.NewString())
+		if err != nil {
+			logger.Log.WithError(err).Error("failed to create dummy password hash")
+			return
+		}
+		s.dummyHash = hash
+	})
+	return s.dummyHash
+}
+
+// rehash moves a password onto the current algorithm and cost while the
+// plain password is at hand. The swap only applies to the hash that was just
+// verified, so a password reset racing the login is never undone. A failure
+// only postpones it to the next login.
+func (s *service) rehash(u *user.User, plain string) {
+	hash, err := s.hasher.Hash(plain)
+	if err != nil {
+		logger.Log.WithError(err).WithField("user_id", u.ID).Warn("failed to upgrade password hash")
+		return
+	}
+	swapped, err := s.userRepo.SwapPasswordHash(u.ID, u.PasswordHash, hash)
+	if err != nil {
+		logger.Log.WithError(err).WithField("user_id", u.ID).Warn("failed to upgrade password hash")
+		return
+	}
+	if swapped {
+		u.PasswordHash = hash
+	}
+}
+
+func (s *service) CheckLoginAllowed(u *user.User) error {
+	if s.requireVerifiedEmail && !u.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
 func (s *service) ValidateToken(token string) (*session.Session, error) {
 	sess, err := s.sessionService.GetSessionByID(token)
 	if err != nil {
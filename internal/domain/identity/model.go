@@ -0,0 +1,20 @@
+package identity
+
+import "time"
+
+// ExternalIdentity links an account at an external identity provider to a
+// local user.
+type ExternalIdentity struct {
+	ID     string `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID string `json:"user_id" bson:"user_id" gorm:"index;not null"`
+	// Provider and Subject identify the external account; the pair is unique.
+	Provider    string     `json:"provider" bson:"provider" gorm:"not null;uniqueIndex:idx_external_identity"`
+	Subject     string     `json:"-" bson:"subject" gorm:"not null;uniqueIndex:idx_external_identity"`
+	Email       string     `json:"email" bson:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" bson:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
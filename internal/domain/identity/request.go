@@ -0,0 +1,23 @@
+package identity
+
+// LoginStart is where to send the browser to sign in with a provider. The
+// flow token must come back with the callback; handlers keep it in a cookie.
+type LoginStart struct {
+	RedirectURL string `json:"redirect_url"`
+	FlowToken   string `json:"-"`
+}
+
+// CallbackRequest is what the provider redirects back with.
+type CallbackRequest struct {
+	Provider  string `json:"provider"`
+	Code      string `json:"code" query:"code"`
+	State     string `json:"state" query:"state"`
+	FlowToken string `json:"-" query:"-"`
+}
+
+// LoginStartRequest carries what the eventual session should be issued for.
+type LoginStartRequest struct {
+	Audience string `json:"audience" query:"audience"`
+	ClientID string `json:"client_id" query:"client_id"`
+	Nonce    string `json:"nonce" query:"nonce"`
+}
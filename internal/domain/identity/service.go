@@ -0,0 +1,78 @@
+package identity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAlreadyLinked = errors.New("external identity already linked")
+	// ErrEmailNotVerified is returned when the provider does not vouch for
+	// the user's email address, which we need to create or match an account.
+	ErrEmailNotVerified = errors.New("identity provider did not return a verified email")
+	// ErrAccountConflict is returned when the email belongs to a local account
+	// whose owner never verified it; linking would hand that account to
+	// whoever registered the address at the provider.
+	ErrAccountConflict = errors.New("an unverified account already uses this email")
+)
+
+type Repository interface {
+	Create(i *ExternalIdentity) error
+	// Find returns nil without an error when nothing matches.
+	Find(provider, subject string) (*ExternalIdentity, error)
+	Touch(id, email string, now time.Time) error
+}
+
+type Service interface {
+	// Find returns nil when the external account is not linked yet.
+	Find(provider, subject string) (*ExternalIdentity, error)
+	Link(userID, provider, subject, email string) (*ExternalIdentity, error)
+	// Touch records a sign-in through the identity.
+	Touch(i *ExternalIdentity, email string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Find(provider, subject string) (*ExternalIdentity, error) {
+	return s.repo.Find(provider, subject)
+}
+
+func (s *service) Link(userID, provider, subject, email string) (*ExternalIdentity, error) {
+	existing, err := s.repo.Find(provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyLinked
+	}
+
+	now := time.Now().UTC()
+	i := &ExternalIdentity{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+	}
+	if err := s.repo.Create(i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (s *service) Touch(i *ExternalIdentity, email string) error {
+	if email == "" {
+		email = i.Email
+	}
+	return s.repo.Touch(i.ID, email, time.Now().UTC())
+}
@@ -0,0 +1,27 @@
+package lockout
+
+import "time"
+
+// Lockout tracks failed password logins for one account identifier. Rows
+// exist for unknown addresses too, so a lock never reveals whether an
+// account exists.
+type Lockout struct {
+	// KeyHash is a hash of the normalised email address.
+	KeyHash       string     `json:"-" bson:"key_hash" gorm:"primaryKey"`
+	Failures      int        `json:"failures" bson:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at" bson:"last_failure_at" gorm:"index"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" bson:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+func (Lockout) TableName() string {
+	return "login_lockouts"
+}
+
+// Status is the lock state of an account as shown to admins.
+type Status struct {
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
@@ -0,0 +1,164 @@
+package lockout
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const (
+	// FreeAttempts failures are allowed back to back before backoff starts.
+	FreeAttempts = 3
+
+	baseDelay = time.Second
+	maxDelay  = time.Minute
+)
+
+var (
+	// ErrLocked and ErrThrottled are returned whether or not the account
+	// exists.
+	ErrLocked    = errors.New("account temporarily locked, try again later")
+	ErrThrottled = errors.New("too many failed attempts, try again later")
+)
+
+// RetryAfterError carries how long the caller has to wait.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+type Repository interface {
+	// Get returns nil without an error when nothing matches.
+	Get(keyHash string) (*Lockout, error)
+	// RecordFailure counts a failure at now, starting over when the previous
+	// one was before windowStart, and returns the updated row.
+	RecordFailure(keyHash string, now, windowStart time.Time) (*Lockout, error)
+	Lock(keyHash string, until time.Time) error
+	Delete(keyHash string) error
+	// DeleteStale removes rows with no failure since cutoff that are not
+	// locked at now.
+	DeleteStale(cutoff, now time.Time) error
+}
+
+type Service interface {
+	// Check returns a *RetryAfterError when a login for email must not be
+	// attempted now.
+	Check(email string) error
+	// Fail records a failed login and reports whether it locked the account.
+	Fail(email string) (bool, error)
+	// Reset clears the failures after a successful login or a password
+	// change, unlocking the account.
+	Reset(email string) error
+	Status(email string) (*Status, error)
+	Purge() error
+}
+
+type service struct {
+	repo      Repository
+	threshold int
+	duration  time.Duration
+}
+
+// NewService locks an account for duration once threshold failures were
+// recorded without a quiet period of duration between them.
+func NewService(r Repository, threshold int, duration time.Duration) Service {
+	return &service{repo: r, threshold: threshold, duration: duration}
+}
+
+// Key is the stored identifier for an email address.
+func Key(email string) string {
+	return security.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// Backoff is the wait required after failures once the first free ones are
+// used up. It doubles with every failure, up to a minute.
+func Backoff(failures, free int) time.Duration {
+	n := failures - free
+	if n <= 0 {
+		return 0
+	}
+	if n > 6 {
+		return maxDelay
+	}
+	return min(baseDelay<<(n-1), maxDelay)
+}
+
+func (s *service) Check(email string) error {
+	l, err := s.repo.Get(Key(email))
+	if err != nil || l == nil {
+		return err
+	}
+
+	now := time.Now()
+	if l.LockedUntil != nil && now.Before(*l.LockedUntil) {
+		return &RetryAfterError{Err: ErrLocked, RetryAfter: l.LockedUntil.Sub(now)}
+	}
+	if now.Sub(l.LastFailureAt) > s.duration {
+		return nil
+	}
+	if next := l.LastFailureAt.Add(Backoff(l.Failures, FreeAttempts)); now.Before(next) {
+		return &RetryAfterError{Err: ErrThrottled, RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+func (s *service) Fail(email string) (bool, error) {
+	key := Key(email)
+	now := time.Now().UTC()
+	l, err := s.repo.RecordFailure(key, now, now.Add(-s.duration))
+	if err != nil {
+		return false, err
+	}
+	if s.threshold <= 0 || l.Failures < s.threshold {
+		return false, nil
+	}
+	if l.LockedUntil != nil && now.Before(*l.LockedUntil) {
+		return false, nil
+	}
+	if err := s.repo.Lock(key, now.Add(s.duration)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *service) Reset(email string) error {
+	return s.repo.Delete(Key(email))
+}
+
+func (s *service) Status(email string) (*Status, error) {
+	l, err := s.repo.Get(Key(email))
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{}
+	if l == nil {
+		return st, nil
+	}
+
+	now := time.Now()
+	if l.LockedUntil != nil && now.Before(*l.LockedUntil) {
+		st.Locked = true
+		st.LockedUntil = l.LockedUntil
+	}
+	// a counter past its window no longer counts
+	if now.Sub(l.LastFailureAt) <= s.duration {
+		st.Failures = l.Failures
+		st.LastFailureAt = &l.LastFailureAt
+	}
+	return st, nil
+}
+
+func (s *service) Purge() error {
+	now := time.Now().UTC()
+	return s.repo.DeleteStale(now.Add(-s.duration), now)
+}
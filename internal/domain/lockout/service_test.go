@@ -0,0 +1,152 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{9, 32 * time.Second},
+		{10, time.Minute},
+		{1000, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.failures, FreeAttempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestKeyNormalizesEmail(t *testing.T) {
+	if Key(" Jane@Example.COM ") != Key("jane@example.com") {
+		t.Fatal("Key() differs by case or surrounding space")
+	}
+	if Key("jane@example.com") == Key("joe@example.com") {
+		t.Fatal("Key() collides")
+	}
+}
+
+func TestServiceLocksAfterThreshold(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo, 5, 15*time.Minute)
+
+	for i := 1; i <= 5; i++ {
+		locked, err := svc.Fail("jane@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == 5) {
+			t.Fatalf("failure %d: locked = %v", i, locked)
+		}
+	}
+
+	var retry *RetryAfterError
+	if err := svc.Check("JANE@example.com"); !errors.As(err, &retry) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("Check() error = %v, want %v", err, ErrLocked)
+	}
+	if retry.RetryAfter <= 14*time.Minute || retry.RetryAfter > 15*time.Minute {
+		t.Errorf("RetryAfter = %v, want about 15m", retry.RetryAfter)
+	}
+	// failures while locked do not extend the lock
+	if locked, err := svc.Fail("jane@example.com"); err != nil || locked {
+		t.Errorf("Fail() while locked = %v, %v, want false", locked, err)
+	}
+
+	st, err := svc.Status("jane@example.com")
+	if err != nil || !st.Locked || st.Failures != 6 {
+		t.Fatalf("Status() = %+v, %v, want locked with 6 failures", st, err)
+	}
+	if err := svc.Reset("jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Check("jane@example.com"); err != nil {
+		t.Fatalf("Check() after Reset error = %v", err)
+	}
+}
+
+func TestServiceCheck(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+	earlier := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		row     *Lockout
+		wantErr error
+	}{
+		{name: "no failures"},
+		{name: "free attempts", row: &Lockout{Failures: FreeAttempts, LastFailureAt: now}},
+		{name: "backing off", row: &Lockout{Failures: FreeAttempts + 2, LastFailureAt: now}, wantErr: ErrThrottled},
+		{name: "backoff elapsed", row: &Lockout{Failures: FreeAttempts + 2, LastFailureAt: now.Add(-3 * time.Second)}},
+		{name: "failures outside the window", row: &Lockout{Failures: 50, LastFailureAt: now.Add(-time.Hour)}},
+		{name: "locked", row: &Lockout{Failures: 1, LastFailureAt: now, LockedUntil: &later}, wantErr: ErrLocked},
+		{name: "lock expired", row: &Lockout{Failures: 1, LastFailureAt: now.Add(-time.Hour), LockedUntil: &earlier}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepo()
+			if tt.row != nil {
+				repo.rows[Key("jane@example.com")] = tt.row
+			}
+			err := NewService(repo, 10, 15*time.Minute).Check("jane@example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			var retry *RetryAfterError
+			if tt.wantErr != nil && (!errors.As(err, &retry) || retry.RetryAfter <= 0) {
+				t.Errorf("Check() error = %#v, want a positive RetryAfter", err)
+			}
+		})
+	}
+}
+
+type memoryRepo struct {
+	rows map[string]*Lockout
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{rows: map[string]*Lockout{}}
+}
+
+func (m *memoryRepo) Get(keyHash string) (*Lockout, error) {
+	return m.rows[keyHash], nil
+}
+
+func (m *memoryRepo) RecordFailure(keyHash string, now, windowStart time.Time) (*Lockout, error) {
+	l, ok := m.rows[keyHash]
+	if !ok {
+		l = &Lockout{KeyHash: keyHash}
+		m.rows[keyHash] = l
+	}
+	if l.LastFailureAt.Before(windowStart) {
+		l.Failures = 0
+	}
+	l.Failures++
+	l.LastFailureAt = now
+	return l, nil
+}
+
+func (m *memoryRepo) Lock(keyHash string, until time.Time) error {
+	m.rows[keyHash].LockedUntil = &until
+	return nil
+}
+
+func (m *memoryRepo) Delete(keyHash string) error {
+	delete(m.rows, keyHash)
+	return nil
+}
+
+func (m *memoryRepo) DeleteStale(cutoff, now time.Time) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package mfa
+
+import "time"
+
+// TOTPFactor is a user's authenticator app enrollment. Secret is encrypted
+// with the data encryption key; LastUsedStep is the newest time step a code
+// was accepted for, so a code cannot be replayed within its window.
+type TOTPFactor struct {
+	UserID       string     `json:"user_id" bson:"user_id" gorm:"primaryKey;type:uuid"`
+	Secret       []byte     `json:"-" bson:"secret" gorm:"not null"`
+	Confirmed    bool       `json:"confirmed" bson:"confirmed" gorm:"not null;default:false"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" bson:"confirmed_at"`
+	LastUsedStep int64      `json:"-" bson:"last_used_step" gorm:"not null;default:0"`
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+func (TOTPFactor) TableName() string {
+	return "mfa_totp_factors"
+}
+
+// RecoveryCode is one of a user's single-use account recovery codes. Only
+// the hash is stored; the codes are shown once when generated.
+type RecoveryCode struct {
+	ID        string     `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID    string     `json:"user_id" bson:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" bson:"code_hash" gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty" bson:"used_at"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
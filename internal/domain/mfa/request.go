@@ -0,0 +1,22 @@
+package mfa
+
+// CodeRequest carries a second factor: an authenticator app code or, where
+// accepted, one of the user's recovery codes.
+type CodeRequest struct {
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" binding:"omitempty"`
+}
+
+// TOTPEnrollment is returned when enrollment starts. The secret is shown
+// once; the user confirms it with a first code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	// QRCode is a data: URI of the PNG encoding URI.
+	QRCode string `json:"qr_code"`
+}
+
+// RecoveryCodes is a freshly generated set, shown to the user once.
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
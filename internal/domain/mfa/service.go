@@ -0,0 +1,273 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const (
+	// RecoveryCodeCount is how many codes a generated set holds.
+	RecoveryCodeCount = 10
+	// recoveryCodeLength random characters give about 49 bits per code.
+	recoveryCodeLength = 10
+	// recoveryAlphabet leaves out characters that are easily confused when
+	// copied from paper: 0/o, 1/l/i.
+	recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrUnavailable     = errors.New("multi-factor authentication requires DATA_ENCRYPTION_KEY")
+	ErrAlreadyEnrolled = errors.New("authenticator app already enabled")
+	ErrNotEnrolled     = errors.New("authenticator app not enabled")
+	ErrInvalidCode     = errors.New("invalid verification code")
+	ErrCodeReused      = errors.New("verification code already used")
+	ErrTooManyAttempts = errors.New("too many wrong codes, try again later")
+)
+
+type Repository interface {
+	// GetTOTP returns nil without an error when the user has no factor.
+	GetTOTP(userID string) (*TOTPFactor, error)
+	SaveTOTP(f *TOTPFactor) error
+	// ConfirmTOTP marks a pending factor confirmed, recording step as used.
+	ConfirmTOTP(userID string, step int64, now time.Time) (bool, error)
+	// UseTOTPStep advances LastUsedStep to step if it is newer, reporting
+	// whether it did. This is what makes codes single-use.
+	UseTOTPStep(userID string, step int64) (bool, error)
+	DeleteTOTP(userID string) error
+	// ReplaceRecoveryCodes atomically swaps the user's codes for codes.
+	ReplaceRecoveryCodes(userID string, codes []RecoveryCode) error
+	// UseRecoveryCode marks an unused code used, reporting whether it did.
+	UseRecoveryCode(userID, codeHash string, now time.Time) (bool, error)
+	CountRecoveryCodes(userID string) (int64, error)
+}
+
+type Service interface {
+	// BeginTOTP creates (or replaces) a pending enrollment and returns the
+	// raw secret to show the user.
+	BeginTOTP(userID string) ([]byte, error)
+	ConfirmTOTP(userID, code string) error
+	VerifyTOTP(userID, code string) error
+	DisableTOTP(userID, code string) error
+	IsEnrolled(userID string) (bool, error)
+	// RemoveTOTP deletes the user's authenticator app without a code, for
+	// callers that already verified another factor.
+	RemoveTOTP(userID string) error
+	// GenerateRecoveryCodes returns a new set of recovery codes, replacing
+	// any previous set.
+	GenerateRecoveryCodes(userID string) ([]string, error)
+	// UseRecoveryCode consumes one recovery code and returns how many unused
+	// codes remain.
+	UseRecoveryCode(userID, code string) (int64, error)
+	RecoveryCodesRemaining(userID string) (int64, error)
+}
+
+type service struct {
+	repo   Repository
+	cipher *security.Cipher
+}
+
+// NewService creates the MFA service. Without a cipher enrollment is refused,
+// since secrets must not be stored in the clear.
+func NewService(r Repository, cipher *security.Cipher) Service {
+	return &service{repo: r, cipher: cipher}
+}
+
+func (s *service) BeginTOTP(userID string) ([]byte, error) {
+	if s.cipher == nil {
+		return nil, ErrUnavailable
+	}
+	existing, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Confirmed {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.repo.SaveTOTP(&TOTPFactor{
+		UserID:    userID,
+		Secret:    encrypted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (s *service) ConfirmTOTP(userID, code string) error {
+	factor, step, err := s.check(userID, code, false)
+	if err != nil {
+		return err
+	}
+	if factor.Confirmed {
+		return ErrAlreadyEnrolled
+	}
+	ok, err := s.repo.ConfirmTOTP(userID, step, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlreadyEnrolled
+	}
+	return nil
+}
+
+func (s *service) VerifyTOTP(userID, code string) error {
+	_, step, err := s.check(userID, code, true)
+	if err != nil {
+		return err
+	}
+	ok, err := s.repo.UseTOTPStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCodeReused
+	}
+	return nil
+}
+
+func (s *service) DisableTOTP(userID, code string) error {
+	if err := s.VerifyTOTP(userID, code); err != nil {
+		return err
+	}
+	return s.repo.DeleteTOTP(userID)
+}
+
+func (s *service) RemoveTOTP(userID string) error {
+	factor, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil {
+		return ErrNotEnrolled
+	}
+	return s.repo.DeleteTOTP(userID)
+}
+
+func (s *service) GenerateRecoveryCodes(userID string) ([]string, error) {
+	now := time.Now().UTC()
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]RecoveryCode, 0, RecoveryCodeCount)
+	for len(codes) < RecoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, RecoveryCode{
+			ID:        uuid.NewString(),
+			UserID:    userID,
+			CodeHash:  security.HashToken(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *service) UseRecoveryCode(userID, code string) (int64, error) {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return 0, ErrInvalidCode
+	}
+	ok, err := s.repo.UseRecoveryCode(userID, security.HashToken(normalized), time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidCode
+	}
+	return s.repo.CountRecoveryCodes(userID)
+}
+
+func (s *service) RecoveryCodesRemaining(userID string) (int64, error) {
+	return s.repo.CountRecoveryCodes(userID)
+}
+
+func (s *service) IsEnrolled(userID string) (bool, error) {
+	factor, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return false, err
+	}
+	return factor != nil && factor.Confirmed, nil
+}
+
+// check decrypts the user's secret and validates code, returning the matched
+// time step. Steps at or before the last used one are refused up front.
+func (s *service) check(userID, code string, mustBeConfirmed bool) (*TOTPFactor, int64, error) {
+	if s.cipher == nil {
+		return nil, 0, ErrUnavailable
+	}
+	factor, err := s.repo.GetTOTP(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if factor == nil || (mustBeConfirmed && !factor.Confirmed) {
+		return nil, 0, ErrNotEnrolled
+	}
+
+	secret, err := s.cipher.Decrypt(factor.Secret)
+	if err != nil {
+		return nil, 0, err
+	}
+	step, ok := security.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, 0, ErrInvalidCode
+	}
+	if step <= factor.LastUsedStep {
+		return nil, 0, ErrCodeReused
+	}
+	return factor, step, nil
+}
+
+// newRecoveryCode returns a random code formatted as two groups of five.
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 1)
+	var sb strings.Builder
+	for sb.Len() < recoveryCodeLength+1 {
+		if sb.Len() == recoveryCodeLength/2 {
+			sb.WriteByte('-')
+			continue
+		}
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		// rejection sampling keeps every character equally likely
+		if int(buf[0]) >= 256-256%len(recoveryAlphabet) {
+			continue
+		}
+		sb.WriteByte(recoveryAlphabet[int(buf[0])%len(recoveryAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+// normalizeRecoveryCode accepts codes as typed: any case, with or without
+// the dash and stray spaces.
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
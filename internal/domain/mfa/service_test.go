@@ -0,0 +1,211 @@
+package mfa
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	repo := newMemoryRepo()
+	cipher, err := security.NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(repo, cipher)
+
+	secret, err := svc.BeginTOTP("user-1")
+	if err != nil {
+		t.Fatalf("BeginTOTP() error = %v", err)
+	}
+	if bytes.Contains(repo.factors["user-1"].Secret, secret) {
+		t.Fatal("the secret was stored in the clear")
+	}
+	// steps stay inside the accepted window even if the clock ticks over
+	step := security.TOTPStep(time.Now())
+	code := func(step int64) string { return security.TOTPCode(secret, step) }
+
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{name: "verify before confirming", call: func() error { return svc.VerifyTOTP("user-1", code(step)) }, wantErr: ErrNotEnrolled},
+		{name: "confirm with a wrong code", call: func() error { return svc.ConfirmTOTP("user-1", code(step-10)) }, wantErr: ErrInvalidCode},
+		{name: "confirm", call: func() error { return svc.ConfirmTOTP("user-1", code(step)) }},
+		{name: "enroll again", call: func() error { _, err := svc.BeginTOTP("user-1"); return err }, wantErr: ErrAlreadyEnrolled},
+		{name: "confirmation code replayed", call: func() error { return svc.VerifyTOTP("user-1", code(step)) }, wantErr: ErrCodeReused},
+		{name: "next code", call: func() error { return svc.VerifyTOTP("user-1", code(step+1)) }},
+		{name: "older code after a newer one", call: func() error { return svc.VerifyTOTP("user-1", code(step)) }, wantErr: ErrCodeReused},
+		{name: "disable with a used code", call: func() error { return svc.DisableTOTP("user-1", code(step+1)) }, wantErr: ErrCodeReused},
+		{name: "other user", call: func() error { return svc.VerifyTOTP("user-2", code(step)) }, wantErr: ErrNotEnrolled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if enrolled, err := svc.IsEnrolled("user-1"); err != nil || !enrolled {
+		t.Fatalf("IsEnrolled() = %v, %v, want true", enrolled, err)
+	}
+	if err := svc.RemoveTOTP("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveTOTP("user-1"); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("second RemoveTOTP() error = %v, want %v", err, ErrNotEnrolled)
+	}
+}
+
+func TestTOTPRequiresCipher(t *testing.T) {
+	svc := NewService(newMemoryRepo(), nil)
+	if _, err := svc.BeginTOTP("user-1"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("BeginTOTP() error = %v, want %v", err, ErrUnavailable)
+	}
+	if err := svc.VerifyTOTP("user-1", "123456"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("VerifyTOTP() error = %v, want %v", err, ErrUnavailable)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo, nil)
+
+	codes, err := svc.GenerateRecoveryCodes("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' || seen[code] {
+			t.Fatalf("code %q is malformed or repeated", code)
+		}
+		for _, r := range strings.Replace(code, "-", "", 1) {
+			if !strings.ContainsRune(recoveryAlphabet, r) {
+				t.Fatalf("code %q uses %q", code, r)
+			}
+		}
+		seen[code] = true
+	}
+
+	tests := []struct {
+		name          string
+		userID        string
+		code          string
+		wantRemaining int64
+		wantErr       error
+	}{
+		{name: "as printed", userID: "user-1", code: codes[0], wantRemaining: 9},
+		{name: "already used", userID: "user-1", code: codes[0], wantErr: ErrInvalidCode},
+		{name: "typed in upper case without the dash", userID: "user-1", code: " " + strings.ToUpper(strings.Replace(codes[1], "-", "", 1)) + " ", wantRemaining: 8},
+		{name: "typed with spaces", userID: "user-1", code: strings.Replace(codes[2], "-", " ", 1), wantRemaining: 7},
+		{name: "another user's code", userID: "user-2", code: codes[3], wantErr: ErrInvalidCode},
+		{name: "wrong length", userID: "user-1", code: codes[3][:4], wantErr: ErrInvalidCode},
+		{name: "unknown code", userID: "user-1", code: "aaaaa-aaaaa", wantErr: ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, err := svc.UseRecoveryCode(tt.userID, tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UseRecoveryCode() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && remaining != tt.wantRemaining {
+				t.Errorf("UseRecoveryCode() remaining = %d, want %d", remaining, tt.wantRemaining)
+			}
+		})
+	}
+
+	// a new set replaces every earlier code
+	if _, err := svc.GenerateRecoveryCodes("user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UseRecoveryCode("user-1", codes[4]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("UseRecoveryCode() with a replaced code error = %v, want %v", err, ErrInvalidCode)
+	}
+	if n, err := svc.RecoveryCodesRemaining("user-1"); err != nil || n != RecoveryCodeCount {
+		t.Fatalf("RecoveryCodesRemaining() = %d, %v, want %d", n, err, RecoveryCodeCount)
+	}
+}
+
+type memoryRepo struct {
+	factors map[string]*TOTPFactor
+	codes   map[string][]RecoveryCode
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{factors: map[string]*TOTPFactor{}, codes: map[string][]RecoveryCode{}}
+}
+
+func (m *memoryRepo) GetTOTP(userID string) (*TOTPFactor, error) {
+	f, ok := m.factors[userID]
+	if !ok {
+		return nil, nil
+	}
+	c := *f
+	return &c, nil
+}
+
+func (m *memoryRepo) SaveTOTP(f *TOTPFactor) error {
+	c := *f
+	m.factors[f.UserID] = &c
+	return nil
+}
+
+func (m *memoryRepo) ConfirmTOTP(userID string, step int64, now time.Time) (bool, error) {
+	f, ok := m.factors[userID]
+	if !ok || f.Confirmed {
+		return false, nil
+	}
+	f.Confirmed, f.ConfirmedAt, f.LastUsedStep = true, &now, step
+	return true, nil
+}
+
+func (m *memoryRepo) UseTOTPStep(userID string, step int64) (bool, error) {
+	f, ok := m.factors[userID]
+	if !ok || step <= f.LastUsedStep {
+		return false, nil
+	}
+	f.LastUsedStep = step
+	return true, nil
+}
+
+func (m *memoryRepo) DeleteTOTP(userID string) error {
+	delete(m.factors, userID)
+	return nil
+}
+
+func (m *memoryRepo) ReplaceRecoveryCodes(userID string, codes []RecoveryCode) error {
+	m.codes[userID] = append([]RecoveryCode(nil), codes...)
+	return nil
+}
+
+func (m *memoryRepo) UseRecoveryCode(userID, codeHash string, now time.Time) (bool, error) {
+	for i, c := range m.codes[userID] {
+		if c.CodeHash == codeHash && c.UsedAt == nil {
+			m.codes[userID][i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryRepo) CountRecoveryCodes(userID string) (int64, error) {
+	var n int64
+	for _, c := range m.codes[userID] {
+		if c.UsedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,79 @@
+package oauth
+
+import (
+	"strings"
+	"time"
+)
+
+// Client is an application allowed to obtain tokens through the
+// authorization endpoint, or a backend service using client credentials.
+type Client struct {
+	// ID is the public client_id.
+	ID   string `json:"client_id" bson:"id" gorm:"primaryKey"`
+	Name string `json:"name" bson:"name" gorm:"not null"`
+	// SecretHash is empty for public clients (mobile and single-page apps),
+	// which cannot keep a secret and rely on PKCE alone.
+	SecretHash string `json:"-" bson:"secret_hash"`
+	// RedirectURIs and Scopes are space separated.
+	RedirectURIs string `json:"redirect_uris" bson:"redirect_uris" gorm:"not null"`
+	Scopes       string `json:"scope" bson:"scopes" gorm:"not null"`
+	Public       bool   `json:"public" bson:"public"`
+	// FirstParty clients are our own apps: users are not asked for consent
+	// and their tokens carry the user's roles.
+	FirstParty bool `json:"first_party" bson:"first_party"`
+	// Machine clients are backend services using the client credentials
+	// grant. They act as themselves, never on behalf of a user, and have no
+	// redirect URIs.
+	Machine   bool      `json:"machine" bson:"machine"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (Client) TableName() string {
+	return "oauth_clients"
+}
+
+func (c *Client) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+func (c *Client) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// AuthorizationCode is a one-time code handed to the client's redirect URI.
+// Only its hash is stored.
+type AuthorizationCode struct {
+	ID            string     `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	CodeHash      string     `json:"-" bson:"code_hash" gorm:"uniqueIndex;not null"`
+	ClientID      string     `json:"client_id" bson:"client_id" gorm:"not null"`
+	UserID        string     `json:"user_id" bson:"user_id" gorm:"not null"`
+	RedirectURI   string     `json:"redirect_uri" bson:"redirect_uri" gorm:"not null"`
+	Scope         string     `json:"scope" bson:"scope"`
+	CodeChallenge string     `json:"-" bson:"code_challenge" gorm:"not null"`
+	Nonce         string     `json:"-" bson:"nonce"`
+	AuthTime      time.Time  `json:"auth_time" bson:"auth_time"`
+	ExpiresAt     time.Time  `json:"expires_at" bson:"expires_at" gorm:"index"`
+	UsedAt        *time.Time `json:"used_at,omitempty" bson:"used_at"`
+	// SessionID is the session the code was redeemed for, revoked if the
+	// code is ever presented again.
+	SessionID string    `json:"session_id,omitempty" bson:"session_id"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (AuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// Consent records the scopes a user granted a third-party client.
+type Consent struct {
+	UserID    string    `json:"user_id" bson:"user_id" gorm:"primaryKey;type:uuid"`
+	ClientID  string    `json:"client_id" bson:"client_id" gorm:"primaryKey"`
+	Scope     string    `json:"scope" bson:"scope"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (Consent) TableName() string {
+	return "oauth_consents"
+}
@@ -0,0 +1,141 @@
+package oauth
+
+import "time"
+
+const (
+	TokenTypeHintAccess  = "access_token"
+	TokenTypeHintRefresh = "refresh_token"
+)
+
+// IntrospectRequest is the RFC 7662 introspection request.
+type IntrospectRequest struct {
+	Token         string `json:"token" form:"token" binding:"required"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint" binding:"omitempty,oneof=access_token refresh_token"`
+}
+
+// IntrospectionResponse is the RFC 7662 introspection response. Inactive
+// tokens only ever carry Active=false so nothing leaks about them.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Sub       string   `json:"sub,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	SessionID string   `json:"session_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	JTI       string   `json:"jti,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	// Act names the admin when the token was issued through impersonation.
+	Act *Actor `json:"act,omitempty"`
+	// Cnf carries the DPoP key binding of sender-constrained tokens.
+	Cnf *Confirmation `json:"cnf,omitempty"`
+}
+
+// Confirmation is the RFC 7800 "cnf" claim.
+type Confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+// Actor is the RFC 8693 "act" claim.
+type Actor struct {
+	Sub      string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// TokenRequest is the form posted to the token endpoint. Which fields are
+// required depends on GrantType.
+type TokenRequest struct {
+	GrantType string `json:"grant_type" form:"grant_type" binding:"required"`
+
+	// token exchange (RFC 8693)
+	SubjectToken       string `json:"subject_token" form:"subject_token"`
+	SubjectTokenType   string `json:"subject_token_type" form:"subject_token_type"`
+	RequestedTokenType string `json:"requested_token_type" form:"requested_token_type"`
+	// RequestedSubject is the id of the user to impersonate.
+	RequestedSubject string `json:"requested_subject" form:"requested_subject"`
+
+	// authorization code and refresh token grants (RFC 6749, RFC 7636)
+	Code         string `json:"code" form:"code"`
+	RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	Scope        string `json:"scope" form:"scope"`
+
+	// client credentials, from the body (client_secret_post) or the
+	// Authorization header (client_secret_basic)
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+
+	IPAddress string `json:"-" form:"-"`
+	UserAgent string `json:"-" form:"-"`
+	// DPoPJKT is the thumbprint of the key that signed the request's DPoP
+	// proof, empty without one.
+	DPoPJKT string `json:"-" form:"-"`
+}
+
+// TokenResponse is the RFC 6749 / RFC 8693 token response.
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+// AuthorizeRequest is the RFC 6749 authorization request, with PKCE.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" query:"response_type"`
+	ClientID            string `json:"client_id" query:"client_id"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri"`
+	Scope               string `json:"scope" query:"scope"`
+	State               string `json:"state" query:"state"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method"`
+	Nonce               string `json:"nonce" query:"nonce"`
+}
+
+// AuthorizeResponse tells the caller where to go next: back to the client,
+// to the login page, or to a consent screen.
+type AuthorizeResponse struct {
+	// RedirectTo is the client redirect URI with the code or an error.
+	RedirectTo string `json:"redirect_to,omitempty"`
+	// LoginRequired is set when nobody is signed in yet.
+	LoginRequired bool `json:"-"`
+	// Consent is set when the user must approve a third-party client.
+	Consent *ConsentPrompt `json:"consent,omitempty"`
+}
+
+// ConsentPrompt is what a consent screen shows. The consent token is posted
+// back with the user's decision.
+type ConsentPrompt struct {
+	ConsentToken string    `json:"consent_token"`
+	ClientID     string    `json:"client_id"`
+	ClientName   string    `json:"client_name"`
+	Scopes       []string  `json:"scopes"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type ConsentRequest struct {
+	ConsentToken string `json:"consent_token"`
+	Approve      bool   `json:"approve"`
+}
+
+type RegisterClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+	FirstParty   bool     `json:"first_party"`
+	Machine      bool     `json:"machine"`
+}
+
+// RegisteredClient is returned once, at registration; the secret cannot be
+// retrieved later.
+type RegisteredClient struct {
+	*Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
@@ -0,0 +1,420 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// Errors map onto the RFC 6749 / RFC 8693 / RFC 9449 error codes.
+var (
+	ErrInvalidRequest          = errors.New("invalid_request")
+	ErrInvalidClient           = errors.New("invalid_client")
+	ErrInvalidGrant            = errors.New("invalid_grant")
+	ErrInvalidScope            = errors.New("invalid_scope")
+	ErrUnauthorizedClient      = errors.New("unauthorized_client")
+	ErrUnsupportedGrantType    = errors.New("unsupported_grant_type")
+	ErrUnsupportedResponseType = errors.New("unsupported_response_type")
+	ErrAccessDenied            = errors.New("access_denied")
+	ErrInvalidDPoPProof        = errors.New("invalid_dpop_proof")
+)
+
+var (
+	ErrClientNotFound     = errors.New("oauth client not found")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	// ErrCodeReused is returned with the code when an authorization code is
+	// presented a second time, so the tokens it produced can be revoked.
+	ErrCodeReused = fmt.Errorf("%w: authorization code already used", ErrInvalidGrant)
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken       = "urn:ietf:params:oauth:token-type:access_token"
+
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+	ScopeOpenID             = "openid"
+	// ScopeIntrospect lets a machine client call the introspection endpoint.
+	ScopeIntrospect = "introspect"
+
+	// AuthorizationCodeTTL is how long a client has to redeem a code.
+	AuthorizationCodeTTL = time.Minute
+)
+
+const (
+	defaultClientScopes      = "openid profile email"
+	maxRedirectURIsPerClient = 10
+	clientSecretBytes        = 32
+	authorizationCodeBytes   = 32
+)
+
+// codeVerifierPattern is the RFC 7636 code_verifier syntax.
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+type Repository interface {
+	CreateClient(c *Client) error
+	// GetClient returns nil without an error when nothing matches.
+	GetClient(id string) (*Client, error)
+	ListClients() ([]Client, error)
+	DeleteClient(id string) (bool, error)
+
+	CreateCode(c *AuthorizationCode) error
+	// GetCodeByHash returns nil without an error when nothing matches.
+	GetCodeByHash(hash string) (*AuthorizationCode, error)
+	// MarkCodeUsed sets used_at only if the code was unused, reporting
+	// whether it did.
+	MarkCodeUsed(id string, now time.Time) (bool, error)
+	SetCodeSession(id, sessionID string) error
+
+	// GetConsent returns nil without an error when nothing matches.
+	GetConsent(userID, clientID string) (*Consent, error)
+	SaveConsent(c *Consent) error
+}
+
+// CodeGrant is what an authorization code is issued for.
+type CodeGrant struct {
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+}
+
+type Service interface {
+	RegisterClient(req *RegisterClientRequest) (*RegisteredClient, error)
+	GetClient(id string) (*Client, error)
+	ListClients() ([]Client, error)
+	DeleteClient(id string) error
+	// AuthenticateClient checks the credentials presented at the token
+	// endpoint. Public clients present none.
+	AuthenticateClient(id, secret string) (*Client, error)
+	// ResolveScope checks requested against what the client may ask for. An
+	// empty request means every scope the client is allowed.
+	ResolveScope(c *Client, requested string) (string, error)
+
+	IssueCode(grant *CodeGrant) (string, error)
+	// RedeemCode burns an authorization code and checks it against the token
+	// request. On ErrCodeReused the returned code names the session to revoke.
+	RedeemCode(clientID, code, redirectURI, codeVerifier string) (*AuthorizationCode, error)
+	AttachSession(codeID, sessionID string) error
+
+	// HasConsent reports whether the user already granted every scope in scope.
+	HasConsent(userID, clientID, scope string) (bool, error)
+	GrantConsent(userID, clientID, scope string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) RegisterClient(req *RegisterClientRequest) (*RegisteredClient, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	scopes := strings.Join(req.Scopes, " ")
+	if req.Machine {
+		if err := validateMachineClient(req, scopes); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(req.RedirectURIs) == 0 || len(req.RedirectURIs) > maxRedirectURIsPerClient {
+			return nil, fmt.Errorf("%w: between 1 and %d redirect uris are required", ErrInvalidRedirectURI, maxRedirectURIsPerClient)
+		}
+		for _, uri := range req.RedirectURIs {
+			if err := validateRedirectURI(uri); err != nil {
+				return nil, err
+			}
+		}
+		if len(strings.Fields(scopes)) == 0 {
+			scopes = defaultClientScopes
+		}
+	}
+	if slices.Contains(strings.Fields(scopes), security.ScopeAccountRecovery) {
+		return nil, fmt.Errorf("%w: %s is reserved", ErrInvalidScope, security.ScopeAccountRecovery)
+	}
+
+	now := time.Now().UTC()
+	c := &Client{
+		ID:           uuid.NewString(),
+		Name:         name,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		Scopes:       strings.Join(strings.Fields(scopes), " "),
+		Public:       req.Public,
+		FirstParty:   req.FirstParty,
+		Machine:      req.Machine,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	var secret string
+	if !c.Public {
+		var err error
+		if secret, err = randomToken(clientSecretBytes); err != nil {
+			return nil, err
+		}
+		c.SecretHash = security.HashToken(secret)
+	}
+
+	if err := s.repo.CreateClient(c); err != nil {
+		return nil, err
+	}
+	return &RegisteredClient{Client: c, ClientSecret: secret}, nil
+}
+
+func (s *service) GetClient(id string) (*Client, error) {
+	c, err := s.repo.GetClient(id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrClientNotFound
+	}
+	return c, nil
+}
+
+func (s *service) ListClients() ([]Client, error) {
+	return s.repo.ListClients()
+}
+
+func (s *service) DeleteClient(id string) error {
+	ok, err := s.repo.DeleteClient(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrClientNotFound
+	}
+	return nil
+}
+
+func (s *service) AuthenticateClient(id, secret string) (*Client, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: client authentication required", ErrInvalidClient)
+	}
+	c, err := s.repo.GetClient(id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrInvalidClient
+	}
+	if c.Public {
+		if secret != "" {
+			return nil, fmt.Errorf("%w: public clients have no secret", ErrInvalidClient)
+		}
+		return c, nil
+	}
+	if secret == "" || !security.CompareTokenHash(c.SecretHash, secret) {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+func (s *service) ResolveScope(c *Client, requested string) (string, error) {
+	allowed := c.ScopeList()
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(allowed, " "), nil
+	}
+
+	var granted []string
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return "", fmt.Errorf("%w: %s is not allowed for this client", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+func (s *service) IssueCode(grant *CodeGrant) (string, error) {
+	raw, err := randomToken(authorizationCodeBytes)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	code := &AuthorizationCode{
+		ID:            uuid.NewString(),
+		CodeHash:      security.HashToken(raw),
+		ClientID:      grant.ClientID,
+		UserID:        grant.UserID,
+		RedirectURI:   grant.RedirectURI,
+		Scope:         grant.Scope,
+		CodeChallenge: grant.CodeChallenge,
+		Nonce:         grant.Nonce,
+		AuthTime:      grant.AuthTime,
+		ExpiresAt:     now.Add(AuthorizationCodeTTL),
+		CreatedAt:     now,
+	}
+	if err := s.repo.CreateCode(code); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *service) RedeemCode(clientID, raw, redirectURI, codeVerifier string) (*AuthorizationCode, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrInvalidRequest)
+	}
+	if !codeVerifierPattern.MatchString(codeVerifier) {
+		return nil, fmt.Errorf("%w: a valid code_verifier is required", ErrInvalidGrant)
+	}
+
+	code, err := s.repo.GetCodeByHash(security.HashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || code.ClientID != clientID {
+		return nil, fmt.Errorf("%w: unknown authorization code", ErrInvalidGrant)
+	}
+
+	// every presentation burns the code, whatever else is wrong with it
+	fresh, err := s.repo.MarkCodeUsed(code.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return code, ErrCodeReused
+	}
+
+	if time.Now().After(code.ExpiresAt) {
+		return nil, fmt.Errorf("%w: authorization code expired", ErrInvalidGrant)
+	}
+	if code.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("%w: redirect_uri does not match the authorization request", ErrInvalidGrant)
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return nil, fmt.Errorf("%w: code_verifier does not match the code_challenge", ErrInvalidGrant)
+	}
+	return code, nil
+}
+
+func (s *service) AttachSession(codeID, sessionID string) error {
+	return s.repo.SetCodeSession(codeID, sessionID)
+}
+
+func (s *service) HasConsent(userID, clientID, scope string) (bool, error) {
+	consent, err := s.repo.GetConsent(userID, clientID)
+	if err != nil || consent == nil {
+		return false, err
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, sc := range strings.Fields(scope) {
+		if !slices.Contains(granted, sc) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *service) GrantConsent(userID, clientID, scope string) error {
+	now := time.Now().UTC()
+	consent, err := s.repo.GetConsent(userID, clientID)
+	if err != nil {
+		return err
+	}
+	if consent == nil {
+		consent = &Consent{UserID: userID, ClientID: clientID, CreatedAt: now}
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, sc := range strings.Fields(scope) {
+		if !slices.Contains(granted, sc) {
+			granted = append(granted, sc)
+		}
+	}
+	consent.Scope = strings.Join(granted, " ")
+	consent.UpdatedAt = now
+	return s.repo.SaveConsent(consent)
+}
+
+// AllowsRedirectURI reports whether uri is registered for the client. The
+// comparison is exact, as RFC 6749 section 3.1.2 requires.
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	return uri != "" && slices.Contains(c.RedirectURIList(), uri)
+}
+
+// validateRedirectURI accepts https URIs, plain http only on loopback for
+// native apps, and private-use schemes (com.example.app:/callback) for mobile
+// apps.
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(raw, " \t\n") {
+		return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, raw)
+	}
+	switch scheme := strings.ToLower(u.Scheme); scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, raw)
+		}
+	case "http":
+		host := u.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("%w: http is only allowed for loopback, got %q", ErrInvalidRedirectURI, raw)
+		}
+	case "javascript", "data", "file", "vbscript":
+		return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, raw)
+	default:
+		// private-use schemes must be reverse domain names
+		if !strings.Contains(scheme, ".") {
+			return fmt.Errorf("%w: custom schemes must be reverse domain names, got %q", ErrInvalidRedirectURI, raw)
+		}
+	}
+	return nil
+}
+
+// validateMachineClient checks a client credentials registration: a machine
+// client must keep a secret, is never trusted with user roles, and is granted
+// explicit scopes since there is no user to consent to defaults.
+func validateMachineClient(req *RegisterClientRequest, scopes string) error {
+	if req.Public {
+		return fmt.Errorf("%w: machine clients must be confidential", ErrInvalidRequest)
+	}
+	if req.FirstParty {
+		return fmt.Errorf("%w: machine clients cannot be first party", ErrInvalidRequest)
+	}
+	if len(req.RedirectURIs) > 0 {
+		return fmt.Errorf("%w: machine clients have no redirect uris", ErrInvalidRedirectURI)
+	}
+	fields := strings.Fields(scopes)
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: machine clients need at least one scope", ErrInvalidScope)
+	}
+	if slices.Contains(fields, ScopeOpenID) {
+		return fmt.Errorf("%w: %s needs a user", ErrInvalidScope, ScopeOpenID)
+	}
+	return nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
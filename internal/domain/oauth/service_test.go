@@ -0,0 +1,260 @@
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example from RFC 7636 appendix B.
+const (
+	rfcCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	testClientID    = "client-1"
+	testRedirectURI = "https://app.example.com/callback"
+)
+
+func TestRedeemCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientID    string
+		redirectURI string
+		verifier    string
+		expired     bool
+		wantErr     error
+	}{
+		{name: "RFC 7636 verifier", verifier: rfcCodeVerifier},
+		{name: "verifier for another challenge", verifier: strings.Repeat("a", 43), wantErr: ErrInvalidGrant},
+		{name: "challenge sent as the verifier", verifier: rfcCodeChallenge, wantErr: ErrInvalidGrant},
+		{name: "verifier one character too short", verifier: rfcCodeVerifier[:42], wantErr: ErrInvalidGrant},
+		{name: "verifier too long", verifier: strings.Repeat("a", 129), wantErr: ErrInvalidGrant},
+		{name: "verifier outside the unreserved set", verifier: rfcCodeVerifier[:42] + "+", wantErr: ErrInvalidGrant},
+		{name: "missing verifier", verifier: "", wantErr: ErrInvalidGrant},
+		{name: "other redirect uri", verifier: rfcCodeVerifier, redirectURI: "https://app.example.com/other", wantErr: ErrInvalidGrant},
+		{name: "code issued to another client", verifier: rfcCodeVerifier, clientID: "client-2", wantErr: ErrInvalidGrant},
+		{name: "expired code", verifier: rfcCodeVerifier, expired: true, wantErr: ErrInvalidGrant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepo()
+			svc := NewService(repo)
+			raw := issueTestCode(t, svc)
+			if tt.expired {
+				for _, c := range repo.codes {
+					c.ExpiresAt = time.Now().Add(-time.Second)
+				}
+			}
+			clientID, redirectURI := testClientID, testRedirectURI
+			if tt.clientID != "" {
+				clientID = tt.clientID
+			}
+			if tt.redirectURI != "" {
+				redirectURI = tt.redirectURI
+			}
+
+			code, err := svc.RedeemCode(clientID, raw, redirectURI, tt.verifier)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RedeemCode() error = %v, want %v", err, tt.wantErr)
+				}
+				if errors.Is(err, ErrCodeReused) {
+					t.Fatalf("RedeemCode() error = %v on the first presentation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RedeemCode() error = %v", err)
+			}
+			if code.UserID != "user-1" || code.Scope != "openid" {
+				t.Errorf("RedeemCode() = %+v, want the issued grant", code)
+			}
+		})
+	}
+}
+
+func TestRedeemCodeBurnsTheCodeOnFailure(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	raw := issueTestCode(t, svc)
+
+	if _, err := svc.RedeemCode(testClientID, raw, testRedirectURI, strings.Repeat("a", 43)); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("RedeemCode() with a wrong verifier error = %v, want %v", err, ErrInvalidGrant)
+	}
+	// an attacker guessing verifiers gets a single try
+	if _, err := svc.RedeemCode(testClientID, raw, testRedirectURI, rfcCodeVerifier); !errors.Is(err, ErrCodeReused) {
+		t.Fatalf("RedeemCode() after a failure error = %v, want %v", err, ErrCodeReused)
+	}
+}
+
+func TestRedeemCodeReuseNamesTheSession(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	raw := issueTestCode(t, svc)
+
+	code, err := svc.RedeemCode(testClientID, raw, testRedirectURI, rfcCodeVerifier)
+	if err != nil {
+		t.Fatalf("RedeemCode() error = %v", err)
+	}
+	if err := svc.AttachSession(code.ID, "session-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reused, err := svc.RedeemCode(testClientID, raw, testRedirectURI, rfcCodeVerifier)
+	if !errors.Is(err, ErrCodeReused) || !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("RedeemCode() replay error = %v, want %v", err, ErrCodeReused)
+	}
+	if reused == nil || reused.SessionID != "session-1" {
+		t.Fatalf("RedeemCode() replay = %+v, want the code naming session-1", reused)
+	}
+}
+
+func TestRedeemCodeRequiresCode(t *testing.T) {
+	svc := NewService(newMemoryRepo())
+	if _, err := svc.RedeemCode(testClientID, "", testRedirectURI, rfcCodeVerifier); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("RedeemCode() error = %v, want %v", err, ErrInvalidRequest)
+	}
+	if _, err := svc.RedeemCode(testClientID, "unknown", testRedirectURI, rfcCodeVerifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("RedeemCode() of an unknown code error = %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri   string
+		valid bool
+	}{
+		{"https://app.example.com/callback", true},
+		{"https://app.example.com/callback?tenant=1", true},
+		{"http://localhost:8080/callback", true},
+		{"http://127.0.0.1/callback", true},
+		{"http://[::1]:9000/callback", true},
+		{"com.example.app:/oauth2redirect", true},
+		{"http://app.example.com/callback", false},
+		{"https:///callback", false},
+		{"https://app.example.com/callback#fragment", false},
+		{"/relative/callback", false},
+		{"javascript:alert(1)", false},
+		{"data:text/html,hi", false},
+		{"myapp:/callback", false},
+		{"https://app.example.com/call back", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			err := validateRedirectURI(tt.uri)
+			if tt.valid && err != nil {
+				t.Errorf("validateRedirectURI() error = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRedirectURI) {
+				t.Errorf("validateRedirectURI() error = %v, want %v", err, ErrInvalidRedirectURI)
+			}
+		})
+	}
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	confidential, err := svc.RegisterClient(&RegisterClientRequest{Name: "web", RedirectURIs: []string{testRedirectURI}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := svc.RegisterClient(&RegisterClientRequest{Name: "spa", RedirectURIs: []string{testRedirectURI}, Public: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		secret  string
+		wantErr bool
+	}{
+		{name: "confidential client with its secret", id: confidential.ID, secret: confidential.ClientSecret},
+		{name: "confidential client without a secret", id: confidential.ID, wantErr: true},
+		{name: "confidential client with a wrong secret", id: confidential.ID, secret: "wrong", wantErr: true},
+		{name: "public client", id: public.ID},
+		{name: "public client presenting a secret", id: public.ID, secret: "anything", wantErr: true},
+		{name: "unknown client", id: "nobody", wantErr: true},
+		{name: "no client id", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.AuthenticateClient(tt.id, tt.secret)
+			if tt.wantErr && !errors.Is(err, ErrInvalidClient) {
+				t.Fatalf("AuthenticateClient() error = %v, want %v", err, ErrInvalidClient)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("AuthenticateClient() error = %v", err)
+			}
+		})
+	}
+}
+
+func issueTestCode(t *testing.T, svc Service) string {
+	t.Helper()
+	raw, err := svc.IssueCode(&CodeGrant{
+		ClientID:      testClientID,
+		UserID:        "user-1",
+		RedirectURI:   testRedirectURI,
+		Scope:         "openid",
+		CodeChallenge: rfcCodeChallenge,
+		AuthTime:      time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// memoryRepo is a Repository keeping everything in maps.
+type memoryRepo struct {
+	Repository
+	clients map[string]*Client
+	codes   map[string]*AuthorizationCode
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{clients: map[string]*Client{}, codes: map[string]*AuthorizationCode{}}
+}
+
+func (m *memoryRepo) CreateClient(c *Client) error {
+	m.clients[c.ID] = c
+	return nil
+}
+
+func (m *memoryRepo) GetClient(id string) (*Client, error) {
+	return m.clients[id], nil
+}
+
+func (m *memoryRepo) CreateCode(c *AuthorizationCode) error {
+	m.codes[c.ID] = c
+	return nil
+}
+
+func (m *memoryRepo) GetCodeByHash(hash string) (*AuthorizationCode, error) {
+	for _, c := range m.codes {
+		if c.CodeHash == hash {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRepo) MarkCodeUsed(id string, now time.Time) (bool, error) {
+	c, ok := m.codes[id]
+	if !ok || c.UsedAt != nil {
+		return false, nil
+	}
+	c.UsedAt = &now
+	return true, nil
+}
+
+func (m *memoryRepo) SetCodeSession(id, sessionID string) error {
+	m.codes[id].SessionID = sessionID
+	return nil
+}
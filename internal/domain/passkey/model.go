@@ -0,0 +1,25 @@
+package passkey
+
+import "time"
+
+// Credential is a WebAuthn credential (passkey) registered to a user.
+type Credential struct {
+	ID     string `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID string `json:"user_id" bson:"user_id" gorm:"index;not null"`
+	// CredentialID is the authenticator's id for the credential.
+	CredentialID []byte `json:"-" bson:"credential_id" gorm:"uniqueIndex;not null"`
+	// PublicKey is the COSE_Key the authenticator registered.
+	PublicKey  []byte `json:"-" bson:"public_key" gorm:"not null"`
+	SignCount  int64  `json:"sign_count" bson:"sign_count" gorm:"not null;default:0"`
+	Name       string `json:"name" bson:"name"`
+	AAGUID     []byte `json:"-" bson:"aaguid"`
+	Transports string `json:"transports,omitempty" bson:"transports"`
+	// BackedUp is set for synced passkeys, which survive losing the device.
+	BackedUp   bool       `json:"backed_up" bson:"backed_up"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (Credential) TableName() string {
+	return "webauthn_credentials"
+}
@@ -0,0 +1,37 @@
+package passkey
+
+import "mikhailjbs/user-auth-service/internal/infra/webauthn"
+
+// RegistrationFinishRequest carries the browser's response to the options
+// returned by the begin step.
+type RegistrationFinishRequest struct {
+	CeremonyToken string                        `json:"ceremony_token" binding:"required"`
+	Name          string                        `json:"name" binding:"omitempty"`
+	Credential    webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+// LoginBeginRequest carries what the session should be issued for, like the
+// matching fields of a password login.
+type LoginBeginRequest struct {
+	ClientID string `json:"client_id" binding:"omitempty"`
+	Nonce    string `json:"nonce" binding:"omitempty"`
+	Audience string `json:"audience" binding:"omitempty"`
+}
+
+type LoginFinishRequest struct {
+	CeremonyToken string                     `json:"ceremony_token" binding:"required"`
+	Credential    webauthn.AssertionResponse `json:"credential" binding:"required"`
+}
+
+// RegistrationOptions is handed to navigator.credentials.create(); the
+// ceremony token must come back with the result.
+type RegistrationOptions struct {
+	CeremonyToken string                    `json:"ceremony_token"`
+	PublicKey     *webauthn.CreationOptions `json:"publicKey"`
+}
+
+// LoginOptions is handed to navigator.credentials.get().
+type LoginOptions struct {
+	CeremonyToken string                   `json:"ceremony_token"`
+	PublicKey     *webauthn.RequestOptions `json:"publicKey"`
+}
@@ -0,0 +1,125 @@
+package passkey
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// maxNameLength keeps user-chosen names display sized.
+const maxNameLength = 64
+
+var (
+	ErrNotFound      = errors.New("passkey not found")
+	ErrAlreadyExists = errors.New("passkey already registered")
+	// ErrCloned is returned when a credential's signature counter goes
+	// backwards, a sign that the authenticator was copied.
+	ErrCloned = errors.New("passkey signature counter mismatch")
+)
+
+type Repository interface {
+	Create(c *Credential) error
+	// GetByCredentialID returns nil without an error when nothing matches.
+	GetByCredentialID(credentialID []byte) (*Credential, error)
+	ListByUser(userID string) ([]Credential, error)
+	// RecordUse moves the sign count from oldCount to newCount, reporting
+	// whether it did. The condition makes concurrent assertions with the same
+	// counter fail instead of both succeeding.
+	RecordUse(id string, oldCount, newCount int64, backedUp bool, now time.Time) (bool, error)
+	Delete(userID, id string) (bool, error)
+}
+
+type Service interface {
+	Register(userID, name string, cred *webauthn.Credential) (*Credential, error)
+	GetByCredentialID(credentialID []byte) (*Credential, error)
+	ListByUser(userID string) ([]Credential, error)
+	RecordUse(c *Credential, assertion *webauthn.Assertion) error
+	Delete(userID, id string) error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Register(userID, name string, cred *webauthn.Credential) (*Credential, error) {
+	existing, err := s.repo.GetByCredentialID(cred.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyExists
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if r := []rune(name); len(r) > maxNameLength {
+		name = string(r[:maxNameLength])
+	}
+
+	c := &Credential{
+		ID:           uuid.NewString(),
+		UserID:       userID,
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		Name:         name,
+		AAGUID:       cred.AAGUID,
+		Transports:   strings.Join(cred.Transports, ","),
+		BackedUp:     cred.BackedUp,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.repo.Create(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *service) GetByCredentialID(credentialID []byte) (*Credential, error) {
+	c, err := s.repo.GetByCredentialID(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+func (s *service) ListByUser(userID string) ([]Credential, error) {
+	return s.repo.ListByUser(userID)
+}
+
+func (s *service) RecordUse(c *Credential, assertion *webauthn.Assertion) error {
+	ok, err := s.repo.RecordUse(c.ID, c.SignCount, int64(assertion.SignCount), assertion.BackedUp, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCloned
+	}
+	return nil
+}
+
+func (s *service) Delete(userID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+	ok, err := s.repo.Delete(userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package password
+
+import "strings"
+
+// Character classes a policy can require.
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// Violation codes.
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeMissingClass     = "missing_class"
+	CodeContainsIdentity = "contains_identity"
+	CodeBreached         = "breached"
+)
+
+// Rules configures a Policy.
+type Rules struct {
+	// MinLength counts characters, not bytes.
+	MinLength int
+	// MaxBytes caps the encoded length. bcrypt ignores everything past 72
+	// bytes, so a longer password would not protect more than its prefix.
+	MaxBytes int
+	// RequiredClasses lists the classes a password must use, e.g. "digit".
+	RequiredClasses []string
+	// DenyIdentity rejects passwords containing the username or email.
+	DenyIdentity bool
+}
+
+// Violation is one rule a password breaks.
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password breaks, so a client can show them
+// all at once.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password rejected: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,122 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidRules = errors.New("invalid password rules")
+
+// minIdentityLength keeps short usernames like "al" from ruling out half the
+// dictionary.
+const minIdentityLength = 3
+
+// BreachChecker tells whether a password appears in a known breach corpus.
+type BreachChecker interface {
+	Contains(password string) bool
+}
+
+// Policy decides whether a new password is acceptable. It is checked
+// wherever a user picks a password, never at login.
+type Policy interface {
+	// Check returns a *PolicyError listing every broken rule. identifiers are
+	// the username and email of the account the password is for.
+	Check(password string, identifiers ...string) error
+}
+
+type policy struct {
+	rules    Rules
+	breached BreachChecker
+}
+
+// NewPolicy builds a policy from rules. breached may be nil to skip the
+// breach check.
+func NewPolicy(rules Rules, breached BreachChecker) (Policy, error) {
+	if rules.MinLength < 1 {
+		rules.MinLength = 1
+	}
+	if rules.MaxBytes > 0 && rules.MaxBytes < rules.MinLength {
+		return nil, fmt.Errorf("%w: maximum length %d is below the minimum %d", ErrInvalidRules, rules.MaxBytes, rules.MinLength)
+	}
+	for _, class := range rules.RequiredClasses {
+		if classCheck(class) == nil {
+			return nil, fmt.Errorf("%w: unknown character class %q", ErrInvalidRules, class)
+		}
+	}
+	return &policy{rules: rules, breached: breached}, nil
+}
+
+func (p *policy) Check(password string, identifiers ...string) error {
+	var violations []Violation
+	add := func(code, message string) {
+		violations = append(violations, Violation{Field: "password", Code: code, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.rules.MinLength {
+		add(CodeTooShort, fmt.Sprintf("must be at least %d characters", p.rules.MinLength))
+	}
+	if p.rules.MaxBytes > 0 && len(password) > p.rules.MaxBytes {
+		add(CodeTooLong, fmt.Sprintf("must be at most %d bytes", p.rules.MaxBytes))
+	}
+	for _, class := range p.rules.RequiredClasses {
+		if !strings.ContainsFunc(password, classCheck(class)) {
+			add(CodeMissingClass, "must contain a "+classNames[class])
+		}
+	}
+	if p.rules.DenyIdentity && containsIdentity(password, identifiers) {
+		add(CodeContainsIdentity, "must not contain your username or email")
+	}
+	// a password already failing other rules is not worth the lookup
+	if len(violations) == 0 && p.breached != nil && p.breached.Contains(password) {
+		add(CodeBreached, "appears in a known data breach, choose another")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+var classNames = map[string]string{
+	ClassLower:  "lowercase letter",
+	ClassUpper:  "uppercase letter",
+	ClassDigit:  "digit",
+	ClassSymbol: "symbol",
+}
+
+func classCheck(class string) func(rune) bool {
+	switch class {
+	case ClassLower:
+		return unicode.IsLower
+	case ClassUpper:
+		return unicode.IsUpper
+	case ClassDigit:
+		return unicode.IsDigit
+	case ClassSymbol:
+		return func(r rune) bool {
+			return unicode.IsPunct(r) || unicode.IsSymbol(r)
+		}
+	}
+	return nil
+}
+
+// containsIdentity reports whether the password contains an identifier, or
+// for an email address its local part, ignoring case.
+func containsIdentity(password string, identifiers []string) bool {
+	lowered := strings.ToLower(password)
+	var parts []string
+	for _, id := range identifiers {
+		id = strings.ToLower(strings.TrimSpace(id))
+		parts = append(parts, id)
+		if local, _, ok := strings.Cut(id, "@"); ok {
+			parts = append(parts, local)
+		}
+	}
+	return slices.ContainsFunc(parts, func(part string) bool {
+		return utf8.RuneCountInString(part) >= minIdentityLength && strings.Contains(lowered, part)
+	})
+}
@@ -0,0 +1,109 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type breachList []string
+
+func (b breachList) Contains(password string) bool {
+	for _, p := range b {
+		if p == password {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPolicyCheck(t *testing.T) {
+	strict := Rules{
+		MinLength:       10,
+		MaxBytes:        72,
+		RequiredClasses: []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol},
+		DenyIdentity:    true,
+	}
+
+	tests := []struct {
+		name        string
+		rules       Rules
+		password    string
+		identifiers []string
+		want        []string
+	}{
+		{name: "acceptable", rules: strict, password: "Correct-Horse-9"},
+		{name: "too short", rules: strict, password: "Sh0rt-pw", want: []string{CodeTooShort}},
+		{name: "length counts characters, not bytes", rules: Rules{MinLength: 4}, password: "ééé", want: []string{CodeTooShort}},
+		{name: "multibyte characters reach the minimum", rules: Rules{MinLength: 4}, password: "éééé"},
+		{name: "too long for bcrypt", rules: strict, password: "Aa1-" + strings.Repeat("x", 69), want: []string{CodeTooLong}},
+		{name: "max length is in bytes", rules: Rules{MaxBytes: 4}, password: "ééé", want: []string{CodeTooLong}},
+		{name: "every missing class is listed", rules: strict, password: "alllowercase", want: []string{CodeMissingClass, CodeMissingClass, CodeMissingClass}},
+		{name: "non-ASCII letters count", rules: Rules{RequiredClasses: []string{ClassUpper}}, password: "Ébène"},
+		{name: "contains the username", rules: strict, password: "Janedoe-2024!", identifiers: []string{"JaneDoe", "jd@example.com"}, want: []string{CodeContainsIdentity}},
+		{name: "contains the email local part", rules: strict, password: "Xx-Jane.Doe-99", identifiers: []string{"jd", "Jane.Doe@example.com"}, want: []string{CodeContainsIdentity}},
+		{name: "short usernames are ignored", rules: strict, password: "Al-is-Great-1", identifiers: []string{"al"}},
+		{name: "identity allowed when not denied", rules: Rules{MinLength: 8}, password: "janedoe-2024", identifiers: []string{"janedoe"}},
+		{name: "breached", rules: strict, password: "P@ssw0rd1234", want: []string{CodeBreached}},
+		{name: "breach lookup skipped when other rules fail", rules: strict, password: "password", want: []string{CodeTooShort, CodeMissingClass, CodeMissingClass, CodeMissingClass}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.rules, breachList{"P@ssw0rd1234", "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.Check(tt.password, tt.identifiers...)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Check() error = %v, want a *PolicyError", err)
+			}
+			var codes []string
+			for _, v := range policyErr.Violations {
+				if v.Field != "password" || v.Message == "" {
+					t.Errorf("violation = %+v", v)
+				}
+				codes = append(codes, v.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.want) {
+				t.Errorf("violations = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		wantErr bool
+	}{
+		{name: "zero rules", rules: Rules{}},
+		{name: "max below min", rules: Rules{MinLength: 12, MaxBytes: 8}, wantErr: true},
+		{name: "unknown class", rules: Rules{RequiredClasses: []string{"emoji"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPolicy(tt.rules, nil)
+			if tt.wantErr != errors.Is(err, ErrInvalidRules) {
+				t.Fatalf("NewPolicy() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyErrorListsEveryMessage(t *testing.T) {
+	err := &PolicyError{Violations: []Violation{{Message: "must be longer"}, {Message: "must contain a digit"}}}
+	if got, want := err.Error(), "password rejected: must be longer; must contain a digit"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly over Per: a token
+// bucket holding Burst tokens that gains one every Per/Burst.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Bucket is the stored state of one token bucket.
+type Bucket struct {
+	Key       string    `json:"key" bson:"key" gorm:"primaryKey"`
+	Tokens    float64   `json:"tokens" bson:"tokens" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at" gorm:"not null"`
+	// ExpiresAt is when the bucket is full again and can be forgotten.
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at" gorm:"index;not null"`
+}
+
+func (Bucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// Result describes a rate limit decision.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this
+	// one was not.
+	RetryAfter time.Duration
+}
+
+// Take refills b for the time elapsed since it was last used and takes cost
+// tokens if there are that many. A cost of zero only checks that a token is
+// left. A new bucket must start with Tokens = Burst.
+func (b *Bucket) Take(limit Limit, cost int, now time.Time) *Result {
+	rate := limit.rate()
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*rate)
+	}
+	b.UpdatedAt = now
+
+	need := math.Max(float64(cost), 1)
+	res := &Result{Limit: limit.Burst}
+	if b.Tokens >= need {
+		b.Tokens -= float64(cost)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((need - b.Tokens) / rate)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((float64(limit.Burst) - b.Tokens) / rate)
+	b.ExpiresAt = now.Add(res.Reset)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
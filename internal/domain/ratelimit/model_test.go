@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	// three requests at once, then one a second
+	limit := Limit{Burst: 3, Per: 3 * time.Second}
+
+	type step struct {
+		after      time.Duration
+		cost       int
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst then throttled",
+			steps: []step{
+				{cost: 1, allowed: true, remaining: 2, reset: time.Second},
+				{cost: 1, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{cost: 1, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{cost: 1, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+			},
+		},
+		{
+			name: "refills one token per second",
+			steps: []step{
+				{cost: 3, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{after: 500 * time.Millisecond, cost: 1, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 2500 * time.Millisecond},
+				{after: 500 * time.Millisecond, cost: 1, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{after: 2 * time.Second, cost: 1, allowed: true, remaining: 1, reset: 2 * time.Second},
+			},
+		},
+		{
+			name: "refill stops at the burst",
+			steps: []step{
+				{cost: 1, allowed: true, remaining: 2, reset: time.Second},
+				{after: time.Hour, cost: 1, allowed: true, remaining: 2, reset: time.Second},
+			},
+		},
+		{
+			name: "cost zero only peeks",
+			steps: []step{
+				{cost: 0, allowed: true, remaining: 3},
+				{cost: 0, allowed: true, remaining: 3},
+				{cost: 3, allowed: true, remaining: 0, reset: 3 * time.Second},
+				{cost: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+				{after: time.Second, cost: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+			},
+		},
+		{
+			name: "a denied request spends nothing",
+			steps: []step{
+				{cost: 2, allowed: true, remaining: 1, reset: 2 * time.Second},
+				{cost: 2, allowed: false, remaining: 1, retryAfter: time.Second, reset: 2 * time.Second},
+				{cost: 1, allowed: true, remaining: 0, reset: 3 * time.Second},
+			},
+		},
+		{
+			name: "cost above the burst never passes",
+			steps: []step{
+				{cost: 4, allowed: false, remaining: 3, retryAfter: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := &Bucket{Key: "k", Tokens: float64(limit.Burst), UpdatedAt: now}
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				res := b.Take(limit, s.cost, now)
+				if res.Allowed != s.allowed || res.Remaining != s.remaining || res.Limit != limit.Burst {
+					t.Fatalf("step %d: Take() = allowed %v remaining %d limit %d, want %v %d %d",
+						i, res.Allowed, res.Remaining, res.Limit, s.allowed, s.remaining, limit.Burst)
+				}
+				if res.RetryAfter != s.retryAfter {
+					t.Errorf("step %d: RetryAfter = %v, want %v", i, res.RetryAfter, s.retryAfter)
+				}
+				if res.Reset != s.reset {
+					t.Errorf("step %d: Reset = %v, want %v", i, res.Reset, s.reset)
+				}
+				if want := now.Add(s.reset); !b.ExpiresAt.Equal(want) {
+					t.Errorf("step %d: ExpiresAt = %v, want %v", i, b.ExpiresAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceRejectsInvalidLimits(t *testing.T) {
+	svc := NewService(nil)
+	for _, l := range []Limit{{Burst: 0, Per: time.Minute}, {Burst: 1}, {Burst: -1, Per: time.Minute}} {
+		if _, err := svc.Allow("k", l); err != ErrInvalidLimit {
+			t.Errorf("Allow(%+v) error = %v, want %v", l, err, ErrInvalidLimit)
+		}
+		if _, err := svc.Check("k", l); err != ErrInvalidLimit {
+			t.Errorf("Check(%+v) error = %v, want %v", l, err, ErrInvalidLimit)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("rate limit needs a positive burst and period")
+
+// Repository stores buckets. Take must be atomic per key, so replicas
+// sharing a store share the limit.
+type Repository interface {
+	// Take spends cost tokens of key's bucket, see Bucket.Take.
+	Take(key string, limit Limit, cost int, now time.Time) (*Result, error)
+	DeleteExpired(now time.Time) error
+}
+
+type Service interface {
+	// Allow spends one request of key's budget under limit.
+	Allow(key string, limit Limit) (*Result, error)
+	// Check reports whether key has budget left without spending any. With
+	// Allow called only on failures, it turns a limit into a failure count.
+	Check(key string, limit Limit) (*Result, error)
+	Purge() error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Allow(key string, limit Limit) (*Result, error) {
+	return s.take(key, limit, 1)
+}
+
+func (s *service) Check(key string, limit Limit) (*Result, error) {
+	return s.take(key, limit, 0)
+}
+
+func (s *service) take(key string, limit Limit, cost int) (*Result, error) {
+	if limit.Burst <= 0 || limit.Per <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	return s.repo.Take(key, limit, cost, time.Now().UTC())
+}
+
+func (s *service) Purge() error {
+	return s.repo.DeleteExpired(time.Now().UTC())
+}
@@ -0,0 +1,15 @@
+package replay
+
+import "time"
+
+// UsedNonce marks a one-time value, such as a DPoP proof jti or a challenge
+// ID, as spent. Entries only need to live until the value would have been
+// rejected as expired anyway.
+type UsedNonce struct {
+	Key       string    `json:"key" bson:"key" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at" gorm:"index;not null"`
+}
+
+func (UsedNonce) TableName() string {
+	return "used_nonces"
+}
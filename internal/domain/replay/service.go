@@ -0,0 +1,43 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Repository is the pluggable store of spent one-time values.
+type Repository interface {
+	// Add stores n unless an entry with the same key is still live at now,
+	// and reports whether it did. The check and the insert are atomic.
+	Add(n *UsedNonce, now time.Time) (bool, error)
+	DeleteExpired(now time.Time) error
+}
+
+// Service implements security.ReplayCache on top of a Repository, so every
+// replica sees the values spent on the others.
+type Service interface {
+	Remember(key string, expiresAt time.Time) (bool, error)
+	Purge() error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Remember(key string, expiresAt time.Time) (bool, error) {
+	// keys may embed client-chosen values of any length
+	sum := sha256.Sum256([]byte(key))
+	return s.repo.Add(&UsedNonce{
+		Key:       hex.EncodeToString(sum[:]),
+		ExpiresAt: expiresAt.UTC(),
+	}, time.Now().UTC())
+}
+
+func (s *service) Purge() error {
+	return s.repo.DeleteExpired(time.Now().UTC())
+}
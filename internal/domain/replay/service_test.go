@@ -0,0 +1,40 @@
+package replay_test
+
+import (
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/replay"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+)
+
+func TestRememberRejectsLiveKeysAcrossReplicas(t *testing.T) {
+	store := repository.NewMemoryReplayRepository()
+	a, b := replay.NewService(store), replay.NewService(store)
+
+	tests := []struct {
+		name      string
+		svc       replay.Service
+		key       string
+		expiresAt time.Time
+		wantFresh bool
+	}{
+		{"first use", a, "challenge-1", time.Now().Add(time.Minute), true},
+		{"replay on the same replica", a, "challenge-1", time.Now().Add(time.Minute), false},
+		{"replay on another replica", b, "challenge-1", time.Now().Add(time.Minute), false},
+		{"other key", b, "challenge-2", time.Now().Add(-time.Second), true},
+		{"expired entry is taken over", a, "challenge-2", time.Now().Add(time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fresh, err := tt.svc.Remember(tt.key, tt.expiresAt)
+			if err != nil {
+				t.Fatalf("Remember() error = %v", err)
+			}
+			if fresh != tt.wantFresh {
+				t.Errorf("Remember() = %v, want %v", fresh, tt.wantFresh)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package revocation
+
+import "time"
+
+// RevokedToken is a denylisted access token. Entries only need to live until
+// the token would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `json:"jti" bson:"jti" gorm:"primaryKey"`
+	Reason    string    `json:"reason" bson:"reason"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at" gorm:"index;not null"`
+	RevokedAt time.Time `json:"revoked_at" bson:"revoked_at"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -0,0 +1,64 @@
+package revocation
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ReasonLogout = "logout"
+	ReasonAdmin  = "admin"
+)
+
+var (
+	ErrMissingJTI = errors.New("token has no jti")
+)
+
+// Repository is the pluggable denylist store.
+type Repository interface {
+	Add(t *RevokedToken) error
+	// Exists reports whether jti is denylisted and not yet expired at now.
+	Exists(jti string, now time.Time) (bool, error)
+	DeleteExpired(now time.Time) error
+}
+
+type Service interface {
+	Revoke(jti string, expiresAt time.Time, reason string) error
+	IsRevoked(jti string) (bool, error)
+	Purge() error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Revoke(jti string, expiresAt time.Time, reason string) error {
+	if jti == "" {
+		return ErrMissingJTI
+	}
+	// an already expired token cannot be replayed, nothing to store
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+	return s.repo.Add(&RevokedToken{
+		JTI:       jti,
+		Reason:    reason,
+		ExpiresAt: expiresAt.UTC(),
+		RevokedAt: time.Now().UTC(),
+	})
+}
+
+func (s *service) IsRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return s.repo.Exists(jti, time.Now().UTC())
+}
+
+func (s *service) Purge() error {
+	return s.repo.DeleteExpired(time.Now().UTC())
+}
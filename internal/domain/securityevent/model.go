@@ -0,0 +1,44 @@
+package securityevent
+
+import "time"
+
+const (
+	TypeRefreshTokenReuse = "refresh_token_reuse"
+	TypePasswordReset     = "password_reset"
+	TypeMFAEnabled        = "mfa_enabled"
+	TypeMFADisabled       = "mfa_disabled"
+	TypePasskeyAdded      = "passkey_added"
+	TypePasskeyRemoved    = "passkey_removed"
+	// TypePasskeyCloned means a passkey's signature counter went backwards.
+	TypePasskeyCloned          = "passkey_clone_suspected"
+	TypeRecoveryCodesGenerated = "recovery_codes_generated"
+	TypeRecoveryCodeUsed       = "recovery_code_used"
+	TypeAccountRecovered       = "account_recovered"
+	TypeIdentityLinked         = "external_identity_linked"
+	TypeAPIKeyCreated          = "api_key_created"
+	TypeAPIKeyRevoked          = "api_key_revoked"
+	TypeAccountLocked          = "account_locked"
+	TypeAccountUnlocked        = "account_unlocked"
+)
+
+// Event is an append-only record of something security relevant that happened
+// to an account.
+type Event struct {
+	ID        string    `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID    string    `json:"user_id" bson:"user_id" gorm:"index"`
+	SessionID string    `json:"session_id,omitempty" bson:"session_id"`
+	Type      string    `json:"type" bson:"type" gorm:"index;not null"`
+	IPAddress string    `json:"ip_address" bson:"ip_address"`
+	UserAgent string    `json:"user_agent" bson:"user_agent"`
+	Details   string    `json:"details,omitempty" bson:"details"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at" gorm:"index"`
+}
+
+func (Event) TableName() string {
+	return "security_events"
+}
+
+type EventQueryParams struct {
+	UserID *string
+	Type   *string
+}
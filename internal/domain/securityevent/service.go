@@ -0,0 +1,39 @@
+package securityevent
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(e *Event) error
+	List(params *EventQueryParams) ([]*Event, error)
+}
+
+type Service interface {
+	Record(e *Event) error
+	List(params *EventQueryParams) ([]*Event, error)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Record(e *Event) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	return s.repo.Create(e)
+}
+
+func (s *service) List(params *EventQueryParams) ([]*Event, error) {
+	return s.repo.List(params)
+}
@@ -10,8 +10,24 @@ type Session struct {
 	Valid            bool      `json:"valid" bson:"valid" gorm:"default:true"`
 	ExpiresAt        time.Time `json:"expires_at" bson:"expires_at"`
 	RefreshTokenHash string    `json:"-" bson:"refresh_token_hash" gorm:"column:refresh_token_hash"`
-	CreatedAt        time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" bson:"updated_at"`
+	// PreviousRefreshTokenHash is the token rotated away at RefreshRotatedAt.
+	// It is still accepted during the grace window so concurrent refreshes
+	// (e.g. two tabs) are not mistaken for reuse.
+	PreviousRefreshTokenHash string     `json:"-" bson:"previous_refresh_token_hash"`
+	RefreshRotatedAt         *time.Time `json:"refresh_rotated_at,omitempty" bson:"refresh_rotated_at"`
+	// DPoPJKT binds the refresh token family to a client key (RFC 9449).
+	DPoPJKT string `json:"dpop_jkt,omitempty" bson:"dpop_jkt" gorm:"column:dpop_jkt"`
+	// ImpersonatorID is the admin who opened this session on the user's behalf.
+	ImpersonatorID *string `json:"impersonator_id,omitempty" bson:"impersonator_id" gorm:"index"`
+	// ClientID and Scope are set for sessions opened through the OAuth
+	// authorization endpoint; only that client may refresh them.
+	ClientID string `json:"client_id,omitempty" bson:"client_id" gorm:"index"`
+	Scope    string `json:"scope,omitempty" bson:"scope"`
+	// IDTokenClientID is the audience of the ID token issued at a direct
+	// login, so refreshed ID tokens go to the same client.
+	IDTokenClientID string    `json:"id_token_client_id,omitempty" bson:"id_token_client_id"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
 }
 
 func (Session) TableName() string {
@@ -19,6 +35,7 @@ func (Session) TableName() string {
 }
 
 type SessionQueryParams struct {
-	UserID *string
-	Valid  *bool
+	UserID       *string
+	Valid        *bool
+	Impersonated *bool
 }
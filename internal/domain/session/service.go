@@ -1,27 +1,68 @@
 package session
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
 
 var (
 	ErrNotFound = errors.New("session not found")
+	// ErrRotationConflict means another request rotated the refresh token
+	// between our read and our compare-and-swap.
+	ErrRotationConflict = errors.New("refresh token already rotated")
 )
 
+// RefreshTokenState classifies a presented refresh token against its family.
+type RefreshTokenState int
+
+const (
+	// RefreshTokenCurrent is the latest token of the family.
+	RefreshTokenCurrent RefreshTokenState = iota
+	// RefreshTokenGrace is the immediately previous token, presented within
+	// the grace window after it was rotated.
+	RefreshTokenGrace
+	// RefreshTokenReused is any other token of the family: a replay.
+	RefreshTokenReused
+)
+
+// Rotation describes a compare-and-swap of a session's refresh token.
+type Rotation struct {
+	SessionID    string
+	ExpectedHash string
+	NewHash      string
+	ExpiresAt    time.Time
+	IPAddress    string
+	UserAgent    string
+}
+
 type Repository interface {
 	Create(s *Session) error
 	GetByID(id string) (*Session, error)
 	Invalidate(id string) error
+	// InvalidateByUser revokes every valid session of the user and returns
+	// how many were revoked.
+	InvalidateByUser(userID string) (int64, error)
 	Delete(id string) error
 	Update(id string, s *Session) (*Session, error)
 	List(params *SessionQueryParams) ([]*Session, error)
+	// RotateRefreshToken swaps the refresh token hash only if it still equals
+	// r.ExpectedHash, keeping the old hash as the previous one. It reports
+	// whether the swap happened.
+	RotateRefreshToken(r *Rotation) (bool, error)
 }
 
 type Service interface {
 	CreateSession(s *Session) error
 	GetSessionByID(id string) (*Session, error)
 	InvalidateSession(id string) error
+	InvalidateUserSessions(userID string) (int64, error)
 	DeleteSession(id string) error
 	UpdateSession(id string, s *Session) (*Session, error)
 	ListSessions(params *SessionQueryParams) ([]*Session, error)
+	ClassifyRefreshToken(s *Session, token string, grace time.Duration) RefreshTokenState
+	RotateRefreshToken(s *Session, currentToken, nextToken string, expiresAt time.Time, ip, userAgent string) error
 }
 
 type service struct {
@@ -44,6 +85,10 @@ func (s *service) InvalidateSession(id string) error {
 	return s.repo.Invalidate(id)
 }
 
+func (s *service) InvalidateUserSessions(userID string) (int64, error) {
+	return s.repo.InvalidateByUser(userID)
+}
+
 func (s *service) DeleteSession(id string) error {
 	return s.repo.Delete(id)
 }
@@ -55,3 +100,35 @@ func (s *service) UpdateSession(id string, sess *Session) (*Session, error) {
 func (s *service) ListSessions(params *SessionQueryParams) ([]*Session, error) {
 	return s.repo.List(params)
 }
+
+// ClassifyRefreshToken tells a current token from a concurrent-refresh race
+// and from a genuine replay. The session is the refresh token family.
+func (s *service) ClassifyRefreshToken(sess *Session, token string, grace time.Duration) RefreshTokenState {
+	if security.CompareTokenHash(sess.RefreshTokenHash, token) {
+		return RefreshTokenCurrent
+	}
+	if grace > 0 && sess.RefreshRotatedAt != nil &&
+		time.Since(*sess.RefreshRotatedAt) <= grace &&
+		security.CompareTokenHash(sess.PreviousRefreshTokenHash, token) {
+		return RefreshTokenGrace
+	}
+	return RefreshTokenReused
+}
+
+func (s *service) RotateRefreshToken(sess *Session, currentToken, nextToken string, expiresAt time.Time, ip, userAgent string) error {
+	swapped, err := s.repo.RotateRefreshToken(&Rotation{
+		SessionID:    sess.ID,
+		ExpectedHash: security.HashToken(currentToken),
+		NewHash:      security.HashToken(nextToken),
+		ExpiresAt:    expiresAt,
+		IPAddress:    ip,
+		UserAgent:    userAgent,
+	})
+	if err != nil {
+		return err
+	}
+	if !swapped {
+		return ErrRotationConflict
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestClassifyRefreshToken(t *testing.T) {
+	const grace = 30 * time.Second
+	recently := time.Now().Add(-10 * time.Second)
+	longAgo := time.Now().Add(-time.Minute)
+
+	rotated := func(at *time.Time) *Session {
+		return &Session{
+			RefreshTokenHash:         security.HashToken("token-2"),
+			PreviousRefreshTokenHash: security.HashToken("token-1"),
+			RefreshRotatedAt:         at,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		sess  *Session
+		token string
+		grace time.Duration
+		want  RefreshTokenState
+	}{
+		{name: "current token", sess: rotated(&recently), token: "token-2", grace: grace, want: RefreshTokenCurrent},
+		{name: "current token of a fresh session", sess: &Session{RefreshTokenHash: security.HashToken("token-1")}, token: "token-1", grace: grace, want: RefreshTokenCurrent},
+		{name: "previous token within the grace window", sess: rotated(&recently), token: "token-1", grace: grace, want: RefreshTokenGrace},
+		{name: "previous token after the grace window", sess: rotated(&longAgo), token: "token-1", grace: grace, want: RefreshTokenReused},
+		{name: "previous token with grace disabled", sess: rotated(&recently), token: "token-1", grace: 0, want: RefreshTokenReused},
+		{name: "older token of the family", sess: rotated(&recently), token: "token-0", grace: grace, want: RefreshTokenReused},
+		{name: "never rotated", sess: &Session{RefreshTokenHash: security.HashToken("token-1")}, token: "token-0", grace: grace, want: RefreshTokenReused},
+		{name: "empty token", sess: rotated(&recently), token: "", grace: grace, want: RefreshTokenReused},
+	}
+
+	svc := NewService(newMemoryRepo())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.ClassifyRefreshToken(tt.sess, tt.token, tt.grace); got != tt.want {
+				t.Errorf("ClassifyRefreshToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		invalid bool
+		present string
+		wantErr error
+	}{
+		{name: "rotates the current token", present: "token-1"},
+		{name: "stale token loses", present: "token-0", wantErr: ErrRotationConflict},
+		{name: "revoked session", invalid: true, present: "token-1", wantErr: ErrRotationConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepo()
+			svc := NewService(repo)
+			sess := &Session{ID: "s1", Valid: !tt.invalid, RefreshTokenHash: security.HashToken("token-1")}
+			if err := svc.CreateSession(sess); err != nil {
+				t.Fatal(err)
+			}
+			expiresAt := time.Now().Add(time.Hour).UTC()
+
+			err := svc.RotateRefreshToken(sess, tt.present, "token-2", expiresAt, "203.0.113.7", "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken() error = %v, want %v", err, tt.wantErr)
+			}
+			got := repo.sessions["s1"]
+			if tt.wantErr != nil {
+				if !security.CompareTokenHash(got.RefreshTokenHash, "token-1") || got.RefreshRotatedAt != nil {
+					t.Fatal("a failed rotation changed the session")
+				}
+				return
+			}
+			if !security.CompareTokenHash(got.RefreshTokenHash, "token-2") ||
+				!security.CompareTokenHash(got.PreviousRefreshTokenHash, "token-1") {
+				t.Error("rotation did not move the current token to previous")
+			}
+			if got.RefreshRotatedAt == nil || !got.ExpiresAt.Equal(expiresAt) || got.IPAddress != "203.0.113.7" {
+				t.Errorf("rotated session = %+v", got)
+			}
+			if state := svc.ClassifyRefreshToken(got, "token-1", time.Minute); state != RefreshTokenGrace {
+				t.Errorf("rotated away token = %v, want %v", state, RefreshTokenGrace)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshTokenConcurrently(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	sess := &Session{ID: "s1", Valid: true, RefreshTokenHash: security.HashToken("token-1")}
+	if err := svc.CreateSession(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	const racers = 16
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		winners   []string
+		conflicts int
+	)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(next string) {
+			defer wg.Done()
+			err := svc.RotateRefreshToken(sess, "token-1", next, time.Now().Add(time.Hour), "", "")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				winners = append(winners, next)
+			case errors.Is(err, ErrRotationConflict):
+				conflicts++
+			default:
+				t.Errorf("RotateRefreshToken() error = %v", err)
+			}
+		}(fmt.Sprintf("token-2-%d", i))
+	}
+	wg.Wait()
+
+	if len(winners) != 1 || conflicts != racers-1 {
+		t.Fatalf("%d rotations won and %d conflicted, want 1 and %d", len(winners), conflicts, racers-1)
+	}
+	if !security.CompareTokenHash(repo.sessions["s1"].RefreshTokenHash, winners[0]) {
+		t.Error("stored token is not the winner's")
+	}
+}
+
+// memoryRepo is a Repository whose rotation is a compare-and-swap under a
+// lock, like the conditional UPDATE in the database.
+type memoryRepo struct {
+	Repository
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{sessions: map[string]*Session{}}
+}
+
+func (m *memoryRepo) Create(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *s
+	m.sessions[s.ID] = &stored
+	return nil
+}
+
+func (m *memoryRepo) RotateRefreshToken(r *Rotation) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[r.SessionID]
+	if !ok || !s.Valid || s.RefreshTokenHash != r.ExpectedHash {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	s.PreviousRefreshTokenHash, s.RefreshTokenHash = s.RefreshTokenHash, r.NewHash
+	s.RefreshRotatedAt = &now
+	s.ExpiresAt = r.ExpiresAt
+	s.IPAddress, s.UserAgent = r.IPAddress, r.UserAgent
+	return true, nil
+}
@@ -0,0 +1,31 @@
+package signingkey
+
+import "time"
+
+const (
+	PurposeAccess  = "access"
+	PurposeRefresh = "refresh"
+)
+
+// Key is a persisted JWT signing key. Material holds the (optionally encrypted)
+// HMAC secret or PKCS#8 PEM private key. Retired keys keep verifying tokens
+// until VerifyUntil and are then pruned.
+type Key struct {
+	ID        string `json:"kid" bson:"id" gorm:"primaryKey"`
+	Purpose   string `json:"purpose" bson:"purpose" gorm:"index;not null"`
+	Algorithm string `json:"alg" bson:"algorithm" gorm:"not null"`
+	Material  []byte `json:"-" bson:"material" gorm:"not null"`
+	Active    bool   `json:"active" bson:"active" gorm:"default:false"`
+	// Configured marks keys imported from JWT_SECRET, JWT_REFRESH_SECRET or
+	// JWT_PRIVATE_KEY_FILE rather than generated by a rotation. The newest
+	// one is kept after it expires, so a changed setting can be told apart
+	// from a rotated-away key.
+	Configured  bool       `json:"configured" bson:"configured" gorm:"not null;default:false"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty" bson:"retired_at"`
+	VerifyUntil *time.Time `json:"verify_until,omitempty" bson:"verify_until"`
+}
+
+func (Key) TableName() string {
+	return "signing_keys"
+}
@@ -0,0 +1,25 @@
+package signingkey
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrActiveKeyChanged is returned by Activate when another replica changed
+// the active key first. Reload and decide again.
+var ErrActiveKeyChanged = errors.New("active signing key changed concurrently")
+
+// Repository persists signing keys so every replica shares the same keyring.
+type Repository interface {
+	ListByPurpose(purpose string) ([]*Key, error)
+	// Activate stores k as the single active key for its purpose and retires
+	// the previously active key, keeping it verifiable until verifyUntil.
+	// replaces is the id of the key the caller saw active, empty if none;
+	// when that is no longer the active key nothing changes and
+	// ErrActiveKeyChanged is returned, so concurrent rotations on several
+	// replicas produce one new key.
+	Activate(k *Key, replaces string, verifyUntil time.Time) error
+	// DeleteExpired removes retired keys past their window, except the
+	// newest configured key of each purpose.
+	DeleteExpired(now time.Time) error
+}
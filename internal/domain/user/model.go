@@ -1,6 +1,9 @@
 package user
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Role string
 
@@ -10,13 +13,23 @@ const (
 )
 
 type User struct {
-	ID           string    `json:"id" bson:"id"`
-	Username     string    `json:"username" bson:"username"`
-	Fullname     string    `json:"fullname" bson:"fullname"`
-	Email        string    `json:"email" bson:"email"`
-	PasswordHash string    `json:"-" bson:"password_hash"`
-	Avatar       *string   `json:"avatar" bson:"avatar"`
-	Role         Role      `json:"role" bson:"role"`
-	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+	ID           string  `json:"id" bson:"id"`
+	Username     string  `json:"username" bson:"username"`
+	Fullname     string  `json:"fullname" bson:"fullname"`
+	Email        string  `json:"email" bson:"email"`
+	PasswordHash string  `json:"-" bson:"password_hash"`
+	Avatar       *string `json:"avatar" bson:"avatar"`
+	Role         Role    `json:"role" bson:"role"`
+	// EmailVerified is set once the user followed the link mailed to them.
+	EmailVerified   bool       `json:"email_verified" bson:"email_verified" gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" bson:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// NormalizeEmail is the form addresses are stored and looked up in. Mail
+// providers treat the local part case-insensitively in practice, so
+// addresses differing only in case are one account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
@@ -6,6 +6,9 @@ type CreateUserRequest struct {
 	Email    string `json:"email" bson:"email"`
 	Password string `json:"password" bson:"password"`
 	Role     string `json:"role" bson:"role"`
+	// Generated marks a random password nobody will type, which the password
+	// policy does not apply to.
+	Generated bool `json:"-" bson:"-"`
 }
 
 type UpdateUserRequest struct {
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"mikhailjbs/user-auth-service/internal/domain/password"
 	"mikhailjbs/user-auth-service/internal/infra/security"
 
 	"github.com/google/uuid"
@@ -22,6 +23,12 @@ type Repository interface {
 	Get(id string) (*User, error)
 	Delete(id string) error
 	Update(id string, u *User) (*User, error)
+	// SwapPasswordHash replaces the hash only while it still equals old,
+	// reporting whether it did.
+	SwapPasswordHash(id, old, new string) (bool, error)
+	// ClearEmailVerified unsets email_verified and email_verified_at, which
+	// Update skips as zero values.
+	ClearEmailVerified(id string) error
 }
 
 // Service defines the interface for user domain logic
@@ -31,18 +38,36 @@ type Service interface {
 	Get(id string) (*User, error)
 	Delete(id string) error
 	Update(id string, u *User) (*User, error)
+	GetByEmail(email string) (*User, error)
+	MarkEmailVerified(id string) (*User, error)
+	// MarkEmailUnverified is called when the address changes; the new one
+	// has to be verified again.
+	MarkEmailUnverified(id string) (*User, error)
+	// CheckPassword applies the password policy to a new password for u.
+	CheckPassword(plain string, u *User) error
+	// HashPassword hashes a new password with the configured algorithm.
+	HashPassword(plain string) (string, error)
 }
 
 type service struct {
-	repo Repository
+	repo      Repository
+	passwords password.Policy
+	hasher    security.PasswordHasher
 }
 
-func NewService(r Repository) Service {
-	return &service{repo: r}
+func NewService(r Repository, passwords password.Policy, hasher security.PasswordHasher) Service {
+	return &service{repo: r, passwords: passwords, hasher: hasher}
 }
 
 func (s *service) Create(u *CreateUserRequest) (*User, error) {
-	existing, err := s.repo.GetByEmail(u.Email)
+	email := NormalizeEmail(u.Email)
+	if !u.Generated {
+		if err := s.passwords.Check(u.Password, u.Username, email); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := s.repo.GetByEmail(email)
 	if err != nil {
 		return nil, err
 	}
@@ -52,14 +77,14 @@ func (s *service) Create(u *CreateUserRequest) (*User, error) {
 	}
 
 	// Hash the password before storing
-	hashedPassword, err := security.HashPassword(u.Password)
+	hashedPassword, err := s.hasher.Hash(u.Password)
 	if err != nil {
 		return nil, err
 	}
 
 	newUser := &User{
 		ID:           uuid.New().String(),
-		Email:        u.Email,
+		Email:        email,
 		PasswordHash: hashedPassword,
 		Fullname:     u.Fullname,
 		Username:     u.Username,
@@ -86,3 +111,28 @@ func (s *service) Delete(id string) error {
 func (s *service) Update(id string, u *User) (*User, error) {
 	return s.repo.Update(id, u)
 }
+
+// GetByEmail returns nil without an error when no user has the address.
+func (s *service) GetByEmail(email string) (*User, error) {
+	return s.repo.GetByEmail(email)
+}
+
+func (s *service) MarkEmailVerified(id string) (*User, error) {
+	now := time.Now()
+	return s.repo.Update(id, &User{EmailVerified: true, EmailVerifiedAt: &now, UpdatedAt: now})
+}
+
+func (s *service) MarkEmailUnverified(id string) (*User, error) {
+	if err := s.repo.ClearEmailVerified(id); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(id)
+}
+
+func (s *service) CheckPassword(plain string, u *User) error {
+	return s.passwords.Check(plain, u.Username, u.Email)
+}
+
+func (s *service) HashPassword(plain string) (string, error) {
+	return s.hasher.Hash(plain)
+}
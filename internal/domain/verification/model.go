@@ -0,0 +1,28 @@
+package verification
+
+import "time"
+
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	PurposeMagicLink         = "magic_link"
+)
+
+// Token is a single-use secret mailed to a user. Only the hash of the secret
+// is stored, like refresh tokens.
+type Token struct {
+	ID        string `json:"id" bson:"id" gorm:"primaryKey;type:uuid"`
+	UserID    string `json:"user_id" bson:"user_id" gorm:"index;not null"`
+	Purpose   string `json:"purpose" bson:"purpose" gorm:"index;not null"`
+	TokenHash string `json:"-" bson:"token_hash" gorm:"uniqueIndex;not null"`
+	// BindingHash, when set, ties the token to a secret held by the browser
+	// that asked for it.
+	BindingHash string     `json:"-" bson:"binding_hash"`
+	ExpiresAt   time.Time  `json:"expires_at" bson:"expires_at" gorm:"index;not null"`
+	UsedAt      *time.Time `json:"used_at,omitempty" bson:"used_at"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+}
+
+func (Token) TableName() string {
+	return "verification_tokens"
+}
@@ -0,0 +1,159 @@
+package verification
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or already used token")
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrWrongBinding is returned for a bound token presented without its
+	// binding secret. The token stays usable.
+	ErrWrongBinding = errors.New("link must be opened in the browser that requested it")
+)
+
+// ThrottleWindow is the longest window IssuedSince may look back over.
+// Purge keeps every token at least this long after it was issued, even
+// when it expired sooner, so throttles keep counting it.
+const ThrottleWindow = time.Hour
+
+type Repository interface {
+	Create(t *Token) error
+	GetByHash(hash string) (*Token, error)
+	// MarkUsed flags the token as used unless it already was, reporting
+	// whether this call won.
+	MarkUsed(id string, now time.Time) (bool, error)
+	// InvalidateForUser burns the user's outstanding tokens for purpose.
+	// They are kept until purged so CountIssuedSince still sees them.
+	InvalidateForUser(userID, purpose string, now time.Time) error
+	CountIssuedSince(userID, purpose string, since time.Time) (int64, error)
+	// DeleteExpired removes tokens expired by now and issued before
+	// issuedBefore.
+	DeleteExpired(now, issuedBefore time.Time) error
+}
+
+type Service interface {
+	// Issue creates a token for purpose, replacing any outstanding one, and
+	// returns the raw secret to mail out.
+	Issue(userID, purpose string, ttl time.Duration) (string, error)
+	// IssueBound is Issue for a token that can only be consumed together with
+	// binding.
+	IssueBound(userID, purpose string, ttl time.Duration, binding string) (string, error)
+	// Peek validates raw for purpose without burning it, for checks that
+	// must pass before the token is spent.
+	Peek(raw, purpose string) (*Token, error)
+	// Consume validates raw for purpose and burns it.
+	Consume(raw, purpose string) (*Token, error)
+	// ConsumeBound is Consume for tokens issued with IssueBound. The binding
+	// is checked before the token is burned.
+	ConsumeBound(raw, purpose, binding string) (*Token, error)
+	// IssuedSince counts tokens issued to the user for purpose since a point
+	// in time, for throttling. since must be within ThrottleWindow of now.
+	IssuedSince(userID, purpose string, since time.Time) (int64, error)
+	Purge() error
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{repo: r}
+}
+
+func (s *service) Issue(userID, purpose string, ttl time.Duration) (string, error) {
+	return s.IssueBound(userID, purpose, ttl, "")
+}
+
+func (s *service) IssueBound(userID, purpose string, ttl time.Duration, binding string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+
+	// only the newest link works
+	now := time.Now().UTC()
+	if err := s.repo.InvalidateForUser(userID, purpose, now); err != nil {
+		return "", err
+	}
+
+	t := &Token{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: security.HashToken(raw),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if binding != "" {
+		t.BindingHash = security.HashToken(binding)
+	}
+	if err := s.repo.Create(t); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (s *service) Consume(raw, purpose string) (*Token, error) {
+	return s.ConsumeBound(raw, purpose, "")
+}
+
+func (s *service) Peek(raw, purpose string) (*Token, error) {
+	return s.lookup(raw, purpose, time.Now().UTC())
+}
+
+func (s *service) ConsumeBound(raw, purpose, binding string) (*Token, error) {
+	now := time.Now().UTC()
+	t, err := s.lookup(raw, purpose, now)
+	if err != nil {
+		return nil, err
+	}
+	if t.BindingHash != "" && !security.CompareTokenHash(t.BindingHash, binding) {
+		return nil, ErrWrongBinding
+	}
+
+	won, err := s.repo.MarkUsed(t.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !won {
+		return nil, ErrInvalidToken
+	}
+	t.UsedAt = &now
+	return t, nil
+}
+
+// lookup returns the unused, unexpired token for raw and purpose.
+func (s *service) lookup(raw, purpose string, now time.Time) (*Token, error) {
+	if raw == "" {
+		return nil, ErrInvalidToken
+	}
+	t, err := s.repo.GetByHash(security.HashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.Purpose != purpose || t.UsedAt != nil {
+		return nil, ErrInvalidToken
+	}
+	if now.After(t.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	return t, nil
+}
+
+func (s *service) IssuedSince(userID, purpose string, since time.Time) (int64, error) {
+	return s.repo.CountIssuedSince(userID, purpose, since)
+}
+
+func (s *service) Purge() error {
+	now := time.Now().UTC()
+	return s.repo.DeleteExpired(now, now.Add(-ThrottleWindow))
+}
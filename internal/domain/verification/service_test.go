@@ -0,0 +1,227 @@
+package verification
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsume(t *testing.T) {
+	tests := []struct {
+		name    string
+		issue   func(s Service) string
+		raw     func(raw string) string
+		purpose string
+		binding string
+		wantErr error
+	}{
+		{name: "valid", issue: issue(time.Hour, "")},
+		{name: "unknown token", issue: issue(time.Hour, ""), raw: func(string) string { return "nope" }, wantErr: ErrInvalidToken},
+		{name: "empty token", issue: issue(time.Hour, ""), raw: func(string) string { return "" }, wantErr: ErrInvalidToken},
+		{name: "other purpose", issue: issue(time.Hour, ""), purpose: PurposePasswordReset, wantErr: ErrInvalidToken},
+		{name: "expired", issue: issue(-time.Second, ""), wantErr: ErrTokenExpired},
+		{name: "bound", issue: issue(time.Hour, "browser-secret"), binding: "browser-secret"},
+		{name: "bound without binding", issue: issue(time.Hour, "browser-secret"), wantErr: ErrWrongBinding},
+		{name: "bound with another binding", issue: issue(time.Hour, "browser-secret"), binding: "other", wantErr: ErrWrongBinding},
+		{name: "unbound ignores binding", issue: issue(time.Hour, ""), binding: "anything"},
+		{name: "replaced by a newer token", issue: func(s Service) string {
+			raw := issue(time.Hour, "")(s)
+			issue(time.Hour, "")(s)
+			return raw
+		}, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(newMemoryRepo())
+			raw := tt.issue(svc)
+			if tt.raw != nil {
+				raw = tt.raw(raw)
+			}
+			purpose := tt.purpose
+			if purpose == "" {
+				purpose = PurposeMagicLink
+			}
+
+			tok, err := svc.ConsumeBound(raw, purpose, tt.binding)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ConsumeBound() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tok.UserID != "user-1" || tok.UsedAt == nil {
+				t.Errorf("ConsumeBound() = %+v", tok)
+			}
+			if _, err := svc.ConsumeBound(raw, purpose, tt.binding); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("second ConsumeBound() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestWrongBindingKeepsToken(t *testing.T) {
+	svc := NewService(newMemoryRepo())
+	raw := issue(time.Hour, "browser-secret")(svc)
+
+	if _, err := svc.ConsumeBound(raw, PurposeMagicLink, ""); !errors.Is(err, ErrWrongBinding) {
+		t.Fatalf("ConsumeBound() error = %v, want %v", err, ErrWrongBinding)
+	}
+	if _, err := svc.ConsumeBound(raw, PurposeMagicLink, "browser-secret"); err != nil {
+		t.Fatalf("ConsumeBound() with the binding error = %v", err)
+	}
+}
+
+func TestPeekDoesNotBurn(t *testing.T) {
+	svc := NewService(newMemoryRepo())
+	raw := issue(time.Hour, "")(svc)
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Peek(raw, PurposeMagicLink); err != nil {
+			t.Fatalf("Peek() error = %v", err)
+		}
+	}
+	if _, err := svc.Consume(raw, PurposeMagicLink); err != nil {
+		t.Fatalf("Consume() after Peek error = %v", err)
+	}
+	if _, err := svc.Peek(raw, PurposeMagicLink); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Peek() after Consume error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestConcurrentConsumeHasOneWinner(t *testing.T) {
+	svc := NewService(newMemoryRepo())
+	raw := issue(time.Hour, "")(svc)
+
+	const racers = 16
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		won int
+	)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Consume(raw, PurposeMagicLink); err == nil {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if won != 1 {
+		t.Fatalf("%d consumers won, want 1", won)
+	}
+}
+
+func TestIssuedSinceCountsReplacedTokens(t *testing.T) {
+	repo := newMemoryRepo()
+	svc := NewService(repo)
+	since := time.Now().Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		issue(time.Hour, "")(svc)
+	}
+	if n, err := svc.IssuedSince("user-1", PurposeMagicLink, since); err != nil || n != 3 {
+		t.Fatalf("IssuedSince() = %d, %v, want 3", n, err)
+	}
+
+	// expired tokens survive Purge while a throttle could still count them
+	repo.mu.Lock()
+	for _, tok := range repo.tokens {
+		tok.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	repo.mu.Unlock()
+	if err := svc.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := svc.IssuedSince("user-1", PurposeMagicLink, since); n != 3 {
+		t.Fatalf("IssuedSince() after Purge = %d, want 3", n)
+	}
+}
+
+func issue(ttl time.Duration, binding string) func(s Service) string {
+	return func(s Service) string {
+		raw, err := s.IssueBound("user-1", PurposeMagicLink, ttl, binding)
+		if err != nil {
+			panic(err)
+		}
+		return raw
+	}
+}
+
+type memoryRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*Token
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{tokens: map[string]*Token{}}
+}
+
+func (m *memoryRepo) Create(t *Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *t
+	m.tokens[t.ID] = &c
+	return nil
+}
+
+func (m *memoryRepo) GetByHash(hash string) (*Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.TokenHash == hash {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRepo) MarkUsed(id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &now
+	return true, nil
+}
+
+func (m *memoryRepo) InvalidateForUser(userID, purpose string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepo) CountIssuedSince(userID, purpose string, since time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && !t.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryRepo) DeleteExpired(now, issuedBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range m.tokens {
+		if t.ExpiresAt.Before(now) && t.CreatedAt.Before(issuedBefore) {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
@@ -9,10 +9,21 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
-func NewServer() *fiber.App {
-	app := fiber.New(fiber.Config{
+// NewServer creates the Fiber app. When trustedProxies is set, c.IP() reads
+// the client address from proxyHeader on requests from those proxies only;
+// otherwise it is always the peer address, so a client cannot pick its own
+// rate limit key. The proxy must overwrite proxyHeader rather than append
+// to it, because the first address in the header is used.
+func NewServer(trustedProxies []string, proxyHeader string) *fiber.App {
+	cfg := fiber.Config{
 		AppName: "User Auth Service",
-	})
+	}
+	if len(trustedProxies) > 0 {
+		cfg.EnableTrustedProxyCheck = true
+		cfg.TrustedProxies = trustedProxies
+		cfg.ProxyHeader = proxyHeader
+	}
+	app := fiber.New(cfg)
 
 	app.Use(cors.New())
 	app.Use(logger.New())
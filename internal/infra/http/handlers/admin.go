@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// AdminHandler exposes operational endpoints reserved for administrators.
+type AdminHandler interface {
+	ListSigningKeys(c *fiber.Ctx) error
+	RotateSigningKeys(c *fiber.Ctx) error
+	RevokeToken(c *fiber.Ctx) error
+	RevokeSession(c *fiber.Ctx) error
+	ListImpersonations(c *fiber.Ctx) error
+	ListSecurityEvents(c *fiber.Ctx) error
+	RegisterOAuthClient(c *fiber.Ctx) error
+	ListOAuthClients(c *fiber.Ctx) error
+	DeleteOAuthClient(c *fiber.Ctx) error
+	GetLockout(c *fiber.Ctx) error
+	Unlock(c *fiber.Ctx) error
+}
+
+type adminHandler struct {
+	tokenManager   *security.TokenManager
+	revocations    revocation.Service
+	sessionService session.Service
+	securityEvents securityevent.Service
+	oauthService   oauth.Service
+	userService    user.Service
+	lockouts       lockout.Service
+}
+
+func NewAdminHandler(
+	tokenManager *security.TokenManager,
+	revocations revocation.Service,
+	sessionService session.Service,
+	securityEvents securityevent.Service,
+	oauthService oauth.Service,
+	userService user.Service,
+	lockouts lockout.Service,
+) AdminHandler {
+	return &adminHandler{
+		tokenManager:   tokenManager,
+		revocations:    revocations,
+		sessionService: sessionService,
+		securityEvents: securityEvents,
+		oauthService:   oauthService,
+		userService:    userService,
+		lockouts:       lockouts,
+	}
+}
+
+type revokeTokenRequest struct {
+	Token     string     `json:"token"`
+	JTI       string     `json:"jti"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (h *adminHandler) ListSigningKeys(c *fiber.Ctx) error {
+	return SendSuccess(c, fiber.StatusOK, "signing keys retrieved", h.tokenManager.Keys())
+}
+
+// RotateSigningKeys rotates the keyring named by ?purpose=access|refresh, or
+// both when omitted. Retired keys keep verifying until their tokens expire.
+func (h *adminHandler) RotateSigningKeys(c *fiber.Ctx) error {
+	purpose := c.Query("purpose")
+	if purpose != "" && purpose != signingkey.PurposeAccess && purpose != signingkey.PurposeRefresh {
+		return SendError(c, fiber.StatusBadRequest, "purpose must be access or refresh")
+	}
+
+	if err := h.tokenManager.RotateKeys(purpose); err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to rotate signing keys")
+	}
+
+	return SendSuccess(c, fiber.StatusOK, "signing keys rotated", h.tokenManager.Keys())
+}
+
+// RevokeToken denylists an access token, identified either by the token itself
+// or by its jti. Without an expiry the jti is denylisted for a full access TTL.
+func (h *adminHandler) RevokeToken(c *fiber.Ctx) error {
+	var req revokeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	jti := req.JTI
+	expiresAt := time.Now().Add(h.tokenManager.AccessTTL())
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if req.Token != "" {
+		claims, err := h.tokenManager.ParseAccessToken(req.Token)
+		if err != nil {
+			return SendError(c, fiber.StatusBadRequest, "token is invalid or already expired")
+		}
+		jti = claims.JTI
+		expiresAt = claims.Expiry
+	}
+	if jti == "" {
+		return SendError(c, fiber.StatusBadRequest, "token or jti is required")
+	}
+
+	if err := h.revocations.Revoke(jti, expiresAt, revocation.ReasonAdmin); err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to revoke token")
+	}
+
+	return SendSuccess(c, fiber.StatusOK, "token revoked", map[string]interface{}{
+		"jti":        jti,
+		"expires_at": expiresAt,
+	})
+}
+
+// RevokeSession invalidates a session. Its refresh token stops working at once
+// and access tokens stop working on routes that require a live session.
+func (h *adminHandler) RevokeSession(c *fiber.Ctx) error {
+	id := c.Params("id")
+	sess, err := h.sessionService.GetSessionByID(id)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to lookup session")
+	}
+	if sess == nil {
+		return SendError(c, fiber.StatusNotFound, session.ErrNotFound.Error())
+	}
+
+	if err := h.sessionService.InvalidateSession(id); err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to revoke session")
+	}
+	return SendSuccess(c, fiber.StatusOK, "session revoked", nil)
+}
+
+// ListImpersonations lists currently valid impersonation sessions so they can
+// be audited and revoked individually.
+func (h *adminHandler) ListImpersonations(c *fiber.Ctx) error {
+	impersonated, valid := true, true
+	sessions, err := h.sessionService.ListSessions(&session.SessionQueryParams{
+		Impersonated: &impersonated,
+		Valid:        &valid,
+	})
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to list impersonation sessions")
+	}
+	return SendSuccess(c, fiber.StatusOK, "impersonation sessions retrieved", sessions)
+}
+
+func (h *adminHandler) ListSecurityEvents(c *fiber.Ctx) error {
+	params := &securityevent.EventQueryParams{}
+	if userID := c.Query("user_id"); userID != "" {
+		params.UserID = &userID
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		params.Type = &eventType
+	}
+
+	events, err := h.securityEvents.List(params)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to list security events")
+	}
+	return SendSuccess(c, fiber.StatusOK, "security events retrieved", events)
+}
+
+// RegisterOAuthClient registers an application with the authorization
+// server. The client secret of a confidential client is only returned here.
+func (h *adminHandler) RegisterOAuthClient(c *fiber.Ctx) error {
+	var req oauth.RegisterClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	client, err := h.oauthService.RegisterClient(&req)
+	if err != nil {
+		switch {
+		case errors.Is(err, oauth.ErrInvalidRequest),
+			errors.Is(err, oauth.ErrInvalidRedirectURI),
+			errors.Is(err, oauth.ErrInvalidScope):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, "failed to register client")
+		}
+	}
+	return SendSuccess(c, fiber.StatusCreated, "client registered", client)
+}
+
+func (h *adminHandler) ListOAuthClients(c *fiber.Ctx) error {
+	clients, err := h.oauthService.ListClients()
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to list clients")
+	}
+	return SendSuccess(c, fiber.StatusOK, "clients retrieved", clients)
+}
+
+// DeleteOAuthClient removes a client. Tokens already issued to it keep
+// working until they expire, but can no longer be refreshed.
+func (h *adminHandler) DeleteOAuthClient(c *fiber.Ctx) error {
+	if err := h.oauthService.DeleteClient(c.Params("id")); err != nil {
+		if errors.Is(err, oauth.ErrClientNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, "failed to delete client")
+	}
+	return SendSuccess(c, fiber.StatusOK, "client deleted", nil)
+}
+
+// GetLockout shows whether a user's password login is locked after failed
+// attempts.
+func (h *adminHandler) GetLockout(c *fiber.Ctx) error {
+	u, err := h.userService.Get(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, "failed to lookup user")
+	}
+
+	status, err := h.lockouts.Status(u.Email)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to lookup lock status")
+	}
+	return SendSuccess(c, fiber.StatusOK, "lock status retrieved", status)
+}
+
+// Unlock clears a user's failed login attempts, lifting any lock.
+func (h *adminHandler) Unlock(c *fiber.Ctx) error {
+	u, err := h.userService.Get(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, "failed to lookup user")
+	}
+
+	if err := h.lockouts.Reset(u.Email); err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to unlock account")
+	}
+
+	event := &securityevent.Event{
+		UserID:    u.ID,
+		Type:      securityevent.TypeAccountUnlocked,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+	if claims, ok := middleware.ClaimsFromContext(c); ok {
+		event.Details = "unlocked by " + claims.UserID
+	}
+	if err := h.securityEvents.Record(event); err != nil {
+		logger.Log.WithError(err).Error("failed to record account unlock")
+	}
+	return SendSuccess(c, fiber.StatusOK, "account unlocked", nil)
+}
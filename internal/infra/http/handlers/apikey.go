@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+	apikeyusecase "mikhailjbs/user-auth-service/internal/usecase/apikey"
+)
+
+// APIKeyHandler lets a signed-in user manage personal API keys for scripts
+// and CI jobs.
+type APIKeyHandler interface {
+	CreateKey(c *fiber.Ctx) error
+	ListKeys(c *fiber.Ctx) error
+	RevokeKey(c *fiber.Ctx) error
+}
+
+type apiKeyHandler struct {
+	createKeyUC apikeyusecase.CreateKeyUseCase
+	listKeysUC  apikeyusecase.ListKeysUseCase
+	revokeKeyUC apikeyusecase.RevokeKeyUseCase
+}
+
+func NewAPIKeyHandler(
+	createKeyUC apikeyusecase.CreateKeyUseCase,
+	listKeysUC apikeyusecase.ListKeysUseCase,
+	revokeKeyUC apikeyusecase.RevokeKeyUseCase,
+) APIKeyHandler {
+	return &apiKeyHandler{
+		createKeyUC: createKeyUC,
+		listKeysUC:  listKeysUC,
+		revokeKeyUC: revokeKeyUC,
+	}
+}
+
+func (h *apiKeyHandler) CreateKey(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+	var req apikey.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	created, err := h.createKeyUC.Execute(c.Context(), claims.UserID, claims.Roles, &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidName), errors.Is(err, apikey.ErrInvalidScope), errors.Is(err, apikey.ErrExpiry):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, apikey.ErrTooManyKeys):
+			return SendError(c, fiber.StatusConflict, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+	return SendSuccess(c, fiber.StatusCreated, "store this key somewhere safe, it is not shown again", created)
+}
+
+func (h *apiKeyHandler) ListKeys(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	keys, err := h.listKeysUC.Execute(c.Context(), claims.UserID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	return SendSuccess(c, fiber.StatusOK, "api keys retrieved", keys)
+}
+
+func (h *apiKeyHandler) RevokeKey(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	if err := h.revokeKeyUC.Execute(c.Context(), claims.UserID, c.Params("id"), c.IP(), c.Get("User-Agent")); err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	return SendSuccess(c, fiber.StatusOK, "api key revoked", nil)
+}
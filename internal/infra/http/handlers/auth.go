@@ -2,23 +2,80 @@ package handlers
 
 import (
 	"errors"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/password"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
 	"mikhailjbs/user-auth-service/internal/domain/session"
 	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/idp"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
 	"mikhailjbs/user-auth-service/internal/infra/security"
 	authusecase "mikhailjbs/user-auth-service/internal/usecase/auth"
+	identityusecase "mikhailjbs/user-auth-service/internal/usecase/identity"
+	passkeyusecase "mikhailjbs/user-auth-service/internal/usecase/passkey"
 )
 
 const (
 	accessTokenCookieName  = "access_token"
 	refreshTokenCookieName = "refresh_token"
+	// magicLinkCookieName holds the secret binding a magic link to the
+	// browser that asked for it.
+	magicLinkCookieName = "magic_link_binding"
+	// externalFlowCookieName holds the flow token of a sign-in with an
+	// external identity provider while the user is away at the provider.
+	externalFlowCookieName = "idp_flow"
+	// mfaChallengeTTL is how long the user has to enter their second factor.
+	mfaChallengeTTL = 5 * time.Minute
 )
 
+// loginContext is what a login asked for. It is carried through the MFA
+// challenge so the second step issues exactly the same tokens.
+type loginContext struct {
+	Audience  string
+	ClientID  string
+	Nonce     string
+	JKT       string
+	IPAddress string
+	UserAgent string
+}
+
+func (l loginContext) challengeData() map[string]string {
+	return map[string]string{
+		"aud":       l.Audience,
+		"client_id": l.ClientID,
+		"nonce":     l.Nonce,
+		"jkt":       l.JKT,
+		"ip":        l.IPAddress,
+		"ua":        l.UserAgent,
+	}
+}
+
+func loginContextFromChallenge(ch *security.Challenge) loginContext {
+	return loginContext{
+		Audience:  ch.Data["aud"],
+		ClientID:  ch.Data["client_id"],
+		Nonce:     ch.Data["nonce"],
+		JKT:       ch.Data["jkt"],
+		IPAddress: ch.Data["ip"],
+		UserAgent: ch.Data["ua"],
+	}
+}
+
 // AuthHandler exposes HTTP endpoints for authentication workflows.
 type AuthHandler interface {
 	Register(c *fiber.Ctx) error
@@ -26,32 +83,111 @@ type AuthHandler interface {
 	Refresh(c *fiber.Ctx) error
 	Logout(c *fiber.Ctx) error
 	Me(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerification(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	VerifyMFA(c *fiber.Ctx) error
+	PasskeyLoginBegin(c *fiber.Ctx) error
+	PasskeyLoginFinish(c *fiber.Ctx) error
+	RecoveryLogin(c *fiber.Ctx) error
+	CompleteRecovery(c *fiber.Ctx) error
+	RequestMagicLink(c *fiber.Ctx) error
+	ConsumeMagicLink(c *fiber.Ctx) error
+	ExternalLoginStart(c *fiber.Ctx) error
+	ExternalLoginCallback(c *fiber.Ctx) error
 }
 
 type authHandler struct {
 	registerUC     authusecase.RegisterUseCase
 	loginUC        authusecase.LoginUseCase
 	meUC           authusecase.GetMeUseCase
+	verifyEmailUC  authusecase.VerifyEmailUseCase
+	resendUC       authusecase.ResendVerificationUseCase
+	forgotUC       authusecase.ForgotPasswordUseCase
+	resetUC        authusecase.ResetPasswordUseCase
+	verifyMFAUC    authusecase.VerifyMFAUseCase
+	mfaService     mfa.Service
+	passkeyBeginUC passkeyusecase.BeginLoginUseCase
+	passkeyEndUC   passkeyusecase.FinishLoginUseCase
+	recoveryUC     authusecase.RecoveryLoginUseCase
+	recoverDoneUC  authusecase.CompleteRecoveryUseCase
+	magicLinkUC    authusecase.RequestMagicLinkUseCase
+	consumeLinkUC  authusecase.ConsumeMagicLinkUseCase
+	idpStartUC     identityusecase.StartLoginUseCase
+	idpCompleteUC  identityusecase.CompleteLoginUseCase
 	sessionService session.Service
+	revocations    revocation.Service
+	securityEvents securityevent.Service
 	tokenManager   *security.TokenManager
+	dpop           *security.DPoPVerifier
+	oauthClients   oauth.Service
 	cookieDomain   string
+	oidcClientID   string
+	refreshGrace   time.Duration
+	magicLinkTTL   time.Duration
+	hideAccounts   bool
 }
 
 func NewAuthHandler(
 	registerUC authusecase.RegisterUseCase,
 	loginUC authusecase.LoginUseCase,
 	meUC authusecase.GetMeUseCase,
+	verifyEmailUC authusecase.VerifyEmailUseCase,
+	resendUC authusecase.ResendVerificationUseCase,
+	forgotUC authusecase.ForgotPasswordUseCase,
+	resetUC authusecase.ResetPasswordUseCase,
+	verifyMFAUC authusecase.VerifyMFAUseCase,
+	mfaService mfa.Service,
+	passkeyBeginUC passkeyusecase.BeginLoginUseCase,
+	passkeyEndUC passkeyusecase.FinishLoginUseCase,
+	recoveryUC authusecase.RecoveryLoginUseCase,
+	recoverDoneUC authusecase.CompleteRecoveryUseCase,
+	magicLinkUC authusecase.RequestMagicLinkUseCase,
+	consumeLinkUC authusecase.ConsumeMagicLinkUseCase,
+	idpStartUC identityusecase.StartLoginUseCase,
+	idpCompleteUC identityusecase.CompleteLoginUseCase,
 	sessionService session.Service,
+	revocations revocation.Service,
+	securityEvents securityevent.Service,
 	tokenManager *security.TokenManager,
+	dpop *security.DPoPVerifier,
+	oauthClients oauth.Service,
 	cookieDomain string,
+	oidcClientID string,
+	refreshGrace time.Duration,
+	magicLinkTTL time.Duration,
+	hideAccounts bool,
 ) AuthHandler {
 	return &authHandler{
 		registerUC:     registerUC,
 		loginUC:        loginUC,
 		meUC:           meUC,
+		verifyEmailUC:  verifyEmailUC,
+		resendUC:       resendUC,
+		forgotUC:       forgotUC,
+		resetUC:        resetUC,
+		verifyMFAUC:    verifyMFAUC,
+		mfaService:     mfaService,
+		passkeyBeginUC: passkeyBeginUC,
+		passkeyEndUC:   passkeyEndUC,
+		recoveryUC:     recoveryUC,
+		recoverDoneUC:  recoverDoneUC,
+		magicLinkUC:    magicLinkUC,
+		consumeLinkUC:  consumeLinkUC,
+		idpStartUC:     idpStartUC,
+		idpCompleteUC:  idpCompleteUC,
 		sessionService: sessionService,
+		revocations:    revocations,
+		securityEvents: securityEvents,
 		tokenManager:   tokenManager,
+		dpop:           dpop,
+		oauthClients:   oauthClients,
 		cookieDomain:   cookieDomain,
+		oidcClientID:   oidcClientID,
+		refreshGrace:   refreshGrace,
+		magicLinkTTL:   magicLinkTTL,
+		hideAccounts:   hideAccounts,
 	}
 }
 
@@ -63,7 +199,10 @@ func (h *authHandler) Register(c *fiber.Ctx) error {
 
 	createdUser, err := h.registerUC.Execute(c.Context(), &req)
 	if err != nil {
+		var policyErr *password.PolicyError
 		switch {
+		case errors.As(err, &policyErr):
+			return sendPasswordRejected(c, policyErr)
 		case errors.Is(err, user.ErrEmailTaken):
 			return SendError(c, fiber.StatusConflict, err.Error())
 		default:
@@ -71,15 +210,96 @@ func (h *authHandler) Register(c *fiber.Ctx) error {
 		}
 	}
 
+	// a new and a taken address get the same answer
+	if h.hideAccounts {
+		return SendSuccess(c, fiber.StatusAccepted, "check your email to continue", nil)
+	}
 	return SendSuccess(c, fiber.StatusCreated, "user registered successfully", sanitizeUser(createdUser))
 }
 
+func (h *authHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req auth.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	verifiedUser, err := h.verifyEmailUC.Execute(c.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, verification.ErrInvalidToken), errors.Is(err, verification.ErrTokenExpired):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, user.ErrNotFound):
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return SendSuccess(c, fiber.StatusOK, "email verified", sanitizeUser(verifiedUser))
+}
+
+func (h *authHandler) ResendVerification(c *fiber.Ctx) error {
+	var req auth.ResendVerificationRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := h.resendUC.Execute(c.Context(), &req); err != nil {
+		logger.Log.WithError(err).Error("failed to resend verification email")
+		if !h.hideAccounts {
+			return SendError(c, fiber.StatusInternalServerError, "failed to send verification email")
+		}
+	}
+
+	// same answer whether or not the address is registered
+	return SendSuccess(c, fiber.StatusAccepted, "if the address needs verification, an email is on its way", nil)
+}
+
+func (h *authHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req auth.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	// failures are only logged: the response must not depend on the account
+	if err := h.forgotUC.Execute(c.Context(), &req); err != nil {
+		logger.Log.WithError(err).Error("failed to process password reset request")
+	}
+
+	return SendSuccess(c, fiber.StatusAccepted, "if an account exists for this address, a reset link has been sent", nil)
+}
+
+func (h *authHandler) ResetPassword(c *fiber.Ctx) error {
+	var req auth.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" || req.Password == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	if err := h.resetUC.Execute(c.Context(), &req); err != nil {
+		var policyErr *password.PolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			return sendPasswordRejected(c, policyErr)
+		case errors.Is(err, verification.ErrInvalidToken), errors.Is(err, verification.ErrTokenExpired):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	h.clearAuthCookies(c)
+	return SendSuccess(c, fiber.StatusOK, "password has been reset, please log in again", nil)
+}
+
 func (h *authHandler) Login(c *fiber.Ctx) error {
 	var req auth.LoginRequest
 	if err := c.BodyParser(&req); err != nil {
 		return SendError(c, fiber.StatusBadRequest, "invalid request body")
 	}
 
+	req.RemoteIP = c.IP()
 	if req.IPAddress == "" {
 		req.IPAddress = c.IP()
 	}
@@ -87,11 +307,32 @@ func (h *authHandler) Login(c *fiber.Ctx) error {
 		req.UserAgent = c.Get("User-Agent")
 	}
 
+	if !h.tokenManager.AllowsAudience(req.Audience) {
+		return SendError(c, fiber.StatusBadRequest, security.ErrAudienceNotAllowed.Error())
+	}
+	if !h.allowsIDTokenClient(req.ClientID) {
+		return SendError(c, fiber.StatusBadRequest, "client_id is not allowed for direct login")
+	}
+
+	jkt, err := h.dpopKey(c)
+	if err != nil {
+		return SendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
 	authenticatedUser, err := h.loginUC.Execute(c.Context(), &req)
 	if err != nil {
+		var retry *lockout.RetryAfterError
 		switch {
+		case errors.As(err, &retry):
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retry.RetryAfter.Seconds()))))
+			if errors.Is(err, lockout.ErrLocked) {
+				return SendError(c, fiber.StatusLocked, err.Error())
+			}
+			return SendError(c, fiber.StatusTooManyRequests, err.Error())
 		case errors.Is(err, auth.ErrInvalidCredentials):
 			return SendError(c, fiber.StatusUnauthorized, err.Error())
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			return SendError(c, fiber.StatusForbidden, err.Error())
 		case errors.Is(err, auth.ErrUserNotFound):
 			return SendError(c, fiber.StatusNotFound, err.Error())
 		default:
@@ -99,29 +340,212 @@ func (h *authHandler) Login(c *fiber.Ctx) error {
 		}
 	}
 
+	login := loginContext{
+		Audience:  req.Audience,
+		ClientID:  req.ClientID,
+		Nonce:     req.Nonce,
+		JKT:       jkt,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	}
+
+	enrolled, err := h.mfaService.IsEnrolled(authenticatedUser.ID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to check multi-factor enrollment")
+	}
+	if enrolled {
+		return h.startMFAChallenge(c, authenticatedUser, login)
+	}
+
+	return h.startSession(c, authenticatedUser, login, "user logged in successfully")
+}
+
+// VerifyMFA is the second login step: it trades the challenge token from
+// Login and a TOTP code for a session.
+func (h *authHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req auth.VerifyMFARequest
+	if err := c.BodyParser(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	authenticatedUser, challenge, err := h.verifyMFAUC.Execute(c.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrInvalidChallenge), errors.Is(err, auth.ErrTooManyAttempts):
+			return SendError(c, fiber.StatusUnauthorized, err.Error())
+		case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrCodeReused), errors.Is(err, mfa.ErrNotEnrolled):
+			return SendError(c, fiber.StatusUnauthorized, mfa.ErrInvalidCode.Error())
+		case errors.Is(err, mfa.ErrTooManyAttempts):
+			return SendError(c, fiber.StatusTooManyRequests, err.Error())
+		case errors.Is(err, user.ErrNotFound):
+			return SendError(c, fiber.StatusUnauthorized, "user no longer exists")
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	login := loginContextFromChallenge(challenge)
+	// a DPoP bound login must be finished by the same key
+	if login.JKT != "" {
+		jkt, err := h.dpopKey(c)
+		if err != nil || jkt != login.JKT {
+			return SendError(c, fiber.StatusUnauthorized, "valid DPoP proof required")
+		}
+	}
+
+	return h.startSession(c, authenticatedUser, login, "user logged in successfully")
+}
+
+// PasskeyLoginBegin returns the options for a usernameless passkey sign-in.
+func (h *authHandler) PasskeyLoginBegin(c *fiber.Ctx) error {
+	var req passkey.LoginBeginRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return SendError(c, fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+	if !h.tokenManager.AllowsAudience(req.Audience) {
+		return SendError(c, fiber.StatusBadRequest, security.ErrAudienceNotAllowed.Error())
+	}
+	if !h.allowsIDTokenClient(req.ClientID) {
+		return SendError(c, fiber.StatusBadRequest, "client_id is not allowed for direct login")
+	}
+
+	jkt, err := h.dpopKey(c)
+	if err != nil {
+		return SendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	login := loginContext{
+		Audience:  req.Audience,
+		ClientID:  req.ClientID,
+		Nonce:     req.Nonce,
+		JKT:       jkt,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+	options, err := h.passkeyBeginUC.Execute(c.Context(), login.challengeData())
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to start passkey sign-in")
+	}
+	return SendSuccess(c, fiber.StatusOK, "passkey sign-in started", options)
+}
+
+// PasskeyLoginFinish verifies the passkey assertion and starts a session the
+// same way a password login does.
+func (h *authHandler) PasskeyLoginFinish(c *fiber.Ctx) error {
+	var req passkey.LoginFinishRequest
+	if err := c.BodyParser(&req); err != nil || req.CeremonyToken == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	authenticatedUser, ceremony, err := h.passkeyEndUC.Execute(c.Context(), &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			return SendError(c, fiber.StatusForbidden, err.Error())
+		case errors.Is(err, security.ErrInvalidChallenge),
+			errors.Is(err, passkey.ErrNotFound),
+			errors.Is(err, passkey.ErrCloned),
+			errors.Is(err, user.ErrNotFound),
+			isWebAuthnError(err):
+			// one answer for every failure, so responses do not reveal
+			// which credentials exist
+			return SendError(c, fiber.StatusUnauthorized, "passkey sign-in failed")
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	login := loginContextFromChallenge(ceremony)
+	if login.JKT != "" {
+		jkt, err := h.dpopKey(c)
+		if err != nil || jkt != login.JKT {
+			return SendError(c, fiber.StatusUnauthorized, "valid DPoP proof required")
+		}
+	}
+
+	return h.startSession(c, authenticatedUser, login, "user logged in successfully")
+}
+
+// allowsIDTokenClient reports whether a direct login may issue its ID token
+// to clientID: our own client or a registered first-party app. Other
+// clients have to go through /oauth/authorize, where the user consents.
+func (h *authHandler) allowsIDTokenClient(clientID string) bool {
+	if clientID == "" || clientID == h.oidcClientID {
+		return true
+	}
+	client, err := h.oauthClients.GetClient(clientID)
+	if err != nil {
+		return false
+	}
+	return client.FirstParty && !client.Machine
+}
+
+// startMFAChallenge answers the password step of an MFA-enabled account with
+// a short-lived challenge instead of tokens.
+func (h *authHandler) startMFAChallenge(c *fiber.Ctx, u *user.User, login loginContext) error {
+	token, challenge, err := h.tokenManager.IssueChallenge(security.ChallengePurposeMFA, u.ID, mfaChallengeTTL, login.challengeData())
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to start multi-factor authentication")
+	}
+
+	methods := []string{"totp"}
+	if remaining, err := h.mfaService.RecoveryCodesRemaining(u.ID); err == nil && remaining > 0 {
+		methods = append(methods, "recovery_code")
+	}
+	data := map[string]interface{}{
+		"mfa_required":   true,
+		"mfa_token":      token,
+		"mfa_methods":    methods,
+		"mfa_expires_at": challenge.ExpiresAt,
+	}
+	return SendSuccess(c, fiber.StatusOK, "multi-factor authentication required", data)
+}
+
+// startSession issues tokens for a fully authenticated user, records the
+// session and sets the auth cookies.
+func (h *authHandler) startSession(c *fiber.Ctx, u *user.User, login loginContext, message string) error {
+	idTokenAudience := login.ClientID
+	if idTokenAudience == "" {
+		idTokenAudience = h.oidcClientID
+	}
 	pair, err := h.tokenManager.GenerateTokenPair(
-		authenticatedUser.ID,
-		authenticatedUser.Email,
-		authenticatedUser.Username,
-		[]string{string(authenticatedUser.Role)},
+		u.ID,
+		u.Email,
+		u.Username,
+		[]string{string(u.Role)},
+		security.WithAudience(login.Audience),
+		security.WithConfirmation(login.JKT),
+		security.WithIDToken(security.IDTokenClaims{
+			Audience:      idTokenAudience,
+			Name:          u.Fullname,
+			EmailVerified: u.EmailVerified,
+			Nonce:         login.Nonce,
+			AuthTime:      time.Now().UTC(),
+		}),
 	)
 	if err != nil {
 		return SendError(c, fiber.StatusInternalServerError, "failed to generate tokens")
 	}
 
-	if err := h.persistSession(pair.SID, authenticatedUser.ID, req.IPAddress, req.UserAgent, pair.RefreshToken, pair.RefreshExp); err != nil {
+	if err := h.persistSession(pair.SID, u.ID, login.IPAddress, login.UserAgent, pair.RefreshToken, pair.RefreshExp, login.JKT, idTokenAudience); err != nil {
 		return SendError(c, fiber.StatusInternalServerError, "failed to persist session")
 	}
 
 	h.setAuthCookies(c, pair)
 	data := map[string]interface{}{
-		"user":                     sanitizeUser(authenticatedUser),
+		"user":                     sanitizeUser(u),
 		"session_id":               pair.SID,
+		"token_type":               tokenType(login.JKT),
+		"id_token":                 pair.IDToken,
 		"access_token_expires_at":  pair.AccessExp,
 		"refresh_token_expires_at": pair.RefreshExp,
 	}
 
-	return SendSuccess(c, fiber.StatusOK, "user logged in successfully", data)
+	return SendSuccess(c, fiber.StatusOK, message, data)
 }
 
 func (h *authHandler) Refresh(c *fiber.Ctx) error {
@@ -142,11 +566,22 @@ func (h *authHandler) Refresh(c *fiber.Ctx) error {
 	if sess == nil || !sess.Valid || time.Now().After(sess.ExpiresAt) {
 		return SendError(c, fiber.StatusUnauthorized, "session expired or revoked")
 	}
+	// OAuth clients refresh through the token endpoint, which keeps their scope
+	if sess.ClientID != "" {
+		return SendError(c, fiber.StatusUnauthorized, "invalid refresh token")
+	}
 
-	if !security.CompareTokenHash(sess.RefreshTokenHash, refreshToken) {
-		_ = h.sessionService.DeleteSession(payload.SID)
-		h.clearAuthCookies(c)
-		return SendError(c, fiber.StatusUnauthorized, "refresh token reuse detected")
+	// a bound family can only be refreshed by the key holder
+	if sess.DPoPJKT != "" {
+		jkt, err := h.dpopKey(c)
+		if err != nil || jkt != sess.DPoPJKT {
+			return SendError(c, fiber.StatusUnauthorized, "valid DPoP proof required")
+		}
+	}
+
+	state := h.sessionService.ClassifyRefreshToken(sess, refreshToken, h.refreshGrace)
+	if state == session.RefreshTokenReused {
+		return h.revokeRefreshFamily(c, sess)
 	}
 
 	userRecord, err := h.meUC.Execute(c.Context(), sess.ID)
@@ -154,17 +589,41 @@ func (h *authHandler) Refresh(c *fiber.Ctx) error {
 		return SendError(c, fiber.StatusUnauthorized, "linked user not found")
 	}
 
+	if state == session.RefreshTokenGrace {
+		return h.refreshWithinGrace(c, sess, userRecord, audienceOf(payload))
+	}
+
+	// the ID token keeps the client it was issued to at login; sessions from
+	// before that was recorded belong to our own client
+	idTokenAudience := sess.IDTokenClientID
+	if idTokenAudience == "" {
+		idTokenAudience = h.oidcClientID
+	}
 	pair, err := h.tokenManager.GenerateTokenPair(
 		userRecord.ID,
 		userRecord.Email,
 		userRecord.Username,
 		[]string{string(userRecord.Role)},
+		security.WithSessionID(sess.ID),
+		security.WithAudience(audienceOf(payload)),
+		security.WithConfirmation(sess.DPoPJKT),
+		security.WithIDToken(security.IDTokenClaims{
+			Audience:      idTokenAudience,
+			Name:          userRecord.Fullname,
+			EmailVerified: userRecord.EmailVerified,
+			AuthTime:      sess.CreatedAt,
+		}),
 	)
 	if err != nil {
 		return SendError(c, fiber.StatusInternalServerError, "failed to rotate tokens")
 	}
 
-	if err := h.rotateSession(sess.ID, pair.RefreshToken, pair.RefreshExp, c.IP(), c.Get("User-Agent")); err != nil {
+	err = h.sessionService.RotateRefreshToken(sess, refreshToken, pair.RefreshToken, pair.RefreshExp, c.IP(), c.Get("User-Agent"))
+	if errors.Is(err, session.ErrRotationConflict) {
+		// lost the race against a concurrent refresh of the same token
+		return h.refreshWithinGrace(c, sess, userRecord, audienceOf(payload))
+	}
+	if err != nil {
 		return SendError(c, fiber.StatusInternalServerError, "failed to rotate session")
 	}
 
@@ -172,6 +631,8 @@ func (h *authHandler) Refresh(c *fiber.Ctx) error {
 	data := map[string]interface{}{
 		"user":                     sanitizeUser(userRecord),
 		"session_id":               pair.SID,
+		"token_type":               tokenType(sess.DPoPJKT),
+		"id_token":                 pair.IDToken,
 		"access_token_expires_at":  pair.AccessExp,
 		"refresh_token_expires_at": pair.RefreshExp,
 	}
@@ -179,8 +640,303 @@ func (h *authHandler) Refresh(c *fiber.Ctx) error {
 	return SendSuccess(c, fiber.StatusOK, "tokens refreshed", data)
 }
 
+// refreshWithinGrace serves a refresh that raced a rotation. The winner
+// already holds the new refresh token (shared via the cookie jar), so only a
+// fresh access token is issued and the refresh cookie is left alone.
+func (h *authHandler) refreshWithinGrace(c *fiber.Ctx, sess *session.Session, userRecord *user.User, audience string) error {
+	pair, err := h.tokenManager.GenerateAccessToken(
+		userRecord.ID,
+		userRecord.Email,
+		userRecord.Username,
+		[]string{string(userRecord.Role)},
+		security.WithSessionID(sess.ID),
+		security.WithAudience(audience),
+		security.WithConfirmation(sess.DPoPJKT),
+	)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to issue access token")
+	}
+
+	h.setAccessCookie(c, pair)
+	data := map[string]interface{}{
+		"user":                    sanitizeUser(userRecord),
+		"session_id":              sess.ID,
+		"token_type":              tokenType(sess.DPoPJKT),
+		"access_token_expires_at": pair.AccessExp,
+	}
+	return SendSuccess(c, fiber.StatusOK, "tokens refreshed", data)
+}
+
+// revokeRefreshFamily handles a replayed refresh token: the whole family
+// (the session) is revoked and the incident recorded.
+func (h *authHandler) revokeRefreshFamily(c *fiber.Ctx, sess *session.Session) error {
+	if err := h.sessionService.InvalidateSession(sess.ID); err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to revoke session")
+	}
+
+	if err := h.securityEvents.Record(&securityevent.Event{
+		UserID:    sess.UserID,
+		SessionID: sess.ID,
+		Type:      securityevent.TypeRefreshTokenReuse,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record refresh token reuse")
+	}
+
+	h.clearAuthCookies(c)
+	return SendError(c, fiber.StatusUnauthorized, "refresh token reuse detected")
+}
+
+// RecoveryLogin opens a restricted session from a recovery code. Only the
+// access cookie is set; the session cannot be refreshed.
+func (h *authHandler) RecoveryLogin(c *fiber.Ctx) error {
+	var req auth.RecoveryLoginRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" || req.RecoveryCode == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	u, pair, err := h.recoveryUC.Execute(c.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return SendError(c, fiber.StatusUnauthorized, "invalid email or recovery code")
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			return SendError(c, fiber.StatusTooManyRequests, "too many attempts, try again later")
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	h.setAccessCookie(c, pair)
+	data := map[string]interface{}{
+		"user":                    sanitizeUser(u),
+		"session_id":              pair.SID,
+		"token_type":              "Bearer",
+		"access_token":            pair.AccessToken,
+		"access_token_expires_at": pair.AccessExp,
+		"scope":                   security.ScopeAccountRecovery,
+	}
+	return SendSuccess(c, fiber.StatusOK, "recovery session started, set a new password", data)
+}
+
+// CompleteRecovery resets credentials from a recovery session and signs the
+// user out everywhere.
+func (h *authHandler) CompleteRecovery(c *fiber.Ctx) error {
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return SendError(c, fiber.StatusUnauthorized, "missing access token")
+	}
+	if !claims.IsRestricted() {
+		return SendError(c, fiber.StatusForbidden, "a recovery session is required")
+	}
+	var req auth.CompleteRecoveryRequest
+	if err := c.BodyParser(&req); err != nil || req.Password == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+
+	if err := h.recoverDoneUC.Execute(c.Context(), claims.UserID, &req); err != nil {
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			return sendPasswordRejected(c, policyErr)
+		}
+		if errors.Is(err, user.ErrNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	if err := h.revocations.Revoke(claims.JTI, claims.Expiry, revocation.ReasonLogout); err != nil {
+		logger.Log.WithError(err).Error("failed to revoke recovery access token")
+	}
+
+	h.clearAuthCookies(c)
+	return SendSuccess(c, fiber.StatusOK, "account recovered, please log in with your new password", nil)
+}
+
+// RequestMagicLink mails a sign-in link. The response is the same whether or
+// not the address belongs to an account.
+func (h *authHandler) RequestMagicLink(c *fiber.Ctx) error {
+	var req auth.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	binding, err := h.magicLinkUC.Execute(c.Context(), &req)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to process magic link request")
+	}
+	if binding != "" {
+		c.Cookie(h.authCookie(magicLinkCookieName, binding, int(h.magicLinkTTL.Seconds())))
+	}
+
+	return SendSuccess(c, fiber.StatusAccepted, "if an account exists for this address, a sign-in link has been sent", nil)
+}
+
+// ConsumeMagicLink exchanges a sign-in link for a session, or for an MFA
+// challenge when the account has a second factor.
+func (h *authHandler) ConsumeMagicLink(c *fiber.Ctx) error {
+	var req auth.ConsumeMagicLinkRequest
+	var err error
+	if c.Method() == fiber.MethodGet {
+		err = c.QueryParser(&req)
+	} else {
+		err = c.BodyParser(&req)
+	}
+	if err != nil || req.Token == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request")
+	}
+	req.Binding = c.Cookies(magicLinkCookieName)
+
+	if !h.tokenManager.AllowsAudience(req.Audience) {
+		return SendError(c, fiber.StatusBadRequest, security.ErrAudienceNotAllowed.Error())
+	}
+	if !h.allowsIDTokenClient(req.ClientID) {
+		return SendError(c, fiber.StatusBadRequest, "client_id is not allowed for direct login")
+	}
+	jkt, err := h.dpopKey(c)
+	if err != nil {
+		return SendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	u, err := h.consumeLinkUC.Execute(c.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, verification.ErrInvalidToken), errors.Is(err, verification.ErrTokenExpired):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, verification.ErrWrongBinding):
+			return SendError(c, fiber.StatusForbidden, err.Error())
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			return SendError(c, fiber.StatusForbidden, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+	h.clearCookie(c, magicLinkCookieName)
+
+	login := loginContext{
+		Audience:  req.Audience,
+		ClientID:  req.ClientID,
+		Nonce:     req.Nonce,
+		JKT:       jkt,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+
+	enrolled, err := h.mfaService.IsEnrolled(u.ID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to check multi-factor enrollment")
+	}
+	if enrolled {
+		return h.startMFAChallenge(c, u, login)
+	}
+	return h.startSession(c, u, login, "user logged in successfully")
+}
+
+// ExternalLoginStart redirects the browser to an external identity provider.
+// The flow token is kept in a cookie until the provider sends the user back.
+func (h *authHandler) ExternalLoginStart(c *fiber.Ctx) error {
+	var req identity.LoginStartRequest
+	if err := c.QueryParser(&req); err != nil {
+		return SendError(c, fiber.StatusBadRequest, "invalid request")
+	}
+	if !h.tokenManager.AllowsAudience(req.Audience) {
+		return SendError(c, fiber.StatusBadRequest, security.ErrAudienceNotAllowed.Error())
+	}
+	if !h.allowsIDTokenClient(req.ClientID) {
+		return SendError(c, fiber.StatusBadRequest, "client_id is not allowed for direct login")
+	}
+
+	// the browser cannot sign DPoP proofs across redirects, so external
+	// sign-in always yields bearer tokens
+	login := loginContext{
+		Audience:  req.Audience,
+		ClientID:  req.ClientID,
+		Nonce:     req.Nonce,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+	start, err := h.idpStartUC.Execute(c.Context(), c.Params("provider"), login.challengeData())
+	if err != nil {
+		switch {
+		case errors.Is(err, idp.ErrUnknownProvider):
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		case errors.Is(err, idp.ErrProvider):
+			return SendError(c, fiber.StatusBadGateway, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, "failed to start external sign-in")
+		}
+	}
+
+	c.Cookie(h.authCookie(externalFlowCookieName, start.FlowToken, int(identityusecase.FlowTTL.Seconds())))
+	return c.Redirect(start.RedirectURL, fiber.StatusFound)
+}
+
+// ExternalLoginCallback finishes a sign-in with an external identity
+// provider and starts a session, or an MFA challenge when the account has a
+// second factor.
+func (h *authHandler) ExternalLoginCallback(c *fiber.Ctx) error {
+	flowToken := c.Cookies(externalFlowCookieName)
+	h.clearCookie(c, externalFlowCookieName)
+
+	if idpErr := c.Query("error"); idpErr != "" {
+		return SendError(c, fiber.StatusUnauthorized, "identity provider sign-in failed: "+idpErr)
+	}
+	var req identity.CallbackRequest
+	if err := c.QueryParser(&req); err != nil || req.Code == "" || flowToken == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request")
+	}
+	req.Provider = c.Params("provider")
+	req.FlowToken = flowToken
+
+	u, flow, err := h.idpCompleteUC.Execute(c.Context(), &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, idp.ErrUnknownProvider):
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		case errors.Is(err, security.ErrInvalidChallenge):
+			return SendError(c, fiber.StatusBadRequest, "sign-in flow expired or invalid, please start again")
+		case errors.Is(err, idp.ErrExchange), errors.Is(err, idp.ErrInvalidIDToken):
+			return SendError(c, fiber.StatusUnauthorized, "identity provider sign-in failed")
+		case errors.Is(err, idp.ErrProvider):
+			return SendError(c, fiber.StatusBadGateway, err.Error())
+		case errors.Is(err, identity.ErrEmailNotVerified), errors.Is(err, auth.ErrEmailNotVerified):
+			return SendError(c, fiber.StatusForbidden, err.Error())
+		case errors.Is(err, identity.ErrAccountConflict),
+			errors.Is(err, identity.ErrAlreadyLinked),
+			errors.Is(err, user.ErrEmailTaken):
+			return SendError(c, fiber.StatusConflict, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+
+	login := loginContextFromChallenge(flow)
+	login.IPAddress, login.UserAgent = c.IP(), c.Get("User-Agent")
+
+	enrolled, err := h.mfaService.IsEnrolled(u.ID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to check multi-factor enrollment")
+	}
+	if enrolled {
+		return h.startMFAChallenge(c, u, login)
+	}
+	return h.startSession(c, u, login, "user logged in successfully")
+}
+
 func (h *authHandler) Logout(c *fiber.Ctx) error {
-	sessionID := h.sessionIDFromRequest(c)
+	// the middleware already verified the token, whatever scheme carried it
+	claims, authenticated := middleware.ClaimsFromContext(c)
+	var sessionID string
+	if authenticated {
+		sessionID = claims.SID
+	}
+	if sessionID == "" {
+		sessionID = h.sessionIDFromRequest(c)
+	}
 	if sessionID == "" {
 		return SendError(c, fiber.StatusUnauthorized, "unable to determine session")
 	}
@@ -189,6 +945,13 @@ func (h *authHandler) Logout(c *fiber.Ctx) error {
 		return SendError(c, fiber.StatusInternalServerError, "failed to revoke session")
 	}
 
+	// the access token would otherwise stay valid until it expires
+	if authenticated {
+		if err := h.revocations.Revoke(claims.JTI, claims.Expiry, revocation.ReasonLogout); err != nil {
+			return SendError(c, fiber.StatusInternalServerError, "failed to revoke access token")
+		}
+	}
+
 	h.clearAuthCookies(c)
 	return SendSuccess(c, fiber.StatusOK, "logged out successfully", nil)
 }
@@ -217,7 +980,7 @@ func (h *authHandler) Me(c *fiber.Ctx) error {
 	return SendSuccess(c, fiber.StatusOK, "authenticated user retrieved", sanitizeUser(u))
 }
 
-func (h *authHandler) persistSession(sessionID, userID, ip, userAgent, refreshToken string, expiresAt time.Time) error {
+func (h *authHandler) persistSession(sessionID, userID, ip, userAgent, refreshToken string, expiresAt time.Time, jkt, idTokenClientID string) error {
 	refreshHash := security.HashToken(refreshToken)
 	now := time.Now().UTC()
 	sess := &session.Session{
@@ -228,67 +991,61 @@ func (h *authHandler) persistSession(sessionID, userID, ip, userAgent, refreshTo
 		Valid:            true,
 		ExpiresAt:        expiresAt,
 		RefreshTokenHash: refreshHash,
+		DPoPJKT:          jkt,
+		IDTokenClientID:  idTokenClientID,
 		CreatedAt:        now,
 		UpdatedAt:        now,
 	}
 	return h.sessionService.CreateSession(sess)
 }
 
-func (h *authHandler) rotateSession(sessionID, refreshToken string, expiresAt time.Time, ip, userAgent string) error {
-	refreshHash := security.HashToken(refreshToken)
-	updated := &session.Session{
-		RefreshTokenHash: refreshHash,
-		ExpiresAt:        expiresAt,
-		IPAddress:        ip,
-		UserAgent:        userAgent,
-		UpdatedAt:        time.Now().UTC(),
+func (h *authHandler) setAuthCookies(c *fiber.Ctx, pair *security.TokenPair) {
+	h.setAccessCookie(c, pair)
+
+	refreshMaxAge := int(time.Until(pair.RefreshExp).Seconds())
+	if refreshMaxAge <= 0 {
+		refreshMaxAge = int(h.tokenManager.RefreshTTL().Seconds())
 	}
-	_, err := h.sessionService.UpdateSession(sessionID, updated)
-	return err
+	c.Cookie(h.authCookie(refreshTokenCookieName, pair.RefreshToken, refreshMaxAge))
 }
 
-func (h *authHandler) setAuthCookies(c *fiber.Ctx, pair *security.TokenPair) {
+func (h *authHandler) setAccessCookie(c *fiber.Ctx, pair *security.TokenPair) {
 	accessMaxAge := int(time.Until(pair.AccessExp).Seconds())
-	refreshMaxAge := int(time.Until(pair.RefreshExp).Seconds())
 	if accessMaxAge <= 0 {
 		accessMaxAge = int(h.tokenManager.AccessTTL().Seconds())
 	}
-	if refreshMaxAge <= 0 {
-		refreshMaxAge = int(h.tokenManager.RefreshTTL().Seconds())
-	}
+	c.Cookie(h.authCookie(accessTokenCookieName, pair.AccessToken, accessMaxAge))
+}
 
-	cookieBase := func(name, value string, maxAge int) *fiber.Cookie {
-		return &fiber.Cookie{
-			Name:     name,
-			Value:    value,
-			Domain:   h.cookieDomain,
-			Path:     "/",
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: fiber.CookieSameSiteNoneMode,
-			MaxAge:   maxAge,
-		}
+func (h *authHandler) authCookie(name, value string, maxAge int) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   h.cookieDomain,
+		Path:     "/",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteNoneMode,
+		MaxAge:   maxAge,
 	}
-
-	c.Cookie(cookieBase(accessTokenCookieName, pair.AccessToken, accessMaxAge))
-	c.Cookie(cookieBase(refreshTokenCookieName, pair.RefreshToken, refreshMaxAge))
 }
 
 func (h *authHandler) clearAuthCookies(c *fiber.Ctx) {
-	clear := func(name string) {
-		c.Cookie(&fiber.Cookie{
-			Name:     name,
-			Value:    "",
-			Domain:   h.cookieDomain,
-			Path:     "/",
-			HTTPOnly: true,
-			Secure:   true,
-			SameSite: fiber.CookieSameSiteNoneMode,
-			Expires:  time.Unix(0, 0),
-		})
-	}
-	clear(accessTokenCookieName)
-	clear(refreshTokenCookieName)
+	h.clearCookie(c, accessTokenCookieName)
+	h.clearCookie(c, refreshTokenCookieName)
+}
+
+func (h *authHandler) clearCookie(c *fiber.Ctx, name string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    "",
+		Domain:   h.cookieDomain,
+		Path:     "/",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteNoneMode,
+		Expires:  time.Unix(0, 0),
+	})
 }
 
 func (h *authHandler) sessionIDFromRequest(c *fiber.Ctx) string {
@@ -310,6 +1067,36 @@ func (h *authHandler) sessionIDFromRequest(c *fiber.Ctx) string {
 	return c.Query("token")
 }
 
+// dpopKey verifies the optional DPoP header and returns the thumbprint of the
+// proof key, or "" when the client did not send a proof.
+func (h *authHandler) dpopKey(c *fiber.Ctx) (string, error) {
+	proof := c.Get("DPoP")
+	if proof == "" {
+		return "", nil
+	}
+	verified, err := h.dpop.Verify(proof, c.Method(), c.Path(), "")
+	if err != nil {
+		return "", errors.New("invalid DPoP proof")
+	}
+	return verified.JKT, nil
+}
+
+// audienceOf returns the audience a refresh token was issued for, so the
+// rotated tokens keep it. Tokens from before audiences get the default.
+func audienceOf(payload *security.ClaimsPayload) string {
+	if len(payload.Audience) == 0 {
+		return ""
+	}
+	return payload.Audience[0]
+}
+
+func tokenType(jkt string) string {
+	if jkt != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
+
 func extractBearerToken(header string) string {
 	if header == "" {
 		return ""
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestLogoutEndsTheAuthenticatedSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		claims        *security.ClaimsPayload
+		authorization string
+		wantStatus    int
+		wantDeleted   string
+		wantRevoked   bool
+	}{
+		{
+			name:          "DPoP-bound access token",
+			claims:        &security.ClaimsPayload{UserID: "user-1", SID: "session-1", JTI: "jti-1", Expiry: time.Now().Add(time.Minute)},
+			authorization: "DPoP opaque-to-the-handler",
+			wantStatus:    fiber.StatusOK,
+			wantDeleted:   "session-1",
+			wantRevoked:   true,
+		},
+		{name: "no session anywhere", wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &deletedSessions{}
+			revocations := revocation.NewService(repository.NewMemoryRevokedTokenRepository())
+			h := &authHandler{sessionService: sessions, revocations: revocations}
+
+			app := fiber.New()
+			app.Post("/logout", func(c *fiber.Ctx) error {
+				if tt.claims != nil {
+					c.Locals(middleware.DefaultClaimsContextKey, tt.claims)
+				}
+				return c.Next()
+			}, h.Logout)
+
+			req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if sessions.deleted != tt.wantDeleted {
+				t.Errorf("deleted session = %q, want %q", sessions.deleted, tt.wantDeleted)
+			}
+			if tt.claims != nil {
+				if revoked, _ := revocations.IsRevoked(tt.claims.JTI); revoked != tt.wantRevoked {
+					t.Errorf("access token revoked = %v, want %v", revoked, tt.wantRevoked)
+				}
+			}
+		})
+	}
+}
+
+type deletedSessions struct {
+	session.Service
+	deleted string
+}
+
+func (s *deletedSessions) DeleteSession(id string) error {
+	s.deleted = id
+	return nil
+}
+
+func TestRefreshKeepsTheIDTokenClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		wantAud  string
+	}{
+		{name: "own client", wantAud: "user-auth-service"},
+		{name: "first-party app", clientID: "mobile-app", wantAud: "mobile-app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := security.NewTokenManager(security.TokenConfig{
+				AccessSecret:  "access-secret-for-tests",
+				RefreshSecret: "refresh-secret-for-tests",
+				AccessTTL:     time.Minute,
+				RefreshTTL:    time.Hour,
+				Issuer:        "https://auth.example.com",
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jane := &user.User{ID: "user-1", Email: "jane@example.com", Username: "jane", Role: user.RoleUser}
+			h := &authHandler{
+				meUC:           fixedMe{jane},
+				sessionService: session.NewService(&memorySessions{byID: map[string]*session.Session{}}),
+				tokenManager:   tm,
+				oidcClientID:   "user-auth-service",
+			}
+
+			app := fiber.New()
+			app.Post("/login", func(c *fiber.Ctx) error {
+				return h.startSession(c, jane, loginContext{ClientID: tt.clientID}, "user logged in successfully")
+			})
+			app.Post("/refresh", h.Refresh)
+
+			login, refreshCookie := postForIDToken(t, app, "/login", nil)
+			refreshed, _ := postForIDToken(t, app, "/refresh", refreshCookie)
+			for step, idToken := range map[string]string{"login": login, "refresh": refreshed} {
+				claims := jwt.MapClaims{}
+				if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+					t.Fatalf("%s ID token: %v", step, err)
+				}
+				if aud, _ := claims.GetAudience(); !slices.Equal(aud, jwt.ClaimStrings{tt.wantAud}) {
+					t.Errorf("%s ID token aud = %v, want %s", step, aud, tt.wantAud)
+				}
+			}
+		})
+	}
+}
+
+// postForIDToken posts with the given cookie and returns the ID token from
+// the response and the refresh cookie it set.
+func postForIDToken(t *testing.T, app *fiber.App, path string, cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Error string `json:"error"`
+		Data  struct {
+			IDToken string `json:"id_token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("POST %s status = %d: %s", path, resp.StatusCode, body.Error)
+	}
+	for _, c := range resp.Cookies() {
+		if c.Name == refreshTokenCookieName {
+			return body.Data.IDToken, c
+		}
+	}
+	return body.Data.IDToken, nil
+}
+
+type fixedMe struct {
+	u *user.User
+}
+
+func (f fixedMe) Execute(context.Context, string) (*user.User, error) {
+	return f.u, nil
+}
+
+// memorySessions is the part of session.Repository a login and a refresh
+// use.
+type memorySessions struct {
+	session.Repository
+	byID map[string]*session.Session
+}
+
+func (m *memorySessions) Create(s *session.Session) error {
+	c := *s
+	m.byID[s.ID] = &c
+	return nil
+}
+
+func (m *memorySessions) GetByID(id string) (*session.Session, error) {
+	if s, ok := m.byID[id]; ok {
+		c := *s
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (m *memorySessions) RotateRefreshToken(r *session.Rotation) (bool, error) {
+	s, ok := m.byID[r.SessionID]
+	if !ok || s.RefreshTokenHash != r.ExpectedHash {
+		return false, nil
+	}
+	s.PreviousRefreshTokenHash, s.RefreshTokenHash, s.ExpiresAt = s.RefreshTokenHash, r.NewHash, r.ExpiresAt
+	return true, nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	mfausecase "mikhailjbs/user-auth-service/internal/usecase/mfa"
+)
+
+// MFAHandler lets a signed-in user manage their second factors.
+type MFAHandler interface {
+	EnrollTOTP(c *fiber.Ctx) error
+	ConfirmTOTP(c *fiber.Ctx) error
+	DisableTOTP(c *fiber.Ctx) error
+	GenerateRecoveryCodes(c *fiber.Ctx) error
+}
+
+type mfaHandler struct {
+	enrollTOTPUC  mfausecase.EnrollTOTPUseCase
+	confirmTOTPUC mfausecase.ConfirmTOTPUseCase
+	disableTOTPUC mfausecase.DisableTOTPUseCase
+	recoveryUC    mfausecase.GenerateRecoveryCodesUseCase
+}
+
+func NewMFAHandler(
+	enrollTOTPUC mfausecase.EnrollTOTPUseCase,
+	confirmTOTPUC mfausecase.ConfirmTOTPUseCase,
+	disableTOTPUC mfausecase.DisableTOTPUseCase,
+	recoveryUC mfausecase.GenerateRecoveryCodesUseCase,
+) MFAHandler {
+	return &mfaHandler{
+		enrollTOTPUC:  enrollTOTPUC,
+		confirmTOTPUC: confirmTOTPUC,
+		disableTOTPUC: disableTOTPUC,
+		recoveryUC:    recoveryUC,
+	}
+}
+
+// EnrollTOTP starts enrollment and returns the secret, otpauth URI and QR code.
+func (h *mfaHandler) EnrollTOTP(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	enrollment, err := h.enrollTOTPUC.Execute(c.Context(), claims.UserID)
+	if err != nil {
+		return sendMFAError(c, err)
+	}
+	return SendSuccess(c, fiber.StatusOK, "scan the QR code and confirm with a code", enrollment)
+}
+
+func (h *mfaHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+	var req mfa.CodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := h.confirmTOTPUC.Execute(c.Context(), claims.UserID, &req, c.IP(), c.Get("User-Agent")); err != nil {
+		return sendMFAError(c, err)
+	}
+	return SendSuccess(c, fiber.StatusOK, "authenticator app enabled", nil)
+}
+
+func (h *mfaHandler) DisableTOTP(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+	var req mfa.CodeRequest
+	if err := c.BodyParser(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := h.disableTOTPUC.Execute(c.Context(), claims.UserID, &req, c.IP(), c.Get("User-Agent")); err != nil {
+		return sendMFAError(c, err)
+	}
+	return SendSuccess(c, fiber.StatusOK, "authenticator app disabled", nil)
+}
+
+// GenerateRecoveryCodes returns a new set of recovery codes; the old set
+// stops working. The codes are not shown again.
+func (h *mfaHandler) GenerateRecoveryCodes(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	codes, err := h.recoveryUC.Execute(c.Context(), claims.UserID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendMFAError(c, err)
+	}
+	return SendSuccess(c, fiber.StatusOK, "store these recovery codes somewhere safe", codes)
+}
+
+// accountOwner returns the caller's claims, refusing impersonated tokens:
+// an admin acting as a user must not change that user's second factors. Tokens
+// issued to OAuth clients, machine ones included, are refused as well.
+func accountOwner(c *fiber.Ctx) (*security.ClaimsPayload, *fiber.Error) {
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "missing access token")
+	}
+	if claims.IsImpersonated() {
+		return nil, fiber.NewError(fiber.StatusForbidden, "not allowed while impersonating")
+	}
+	if claims.ClientID != "" || claims.IsMachine() {
+		return nil, fiber.NewError(fiber.StatusForbidden, "not allowed for OAuth client tokens")
+	}
+	return claims, nil
+}
+
+func sendMFAError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrUnavailable):
+		return SendError(c, fiber.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, mfa.ErrAlreadyEnrolled):
+		return SendError(c, fiber.StatusConflict, err.Error())
+	case errors.Is(err, mfa.ErrNotEnrolled):
+		return SendError(c, fiber.StatusNotFound, err.Error())
+	case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrCodeReused):
+		return SendError(c, fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, mfa.ErrTooManyAttempts):
+		return SendError(c, fiber.StatusTooManyRequests, err.Error())
+	default:
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+}
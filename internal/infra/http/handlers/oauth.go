@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	oauthusecase "mikhailjbs/user-auth-service/internal/usecase/oauth"
+)
+
+// OAuthHandler exposes the standard OAuth 2.0 endpoints. Responses follow the
+// RFCs rather than the API envelope so off-the-shelf clients can use them.
+type OAuthHandler interface {
+	Introspect(c *fiber.Ctx) error
+	Token(c *fiber.Ctx) error
+	Authorize(c *fiber.Ctx) error
+	Consent(c *fiber.Ctx) error
+}
+
+type oauthHandler struct {
+	introspectUC    oauthusecase.IntrospectUseCase
+	tokenExchangeUC oauthusecase.TokenExchangeUseCase
+	authorizeUC     oauthusecase.AuthorizeUseCase
+	consentUC       oauthusecase.ConsentUseCase
+	codeGrantUC     oauthusecase.AuthorizationCodeGrantUseCase
+	refreshGrantUC  oauthusecase.RefreshTokenGrantUseCase
+	clientGrantUC   oauthusecase.ClientCredentialsGrantUseCase
+	dpop            *security.DPoPVerifier
+	issuer          string
+	// loginURL and consentURL are the web app pages the authorization
+	// endpoint sends the browser to.
+	loginURL   string
+	consentURL string
+}
+
+func NewOAuthHandler(
+	introspectUC oauthusecase.IntrospectUseCase,
+	tokenExchangeUC oauthusecase.TokenExchangeUseCase,
+	authorizeUC oauthusecase.AuthorizeUseCase,
+	consentUC oauthusecase.ConsentUseCase,
+	codeGrantUC oauthusecase.AuthorizationCodeGrantUseCase,
+	refreshGrantUC oauthusecase.RefreshTokenGrantUseCase,
+	clientGrantUC oauthusecase.ClientCredentialsGrantUseCase,
+	dpop *security.DPoPVerifier,
+	issuer string,
+	loginURL string,
+	consentURL string,
+) OAuthHandler {
+	return &oauthHandler{
+		introspectUC:    introspectUC,
+		tokenExchangeUC: tokenExchangeUC,
+		authorizeUC:     authorizeUC,
+		consentUC:       consentUC,
+		codeGrantUC:     codeGrantUC,
+		refreshGrantUC:  refreshGrantUC,
+		clientGrantUC:   clientGrantUC,
+		dpop:            dpop,
+		issuer:          strings.TrimRight(issuer, "/"),
+		loginURL:        loginURL,
+		consentURL:      consentURL,
+	}
+}
+
+func (h *oauthHandler) Introspect(c *fiber.Ctx) error {
+	var req oauth.IntrospectRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	resp, err := h.introspectUC.Execute(c.Context(), &req)
+	if err != nil {
+		return sendOAuthError(c, fiber.StatusInternalServerError, "server_error", "failed to introspect token")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// Token is the OAuth token endpoint, dispatching on grant_type.
+func (h *oauthHandler) Token(c *fiber.Ctx) error {
+	var req oauth.TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "invalid request body")
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get("User-Agent")
+	if id, secret, ok := basicCredentials(c.Get(fiber.HeaderAuthorization)); ok {
+		// RFC 6749 section 2.3: one authentication method per request
+		if req.ClientSecret != "" || (req.ClientID != "" && req.ClientID != id) {
+			return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "multiple client authentication methods")
+		}
+		req.ClientID, req.ClientSecret = id, secret
+	}
+	if proof := c.Get("DPoP"); proof != "" {
+		verified, err := h.dpop.Verify(proof, c.Method(), c.Path(), "")
+		if err != nil {
+			return sendOAuthError(c, fiber.StatusBadRequest, oauth.ErrInvalidDPoPProof.Error(), "invalid DPoP proof")
+		}
+		req.DPoPJKT = verified.JKT
+	}
+
+	var (
+		resp *oauth.TokenResponse
+		err  error
+	)
+	switch req.GrantType {
+	case oauth.GrantTypeAuthorizationCode:
+		resp, err = h.codeGrantUC.Execute(c.Context(), &req)
+	case oauth.GrantTypeRefreshToken:
+		resp, err = h.refreshGrantUC.Execute(c.Context(), &req)
+	case oauth.GrantTypeClientCredentials:
+		resp, err = h.clientGrantUC.Execute(c.Context(), &req)
+	case oauth.GrantTypeTokenExchange:
+		resp, err = h.tokenExchangeUC.Execute(c.Context(), &req)
+	default:
+		err = oauth.ErrUnsupportedGrantType
+	}
+	if err != nil {
+		return sendOAuthErrorFrom(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// Authorize is the authorization endpoint. The browser is sent to the login
+// page when nobody is signed in, to the consent page when a third-party
+// client needs approval, and otherwise straight back to the client.
+func (h *oauthHandler) Authorize(c *fiber.Ctx) error {
+	var req oauth.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "invalid authorization request")
+	}
+
+	var userID string
+	if claims, ok := middleware.ClaimsFromContext(c); ok {
+		// delegated tokens must not hand out further delegations
+		if claims.IsImpersonated() || claims.ClientID != "" {
+			return sendOAuthError(c, fiber.StatusForbidden, "access_denied", "sign in directly to authorize applications")
+		}
+		userID = claims.UserID
+	}
+
+	resp, err := h.authorizeUC.Execute(c.Context(), &req, userID)
+	if err != nil {
+		return sendOAuthErrorFrom(c, err)
+	}
+
+	switch {
+	case resp.LoginRequired:
+		returnTo := h.issuer + c.OriginalURL()
+		return c.Redirect(withQuery(h.loginURL, url.Values{"return_to": {returnTo}}), fiber.StatusFound)
+	case resp.Consent != nil:
+		return c.Redirect(withQuery(h.consentURL, url.Values{
+			"consent_token": {resp.Consent.ConsentToken},
+			"client_id":     {resp.Consent.ClientID},
+			"client_name":   {resp.Consent.ClientName},
+			"scope":         {strings.Join(resp.Consent.Scopes, " ")},
+		}), fiber.StatusFound)
+	default:
+		return c.Redirect(resp.RedirectTo, fiber.StatusFound)
+	}
+}
+
+// Consent takes the user's decision from the consent page and returns the
+// client redirect the page should navigate to.
+func (h *oauthHandler) Consent(c *fiber.Ctx) error {
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return SendError(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+	if claims.IsImpersonated() || claims.ClientID != "" {
+		return SendError(c, fiber.StatusForbidden, "sign in directly to authorize applications")
+	}
+
+	var req oauth.ConsentRequest
+	if err := c.BodyParser(&req); err != nil || req.ConsentToken == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	resp, err := h.consentUC.Execute(c.Context(), &req, claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrInvalidChallenge):
+			return SendError(c, fiber.StatusBadRequest, "consent request expired or invalid")
+		case errors.Is(err, oauth.ErrInvalidRequest):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, "failed to record consent")
+		}
+	}
+	return SendSuccess(c, fiber.StatusOK, "consent recorded", resp)
+}
+
+// basicCredentials decodes client_secret_basic credentials, which are form
+// encoded before being base64 encoded (RFC 6749 section 2.3.1).
+func basicCredentials(header string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+	rawID, rawSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+	id, err := url.QueryUnescape(rawID)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err := url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+func withQuery(base string, params url.Values) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func sendOAuthError(c *fiber.Ctx, status int, code, description string) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(status).JSON(oauthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+// sendOAuthErrorFrom maps domain errors onto RFC 6749 error responses. The
+// wrapped message after the error code becomes the description.
+func sendOAuthErrorFrom(c *fiber.Ctx, err error) error {
+	codes := []struct {
+		target error
+		status int
+	}{
+		{oauth.ErrInvalidRequest, fiber.StatusBadRequest},
+		{oauth.ErrInvalidClient, fiber.StatusUnauthorized},
+		{oauth.ErrInvalidGrant, fiber.StatusBadRequest},
+		{oauth.ErrInvalidScope, fiber.StatusBadRequest},
+		{oauth.ErrUnauthorizedClient, fiber.StatusBadRequest},
+		{oauth.ErrUnsupportedGrantType, fiber.StatusBadRequest},
+		{oauth.ErrUnsupportedResponseType, fiber.StatusBadRequest},
+		{oauth.ErrAccessDenied, fiber.StatusForbidden},
+		{oauth.ErrInvalidDPoPProof, fiber.StatusBadRequest},
+	}
+	for _, code := range codes {
+		if errors.Is(err, code.target) {
+			description := strings.TrimPrefix(err.Error(), code.target.Error())
+			description = strings.TrimPrefix(description, ": ")
+			return sendOAuthError(c, code.status, code.target.Error(), description)
+		}
+	}
+	return sendOAuthError(c, fiber.StatusInternalServerError, "server_error", "")
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	usecase "mikhailjbs/user-auth-service/internal/usecase/user"
+)
+
+// OIDCHandler exposes the OpenID Connect provider endpoints.
+type OIDCHandler interface {
+	UserInfo(c *fiber.Ctx) error
+}
+
+type oidcHandler struct {
+	getUserUC usecase.GetUserUseCase
+}
+
+func NewOIDCHandler(getUserUC usecase.GetUserUseCase) OIDCHandler {
+	return &oidcHandler{getUserUC: getUserUC}
+}
+
+// UserInfo returns the standard claims for the subject of the access token.
+// Like the discovery document the response is not wrapped in the API envelope.
+func (h *oidcHandler) UserInfo(c *fiber.Ctx) error {
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return SendError(c, fiber.StatusUnauthorized, "missing access token")
+	}
+
+	u, err := h.getUserUC.Execute(c.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return SendError(c, fiber.StatusUnauthorized, "user no longer exists")
+		}
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	info := map[string]interface{}{
+		"sub":                u.ID,
+		"email":              u.Email,
+		"email_verified":     u.EmailVerified,
+		"name":               u.Fullname,
+		"preferred_username": u.Username,
+		"updated_at":         u.UpdatedAt.Unix(),
+	}
+	if u.Avatar != nil {
+		info["picture"] = *u.Avatar
+	}
+
+	return c.Status(fiber.StatusOK).JSON(info)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/middleware"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestOpenIDConfiguration(t *testing.T) {
+	tm, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		Issuer:        "https://auth.example.com/",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Get("/.well-known/openid-configuration", NewWellKnownHandler(tm).OpenIDConfiguration)
+
+	var doc map[string]any
+	status := getJSON(t, app, "/.well-known/openid-configuration", &doc)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	want := map[string]any{
+		"issuer":            "https://auth.example.com",
+		"jwks_uri":          "https://auth.example.com/.well-known/jwks.json",
+		"userinfo_endpoint": "https://auth.example.com/userinfo",
+		"token_endpoint":    "https://auth.example.com/api/v1/oauth/token",
+	}
+	for k, v := range want {
+		if doc[k] != v {
+			t.Errorf("%s = %v, want %v", k, doc[k], v)
+		}
+	}
+	if algs, _ := doc["id_token_signing_alg_values_supported"].([]any); len(algs) != 1 || algs[0] != tm.Algorithm() {
+		t.Errorf("id_token_signing_alg_values_supported = %v", doc["id_token_signing_alg_values_supported"])
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	avatar := "https://cdn.example.com/jane.png"
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	users := fixedUsers{"user-1": {ID: "user-1", Username: "jane", Fullname: "Jane Doe", Email: "jane@example.com", EmailVerified: true, Avatar: &avatar, UpdatedAt: updated}}
+
+	tests := []struct {
+		name       string
+		claims     *security.ClaimsPayload
+		wantStatus int
+		want       map[string]any
+	}{
+		{
+			name:       "standard claims",
+			claims:     &security.ClaimsPayload{UserID: "user-1"},
+			wantStatus: fiber.StatusOK,
+			want: map[string]any{
+				"sub": "user-1", "email": "jane@example.com", "email_verified": true, "name": "Jane Doe",
+				"preferred_username": "jane", "picture": avatar, "updated_at": float64(updated.Unix()),
+			},
+		},
+		{name: "deleted user", claims: &security.ClaimsPayload{UserID: "user-2"}, wantStatus: fiber.StatusUnauthorized},
+		{name: "no access token", wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/userinfo", func(c *fiber.Ctx) error {
+				if tt.claims != nil {
+					c.Locals(middleware.DefaultClaimsContextKey, tt.claims)
+				}
+				return c.Next()
+			}, NewOIDCHandler(users).UserInfo)
+
+			var body map[string]any
+			if status := getJSON(t, app, "/userinfo", &body); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			for k, v := range tt.want {
+				if body[k] != v {
+					t.Errorf("%s = %v, want %v", k, body[k], v)
+				}
+			}
+		})
+	}
+}
+
+func getJSON(t *testing.T, app *fiber.App, path string, out any) int {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+type fixedUsers map[string]*user.User
+
+func (f fixedUsers) Execute(_ context.Context, id string) (*user.User, error) {
+	if u, ok := f[id]; ok {
+		return u, nil
+	}
+	return nil, user.ErrNotFound
+}
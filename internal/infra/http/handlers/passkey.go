@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+	passkeyusecase "mikhailjbs/user-auth-service/internal/usecase/passkey"
+)
+
+// PasskeyHandler lets a signed-in user register, list and remove passkeys.
+// Passkey sign-in lives on AuthHandler next to the other login methods.
+type PasskeyHandler interface {
+	BeginRegistration(c *fiber.Ctx) error
+	FinishRegistration(c *fiber.Ctx) error
+	ListCredentials(c *fiber.Ctx) error
+	DeleteCredential(c *fiber.Ctx) error
+}
+
+type passkeyHandler struct {
+	beginRegistrationUC  passkeyusecase.BeginRegistrationUseCase
+	finishRegistrationUC passkeyusecase.FinishRegistrationUseCase
+	listCredentialsUC    passkeyusecase.ListCredentialsUseCase
+	deleteCredentialUC   passkeyusecase.DeleteCredentialUseCase
+}
+
+func NewPasskeyHandler(
+	beginRegistrationUC passkeyusecase.BeginRegistrationUseCase,
+	finishRegistrationUC passkeyusecase.FinishRegistrationUseCase,
+	listCredentialsUC passkeyusecase.ListCredentialsUseCase,
+	deleteCredentialUC passkeyusecase.DeleteCredentialUseCase,
+) PasskeyHandler {
+	return &passkeyHandler{
+		beginRegistrationUC:  beginRegistrationUC,
+		finishRegistrationUC: finishRegistrationUC,
+		listCredentialsUC:    listCredentialsUC,
+		deleteCredentialUC:   deleteCredentialUC,
+	}
+}
+
+func (h *passkeyHandler) BeginRegistration(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	options, err := h.beginRegistrationUC.Execute(c.Context(), claims.UserID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, "failed to start passkey registration")
+	}
+	return SendSuccess(c, fiber.StatusOK, "passkey registration started", options)
+}
+
+func (h *passkeyHandler) FinishRegistration(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+	var req passkey.RegistrationFinishRequest
+	if err := c.BodyParser(&req); err != nil || req.CeremonyToken == "" {
+		return SendError(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	cred, err := h.finishRegistrationUC.Execute(c.Context(), claims.UserID, &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrInvalidChallenge), isWebAuthnError(err):
+			return SendError(c, fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, passkey.ErrAlreadyExists):
+			return SendError(c, fiber.StatusConflict, err.Error())
+		default:
+			return SendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+	}
+	return SendSuccess(c, fiber.StatusCreated, "passkey registered", cred)
+}
+
+func (h *passkeyHandler) ListCredentials(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	creds, err := h.listCredentialsUC.Execute(c.Context(), claims.UserID)
+	if err != nil {
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	return SendSuccess(c, fiber.StatusOK, "passkeys retrieved", creds)
+}
+
+func (h *passkeyHandler) DeleteCredential(c *fiber.Ctx) error {
+	claims, denied := accountOwner(c)
+	if denied != nil {
+		return SendError(c, denied.Code, denied.Message)
+	}
+
+	if err := h.deleteCredentialUC.Execute(c.Context(), claims.UserID, c.Params("id"), c.IP(), c.Get("User-Agent")); err != nil {
+		if errors.Is(err, passkey.ErrNotFound) {
+			return SendError(c, fiber.StatusNotFound, err.Error())
+		}
+		return SendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	return SendSuccess(c, fiber.StatusOK, "passkey removed", nil)
+}
+
+// isWebAuthnError reports whether err is a verification failure of the
+// browser's response rather than a server fault.
+func isWebAuthnError(err error) bool {
+	for _, target := range []error{
+		webauthn.ErrInvalidResponse,
+		webauthn.ErrChallenge,
+		webauthn.ErrOrigin,
+		webauthn.ErrRPID,
+		webauthn.ErrUserNotVerified,
+		webauthn.ErrSignCount,
+		webauthn.ErrUnsupportedKey,
+		webauthn.ErrBadSignature,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
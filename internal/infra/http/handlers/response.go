@@ -10,9 +10,17 @@ type SuccessResponse struct {
 }
 
 type ErrorResponse struct {
-	Ok     bool   `json:"ok"`
-	Status int    `json:"status"`
-	Error  string `json:"error"`
+	Ok     bool         `json:"ok"`
+	Status int          `json:"status"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError says what is wrong with one field of the request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func SendSuccess(c *fiber.Ctx, status int, message string, data interface{}) error {
@@ -31,3 +39,13 @@ func SendError(c *fiber.Ctx, status int, errMessage string) error {
 		Error:  errMessage,
 	})
 }
+
+// SendFieldErrors is SendError for a request rejected field by field.
+func SendFieldErrors(c *fiber.Ctx, status int, errMessage string, fields []FieldError) error {
+	return c.Status(status).JSON(ErrorResponse{
+		Ok:     false,
+		Status: status,
+		Error:  errMessage,
+		Fields: fields,
+	})
+}
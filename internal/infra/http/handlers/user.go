@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+
+	"mikhailjbs/user-auth-service/internal/domain/password"
 	"mikhailjbs/user-auth-service/internal/domain/user"
 	usecase "mikhailjbs/user-auth-service/internal/usecase/user"
 
@@ -47,6 +50,10 @@ func (h *userHandler) CreateUser(c *fiber.Ctx) error {
 
 	createdUser, err := h.createUserUC.Execute(c.Context(), &req)
 	if err != nil {
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			return sendPasswordRejected(c, policyErr)
+		}
 		if err == user.ErrEmailTaken {
 			return SendError(c, fiber.StatusConflict, err.Error())
 		}
@@ -98,6 +105,10 @@ func (h *userHandler) UpdateUser(c *fiber.Ctx) error {
 
 	updatedUser, err := h.updateUserUC.Execute(c.Context(), id, &req)
 	if err != nil {
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			return sendPasswordRejected(c, policyErr)
+		}
 		if err == user.ErrNotFound {
 			return SendError(c, fiber.StatusNotFound, err.Error())
 		}
@@ -117,3 +128,12 @@ func (h *userHandler) DeleteUser(c *fiber.Ctx) error {
 	}
 	return SendSuccess(c, fiber.StatusOK, "User deleted successfully", nil)
 }
+
+// sendPasswordRejected lists every password rule the request broke.
+func sendPasswordRejected(c *fiber.Ctx, err *password.PolicyError) error {
+	fields := make([]FieldError, len(err.Violations))
+	for i, v := range err.Violations {
+		fields[i] = FieldError{Field: v.Field, Code: v.Code, Message: v.Message}
+	}
+	return SendFieldErrors(c, fiber.StatusBadRequest, "password does not meet the policy", fields)
+}
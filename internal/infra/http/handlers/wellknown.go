@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// WellKnownHandler serves the public discovery documents other services use
+// to verify our tokens.
+type WellKnownHandler interface {
+	JWKS(c *fiber.Ctx) error
+	OpenIDConfiguration(c *fiber.Ctx) error
+}
+
+type wellKnownHandler struct {
+	tokenManager *security.TokenManager
+}
+
+func NewWellKnownHandler(tokenManager *security.TokenManager) WellKnownHandler {
+	return &wellKnownHandler{tokenManager: tokenManager}
+}
+
+// JWKS returns the JSON Web Key Set as-is (not wrapped in the API envelope)
+// so standard JWT libraries can consume it directly.
+func (h *wellKnownHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.tokenManager.JWKS())
+}
+
+// OpenIDConfiguration serves the OIDC discovery document. Endpoint URLs are
+// derived from the issuer, which must be the externally visible base URL.
+func (h *wellKnownHandler) OpenIDConfiguration(c *fiber.Ctx) error {
+	issuer := strings.TrimRight(h.tokenManager.Issuer(), "/")
+	doc := map[string]interface{}{
+		"issuer":                                issuer,
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"authorization_endpoint":                issuer + "/api/v1/oauth/authorize",
+		"token_endpoint":                        issuer + "/api/v1/oauth/token",
+		"introspection_endpoint":                issuer + "/api/v1/oauth/introspect",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials", "urn:ietf:params:oauth:grant-type:token-exchange"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{h.tokenManager.Algorithm()},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"claims_supported": []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "sid",
+			"email", "email_verified", "name", "preferred_username", "picture",
+		},
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(doc)
+}
@@ -1,27 +1,125 @@
 package http
 
 import (
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
 	"mikhailjbs/user-auth-service/internal/infra/http/handlers"
 	"mikhailjbs/user-auth-service/internal/infra/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterRoutes(app *fiber.App, userHandler handlers.UserHandler, authHandler handlers.AuthHandler, authz *middleware.AuthMiddleware) {
+func RegisterRoutes(app *fiber.App, userHandler handlers.UserHandler, authHandler handlers.AuthHandler, wellKnownHandler handlers.WellKnownHandler, adminHandler handlers.AdminHandler, oidcHandler handlers.OIDCHandler, oauthHandler handlers.OAuthHandler, mfaHandler handlers.MFAHandler, passkeyHandler handlers.PasskeyHandler, apiKeyHandler handlers.APIKeyHandler, authz *middleware.AuthMiddleware, limiter *middleware.RateLimiter) {
+	wellKnown := app.Group("/.well-known")
+	wellKnown.Get("/jwks.json", wellKnownHandler.JWKS)
+	wellKnown.Get("/openid-configuration", wellKnownHandler.OpenIDConfiguration)
+
+	live := middleware.Policy{RequireLiveSession: true}
+	// managing sign-in methods is for the user's own session, never for
+	// tokens handed to OAuth clients
+	account := middleware.Policy{RequireLiveSession: true, FirstPartyOnly: true}
+
+	// unauthenticated endpoints that check a secret or send mail
+	loginLimit := limiter.Limit(
+		middleware.RateLimit{Name: "login-ip", Limit: ratelimit.Limit{Burst: 20, Per: time.Minute}, Key: middleware.ByIP},
+		middleware.RateLimit{Name: "login-account", Limit: ratelimit.Limit{Burst: 10, Per: 15 * time.Minute}, Key: middleware.ByAccount},
+	)
+	signupLimit := limiter.Limit(
+		middleware.RateLimit{Name: "signup-ip", Limit: ratelimit.Limit{Burst: 10, Per: time.Hour}, Key: middleware.ByIP},
+	)
+	mailLimit := limiter.Limit(
+		middleware.RateLimit{Name: "mail-ip", Limit: ratelimit.Limit{Burst: 20, Per: time.Hour}, Key: middleware.ByIP},
+		middleware.RateLimit{Name: "mail-account", Limit: ratelimit.Limit{Burst: 5, Per: time.Hour}, Key: middleware.ByAccount},
+	)
+	challengeLimit := limiter.Limit(
+		middleware.RateLimit{Name: "challenge-ip", Limit: ratelimit.Limit{Burst: 30, Per: time.Minute}, Key: middleware.ByIP},
+	)
+	// second factor changes check a code a stolen token could guess at
+	mfaLimit := limiter.Limit(
+		middleware.RateLimit{Name: "mfa-user", Limit: ratelimit.Limit{Burst: 10, Per: 15 * time.Minute}, Key: middleware.ByUser},
+	)
+	refreshLimit := limiter.Limit(
+		middleware.RateLimit{Name: "refresh-ip", Limit: ratelimit.Limit{Burst: 60, Per: time.Minute}, Key: middleware.ByIP},
+	)
+	tokenLimit := limiter.Limit(
+		middleware.RateLimit{Name: "token-client", Limit: ratelimit.Limit{Burst: 120, Per: time.Minute}, Key: middleware.ByClientID},
+		middleware.RateLimit{Name: "token-ip", Limit: ratelimit.Limit{Burst: 120, Per: time.Minute}, Key: middleware.ByIP},
+	)
+	// admin scripts and CI jobs may use an API key with the admin scope
+	adminOnly := middleware.Policy{Roles: []string{"admin"}, RequireLiveSession: true, AllowAPIKeys: true}
+
+	app.Get("/userinfo", authz.Require(live), oidcHandler.UserInfo)
+	app.Post("/userinfo", authz.Require(live), oidcHandler.UserInfo)
+
 	api := app.Group("/api")
 	v1 := api.Group("/v1")
 
-	users := v1.Group("/users", authz.Require(middleware.Policy{Roles: []string{"admin"}}))
+	users := v1.Group("/users", authz.Require(adminOnly))
 	users.Post("/", userHandler.CreateUser)
 	users.Get("/", userHandler.GetUsers)
 	users.Get("/:id", userHandler.GetUser)
 	users.Put("/:id", userHandler.UpdateUser)
 	users.Delete("/:id", userHandler.DeleteUser)
 
+	admin := v1.Group("/admin", authz.Require(adminOnly))
+	admin.Get("/keys", adminHandler.ListSigningKeys)
+	admin.Post("/keys/rotate", adminHandler.RotateSigningKeys)
+	admin.Post("/tokens/revoke", adminHandler.RevokeToken)
+	admin.Post("/sessions/:id/revoke", adminHandler.RevokeSession)
+	admin.Get("/impersonations", adminHandler.ListImpersonations)
+	admin.Get("/security-events", adminHandler.ListSecurityEvents)
+	admin.Get("/users/:id/lockout", adminHandler.GetLockout)
+	admin.Delete("/users/:id/lockout", adminHandler.Unlock)
+	admin.Post("/oauth/clients", adminHandler.RegisterOAuthClient)
+	admin.Get("/oauth/clients", adminHandler.ListOAuthClients)
+	admin.Delete("/oauth/clients/:id", adminHandler.DeleteOAuthClient)
+
+	// resource servers authenticate with a client credentials token, or an
+	// admin access token
+	introspector := middleware.Policy{Roles: []string{"admin"}, RequireLiveSession: true, ClientScopes: []string{"introspect"}}
+	oauth := v1.Group("/oauth")
+	oauth.Post("/introspect", authz.Require(introspector), oauthHandler.Introspect)
+	oauth.Post("/token", tokenLimit, oauthHandler.Token)
+	// signing in happens on the login page; anonymous visitors are sent there
+	oauth.Get("/authorize", authz.Require(middleware.Policy{AllowAnonymous: true, RequireLiveSession: true}), oauthHandler.Authorize)
+	oauth.Post("/authorize/consent", authz.Require(account), oauthHandler.Consent)
+
 	auth := v1.Group("/auth")
-	auth.Post("/register", authHandler.Register)
-	auth.Post("/login", authHandler.Login)
-	auth.Post("/refresh", authHandler.Refresh)
-	auth.Post("/logout", authz.Require(middleware.Policy{}), authHandler.Logout)
-	auth.Get("/me", authz.Require(middleware.Policy{}), authHandler.Me)
+	auth.Post("/register", signupLimit, authHandler.Register)
+	auth.Post("/login", loginLimit, authHandler.Login)
+	auth.Post("/verify-email", challengeLimit, authHandler.VerifyEmail)
+	auth.Post("/resend-verification", mailLimit, authHandler.ResendVerification)
+	auth.Post("/password/forgot", mailLimit, authHandler.ForgotPassword)
+	auth.Post("/password/reset", challengeLimit, authHandler.ResetPassword)
+	auth.Post("/magic-link", mailLimit, authHandler.RequestMagicLink)
+	auth.Get("/magic-link/consume", challengeLimit, authHandler.ConsumeMagicLink)
+	auth.Post("/magic-link/consume", challengeLimit, authHandler.ConsumeMagicLink)
+	auth.Post("/refresh", refreshLimit, authHandler.Refresh)
+	auth.Post("/logout", authz.Require(middleware.Policy{AllowRestricted: true}), authHandler.Logout)
+	auth.Get("/me", authz.Require(live), authHandler.Me)
+
+	auth.Post("/mfa/verify", challengeLimit, authHandler.VerifyMFA)
+	auth.Post("/mfa/totp", authz.Require(account), mfaHandler.EnrollTOTP)
+	auth.Post("/mfa/totp/confirm", authz.Require(account), mfaLimit, mfaHandler.ConfirmTOTP)
+	auth.Delete("/mfa/totp", authz.Require(account), mfaLimit, mfaHandler.DisableTOTP)
+	auth.Post("/mfa/recovery-codes", authz.Require(account), mfaHandler.GenerateRecoveryCodes)
+
+	recovering := middleware.Policy{RequireLiveSession: true, AllowRestricted: true, FirstPartyOnly: true}
+	auth.Post("/recovery/login", loginLimit, authHandler.RecoveryLogin)
+	auth.Post("/recovery/complete", authz.Require(recovering), authHandler.CompleteRecovery)
+
+	auth.Post("/webauthn/login/begin", challengeLimit, authHandler.PasskeyLoginBegin)
+	auth.Post("/webauthn/login/finish", challengeLimit, authHandler.PasskeyLoginFinish)
+	auth.Post("/webauthn/register/begin", authz.Require(account), passkeyHandler.BeginRegistration)
+	auth.Post("/webauthn/register/finish", authz.Require(account), passkeyHandler.FinishRegistration)
+	auth.Get("/webauthn/credentials", authz.Require(account), passkeyHandler.ListCredentials)
+	auth.Delete("/webauthn/credentials/:id", authz.Require(account), passkeyHandler.DeleteCredential)
+
+	auth.Post("/api-keys", authz.Require(account), apiKeyHandler.CreateKey)
+	auth.Get("/api-keys", authz.Require(account), apiKeyHandler.ListKeys)
+	auth.Delete("/api-keys/:id", authz.Require(account), apiKeyHandler.RevokeKey)
+
+	auth.Get("/idp/:provider/start", authHandler.ExternalLoginStart)
+	auth.Get("/idp/:provider/callback", authHandler.ExternalLoginCallback)
 }
@@ -0,0 +1,105 @@
+package idp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+)
+
+var defaultGitHubScopes = []string{"read:user", "user:email"}
+
+// githubProvider signs in with GitHub, which issues no ID token: the identity
+// comes from the REST API using the access token.
+type githubProvider struct {
+	cfg    Config
+	client *http.Client
+}
+
+func newGitHubProvider(cfg Config, client *http.Client) *githubProvider {
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = githubAuthURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = githubTokenURL
+	}
+	if cfg.UserInfoURL == "" {
+		cfg.UserInfoURL = githubAPIURL + "/user"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultGitHubScopes
+	}
+	return &githubProvider{cfg: cfg, client: client}
+}
+
+func (p *githubProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {req.State},
+		"code_challenge":        {req.CodeChallenge},
+		"code_challenge_method": {"S256"},
+		"allow_signup":          {"false"},
+	}
+	return appendQuery(p.cfg.AuthURL, q)
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURI, nonce string) (*Identity, error) {
+	tok, err := redeemCode(ctx, p.client, p.cfg.TokenURL, p.cfg, code, codeVerifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var u githubUser
+	if err := getJSON(ctx, p.client, p.cfg.UserInfoURL, tok.AccessToken, &u); err != nil {
+		return nil, err
+	}
+	if u.ID == 0 {
+		return nil, ErrProvider
+	}
+	id := &Identity{
+		Provider: p.cfg.Name,
+		Subject:  strconv.FormatInt(u.ID, 10),
+		Name:     u.Name,
+		Username: u.Login,
+	}
+
+	// the profile email is user-editable and unverified; use the primary
+	// address from the emails API instead
+	var emails []githubEmail
+	if err := getJSON(ctx, p.client, strings.TrimSuffix(p.cfg.UserInfoURL, "/user")+"/user/emails", tok.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			id.Email, id.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+	return id, nil
+}
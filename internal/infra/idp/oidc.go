@@ -0,0 +1,264 @@
+package idp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const (
+	// discoveryTTL is how long discovery documents and keys are cached.
+	discoveryTTL = time.Hour
+	// minKeyRefresh stops an unknown kid from turning into a fetch per request.
+	minKeyRefresh = time.Minute
+	idTokenLeeway = 30 * time.Second
+)
+
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider is a generic OpenID Connect provider configured through
+// discovery.
+type oidcProvider struct {
+	cfg    Config
+	client *http.Client
+
+	mu           sync.Mutex
+	doc          *discoveryDocument
+	docFetchedAt time.Time
+	keys         map[string]any
+	keysAt       time.Time
+}
+
+func newOIDCProvider(cfg Config, client *http.Client) *oidcProvider {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultOIDCScopes
+	}
+	return &oidcProvider{cfg: cfg, client: client}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {req.RedirectURI},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {req.CodeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	return appendQuery(doc.AuthorizationEndpoint, q)
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURI, nonce string) (*Identity, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := redeemCode(ctx, p.client, doc.TokenEndpoint, p.cfg, code, codeVerifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrInvalidIDToken)
+	}
+
+	claims, err := p.verifyIDToken(ctx, doc, tok.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	id := identityFromClaims(p.cfg.Name, claims)
+
+	// some providers keep the email out of the ID token
+	if id.Email == "" && doc.UserInfoEndpoint != "" {
+		var info map[string]any
+		if err := getJSON(ctx, p.client, doc.UserInfoEndpoint, tok.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if sub, _ := info["sub"].(string); sub == id.Subject {
+			fromInfo := identityFromClaims(p.cfg.Name, info)
+			id.Email, id.EmailVerified = fromInfo.Email, fromInfo.EmailVerified
+			if id.Name == "" {
+				id.Name = fromInfo.Name
+			}
+		}
+	}
+	return id, nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, doc *discoveryDocument, raw, nonce string) (map[string]any, error) {
+	token, err := jwt.Parse(raw, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, doc, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidIDToken
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	// with several audiences the token must name us as the authorized party
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, fmt.Errorf("%w: azp mismatch", ErrInvalidIDToken)
+		}
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// key returns the provider's verification key for kid, refetching the key
+// set once when the kid is unknown (the provider may have rotated).
+func (p *oidcProvider) key(ctx context.Context, doc *discoveryDocument, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stale := time.Since(p.keysAt) > discoveryTTL
+	if k, ok := p.lookupKey(kid); ok && !stale {
+		return k, nil
+	}
+	if !stale && time.Since(p.keysAt) < minKeyRefresh {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set security.JWKSet
+	if err := getJSON(ctx, p.client, doc.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = pub
+	}
+	p.keys, p.keysAt = keys, time.Now()
+
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// lookupKey finds kid; a token without kid is accepted only when the set
+// holds a single key.
+func (p *oidcProvider) lookupKey(kid string) (any, bool) {
+	if kid == "" {
+		if len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k, true
+			}
+		}
+		return nil, false
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.doc != nil && time.Since(p.docFetchedAt) < discoveryTTL {
+		return p.doc, nil
+	}
+
+	var doc discoveryDocument
+	if err := getJSON(ctx, p.client, p.cfg.Issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		if p.doc != nil {
+			// keep serving the last good document while the provider is down
+			return p.doc, nil
+		}
+		return nil, err
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match %q", ErrProvider, doc.Issuer, p.cfg.Issuer)
+	}
+	// explicit endpoints win over discovered ones
+	if p.cfg.AuthURL != "" {
+		doc.AuthorizationEndpoint = p.cfg.AuthURL
+	}
+	if p.cfg.TokenURL != "" {
+		doc.TokenEndpoint = p.cfg.TokenURL
+	}
+	if p.cfg.UserInfoURL != "" {
+		doc.UserInfoEndpoint = p.cfg.UserInfoURL
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrProvider)
+	}
+
+	p.doc, p.docFetchedAt = &doc, time.Now()
+	return p.doc, nil
+}
+
+func identityFromClaims(provider string, claims map[string]any) *Identity {
+	id := &Identity{Provider: provider}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	id.Username, _ = claims["preferred_username"].(string)
+	// some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	}
+	return id
+}
+
+func appendQuery(endpoint string, q url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	existing := u.Query()
+	for k, v := range q {
+		existing[k] = v
+	}
+	u.RawQuery = existing.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,227 @@
+// Package idp signs users in with external identity providers: any OpenID
+// Connect provider (Google included) and GitHub, which only speaks OAuth 2.0.
+// Every flow is an authorization code flow with PKCE (RFC 7636).
+package idp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+
+	// maxResponseSize bounds what we read from a provider.
+	maxResponseSize = 1 << 20
+	httpTimeout     = 10 * time.Second
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrExchange        = errors.New("identity provider rejected the authorization code")
+	ErrInvalidIDToken  = errors.New("invalid ID token from identity provider")
+	ErrProvider        = errors.New("identity provider unavailable")
+)
+
+// Config configures one provider. Endpoints left empty are discovered (OIDC)
+// or defaulted (GitHub).
+type Config struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	// Issuer is the OIDC issuer URL; discovery is done against it.
+	Issuer      string
+	Scopes      []string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+// Identity is who the provider says the user is.
+type Identity struct {
+	Provider string
+	// Subject is the provider's stable user id.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// AuthRequest holds the per-flow values sent to the authorization endpoint.
+type AuthRequest struct {
+	State         string
+	Nonce         string
+	CodeChallenge string
+	RedirectURI   string
+}
+
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser is sent to sign in.
+	AuthCodeURL(ctx context.Context, req AuthRequest) (string, error)
+	// Exchange redeems the authorization code and returns the identity.
+	Exchange(ctx context.Context, code, codeVerifier, redirectURI, nonce string) (*Identity, error)
+}
+
+// New builds the provider described by cfg.
+func New(cfg Config, client *http.Client) (Provider, error) {
+	if cfg.Name == "" || cfg.ClientID == "" {
+		return nil, errors.New("idp: provider name and client id are required")
+	}
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+	switch cfg.Type {
+	case TypeGitHub:
+		return newGitHubProvider(cfg, client), nil
+	case TypeOIDC, "":
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("idp: provider %q needs an issuer", cfg.Name)
+		}
+		return newOIDCProvider(cfg, client), nil
+	default:
+		return nil, fmt.Errorf("idp: provider %q has unknown type %q", cfg.Name, cfg.Type)
+	}
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names lists the configured providers, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPKCE returns a code verifier and its S256 challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	if verifier, err = RandomString(); err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns 32 random bytes, base64url encoded, for state and
+// nonce values.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// redeemCode posts the authorization code to the token endpoint, sending the
+// client secret in the body (client_secret_post).
+func redeemCode(ctx context.Context, client *http.Client, tokenURL string, cfg Config, code, codeVerifier, redirectURI string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok tokenResponse
+	status, err := doJSON(client, req, &tok)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tok.Error != "" || tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchange, tok.Error, tok.ErrorDescription)
+	}
+	return &tok, nil
+}
+
+// getJSON fetches url, with a bearer token when one is given.
+func getJSON(ctx context.Context, client *http.Client, url, bearer string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	status, err := doJSON(client, req, out)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %d", ErrProvider, url, status)
+	}
+	return nil
+}
+
+func doJSON(client *http.Client, req *http.Request, out any) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+			return 0, fmt.Errorf("%w: malformed response from %s", ErrProvider, req.URL.Host)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,94 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+const (
+	// asyncSendTimeout bounds one background delivery.
+	asyncSendTimeout = time.Minute
+	asyncWorkers     = 4
+	asyncQueueSize   = 1000
+)
+
+// AsyncMailer hands messages to another Mailer through a bounded queue
+// served by a fixed set of workers, so a request takes as long whether or
+// not it sent an email. Delivery errors are logged rather than returned.
+// When the queue is full the message is dropped and logged: blocking would
+// show in the timing again.
+type AsyncMailer struct {
+	next  Mailer
+	queue chan Message
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewAsyncMailer(next Mailer) *AsyncMailer {
+	m := &AsyncMailer{
+		next:  next,
+		queue: make(chan Message, asyncQueueSize),
+	}
+	m.wg.Add(asyncWorkers)
+	for i := 0; i < asyncWorkers; i++ {
+		go m.work()
+	}
+	return m
+}
+
+// Send never fails. The request context is not used since it ends with the
+// request.
+func (m *AsyncMailer) Send(_ context.Context, msg Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		logger.Log.WithField("subject", msg.Subject).Error("mailer closed, email dropped")
+		return nil
+	}
+	select {
+	case m.queue <- msg:
+	default:
+		logger.Log.WithField("subject", msg.Subject).Error("mail queue full, email dropped")
+	}
+	return nil
+}
+
+// Close stops accepting messages and waits until the queued ones are
+// delivered or ctx is done.
+func (m *AsyncMailer) Close(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(errors.New("mail queue not drained"), ctx.Err())
+	}
+}
+
+func (m *AsyncMailer) work() {
+	defer m.wg.Done()
+	for msg := range m.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), asyncSendTimeout)
+		if err := m.next.Send(ctx, msg); err != nil {
+			logger.Log.WithError(err).WithField("subject", msg.Subject).Error("failed to deliver email")
+		}
+		cancel()
+	}
+}
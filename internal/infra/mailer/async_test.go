@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+func TestAsyncMailerDrainsOnClose(t *testing.T) {
+	logger.Init()
+	memory := NewMemoryMailer()
+	async := NewAsyncMailer(memory)
+
+	for i := 0; i < 50; i++ {
+		if err := async.Send(context.Background(), Message{To: fmt.Sprintf("user%d@example.com", i), Subject: "hi"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(memory.Messages()); got != 50 {
+		t.Fatalf("delivered %d messages, want 50", got)
+	}
+
+	// sends after Close are dropped, not delivered or panicking
+	if err := async.Send(context.Background(), Message{To: "late@example.com"}); err != nil {
+		t.Fatalf("Send() after Close error = %v", err)
+	}
+	if _, ok := memory.Last("late@example.com"); ok {
+		t.Fatal("a message sent after Close was delivered")
+	}
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+}
+
+func TestAsyncMailerCloseGivesUp(t *testing.T) {
+	logger.Init()
+	release := make(chan struct{})
+	defer close(release)
+	async := NewAsyncMailer(blockingMailer{release})
+	if err := async.Send(context.Background(), Message{To: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := async.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAsyncMailerDropsWhenFull(t *testing.T) {
+	logger.Init()
+	release := make(chan struct{})
+	async := NewAsyncMailer(blockingMailer{release})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// workers hold asyncWorkers messages, the queue the rest
+		for i := 0; i < asyncWorkers+asyncQueueSize+10; i++ {
+			_ = async.Send(context.Background(), Message{To: "a@example.com"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send() blocked on a full queue")
+	}
+	close(release)
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type blockingMailer struct {
+	release chan struct{}
+}
+
+func (b blockingMailer) Send(ctx context.Context, _ Message) error {
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderStripsHeaderInjection(t *testing.T) {
+	raw := string(render("no-reply@example.com", Message{
+		To:      "victim@example.com\r\nBcc: attacker@example.net",
+		Subject: "Reset\nBcc: attacker@example.net",
+		Body:    "line one\nline two",
+	}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	headers, body, ok := strings.Cut(raw, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", raw)
+	}
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("injected header %q", line)
+		}
+	}
+	for _, want := range []string{"To: victim@example.comBcc: attacker@example.net", "Message-ID: <", "@example.com>", "Date: Fri, 02 Jan 2026 03:04:05 +0000"} {
+		if !strings.Contains(headers, want) {
+			t.Errorf("headers missing %q:\n%s", want, headers)
+		}
+	}
+	if body != "line one\r\nline two" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -1,11 +1,20 @@
 package middleware
 
 import (
+	"errors"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
 
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
 	"mikhailjbs/user-auth-service/internal/infra/security"
+	apikeyusecase "mikhailjbs/user-auth-service/internal/usecase/apikey"
 )
 
 const (
@@ -18,11 +27,49 @@ type Config struct {
 	AccessTokenCookie string
 	ContextKey        string
 	AllowQueryToken   bool
+	// Denylist, when set, is consulted on every request so revoked tokens
+	// stop working before they expire.
+	Denylist revocation.Service
+	// Sessions backs Policy.RequireLiveSession. SessionCacheTTL bounds how
+	// long a revoked session may keep working; zero disables the cache.
+	Sessions        session.Service
+	SessionCacheTTL time.Duration
+	// DPoP verifies proofs for sender-constrained tokens. Without it, tokens
+	// carrying cnf.jkt are rejected.
+	DPoP *security.DPoPVerifier
+	// Audiences this service accepts; a token must be issued for one of them.
+	// Empty disables the audience check.
+	Audiences []string
+	// APIKeys authenticates personal API keys. Without it, API keys are
+	// rejected.
+	APIKeys apikeyusecase.AuthenticateUseCase
 }
 
 type Policy struct {
 	Roles          []string
 	AllowAnonymous bool
+	// RequireLiveSession rejects tokens whose session has been revoked or has
+	// expired, instead of trusting the token until its own exp.
+	RequireLiveSession bool
+	// AllowRestricted admits account recovery sessions, which every other
+	// route refuses.
+	AllowRestricted bool
+	// Scopes must all be present in the token's scope claim.
+	Scopes []string
+	// ClientIDs, when set, limits the route to tokens issued to one of these
+	// OAuth clients.
+	ClientIDs []string
+	// ClientScopes admits machine tokens (client credentials) that carry all
+	// of these scopes. Machine tokens have no user, roles or session, so
+	// routes without ClientScopes refuse them.
+	ClientScopes []string
+	// AllowAPIKeys admits personal API keys. They are checked against the
+	// database on every request, which stands in for RequireLiveSession.
+	AllowAPIKeys bool
+	// FirstPartyOnly refuses tokens issued to OAuth clients. Routes that
+	// manage the account's own credentials use it, so an app the user
+	// consented to cannot add a factor and outlive the consent.
+	FirstPartyOnly bool
 }
 
 type AuthMiddleware struct {
@@ -30,6 +77,12 @@ type AuthMiddleware struct {
 	accessCookie string
 	contextKey   string
 	allowQuery   bool
+	denylist     revocation.Service
+	sessions     session.Service
+	sessionCache *sessionCache
+	dpop         *security.DPoPVerifier
+	audiences    []string
+	apiKeys      apikeyusecase.AuthenticateUseCase
 }
 
 func NewAuthMiddleware(cfg Config) *AuthMiddleware {
@@ -48,20 +101,29 @@ func NewAuthMiddleware(cfg Config) *AuthMiddleware {
 		accessCookie: cfg.AccessTokenCookie,
 		contextKey:   cfg.ContextKey,
 		allowQuery:   cfg.AllowQueryToken,
+		denylist:     cfg.Denylist,
+		sessions:     cfg.Sessions,
+		sessionCache: newSessionCache(cfg.SessionCacheTTL),
+		dpop:         cfg.DPoP,
+		audiences:    cfg.Audiences,
+		apiKeys:      cfg.APIKeys,
 	}
 }
 
 func (a *AuthMiddleware) Require(policy Policy) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		token := a.extractToken(c)
+		token, kind := a.extractToken(c)
 		if token == "" {
 			if policy.AllowAnonymous {
 				return c.Next()
 			}
 			return unauthorized(c, "missing access token")
 		}
+		if kind == credentialAPIKey {
+			return a.authorizeAPIKey(c, policy, token)
+		}
 
-		claims, err := a.tokenManager.ParseAccessToken(token)
+		claims, err := a.tokenManager.ParseAccessToken(token, a.audiences...)
 		if err != nil {
 			if policy.AllowAnonymous {
 				return c.Next()
@@ -69,15 +131,160 @@ func (a *AuthMiddleware) Require(policy Policy) fiber.Handler {
 			return unauthorized(c, "invalid access token")
 		}
 
+		if err := a.verifyDPoP(c, claims, token, kind == credentialDPoP); err != nil {
+			if policy.AllowAnonymous {
+				return c.Next()
+			}
+			c.Set(fiber.HeaderWWWAuthenticate, `DPoP error="invalid_dpop_proof"`)
+			return unauthorized(c, err.Error())
+		}
+
+		if a.denylist != nil {
+			revoked, err := a.denylist.IsRevoked(claims.JTI)
+			if err != nil {
+				return unavailable(c, "unable to verify access token")
+			}
+			if revoked {
+				if policy.AllowAnonymous {
+					return c.Next()
+				}
+				return unauthorized(c, "access token revoked")
+			}
+		}
+
+		if claims.IsMachine() {
+			return a.authorizeMachine(c, policy, claims)
+		}
+
+		// impersonation and recovery must stay revocable on their own,
+		// whatever the route
+		if policy.RequireLiveSession || claims.IsImpersonated() || claims.IsRestricted() {
+			live, err := a.sessionIsLive(claims)
+			if err != nil {
+				return unavailable(c, "unable to verify session")
+			}
+			if !live {
+				if policy.AllowAnonymous {
+					return c.Next()
+				}
+				return unauthorized(c, "session expired or revoked")
+			}
+		}
+
+		if claims.IsRestricted() && !policy.AllowRestricted {
+			return forbidden(c, "recovery session may only reset credentials")
+		}
+		if policy.FirstPartyOnly && claims.ClientID != "" {
+			return forbidden(c, "route not available to OAuth clients")
+		}
+
 		if len(policy.Roles) > 0 && !hasIntersection(claims.Roles, policy.Roles) {
 			return forbidden(c, "insufficient permissions")
 		}
 
+		if !hasScopes(claims, policy.Scopes) {
+			return insufficientScope(c, policy.Scopes)
+		}
+		if len(policy.ClientIDs) > 0 && !slices.Contains(policy.ClientIDs, claims.ClientID) {
+			return forbidden(c, "token not issued to an allowed client")
+		}
+
+		if claims.IsImpersonated() {
+			logger.Log.WithFields(logrus.Fields{
+				"actor_id":   claims.Actor.Subject,
+				"subject_id": claims.UserID,
+				"session_id": claims.SID,
+				"method":     c.Method(),
+				"path":       c.Path(),
+			}).Info("impersonated request")
+		}
+
 		c.Locals(a.contextKey, claims)
 		return c.Next()
 	}
 }
 
+// authorizeAPIKey authenticates a personal API key and applies the policy to
+// it like to an access token of the key's owner.
+func (a *AuthMiddleware) authorizeAPIKey(c *fiber.Ctx, policy Policy, key string) error {
+	if a.apiKeys == nil {
+		if policy.AllowAnonymous {
+			return c.Next()
+		}
+		return unauthorized(c, "API keys are not accepted")
+	}
+	claims, err := a.apiKeys.Execute(c.Context(), key, c.IP())
+	if err != nil {
+		if !errors.Is(err, apikey.ErrInvalidKey) {
+			return unavailable(c, "unable to verify API key")
+		}
+		if policy.AllowAnonymous {
+			return c.Next()
+		}
+		return unauthorized(c, "invalid API key")
+	}
+
+	if !policy.AllowAPIKeys {
+		if policy.AllowAnonymous {
+			return c.Next()
+		}
+		return forbidden(c, "route not available to API keys")
+	}
+	if len(policy.Roles) > 0 && !hasIntersection(claims.Roles, policy.Roles) {
+		return forbidden(c, "insufficient permissions")
+	}
+	if !hasScopes(claims, policy.Scopes) {
+		return insufficientScope(c, policy.Scopes)
+	}
+	if len(policy.ClientIDs) > 0 {
+		return forbidden(c, "token not issued to an allowed client")
+	}
+
+	c.Locals(a.contextKey, claims)
+	return c.Next()
+}
+
+// authorizeMachine admits a client credentials token on routes that name the
+// scopes services need. Roles and session checks do not apply.
+func (a *AuthMiddleware) authorizeMachine(c *fiber.Ctx, policy Policy, claims *security.ClaimsPayload) error {
+	if len(policy.ClientScopes) == 0 {
+		if policy.AllowAnonymous {
+			return c.Next()
+		}
+		return forbidden(c, "route not available to service clients")
+	}
+	if !hasScopes(claims, policy.ClientScopes) {
+		return insufficientScope(c, policy.ClientScopes)
+	}
+	if len(policy.ClientIDs) > 0 && !slices.Contains(policy.ClientIDs, claims.ClientID) {
+		return forbidden(c, "token not issued to an allowed client")
+	}
+
+	c.Locals(a.contextKey, claims)
+	return c.Next()
+}
+
+func (a *AuthMiddleware) sessionIsLive(claims *security.ClaimsPayload) (bool, error) {
+	if a.sessions == nil {
+		// fail closed: a policy asked for a check we cannot perform
+		return false, errors.New("session service not configured")
+	}
+	if claims.SID == "" {
+		return false, nil
+	}
+	if live, ok := a.sessionCache.get(claims.SID); ok {
+		return live, nil
+	}
+
+	sess, err := a.sessions.GetSessionByID(claims.SID)
+	if err != nil {
+		return false, err
+	}
+	live := sess != nil && sess.Valid && sess.UserID == claims.UserID && time.Now().Before(sess.ExpiresAt)
+	a.sessionCache.set(claims.SID, live)
+	return live, nil
+}
+
 func ClaimsFromContext(c *fiber.Ctx) (*security.ClaimsPayload, bool) {
 	return ClaimsFromContextWithKey(c, DefaultClaimsContextKey)
 }
@@ -96,19 +303,69 @@ func ClaimsFromContextWithKey(c *fiber.Ctx, key string) (*security.ClaimsPayload
 	return nil, false
 }
 
-func (a *AuthMiddleware) extractToken(c *fiber.Ctx) string {
+// credentialKind is how the caller presented its credential.
+type credentialKind int
+
+const (
+	credentialBearer credentialKind = iota
+	credentialDPoP
+	credentialAPIKey
+)
+
+// extractToken returns the credential and how it was presented. API keys are
+// only recognised in the Authorization header, never in cookies or the query.
+func (a *AuthMiddleware) extractToken(c *fiber.Ctx) (string, credentialKind) {
+	if token, ok := extractDPoPToken(c.Get("Authorization")); ok {
+		return token, credentialDPoP
+	}
 	if token := extractBearerToken(c.Get("Authorization")); token != "" {
-		return token
+		if apikey.LooksLikeKey(token) {
+			return token, credentialAPIKey
+		}
+		return token, credentialBearer
 	}
 	if token := c.Cookies(a.accessCookie); token != "" {
-		return token
+		return token, credentialBearer
 	}
 	if a.allowQuery {
 		if token := c.Query("token"); token != "" {
-			return token
+			return token, credentialBearer
+		}
+	}
+	return "", credentialBearer
+}
+
+// verifyDPoP enforces sender-constraining: a token bound to a key (cnf.jkt)
+// needs a fresh proof signed by that key for this exact request, and the DPoP
+// scheme must not be used to present an unbound token.
+func (a *AuthMiddleware) verifyDPoP(c *fiber.Ctx, claims *security.ClaimsPayload, token string, isDPoP bool) error {
+	if claims.JKT == "" {
+		if isDPoP {
+			return errors.New("access token is not DPoP bound")
 		}
+		return nil
+	}
+	if a.dpop == nil {
+		return errors.New("DPoP bound tokens are not accepted")
+	}
+
+	proof, err := a.dpop.Verify(c.Get("DPoP"), c.Method(), c.Path(), token)
+	if err != nil {
+		return errors.New("invalid DPoP proof")
+	}
+	if proof.JKT != claims.JKT {
+		return errors.New("DPoP key does not match token binding")
 	}
-	return ""
+	return nil
+}
+
+func extractDPoPToken(header string) (string, bool) {
+	header = strings.TrimSpace(header)
+	const prefix = "DPoP "
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):]), true
+	}
+	return "", false
 }
 
 func extractBearerToken(header string) string {
@@ -136,6 +393,20 @@ func hasIntersection(userRoles, required []string) bool {
 	return false
 }
 
+func hasScopes(claims *security.ClaimsPayload, required []string) bool {
+	for _, scope := range required {
+		if !claims.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func insufficientScope(c *fiber.Ctx, required []string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="`+strings.Join(required, " ")+`"`)
+	return forbidden(c, "insufficient scope")
+}
+
 func unauthorized(c *fiber.Ctx, message string) error {
 	return c.Status(fiber.StatusUnauthorized).JSON(errorResponse{
 		Ok:     false,
@@ -152,6 +423,14 @@ func forbidden(c *fiber.Ctx, message string) error {
 	})
 }
 
+func unavailable(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(errorResponse{
+		Ok:     false,
+		Status: fiber.StatusServiceUnavailable,
+		Error:  message,
+	})
+}
+
 type errorResponse struct {
 	Ok     bool   `json:"ok"`
 	Status int    `json:"status"`
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestAuthMiddlewareRequire(t *testing.T) {
+	logger.Init()
+	tm, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		Issuer:        "https://auth.example.com",
+		Audience:      "web",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(userID string, roles []string, opts ...security.TokenOption) *security.TokenPair {
+		pair, err := tm.GenerateAccessToken(userID, "jane@example.com", "jane", roles, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair
+	}
+
+	sessions := &memorySessions{sessions: map[string]*session.Session{
+		"live":    {ID: "live", UserID: "user-1", Valid: true, ExpiresAt: time.Now().Add(time.Hour)},
+		"revoked": {ID: "revoked", UserID: "user-1", Valid: false, ExpiresAt: time.Now().Add(time.Hour)},
+		"expired": {ID: "expired", UserID: "user-1", Valid: true, ExpiresAt: time.Now().Add(-time.Second)},
+	}}
+	denylist := revocation.NewService(repository.NewMemoryRevokedTokenRepository())
+	revoked := token("user-1", []string{"user"})
+	if err := denylist.Revoke(revoked.JTI, revoked.AccessExp, revocation.ReasonLogout); err != nil {
+		t.Fatal(err)
+	}
+
+	user := token("user-1", []string{"user"}, security.WithSessionID("live")).AccessToken
+	admin := token("user-1", []string{"admin"}, security.WithSessionID("live")).AccessToken
+	impersonated := func(sid string) string {
+		return token("user-1", []string{"user"}, security.WithSessionID(sid), security.WithActor(security.Actor{Subject: "admin-1"})).AccessToken
+	}
+	recovery := func(sid string) string {
+		return token("user-1", []string{"user"}, security.WithSessionID(sid), security.WithScope(security.ScopeAccountRecovery)).AccessToken
+	}
+	thirdParty := token("user-1", []string{"user"}, security.WithSessionID("live"), security.WithClientID("app"), security.WithScope("profile")).AccessToken
+	machine := token(security.MachineSubjectPrefix+"svc", nil, security.WithClientID("svc"), security.WithScope("users:read")).AccessToken
+	bound := token("user-1", []string{"user"}, security.WithSessionID("live"), security.WithConfirmation("thumbprint")).AccessToken
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		auth       string
+		cookie     string
+		wantStatus int
+	}{
+		{name: "missing token", wantStatus: fiber.StatusUnauthorized},
+		{name: "anonymous allowed", policy: Policy{AllowAnonymous: true}, wantStatus: fiber.StatusOK},
+		{name: "bearer", auth: "Bearer " + user, wantStatus: fiber.StatusOK},
+		{name: "bare header", auth: user, wantStatus: fiber.StatusOK},
+		{name: "cookie", cookie: user, wantStatus: fiber.StatusOK},
+		{name: "garbage", auth: "Bearer not-a-jwt", wantStatus: fiber.StatusUnauthorized},
+		{name: "garbage on anonymous route", policy: Policy{AllowAnonymous: true}, auth: "Bearer not-a-jwt", wantStatus: fiber.StatusOK},
+		{name: "revoked token", auth: "Bearer " + revoked.AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "live session", policy: Policy{RequireLiveSession: true}, auth: "Bearer " + user, wantStatus: fiber.StatusOK},
+		{name: "revoked session", policy: Policy{RequireLiveSession: true}, auth: "Bearer " + token("user-1", nil, security.WithSessionID("revoked")).AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "expired session", policy: Policy{RequireLiveSession: true}, auth: "Bearer " + token("user-1", nil, security.WithSessionID("expired")).AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "another user's session", policy: Policy{RequireLiveSession: true}, auth: "Bearer " + token("user-2", nil, security.WithSessionID("live")).AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "no session", policy: Policy{RequireLiveSession: true}, auth: "Bearer " + token("user-1", nil).AccessToken, wantStatus: fiber.StatusUnauthorized},
+		{name: "impersonation", auth: "Bearer " + impersonated("live"), wantStatus: fiber.StatusOK},
+		{name: "impersonation always checks the session", auth: "Bearer " + impersonated("revoked"), wantStatus: fiber.StatusUnauthorized},
+		{name: "recovery session on a normal route", auth: "Bearer " + recovery("live"), wantStatus: fiber.StatusForbidden},
+		{name: "recovery session where allowed", policy: Policy{AllowRestricted: true}, auth: "Bearer " + recovery("live"), wantStatus: fiber.StatusOK},
+		{name: "revoked recovery session", policy: Policy{AllowRestricted: true}, auth: "Bearer " + recovery("revoked"), wantStatus: fiber.StatusUnauthorized},
+		{name: "role", policy: Policy{Roles: []string{"ADMIN"}}, auth: "Bearer " + admin, wantStatus: fiber.StatusOK},
+		{name: "missing role", policy: Policy{Roles: []string{"admin"}}, auth: "Bearer " + user, wantStatus: fiber.StatusForbidden},
+		{name: "scope", policy: Policy{Scopes: []string{"profile"}}, auth: "Bearer " + thirdParty, wantStatus: fiber.StatusOK},
+		{name: "missing scope", policy: Policy{Scopes: []string{"email"}}, auth: "Bearer " + thirdParty, wantStatus: fiber.StatusForbidden},
+		{name: "allowed client", policy: Policy{ClientIDs: []string{"app"}}, auth: "Bearer " + thirdParty, wantStatus: fiber.StatusOK},
+		{name: "first-party token on a client route", policy: Policy{ClientIDs: []string{"app"}}, auth: "Bearer " + user, wantStatus: fiber.StatusForbidden},
+		{name: "client token on a first-party route", policy: Policy{FirstPartyOnly: true}, auth: "Bearer " + thirdParty, wantStatus: fiber.StatusForbidden},
+		{name: "machine token on a user route", auth: "Bearer " + machine, wantStatus: fiber.StatusForbidden},
+		{name: "machine token", policy: Policy{ClientScopes: []string{"users:read"}}, auth: "Bearer " + machine, wantStatus: fiber.StatusOK},
+		{name: "machine token without the scope", policy: Policy{ClientScopes: []string{"users:write"}}, auth: "Bearer " + machine, wantStatus: fiber.StatusForbidden},
+		{name: "DPoP scheme with an unbound token", auth: "DPoP " + user, wantStatus: fiber.StatusUnauthorized},
+		{name: "bound token without a verifier", auth: "Bearer " + bound, wantStatus: fiber.StatusUnauthorized},
+		{name: "API key without API key support", policy: Policy{AllowAPIKeys: true}, auth: "Bearer uas_" + strings.Repeat("A", 43), wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuthMiddleware(Config{TokenManager: tm, Denylist: denylist, Sessions: sessions, Audiences: []string{"web"}})
+			app := fiber.New()
+			app.Get("/", auth.Require(tt.policy), func(c *fiber.Ctx) error {
+				if _, ok := ClaimsFromContext(c); !ok && !tt.policy.AllowAnonymous {
+					t.Error("handler ran without claims")
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.auth)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: DefaultAccessTokenCookie, Value: tt.cookie})
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireLiveSessionCachesLiveness(t *testing.T) {
+	tm, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tm.GenerateAccessToken("user-1", "", "jane", nil, security.WithSessionID("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := &memorySessions{sessions: map[string]*session.Session{
+		"s": {ID: "s", UserID: "user-1", Valid: true, ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+	auth := NewAuthMiddleware(Config{TokenManager: tm, Sessions: sessions, SessionCacheTTL: 50 * time.Millisecond})
+	app := fiber.New()
+	app.Get("/", auth.Require(Policy{RequireLiveSession: true}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	status := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+pair.AccessToken)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := status(); got != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", got, fiber.StatusOK)
+	}
+	sessions.sessions["s"].Valid = false
+	// the revocation takes effect once the cached answer runs out
+	if got := status(); got != fiber.StatusOK {
+		t.Fatalf("status within the cache TTL = %d, want %d", got, fiber.StatusOK)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := status(); got != fiber.StatusUnauthorized {
+		t.Fatalf("status after the cache TTL = %d, want %d", got, fiber.StatusUnauthorized)
+	}
+}
+
+func TestAuthMiddlewareAudiences(t *testing.T) {
+	tm, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		Audience:      "web",
+		Audiences:     []string{"mobile", "billing"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		accepts    []string
+		issuedFor  string
+		wantStatus int
+	}{
+		{name: "own audience", accepts: []string{"billing"}, issuedFor: "billing", wantStatus: fiber.StatusOK},
+		{name: "one of several", accepts: []string{"web", "mobile"}, issuedFor: "mobile", wantStatus: fiber.StatusOK},
+		{name: "token for another service", accepts: []string{"billing"}, issuedFor: "web", wantStatus: fiber.StatusUnauthorized},
+		{name: "default audience elsewhere", accepts: []string{"mobile"}, wantStatus: fiber.StatusUnauthorized},
+		{name: "check disabled", issuedFor: "billing", wantStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := tm.GenerateAccessToken("user-1", "", "jane", nil, security.WithAudience(tt.issuedFor))
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth := NewAuthMiddleware(Config{TokenManager: tm, Audiences: tt.accepts})
+			app := fiber.New()
+			app.Get("/", auth.Require(Policy{}), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+pair.AccessToken)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSessionCache(t *testing.T) {
+	disabled := newSessionCache(0)
+	disabled.set("s", true)
+	if _, ok := disabled.get("s"); ok {
+		t.Fatal("a zero TTL cache returned an entry")
+	}
+
+	cache := newSessionCache(20 * time.Millisecond)
+	cache.set("s", false)
+	if live, ok := cache.get("s"); !ok || live {
+		t.Fatalf("get() = %v, %v, want a cached dead session", live, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.get("s"); ok {
+		t.Fatal("get() returned an entry past its TTL")
+	}
+}
+
+type memorySessions struct {
+	session.Service
+	sessions map[string]*session.Session
+}
+
+func (m *memorySessions) GetSessionByID(id string) (*session.Session, error) {
+	return m.sessions[id], nil
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// KeyFunc picks what a rate limit counts requests by. An empty key exempts
+// the request from that limit.
+type KeyFunc func(c *fiber.Ctx) string
+
+// RateLimit is one limit applied to a route.
+type RateLimit struct {
+	// Name keeps the buckets of different limits apart, e.g. "login-ip".
+	Name  string
+	Limit ratelimit.Limit
+	Key   KeyFunc
+}
+
+type RateLimiter struct {
+	limits ratelimit.Service
+}
+
+func NewRateLimiter(limits ratelimit.Service) *RateLimiter {
+	if limits == nil {
+		panic("middleware.NewRateLimiter: rate limit service is required")
+	}
+	return &RateLimiter{limits: limits}
+}
+
+// Limit admits a request only while every given limit has budget left. The
+// RateLimit-* headers describe the tightest of them. If the store fails the
+// request is let through: an outage must not lock everybody out.
+func (r *RateLimiter) Limit(limits ...RateLimit) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var (
+			tightest *ratelimit.Result
+			policy   RateLimit
+		)
+		for _, l := range limits {
+			key := l.Key(c)
+			if key == "" {
+				continue
+			}
+			res, err := r.limits.Allow(l.Name+":"+key, l.Limit)
+			if err != nil {
+				logger.Log.WithError(err).WithField("limit", l.Name).Error("rate limit check failed")
+				continue
+			}
+			if tightest == nil || !res.Allowed || (tightest.Allowed && res.Remaining < tightest.Remaining) {
+				tightest, policy = res, l
+			}
+			if !res.Allowed {
+				break
+			}
+		}
+		if tightest == nil {
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Set("RateLimit-Reset", ceilSeconds(tightest.Reset))
+		c.Set("RateLimit-Policy", strconv.Itoa(policy.Limit.Burst)+";w="+ceilSeconds(policy.Limit.Per))
+		if !tightest.Allowed {
+			c.Set(fiber.HeaderRetryAfter, ceilSeconds(tightest.RetryAfter))
+			return c.Status(fiber.StatusTooManyRequests).JSON(errorResponse{
+				Ok:     false,
+				Status: fiber.StatusTooManyRequests,
+				Error:  "too many requests",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// ByIP counts requests per client address.
+func ByIP(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// ByUser counts requests per signed-in user. It must run after
+// AuthMiddleware.Require.
+func ByUser(c *fiber.Ctx) string {
+	if claims, ok := ClaimsFromContext(c); ok {
+		return claims.UserID
+	}
+	return ""
+}
+
+// ByAccount counts requests per email address in the body, so one account
+// cannot be targeted from many addresses. The address is hashed so buckets
+// hold no personal data.
+func ByAccount(c *fiber.Ctx) string {
+	var body struct {
+		Email string `json:"email" form:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return ""
+	}
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+	if email == "" {
+		return ""
+	}
+	return security.HashToken(email)
+}
+
+// ByClientID counts requests per OAuth client and client address. The
+// client id is not authenticated yet, so keying on it alone would let
+// anyone spend a real client's budget; with the address added, a client
+// can only exhaust its own share.
+func ByClientID(c *fiber.Ctx) string {
+	id := basicClientID(c.Get(fiber.HeaderAuthorization))
+	if id == "" {
+		var body struct {
+			ClientID string `json:"client_id" form:"client_id"`
+		}
+		if err := c.BodyParser(&body); err == nil {
+			id = body.ClientID
+		}
+	}
+	if id == "" {
+		return ""
+	}
+	return id + "|" + c.IP()
+}
+
+// basicClientID returns the client id of HTTP Basic client authentication
+// (RFC 6749 section 2.3.1). The secret is not checked here.
+func basicClientID(header string) string {
+	const prefix = "Basic "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return ""
+	}
+	rawID, _, _ := strings.Cut(string(decoded), ":")
+	id, err := url.QueryUnescape(rawID)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestRateLimiterLimit(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewService(repository.NewMemoryRateLimitRepository()))
+	app := fiber.New()
+	app.Post("/login", limiter.Limit(
+		RateLimit{Name: "login-ip", Limit: ratelimit.Limit{Burst: 3, Per: time.Minute}, Key: ByIP},
+		RateLimit{Name: "login-account", Limit: ratelimit.Limit{Burst: 2, Per: time.Minute}, Key: ByAccount},
+	), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		email         string
+		wantStatus    int
+		wantRemaining string
+		wantPolicy    string
+	}{
+		// the account limit is the tighter one
+		{"jane@example.com", fiber.StatusNoContent, "1", "2;w=60"},
+		{"JANE@example.com ", fiber.StatusNoContent, "0", "2;w=60"},
+		{"jane@example.com", fiber.StatusTooManyRequests, "0", "2;w=60"},
+		// the address limit ran out along the way
+		{"joe@example.com", fiber.StatusTooManyRequests, "0", "3;w=60"},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"`+tt.email+`"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i, resp.StatusCode, tt.wantStatus)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i, got, tt.wantRemaining)
+		}
+		if got := resp.Header.Get("RateLimit-Policy"); got != tt.wantPolicy {
+			t.Errorf("request %d: RateLimit-Policy = %q, want %q", i, got, tt.wantPolicy)
+		}
+		retryAfter := resp.Header.Get(fiber.HeaderRetryAfter)
+		if (tt.wantStatus == fiber.StatusTooManyRequests) != (retryAfter != "") {
+			t.Errorf("request %d: Retry-After = %q", i, retryAfter)
+		}
+	}
+}
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	logger.Init()
+	limiter := NewRateLimiter(failingLimits{})
+	app := fiber.New()
+	app.Get("/", limiter.Limit(RateLimit{Name: "ip", Limit: ratelimit.Limit{Burst: 1, Per: time.Minute}, Key: ByIP}),
+		func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusNoContent || resp.Header.Get("RateLimit-Limit") != "" {
+		t.Fatalf("status = %d with headers %v, want the request let through", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	basic := func(id, secret string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(id+":"+secret))
+	}
+
+	tests := []struct {
+		name   string
+		key    KeyFunc
+		header string
+		body   string
+		claims *security.ClaimsPayload
+		want   string
+	}{
+		{name: "ip", key: ByIP, want: "0.0.0.0"},
+		{name: "user", key: ByUser, claims: &security.ClaimsPayload{UserID: "user-1"}, want: "user-1"},
+		{name: "no user", key: ByUser, want: ""},
+		{name: "account is normalized and hashed", key: ByAccount, body: `{"email":" Jane@Example.com "}`, want: security.HashToken("jane@example.com")},
+		{name: "no account", key: ByAccount, body: `{}`, want: ""},
+		{name: "client from basic auth", key: ByClientID, header: basic("web%20app", "secret"), want: "web app|0.0.0.0"},
+		{name: "basic auth wins over the body", key: ByClientID, header: basic("web", "secret"), body: `{"client_id":"other"}`, want: "web|0.0.0.0"},
+		{name: "client from the body", key: ByClientID, body: `{"client_id":"spa"}`, want: "spa|0.0.0.0"},
+		{name: "malformed basic auth", key: ByClientID, header: "Basic !!!", want: ""},
+		{name: "bearer is not a client", key: ByClientID, header: "Bearer abc", want: ""},
+		{name: "no client", key: ByClientID, body: `{}`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error {
+				if tt.claims != nil {
+					c.Locals(DefaultClaimsContextKey, tt.claims)
+				}
+				got = tt.key(c)
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.header)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingLimits struct {
+	ratelimit.Service
+}
+
+func (failingLimits) Allow(string, ratelimit.Limit) (*ratelimit.Result, error) {
+	return nil, errors.New("store unavailable")
+}
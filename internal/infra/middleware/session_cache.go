@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedSessions bounds memory; the cache is swept once it grows past it.
+const maxCachedSessions = 10000
+
+type cachedSession struct {
+	live      bool
+	expiresAt time.Time
+}
+
+// sessionCache remembers session liveness for a short TTL so that
+// RequireLiveSession does not hit the database on every request while a
+// revocation still takes effect within ttl.
+type sessionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedSession
+}
+
+func newSessionCache(ttl time.Duration) *sessionCache {
+	return &sessionCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedSession),
+	}
+}
+
+func (c *sessionCache) get(sid string) (live bool, ok bool) {
+	if c.ttl <= 0 {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[sid]
+	if !found || time.Now().After(entry.expiresAt) {
+		return false, false
+	}
+	return entry.live, true
+}
+
+func (c *sessionCache) set(sid string, live bool) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCachedSessions {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedSessions {
+			c.entries = make(map[string]cachedSession)
+		}
+	}
+	c.entries[sid] = cachedSession{live: live, expiresAt: now.Add(c.ttl)}
+}
@@ -0,0 +1,289 @@
+package qrcode
+
+// levelMFormatBits are the two error correction level bits for level M.
+const levelMFormatBits = 0
+
+type builder struct {
+	Code
+	isFunction [][]bool
+}
+
+func newCode(version int) *builder {
+	size := version*4 + 17
+	b := &builder{Code: Code{Version: version, Size: size}}
+	b.Modules = make([][]bool, size)
+	b.isFunction = make([][]bool, size)
+	for y := range b.Modules {
+		b.Modules[y] = make([]bool, size)
+		b.isFunction[y] = make([]bool, size)
+	}
+	return b
+}
+
+func (b *builder) setFunction(x, y int, dark bool) {
+	b.Modules[y][x] = dark
+	b.isFunction[y][x] = true
+}
+
+func (b *builder) drawFunctionPatterns() {
+	// timing patterns
+	for i := 0; i < b.Size; i++ {
+		b.setFunction(6, i, i%2 == 0)
+		b.setFunction(i, 6, i%2 == 0)
+	}
+
+	// finder patterns with their separators
+	b.drawFinder(3, 3)
+	b.drawFinder(b.Size-4, 3)
+	b.drawFinder(3, b.Size-4)
+
+	// alignment patterns, except where they would overlap the finders
+	positions := versions[b.Version].alignment
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			b.drawAlignment(x, y)
+		}
+	}
+
+	// reserve the format areas; real bits are written after masking
+	b.drawFormatBits(0)
+	b.drawVersion()
+}
+
+func (b *builder) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= b.Size || y < 0 || y >= b.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			b.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (b *builder) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			b.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits writes both copies of the 15 bit format information.
+func (b *builder) drawFormatBits(mask int) {
+	data := levelMFormatBits<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		b.setFunction(8, i, bit(i))
+	}
+	b.setFunction(8, 7, bit(6))
+	b.setFunction(8, 8, bit(7))
+	b.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		b.setFunction(14-i, 8, bit(i))
+	}
+
+	// split between the other two finders
+	for i := 0; i < 8; i++ {
+		b.setFunction(b.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		b.setFunction(8, b.Size-15+i, bit(i))
+	}
+	b.setFunction(8, b.Size-8, true) // always dark
+}
+
+// drawVersion writes the two 18 bit version blocks (version 7 and up).
+func (b *builder) drawVersion() {
+	if b.Version < 7 {
+		return
+	}
+	rem := b.Version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := b.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 == 1
+		x := b.Size - 11 + i%3
+		y := i / 3
+		b.setFunction(x, y, dark)
+		b.setFunction(y, x, dark)
+	}
+}
+
+// drawCodewords places the data in the zigzag order: two module wide
+// columns from the bottom right, alternating upwards and downwards and
+// skipping the vertical timing pattern.
+func (b *builder) drawCodewords(data []byte) {
+	i := 0
+	for right := b.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < b.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = b.Size - 1 - vert
+				}
+				if b.isFunction[y][x] || i >= len(data)*8 {
+					continue
+				}
+				b.Modules[y][x] = data[i/8]>>uint(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (b *builder) applyMask(mask int) {
+	for y := 0; y < b.Size; y++ {
+		for x := 0; x < b.Size; x++ {
+			if !b.isFunction[y][x] && maskBit(mask, x, y) {
+				b.Modules[y][x] = !b.Modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask tries all eight masks and keeps the one with the lowest
+// penalty score.
+func (b *builder) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		b.applyMask(mask)
+		b.drawFormatBits(mask)
+		if p := b.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		b.applyMask(mask) // XOR again to undo
+	}
+	b.applyMask(best)
+	b.drawFormatBits(best)
+}
+
+// penalty scores the symbol with the four rules of the standard.
+func (b *builder) penalty() int {
+	score := 0
+	size := b.Size
+	at := func(x, y int, horizontal bool) bool {
+		if horizontal {
+			return b.Modules[y][x]
+		}
+		return b.Modules[x][y]
+	}
+
+	for _, horizontal := range []bool{true, false} {
+		for line := 0; line < size; line++ {
+			// rule 1: runs of five or more same coloured modules
+			run := 1
+			for i := 1; i < size; i++ {
+				if at(i, line, horizontal) == at(i-1, line, horizontal) {
+					run++
+					if run == 5 {
+						score += 3
+					} else if run > 5 {
+						score++
+					}
+				} else {
+					run = 1
+				}
+			}
+			// rule 3: finder-like 1:1:3:1:1 patterns next to four light modules
+			for i := 0; i+7 <= size; i++ {
+				if !at(i, line, horizontal) || at(i+1, line, horizontal) || !at(i+2, line, horizontal) ||
+					!at(i+3, line, horizontal) || !at(i+4, line, horizontal) || at(i+5, line, horizontal) ||
+					!at(i+6, line, horizontal) {
+					continue
+				}
+				if b.lightRun(line, i-4, i, horizontal) || b.lightRun(line, i+7, i+11, horizontal) {
+					score += 40
+				}
+			}
+		}
+	}
+
+	// rule 2: 2x2 blocks of the same colour
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if b.Modules[y][x] {
+				dark++
+			}
+			if x+1 < size && y+1 < size {
+				c := b.Modules[y][x]
+				if c == b.Modules[y][x+1] && c == b.Modules[y+1][x] && c == b.Modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// rule 4: balance of dark and light modules
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		score += k * 10
+	}
+	return score
+}
+
+// lightRun reports whether modules [from, to) on a line are all light,
+// treating the area outside the symbol as light.
+func (b *builder) lightRun(line, from, to int, horizontal bool) bool {
+	for i := from; i < to; i++ {
+		if i < 0 || i >= b.Size {
+			continue
+		}
+		if horizontal && b.Modules[line][i] || !horizontal && b.Modules[i][line] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,156 @@
+// Package qrcode is a small QR code encoder (ISO/IEC 18004) covering what the
+// service needs: byte mode, error correction level M, versions 1 to 15. That
+// comfortably fits otpauth:// enrollment URIs.
+package qrcode
+
+import (
+	"errors"
+)
+
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code is an encoded QR symbol. Modules are indexed [y][x]; true is dark.
+type Code struct {
+	Version int
+	Size    int
+	Modules [][]bool
+}
+
+// versionInfo describes the level M block structure of one version.
+type versionInfo struct {
+	ecPerBlock int
+	// group 1 and group 2 blocks: count and data codewords per block
+	blocks1, data1 int
+	blocks2, data2 int
+	alignment      []int
+	remainderBits  int
+}
+
+var versions = [...]versionInfo{
+	1:  {10, 1, 16, 0, 0, nil, 0},
+	2:  {16, 1, 28, 0, 0, []int{6, 18}, 7},
+	3:  {26, 1, 44, 0, 0, []int{6, 22}, 7},
+	4:  {18, 2, 32, 0, 0, []int{6, 26}, 7},
+	5:  {24, 2, 43, 0, 0, []int{6, 30}, 7},
+	6:  {16, 4, 27, 0, 0, []int{6, 34}, 7},
+	7:  {18, 4, 31, 0, 0, []int{6, 22, 38}, 0},
+	8:  {22, 2, 38, 2, 39, []int{6, 24, 42}, 0},
+	9:  {22, 3, 36, 2, 37, []int{6, 26, 46}, 0},
+	10: {26, 4, 43, 1, 44, []int{6, 28, 50}, 0},
+	11: {30, 1, 50, 4, 51, []int{6, 30, 54}, 0},
+	12: {22, 6, 36, 2, 37, []int{6, 32, 58}, 0},
+	13: {22, 8, 37, 1, 38, []int{6, 34, 62}, 0},
+	14: {24, 4, 40, 5, 41, []int{6, 26, 46, 66}, 3},
+	15: {24, 5, 41, 5, 42, []int{6, 26, 48, 70}, 3},
+}
+
+const maxVersion = len(versions) - 1
+
+func (v versionInfo) dataCodewords() int {
+	return v.blocks1*v.data1 + v.blocks2*v.data2
+}
+
+// Encode builds the smallest QR code holding data in byte mode at level M.
+func Encode(data []byte) (*Code, error) {
+	for version := 1; version <= maxVersion; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		info := versions[version]
+		capacity := info.dataCodewords() * 8
+		if 4+countBits+8*len(data) > capacity {
+			continue
+		}
+
+		codewords := encodeData(data, countBits, info.dataCodewords())
+		code := newCode(version)
+		code.drawFunctionPatterns()
+		code.drawCodewords(interleave(codewords, info))
+		code.applyBestMask()
+		return &code.Code, nil
+	}
+	return nil, ErrTooLong
+}
+
+// encodeData produces the padded data codewords: mode, count, payload,
+// terminator and the alternating 0xEC/0x11 pad bytes.
+func encodeData(data []byte, countBits, capacity int) []byte {
+	var bb bitBuffer
+	bb.append(0b0100, 4) // byte mode
+	bb.append(uint32(len(data)), countBits)
+	for _, b := range data {
+		bb.append(uint32(b), 8)
+	}
+
+	capacityBits := capacity * 8
+	terminator := min(4, capacityBits-bb.len())
+	bb.append(0, terminator)
+	bb.append(0, (8-bb.len()%8)%8)
+
+	out := bb.bytes()
+	for pad := byte(0xEC); len(out) < capacity; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave splits data into blocks, appends each block's Reed-Solomon
+// codewords and interleaves the result as the standard requires.
+func interleave(data []byte, info versionInfo) []byte {
+	numBlocks := info.blocks1 + info.blocks2
+	blocks := make([][]byte, 0, numBlocks)
+	ecBlocks := make([][]byte, 0, numBlocks)
+	generator := rsGenerator(info.ecPerBlock)
+
+	offset := 0
+	for i := 0; i < numBlocks; i++ {
+		size := info.data1
+		if i >= info.blocks1 {
+			size = info.data2
+		}
+		block := data[offset : offset+size]
+		offset += size
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, generator))
+	}
+
+	out := make([]byte, 0, len(data)+numBlocks*info.ecPerBlock)
+	for i := 0; i < max(info.data1, info.data2); i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, value>>uint(i)&1 == 1)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	out := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
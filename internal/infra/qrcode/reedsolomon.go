@@ -0,0 +1,45 @@
+package qrcode
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		carry := z >> 7
+		z <<= 1
+		z ^= carry * 0x1D
+		z ^= (y >> uint(i) & 1) * x
+	}
+	return z
+}
+
+// rsGenerator returns the coefficients (highest power first, leading 1
+// omitted) of the Reed-Solomon generator polynomial of the given degree.
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes the error correction codewords for data.
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range generator {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) apikey.Repository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(k *apikey.APIKey) error {
+	return r.db.Create(k).Error
+}
+
+func (r *apiKeyRepository) GetByHash(hash string) (*apikey.APIKey, error) {
+	var k apikey.APIKey
+	if err := r.db.Where("key_hash = ?", hash).First(&k).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepository) ListByUser(userID string) ([]apikey.APIKey, error) {
+	var keys []apikey.APIKey
+	if err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at ASC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(userID, id string, now time.Time) (bool, error) {
+	result := r.db.Model(&apikey.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *apiKeyRepository) Touch(id string, now time.Time, ip string) error {
+	return r.db.Model(&apikey.APIKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+
+	"gorm.io/gorm"
+)
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) identity.Repository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Create(i *identity.ExternalIdentity) error {
+	return r.db.Create(i).Error
+}
+
+func (r *identityRepository) Find(provider, subject string) (*identity.ExternalIdentity, error) {
+	var i identity.ExternalIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&i).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *identityRepository) Touch(id, email string, now time.Time) error {
+	return r.db.Model(&identity.ExternalIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email":         email,
+			"last_login_at": now,
+		}).Error
+}
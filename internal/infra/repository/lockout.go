@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type lockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewLockoutRepository(db *gorm.DB) lockout.Repository {
+	return &lockoutRepository{db: db}
+}
+
+func (r *lockoutRepository) Get(keyHash string) (*lockout.Lockout, error) {
+	var l lockout.Lockout
+	if err := r.db.Where("key_hash = ?", keyHash).First(&l).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// RecordFailure increments in a single upsert so concurrent failures are all
+// counted.
+func (r *lockoutRepository) RecordFailure(keyHash string, now, windowStart time.Time) (*lockout.Lockout, error) {
+	l := &lockout.Lockout{KeyHash: keyHash, Failures: 1, LastFailureAt: now, UpdatedAt: now}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key_hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":        gorm.Expr("CASE WHEN login_lockouts.last_failure_at < ? THEN 1 ELSE login_lockouts.failures + 1 END", windowStart),
+			"last_failure_at": now,
+			"updated_at":      now,
+		}),
+	}).Create(l).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(keyHash)
+}
+
+func (r *lockoutRepository) Lock(keyHash string, until time.Time) error {
+	return r.db.Model(&lockout.Lockout{}).
+		Where("key_hash = ?", keyHash).
+		Updates(map[string]interface{}{
+			"locked_until": until,
+			"updated_at":   time.Now().UTC(),
+		}).Error
+}
+
+func (r *lockoutRepository) Delete(keyHash string) error {
+	return r.db.Where("key_hash = ?", keyHash).Delete(&lockout.Lockout{}).Error
+}
+
+func (r *lockoutRepository) DeleteStale(cutoff, now time.Time) error {
+	return r.db.
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", cutoff, now).
+		Delete(&lockout.Lockout{}).Error
+}
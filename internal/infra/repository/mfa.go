@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+
+	"gorm.io/gorm"
+)
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) mfa.Repository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetTOTP(userID string) (*mfa.TOTPFactor, error) {
+	var f mfa.TOTPFactor
+	if err := r.db.Where("user_id = ?", userID).First(&f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *mfaRepository) SaveTOTP(f *mfa.TOTPFactor) error {
+	return r.db.Save(f).Error
+}
+
+func (r *mfaRepository) ConfirmTOTP(userID string, step int64, now time.Time) (bool, error) {
+	result := r.db.Model(&mfa.TOTPFactor{}).
+		Where("user_id = ? AND confirmed = ?", userID, false).
+		Updates(map[string]interface{}{
+			"confirmed":      true,
+			"confirmed_at":   now,
+			"last_used_step": step,
+			"updated_at":     now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	result := r.db.Model(&mfa.TOTPFactor{}).
+		Where("user_id = ? AND confirmed = ? AND last_used_step < ?", userID, true, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) DeleteTOTP(userID string) error {
+	return r.db.Where("user_id = ?", userID).Delete(&mfa.TOTPFactor{}).Error
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID string, codes []mfa.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&mfa.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID, codeHash string, now time.Time) (bool, error) {
+	result := r.db.Model(&mfa.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&mfa.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
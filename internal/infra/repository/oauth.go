@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) oauth.Repository {
+	return &oauthRepository{db: db}
+}
+
+func (r *oauthRepository) CreateClient(c *oauth.Client) error {
+	return r.db.Create(c).Error
+}
+
+func (r *oauthRepository) GetClient(id string) (*oauth.Client, error) {
+	var c oauth.Client
+	if err := r.db.Where("id = ?", id).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) ListClients() ([]oauth.Client, error) {
+	var clients []oauth.Client
+	if err := r.db.Order("created_at ASC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteClient removes the client together with its codes and consents.
+func (r *oauthRepository) DeleteClient(id string) (bool, error) {
+	var deleted bool
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", id).Delete(&oauth.AuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("client_id = ?", id).Delete(&oauth.Consent{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&oauth.Client{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected == 1
+		return nil
+	})
+	return deleted, err
+}
+
+func (r *oauthRepository) CreateCode(c *oauth.AuthorizationCode) error {
+	return r.db.Create(c).Error
+}
+
+func (r *oauthRepository) GetCodeByHash(hash string) (*oauth.AuthorizationCode, error) {
+	var c oauth.AuthorizationCode
+	if err := r.db.Where("code_hash = ?", hash).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) MarkCodeUsed(id string, now time.Time) (bool, error) {
+	result := r.db.Model(&oauth.AuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *oauthRepository) SetCodeSession(id, sessionID string) error {
+	return r.db.Model(&oauth.AuthorizationCode{}).Where("id = ?", id).Update("session_id", sessionID).Error
+}
+
+func (r *oauthRepository) GetConsent(userID, clientID string) (*oauth.Consent, error) {
+	var c oauth.Consent
+	if err := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepository) SaveConsent(c *oauth.Consent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(c).Error
+}
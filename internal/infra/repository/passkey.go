@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+
+	"gorm.io/gorm"
+)
+
+type passkeyRepository struct {
+	db *gorm.DB
+}
+
+func NewPasskeyRepository(db *gorm.DB) passkey.Repository {
+	return &passkeyRepository{db: db}
+}
+
+func (r *passkeyRepository) Create(c *passkey.Credential) error {
+	return r.db.Create(c).Error
+}
+
+func (r *passkeyRepository) GetByCredentialID(credentialID []byte) (*passkey.Credential, error) {
+	var c passkey.Credential
+	if err := r.db.Where("credential_id = ?", credentialID).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *passkeyRepository) ListByUser(userID string) ([]passkey.Credential, error) {
+	var creds []passkey.Credential
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func (r *passkeyRepository) RecordUse(id string, oldCount, newCount int64, backedUp bool, now time.Time) (bool, error) {
+	result := r.db.Model(&passkey.Credential{}).
+		Where("id = ? AND sign_count = ?", id, oldCount).
+		Updates(map[string]interface{}{
+			"sign_count":   newCount,
+			"backed_up":    backedUp,
+			"last_used_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *passkeyRepository) Delete(userID, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&passkey.Credential{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
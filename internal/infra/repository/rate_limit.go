@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type rateLimitRepository struct {
+	db *gorm.DB
+}
+
+// NewRateLimitRepository keeps buckets in Postgres so every replica draws on
+// the same budget.
+func NewRateLimitRepository(db *gorm.DB) ratelimit.Repository {
+	return &rateLimitRepository{db: db}
+}
+
+func (r *rateLimitRepository) Take(key string, limit ratelimit.Limit, cost int, now time.Time) (*ratelimit.Result, error) {
+	var res *ratelimit.Result
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		fresh := &ratelimit.Bucket{Key: key, Tokens: float64(limit.Burst), UpdatedAt: now, ExpiresAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(fresh).Error; err != nil {
+			return err
+		}
+
+		var b ratelimit.Bucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&b).Error; err != nil {
+			return err
+		}
+		res = b.Take(limit, cost, now)
+		return tx.Model(&ratelimit.Bucket{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"tokens":     b.Tokens,
+			"updated_at": b.UpdatedAt,
+			"expires_at": b.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *rateLimitRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at < ?", now).Delete(&ratelimit.Bucket{}).Error
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+)
+
+// memoryRateLimitRepository keeps buckets in process. Each replica then
+// enforces its own limits, so it is only suitable for single-replica
+// deployments and tests.
+type memoryRateLimitRepository struct {
+	mu      sync.Mutex
+	buckets map[string]*ratelimit.Bucket
+}
+
+func NewMemoryRateLimitRepository() ratelimit.Repository {
+	return &memoryRateLimitRepository{buckets: make(map[string]*ratelimit.Bucket)}
+}
+
+func (r *memoryRateLimitRepository) Take(key string, limit ratelimit.Limit, cost int, now time.Time) (*ratelimit.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &ratelimit.Bucket{Key: key, Tokens: float64(limit.Burst), UpdatedAt: now}
+		r.buckets[key] = b
+	}
+	return b.Take(limit, cost, now), nil
+}
+
+func (r *memoryRateLimitRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, b := range r.buckets {
+		if b.ExpiresAt.Before(now) {
+			delete(r.buckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/replay"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type replayRepository struct {
+	db *gorm.DB
+}
+
+// NewReplayRepository keeps spent one-time values in Postgres so a value
+// used on one replica is rejected on all of them.
+func NewReplayRepository(db *gorm.DB) replay.Repository {
+	return &replayRepository{db: db}
+}
+
+func (r *replayRepository) Add(n *replay.UsedNonce, now time.Time) (bool, error) {
+	// an expired entry that has not been purged yet is taken over in place
+	res := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"expires_at": n.ExpiresAt}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "used_nonces.expires_at <= ?", Vars: []interface{}{now}},
+		}},
+	}).Create(n)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *replayRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&replay.UsedNonce{}).Error
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/replay"
+)
+
+// memoryReplayRepository keeps spent values in process. It is only suitable
+// for single-replica deployments and tests.
+type memoryReplayRepository struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewMemoryReplayRepository() replay.Repository {
+	return &memoryReplayRepository{entries: make(map[string]time.Time)}
+}
+
+func (r *memoryReplayRepository) Add(n *replay.UsedNonce, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if expiresAt, ok := r.entries[n.Key]; ok && expiresAt.After(now) {
+		return false, nil
+	}
+	r.entries[n.Key] = n.ExpiresAt
+	return true, nil
+}
+
+func (r *memoryReplayRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, expiresAt := range r.entries {
+		if !expiresAt.After(now) {
+			delete(r.entries, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type revokedTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRevokedTokenRepository(db *gorm.DB) revocation.Repository {
+	return &revokedTokenRepository{db: db}
+}
+
+func (r *revokedTokenRepository) Add(t *revocation.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(t).Error
+}
+
+func (r *revokedTokenRepository) Exists(jti string, now time.Time) (bool, error) {
+	var count int64
+	if err := r.db.Model(&revocation.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, now).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *revokedTokenRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&revocation.RevokedToken{}).Error
+}
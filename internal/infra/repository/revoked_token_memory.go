@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+)
+
+// memoryRevokedTokenRepository keeps the denylist in process. It is only
+// suitable for single-replica deployments and tests.
+type memoryRevokedTokenRepository struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+func NewMemoryRevokedTokenRepository() revocation.Repository {
+	return &memoryRevokedTokenRepository{entries: make(map[string]time.Time)}
+}
+
+func (r *memoryRevokedTokenRepository) Add(t *revocation.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[t.JTI] = t.ExpiresAt
+	return nil
+}
+
+func (r *memoryRevokedTokenRepository) Exists(jti string, now time.Time) (bool, error) {
+	r.mu.RLock()
+	expiresAt, ok := r.entries[jti]
+	r.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if !expiresAt.After(now) {
+		r.mu.Lock()
+		delete(r.entries, jti)
+		r.mu.Unlock()
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *memoryRevokedTokenRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for jti, expiresAt := range r.entries {
+		if !expiresAt.After(now) {
+			delete(r.entries, jti)
+		}
+	}
+	return nil
+}
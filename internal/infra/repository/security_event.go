@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+
+	"gorm.io/gorm"
+)
+
+type securityEventRepository struct {
+	db *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) securityevent.Repository {
+	return &securityEventRepository{db: db}
+}
+
+func (r *securityEventRepository) Create(e *securityevent.Event) error {
+	return r.db.Create(e).Error
+}
+
+func (r *securityEventRepository) List(params *securityevent.EventQueryParams) ([]*securityevent.Event, error) {
+	var events []*securityevent.Event
+	query := r.db.Model(&securityevent.Event{})
+	if params != nil {
+		if params.UserID != nil {
+			query = query.Where("user_id = ?", *params.UserID)
+		}
+		if params.Type != nil {
+			query = query.Where("type = ?", *params.Type)
+		}
+	}
+
+	if err := query.Order("created_at DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"mikhailjbs/user-auth-service/internal/domain/session"
 
@@ -15,6 +16,7 @@ type Repository interface {
 	Delete(id string) error
 	Update(id string, s *session.Session) (*session.Session, error)
 	List(params *session.SessionQueryParams) ([]*session.Session, error)
+	RotateRefreshToken(r *session.Rotation) (bool, error)
 }
 
 type sessionRepository struct {
@@ -44,6 +46,13 @@ func (r *sessionRepository) Invalidate(id string) error {
 	return r.db.Model(&session.Session{}).Where("id = ?", id).Update("valid", false).Error
 }
 
+func (r *sessionRepository) InvalidateByUser(userID string) (int64, error) {
+	result := r.db.Model(&session.Session{}).
+		Where("user_id = ? AND valid = ?", userID, true).
+		Update("valid", false)
+	return result.RowsAffected, result.Error
+}
+
 func (r *sessionRepository) Delete(id string) error {
 	return r.db.Delete(&session.Session{}, "id = ?", id).Error
 }
@@ -66,6 +75,13 @@ func (r *sessionRepository) List(params *session.SessionQueryParams) ([]*session
 		if params.Valid != nil {
 			query = query.Where("valid = ?", *params.Valid)
 		}
+		if params.Impersonated != nil {
+			if *params.Impersonated {
+				query = query.Where("impersonator_id IS NOT NULL")
+			} else {
+				query = query.Where("impersonator_id IS NULL")
+			}
+		}
 	}
 
 	if err := query.Find(&sessions).Error; err != nil {
@@ -73,3 +89,24 @@ func (r *sessionRepository) List(params *session.SessionQueryParams) ([]*session
 	}
 	return sessions, nil
 }
+
+func (r *sessionRepository) RotateRefreshToken(rot *session.Rotation) (bool, error) {
+	now := time.Now().UTC()
+	// single conditional UPDATE: concurrent rotations of the same token race
+	// on the WHERE clause and only one of them wins
+	result := r.db.Model(&session.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND valid = ?", rot.SessionID, rot.ExpectedHash, true).
+		Updates(map[string]interface{}{
+			"previous_refresh_token_hash": gorm.Expr("refresh_token_hash"),
+			"refresh_token_hash":          rot.NewHash,
+			"refresh_rotated_at":          now,
+			"expires_at":                  rot.ExpiresAt,
+			"ip_address":                  rot.IPAddress,
+			"user_agent":                  rot.UserAgent,
+			"updated_at":                  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
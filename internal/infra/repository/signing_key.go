@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"slices"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
+
+	"gorm.io/gorm"
+)
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) signingkey.Repository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) ListByPurpose(purpose string) ([]*signingkey.Key, error) {
+	var keys []*signingkey.Key
+	if err := r.db.Where("purpose = ?", purpose).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) Activate(k *signingkey.Key, replaces string, verifyUntil time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// serialize activations per purpose across replicas; the lock is
+		// released with the transaction
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "signing_keys:"+k.Purpose).Error; err != nil {
+			return err
+		}
+
+		var current []string
+		if err := tx.Model(&signingkey.Key{}).
+			Where("purpose = ? AND active = ?", k.Purpose, true).
+			Pluck("id", &current).Error; err != nil {
+			return err
+		}
+		if (replaces == "" && len(current) > 0) || (replaces != "" && !slices.Contains(current, replaces)) {
+			return signingkey.ErrActiveKeyChanged
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Model(&signingkey.Key{}).
+			Where("purpose = ? AND active = ?", k.Purpose, true).
+			Updates(map[string]interface{}{
+				"active":       false,
+				"retired_at":   now,
+				"verify_until": verifyUntil,
+			}).Error; err != nil {
+			return err
+		}
+		k.Active = true
+		return tx.Create(k).Error
+	})
+}
+
+func (r *signingKeyRepository) DeleteExpired(now time.Time) error {
+	newestConfigured := r.db.Model(&signingkey.Key{}).
+		Select("DISTINCT ON (purpose) id").
+		Where("configured = ?", true).
+		Order("purpose, created_at DESC")
+	return r.db.
+		Where("active = ? AND verify_until < ?", false, now).
+		Where("id NOT IN (?)", newestConfigured).
+		Delete(&signingkey.Key{}).Error
+}
@@ -29,7 +29,8 @@ func (r *userRepository) Create(u *user.User) (*user.User, error) {
 
 func (r *userRepository) GetByEmail(email string) (*user.User, error) {
 	var u user.User
-	if err := r.db.Where("email = ?", email).First(&u).Error; err != nil {
+	// LOWER also matches rows stored before addresses were normalized
+	if err := r.db.Where("LOWER(email) = ?", user.NormalizeEmail(email)).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -44,7 +45,7 @@ func (r *userRepository) GetAll(params *user.UserQueryParams) ([]*user.User, err
 
 	if params != nil {
 		if params.Email != nil {
-			query = query.Where("email = ?", *params.Email)
+			query = query.Where("LOWER(email) = ?", user.NormalizeEmail(*params.Email))
 		}
 		if params.Role != nil {
 			query = query.Where("role = ?", *params.Role)
@@ -89,3 +90,22 @@ func (r *userRepository) Update(id string, u *user.User) (*user.User, error) {
 	}
 	return r.Get(id)
 }
+
+func (r *userRepository) SwapPasswordHash(id, old, new string) (bool, error) {
+	result := r.db.Model(&user.User{}).
+		Where("id = ? AND password_hash = ?", id, old).
+		Update("password_hash", new)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) ClearEmailVerified(id string) error {
+	return r.db.Model(&user.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"email_verified":    false,
+			"email_verified_at": nil,
+		}).Error
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+
+	"gorm.io/gorm"
+)
+
+type verificationTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationTokenRepository(db *gorm.DB) verification.Repository {
+	return &verificationTokenRepository{db: db}
+}
+
+func (r *verificationTokenRepository) Create(t *verification.Token) error {
+	return r.db.Create(t).Error
+}
+
+func (r *verificationTokenRepository) GetByHash(hash string) (*verification.Token, error) {
+	var t verification.Token
+	if err := r.db.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *verificationTokenRepository) MarkUsed(id string, now time.Time) (bool, error) {
+	result := r.db.Model(&verification.Token{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *verificationTokenRepository) InvalidateForUser(userID, purpose string, now time.Time) error {
+	return r.db.Model(&verification.Token{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error
+}
+
+func (r *verificationTokenRepository) CountIssuedSince(userID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	if err := r.db.Model(&verification.Token{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *verificationTokenRepository) DeleteExpired(now, issuedBefore time.Time) error {
+	return r.db.Where("expires_at <= ? AND created_at < ?", now, issuedBefore).Delete(&verification.Token{}).Error
+}
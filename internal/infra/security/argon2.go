@@ -0,0 +1,119 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltBytes = 16
+	argon2KeyBytes  = 32
+)
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params is the OWASP recommended minimum: 19 MiB, two passes,
+// one lane.
+var DefaultArgon2Params = Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+// Argon2idHasher hashes passwords with argon2id into PHC strings such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+// NewArgon2idHasher returns an Argon2idHasher. Zero parameters take the
+// defaults.
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	return &Argon2idHasher{params: params}
+}
+
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	if password == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	salt := make([]byte, argon2SaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := a.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyBytes)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Compare checks whether the provided password matches the hashed value,
+// whichever supported algorithm produced it.
+func (a *Argon2idHasher) Compare(hash, password string) error {
+	return comparePasswordHash(hash, password)
+}
+
+// NeedsRehash reports whether hash is not argon2id or uses other parameters.
+func (a *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	return err != nil || params != a.params
+}
+
+func isArgon2idHash(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func compareArgon2id(hash, password string) error {
+	p, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// parseArgon2id splits a PHC argon2id string into its parameters, salt and
+// key.
+func parseArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: unsupported argon2 version", ErrUnknownPasswordHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: bad argon2 parameters", ErrUnknownPasswordHash)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("%w: bad argon2 salt", ErrUnknownPasswordHash)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("%w: bad argon2 hash", ErrUnknownPasswordHash)
+	}
+	return p, salt, key, nil
+}
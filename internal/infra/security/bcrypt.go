@@ -1,7 +1,9 @@
 package security
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -33,23 +35,29 @@ func (b *BcryptHasher) Hash(password string) (string, error) {
 	return string(hash), nil
 }
 
-// Compare checks whether the provided password matches the hashed value.
-// Returns nil if they match, bcrypt.ErrMismatchedHashAndPassword if not.
+// Compare checks whether the provided password matches the hashed value,
+// whichever supported algorithm produced it.
 func (b *BcryptHasher) Compare(hash, password string) error {
-	if hash == "" || password == "" {
-		return fmt.Errorf("hash and password must be provided")
+	return comparePasswordHash(hash, password)
+}
+
+// NeedsRehash reports whether hash is not bcrypt or uses another cost.
+func (b *BcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcryptHash(hash) {
+		return true
 	}
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.cost
 }
 
-// HashPassword is a convenience function to hash password using default cost.
-func HashPassword(password string) (string, error) {
-	hasher := NewBcryptHasher(bcrypt.DefaultCost)
-	return hasher.Hash(password)
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
 }
 
-// ComparePassword is a convenience function to compare password using default cost.
-func ComparePassword(hash, password string) error {
-	hasher := NewBcryptHasher(bcrypt.DefaultCost)
-	return hasher.Compare(hash, password)
+func compareBcrypt(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
 }
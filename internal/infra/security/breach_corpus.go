@@ -0,0 +1,74 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// minBreachPrefix is the shortest hash prefix a corpus entry may use. Shorter
+// prefixes would reject too many good passwords by accident.
+const minBreachPrefix = 10
+
+// BreachCorpus is an in-memory set of breached password hashes, checked
+// without calling out to any service.
+type BreachCorpus struct {
+	prefixes map[string]struct{}
+	lengths  []int
+}
+
+// LoadBreachCorpus reads a corpus file in the Pwned Passwords format: one
+// uppercase or lowercase SHA-1 hex digest per line, optionally followed by
+// ":count". Digests may be cut down to a prefix of at least 10 characters to
+// keep the file small, at the cost of rare false positives. Blank lines and
+// lines starting with # are skipped.
+func LoadBreachCorpus(path string) (*BreachCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	corpus := &BreachCorpus{prefixes: make(map[string]struct{})}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		entry, _, _ = strings.Cut(entry, ":")
+		entry = strings.ToUpper(entry)
+		if strings.Trim(entry, "0123456789ABCDEF") != "" || len(entry) < minBreachPrefix || len(entry) > sha1.Size*2 {
+			return nil, fmt.Errorf("breach corpus %s line %d: not a sha-1 hash prefix", path, line)
+		}
+		corpus.prefixes[entry] = struct{}{}
+		if !slices.Contains(corpus.lengths, len(entry)) {
+			corpus.lengths = append(corpus.lengths, len(entry))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return corpus, nil
+}
+
+// Contains reports whether the password's SHA-1 digest matches an entry.
+func (c *BreachCorpus) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	for _, n := range c.lengths {
+		if _, ok := c.prefixes[digest[:n]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len is the number of entries loaded.
+func (c *BreachCorpus) Len() int {
+	return len(c.prefixes)
+}
@@ -0,0 +1,74 @@
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sha1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+// sha1("123456")   = 7C4A8D09CA3762AF61E59520943DC26494F8941B
+
+func TestBreachCorpus(t *testing.T) {
+	corpus := loadTestCorpus(t, "# pwned passwords sample\n"+
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"+
+		"\n"+
+		"7c4a8d09ca\n")
+	if corpus.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", corpus.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true}, // matched by its lowercase prefix
+		{"Password", false},
+		{"correct horse battery staple", false},
+	}
+	for _, tt := range tests {
+		if got := corpus.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestLoadBreachCorpusRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"prefix too short", "5BAA61E4C\n"},
+		{"longer than sha-1", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD800\n"},
+		{"not hex", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FDZ\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadBreachCorpus(writeCorpus(t, tt.content)); err == nil {
+				t.Fatal("LoadBreachCorpus() succeeded")
+			}
+		})
+	}
+	if _, err := LoadBreachCorpus(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("LoadBreachCorpus() of a missing file succeeded")
+	}
+}
+
+func writeCorpus(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadTestCorpus(t *testing.T, content string) *BreachCorpus {
+	t.Helper()
+	corpus, err := LoadBreachCorpus(writeCorpus(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
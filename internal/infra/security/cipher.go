@@ -0,0 +1,59 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Cipher encrypts small secrets (signing keys, TOTP seeds) before they are
+// written to the database, using AES-256-GCM with a random nonce per value.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a 32 byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// NewCipherFromBase64 decodes a standard base64 key (as found in env vars).
+func NewCipherFromBase64(encoded string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+	return NewCipher(key)
+}
+
+// Encrypt returns nonce || ciphertext.
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt reverses Encrypt.
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	return c.aead.Open(nil, data[:size], data[size:], nil)
+}
@@ -0,0 +1,141 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const dpopProofType = "dpop+jwt"
+
+var (
+	ErrInvalidDPoPProof = errors.New("invalid DPoP proof")
+	ErrDPoPReplay       = errors.New("DPoP proof replayed")
+)
+
+// dpopAlgorithms are the asymmetric algorithms a client may sign proofs with.
+var dpopAlgorithms = []string{AlgES256, AlgRS256, AlgEdDSA}
+
+// DPoPProof is the verified content of a DPoP proof (RFC 9449).
+type DPoPProof struct {
+	// JKT is the RFC 7638 thumbprint of the client's public key.
+	JKT      string
+	JTI      string
+	IssuedAt time.Time
+}
+
+// DPoPVerifier validates DPoP proof JWTs presented in the DPoP header.
+type DPoPVerifier struct {
+	baseURL string
+	window  time.Duration
+	replay  ReplayCache
+}
+
+// NewDPoPVerifier creates a verifier. baseURL is the externally visible base
+// URL of the service (the request path is appended to build htu); window is
+// how far iat may deviate from now.
+func NewDPoPVerifier(baseURL string, window time.Duration, replay ReplayCache) *DPoPVerifier {
+	if window <= 0 {
+		window = time.Minute
+	}
+	if replay == nil {
+		replay = NewMemoryReplayCache()
+	}
+	return &DPoPVerifier{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		window:  window,
+		replay:  replay,
+	}
+}
+
+// Verify checks a proof for the request method and path. When accessToken is
+// non-empty the proof must also carry its hash in ath.
+func (v *DPoPVerifier) Verify(proof, method, path, accessToken string) (*DPoPProof, error) {
+	if proof == "" || strings.Contains(proof, ",") {
+		// exactly one proof is allowed
+		return nil, ErrInvalidDPoPProof
+	}
+
+	var jkt string
+	token, err := jwt.Parse(proof, func(token *jwt.Token) (any, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, dpopProofType) {
+			return nil, errors.New("wrong typ")
+		}
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		var jwk JWK
+		if err := json.Unmarshal(raw, &jwk); err != nil {
+			return nil, err
+		}
+		if jkt, err = jwk.Thumbprint(); err != nil {
+			return nil, err
+		}
+		return jwk.PublicKey()
+	}, jwt.WithValidMethods(dpopAlgorithms), jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	htm, _ := claims["htm"].(string)
+	htu, _ := claims["htu"].(string)
+	jti, _ := claims["jti"].(string)
+	if jti == "" || !strings.EqualFold(htm, method) || !sameURI(htu, v.baseURL+path) {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	iatv, ok := claims["iat"].(float64)
+	if !ok {
+		return nil, ErrInvalidDPoPProof
+	}
+	iat := time.Unix(int64(iatv), 0)
+	if skew := time.Since(iat); skew > v.window || skew < -v.window {
+		return nil, fmt.Errorf("%w: iat outside acceptable window", ErrInvalidDPoPProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != b64.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("%w: ath mismatch", ErrInvalidDPoPProof)
+		}
+	}
+
+	// the proof cannot be used again while its iat is still acceptable
+	fresh, err := v.replay.Remember(jkt+":"+jti, iat.Add(2*v.window))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrDPoPReplay
+	}
+
+	return &DPoPProof{JKT: jkt, JTI: jti, IssuedAt: iat}, nil
+}
+
+// sameURI compares htu ignoring query and fragment, as RFC 9449 requires.
+// Scheme and host are case-insensitive, the path is not.
+func sameURI(htu, expected string) bool {
+	a, err := url.Parse(htu)
+	if err != nil || a.Host == "" {
+		return false
+	}
+	b, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		strings.TrimRight(a.Path, "/") == strings.TrimRight(b.Path, "/")
+}
@@ -0,0 +1,164 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const dpopTestBaseURL = "https://auth.example.com"
+
+type dpopClient struct {
+	key *ecdsa.PrivateKey
+	jwk *JWK
+	jkt string
+}
+
+func newDPoPClient(t *testing.T) *dpopClient {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := PublicJWK(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jkt, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dpopClient{key: key, jwk: jwk, jkt: jkt}
+}
+
+// proof signs a proof with claims over the defaults for POST /token.
+func (c *dpopClient) proof(t *testing.T, typ string, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"jti": uuid.NewString(),
+		"htm": "POST",
+		"htu": dpopTestBaseURL + "/api/v1/oauth/token",
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+			continue
+		}
+		base[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, base)
+	token.Header["typ"] = typ
+	token.Header["jwk"] = c.jwk
+	signed, err := token.SignedString(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestDPoPVerify(t *testing.T) {
+	client := newDPoPClient(t)
+	accessToken := "an-access-token"
+	ath := sha256.Sum256([]byte(accessToken))
+
+	tests := []struct {
+		name        string
+		typ         string
+		claims      jwt.MapClaims
+		method      string
+		path        string
+		accessToken string
+		wantErr     error
+	}{
+		{name: "valid", typ: "dpop+jwt", method: "POST", path: "/api/v1/oauth/token"},
+		{name: "method is case-insensitive", typ: "dpop+jwt", method: "post", path: "/api/v1/oauth/token"},
+		{name: "query in htu is ignored", typ: "dpop+jwt", claims: jwt.MapClaims{"htu": dpopTestBaseURL + "/api/v1/oauth/token?x=1"}, method: "POST", path: "/api/v1/oauth/token"},
+		{name: "valid with ath", typ: "dpop+jwt", claims: jwt.MapClaims{"ath": b64.EncodeToString(ath[:])}, method: "POST", path: "/api/v1/oauth/token", accessToken: accessToken},
+		{name: "wrong typ", typ: "JWT", method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "wrong method", typ: "dpop+jwt", method: "GET", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "wrong path", typ: "dpop+jwt", method: "POST", path: "/api/v1/auth/me", wantErr: ErrInvalidDPoPProof},
+		{name: "wrong host", typ: "dpop+jwt", claims: jwt.MapClaims{"htu": "https://evil.example.com/api/v1/oauth/token"}, method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "missing jti", typ: "dpop+jwt", claims: jwt.MapClaims{"jti": nil}, method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "missing iat", typ: "dpop+jwt", claims: jwt.MapClaims{"iat": nil}, method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "iat too old", typ: "dpop+jwt", claims: jwt.MapClaims{"iat": time.Now().Add(-5 * time.Minute).Unix()}, method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "iat in the future", typ: "dpop+jwt", claims: jwt.MapClaims{"iat": time.Now().Add(5 * time.Minute).Unix()}, method: "POST", path: "/api/v1/oauth/token", wantErr: ErrInvalidDPoPProof},
+		{name: "missing ath", typ: "dpop+jwt", method: "POST", path: "/api/v1/oauth/token", accessToken: accessToken, wantErr: ErrInvalidDPoPProof},
+		{name: "ath of another token", typ: "dpop+jwt", claims: jwt.MapClaims{"ath": b64.EncodeToString(ath[:])}, method: "POST", path: "/api/v1/oauth/token", accessToken: "another-token", wantErr: ErrInvalidDPoPProof},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewDPoPVerifier(dpopTestBaseURL, time.Minute, nil)
+			got, err := v.Verify(client.proof(t, tt.typ, tt.claims), tt.method, tt.path, tt.accessToken)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.JKT != client.jkt {
+				t.Errorf("JKT = %s, want %s", got.JKT, client.jkt)
+			}
+		})
+	}
+}
+
+func TestDPoPVerifyRejectsReplay(t *testing.T) {
+	client := newDPoPClient(t)
+	v := NewDPoPVerifier(dpopTestBaseURL, time.Minute, nil)
+	proof := client.proof(t, "dpop+jwt", nil)
+
+	if _, err := v.Verify(proof, "POST", "/api/v1/oauth/token", ""); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := v.Verify(proof, "POST", "/api/v1/oauth/token", ""); !errors.Is(err, ErrDPoPReplay) {
+		t.Fatalf("second use error = %v, want %v", err, ErrDPoPReplay)
+	}
+}
+
+func TestDPoPVerifyRejectsMalformedHeaders(t *testing.T) {
+	client := newDPoPClient(t)
+	proof := client.proof(t, "dpop+jwt", nil)
+	v := NewDPoPVerifier(dpopTestBaseURL, time.Minute, nil)
+
+	for name, header := range map[string]string{
+		"empty":          "",
+		"two proofs":     proof + "," + proof,
+		"not a JWT":      "not-a-jwt",
+		"HMAC signature": hmacProof(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := v.Verify(header, "POST", "/api/v1/oauth/token", ""); !errors.Is(err, ErrInvalidDPoPProof) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrInvalidDPoPProof)
+			}
+		})
+	}
+}
+
+// hmacProof is a proof signed with a symmetric key, which DPoP forbids.
+func hmacProof(t *testing.T) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"jti": uuid.NewString(),
+		"htm": "POST",
+		"htu": dpopTestBaseURL + "/api/v1/oauth/token",
+		"iat": time.Now().Unix(),
+	})
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = map[string]string{"kty": "oct", "k": "c2VjcmV0"}
+	signed, err := token.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
@@ -0,0 +1,35 @@
+package security
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signIDToken builds an OpenID Connect ID token. It is signed with the access
+// keyring so relying parties verify it through the published JWKS.
+func (t *TokenManager) signIDToken(userID, email, username, sid string, now, exp time.Time, c *IDTokenClaims) (string, error) {
+	authTime := c.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                t.issuer,
+		"sub":                userID,
+		"aud":                c.Audience,
+		"iat":                now.Unix(),
+		"exp":                exp.Unix(),
+		"auth_time":          authTime.Unix(),
+		"sid":                sid,
+		"email":              email,
+		"email_verified":     c.EmailVerified,
+		"name":               c.Name,
+		"preferred_username": username,
+	}
+	if c.Nonce != "" {
+		claims["nonce"] = c.Nonce
+	}
+
+	return sign(t.accessKeys, claims)
+}
@@ -0,0 +1,72 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIDToken(t *testing.T) {
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		claims IDTokenClaims
+		want   jwt.MapClaims
+	}{
+		{
+			name:   "with nonce",
+			claims: IDTokenClaims{Audience: "client-app", Name: "Jane Doe", EmailVerified: true, Nonce: "n-0S6_WzA2Mj", AuthTime: authTime},
+			want:   jwt.MapClaims{"aud": "client-app", "nonce": "n-0S6_WzA2Mj", "name": "Jane Doe", "email_verified": true, "auth_time": float64(authTime.Unix())},
+		},
+		{
+			name:   "without nonce",
+			claims: IDTokenClaims{Audience: "client-app"},
+			want:   jwt.MapClaims{"aud": "client-app", "email_verified": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newTestTokenManager(t, AlgES256)
+			pair, err := tm.GenerateTokenPair("user-1", "jane@example.com", "jane", nil, WithSessionID("session-1"), WithIDToken(tt.claims))
+			if err != nil {
+				t.Fatalf("GenerateTokenPair() error = %v", err)
+			}
+
+			// relying parties verify it with the published key
+			jwk := tm.JWKS().Keys[0]
+			pub, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := jwt.MapClaims{}
+			tok, err := jwt.ParseWithClaims(pair.IDToken, claims, func(*jwt.Token) (any, error) { return pub, nil },
+				jwt.WithValidMethods([]string{AlgES256}), jwt.WithIssuer(testIssuer), jwt.WithAudience(tt.claims.Audience))
+			if err != nil {
+				t.Fatalf("ID token does not verify: %v", err)
+			}
+			if tok.Header["kid"] != jwk.Kid {
+				t.Errorf("kid = %v, want %v", tok.Header["kid"], jwk.Kid)
+			}
+
+			for k, v := range tt.want {
+				if claims[k] != v {
+					t.Errorf("%s = %v, want %v", k, claims[k], v)
+				}
+			}
+			if _, ok := tt.want["nonce"]; !ok {
+				if _, ok := claims["nonce"]; ok {
+					t.Error("nonce present without one being asked for")
+				}
+			}
+			if _, ok := tt.want["auth_time"]; !ok && claims["auth_time"] != claims["iat"] {
+				t.Errorf("auth_time = %v, want iat %v", claims["auth_time"], claims["iat"])
+			}
+			if claims["sub"] != "user-1" || claims["sid"] != "session-1" || claims["preferred_username"] != "jane" || claims["email"] != "jane@example.com" {
+				t.Errorf("ID token claims = %v", claims)
+			}
+		})
+	}
+}
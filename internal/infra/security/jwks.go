@@ -0,0 +1,163 @@
+package security
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// JWK is the JSON Web Key representation (RFC 7517) of a public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC / OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served on /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// PublicJWK converts a public key into its JWK form.
+func PublicJWK(pub any) (*JWK, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			N:   b64.EncodeToString(k.N.Bytes()),
+			E:   b64.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 keys are supported")
+		}
+		ecdh, err := k.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		// uncompressed point: 0x04 || X || Y
+		raw := ecdh.Bytes()
+		return &JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   b64.EncodeToString(raw[1:33]),
+			Y:   b64.EncodeToString(raw[33:65]),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   b64.EncodeToString(k),
+		}, nil
+	}
+	return nil, errors.New("unsupported public key type")
+}
+
+// PublicKey converts the JWK back into a crypto public key.
+func (j *JWK) PublicKey() (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 coordinates")
+		}
+		raw := append(append([]byte{0x04}, x...), y...)
+		// validates that the point is on the curve
+		if _, err := ecdh.P256().NewPublicKey(raw); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of the key.
+func (j *JWK) Thumbprint() (string, error) {
+	// members must be in lexicographic order with no whitespace
+	var members any
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+	raw, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return b64.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,132 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638 section 3.1
+			name: "RSA",
+			jwk: JWK{
+				Kty: "RSA",
+				N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+					"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n9" +
+					"1CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E:   "AQAB",
+				Alg: "RS256",
+				Kid: "2011-04-29",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 appendix A.3
+			name: "Ed25519",
+			jwk:  JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.jwk.Thumbprint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Thumbprint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKThumbprintIgnoresOptionalMembers(t *testing.T) {
+	base := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+	decorated := base
+	decorated.Kid, decorated.Use, decorated.Alg = "some-kid", "sig", "EdDSA"
+
+	a, err := base.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := decorated.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("thumbprints differ: %s and %s", a, b)
+	}
+}
+
+func TestPublicJWKRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pub  any
+		kty  string
+	}{
+		{"RSA", &rsaKey.PublicKey, "RSA"},
+		{"P-256", &ecKey.PublicKey, "EC"},
+		{"Ed25519", edPub, "OKP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk, err := PublicJWK(tt.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jwk.Kty != tt.kty {
+				t.Errorf("kty = %s, want %s", jwk.Kty, tt.kty)
+			}
+			back, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eq, ok := back.(interface{ Equal(crypto.PublicKey) bool }); !ok || !eq.Equal(tt.pub) {
+				t.Errorf("PublicKey() = %v, want %v", back, tt.pub)
+			}
+		})
+	}
+}
+
+func TestJWKPublicKeyRejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+	}{
+		{"unknown kty", JWK{Kty: "oct"}},
+		{"unsupported curve", JWK{Kty: "EC", Crv: "P-384", X: "AA", Y: "AA"}},
+		{"short coordinates", JWK{Kty: "EC", Crv: "P-256", X: "AAAA", Y: "AAAA"}},
+		{"point not on curve", JWK{Kty: "EC", Crv: "P-256", X: b64.EncodeToString(make([]byte, 32)), Y: b64.EncodeToString(make([]byte, 32))}},
+		{"short Ed25519 key", JWK{Kty: "OKP", Crv: "Ed25519", X: "AAAA"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.jwk.PublicKey(); err == nil {
+				t.Error("PublicKey() succeeded, want an error")
+			}
+		})
+	}
+}
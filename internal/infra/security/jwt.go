@@ -1,22 +1,74 @@
 package security
 
 import (
+	"context"
 	"errors"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
+)
+
+// tokenLeeway absorbs clock skew between us and the services verifying our tokens.
+const tokenLeeway = 5 * time.Second
+
+// ScopeAccountRecovery marks the access token of a recovery session.
+const ScopeAccountRecovery = "account_recovery"
+
+// MachineSubjectPrefix starts the subject of tokens issued to a client on its
+// own behalf: "client:<client_id>".
+const MachineSubjectPrefix = "client:"
+
+var (
+	ErrAudienceNotAllowed = errors.New("audience not allowed")
+	ErrInvalidAudience    = errors.New("token not issued for this audience")
 )
 
 // TokenManager provides methods to create and verify access & refresh tokens.
+// Access tokens are signed with the configured algorithm (HMAC or an asymmetric
+// key pair); refresh tokens are only ever verified by this service and stay HS256.
+// Each token type is backed by a Keyring so keys can rotate without logging
+// everybody out: tokens carry a kid header and retired keys stay verify-only.
 type TokenManager struct {
-	accessSecret  []byte
-	refreshSecret []byte
-	accessTTL     time.Duration
-	refreshTTL    time.Duration
-	logger        *logrus.Logger
+	accessKeys  *Keyring
+	refreshKeys *Keyring
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	issuer      string
+	audience    string
+	audiences   []string
+	logger      *logrus.Logger
+}
+
+// TokenConfig configures a TokenManager.
+type TokenConfig struct {
+	// Algorithm used for access tokens: HS256 (default), RS256, ES256 or EdDSA.
+	Algorithm string
+	// AccessSecret is required for HS256 only.
+	AccessSecret string
+	// PrivateKeyPEM is required for the asymmetric algorithms.
+	PrivateKeyPEM []byte
+	RefreshSecret string
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+	// KeyStore shares rotated keys between replicas. The configured secret and
+	// private key only seed an empty store. Without a store, rotated keys live
+	// in memory only.
+	KeyStore signingkey.Repository
+	// KeyCipher encrypts key material before it is persisted. Optional.
+	KeyCipher *Cipher
+	// Issuer is placed in the iss claim of every token and enforced on parse.
+	Issuer string
+	// Audience is the aud of access tokens when the caller does not ask for one.
+	Audience string
+	// Audiences lists the additional audiences a caller may request.
+	Audiences []string
 }
 
 // TokenPair is the pair of tokens returned on login/refresh.
@@ -25,6 +77,7 @@ type TokenPair struct {
 	RefreshToken string
 	AccessExp    time.Time
 	RefreshExp   time.Time
+	IDToken      string // only set when requested via WithIDToken
 	JTI          string // unique id for the access token
 	SID          string // session id associated with refresh token
 }
@@ -38,59 +91,141 @@ type ClaimsPayload struct {
 	JTI      string
 	SID      string
 	Expiry   time.Time
+	Issuer   string
+	Audience []string
+	// Actor is set when the token was obtained through impersonation: the
+	// subject is the impersonated user, Actor the admin acting as them.
+	Actor *Actor
+	// JKT is the DPoP key thumbprint (cnf.jkt) the token is bound to, if any.
+	JKT string
+	// Scope is the space separated "scope" claim.
+	Scope string
+	// ClientID is the OAuth client the token was issued to, if any.
+	ClientID string
+	// APIKeyID is set when the caller authenticated with an API key rather
+	// than a token.
+	APIKeyID string
+}
+
+// Actor identifies who is acting on behalf of the subject (RFC 8693 "act").
+type Actor struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// IsImpersonated reports whether the token was issued via impersonation.
+func (c *ClaimsPayload) IsImpersonated() bool {
+	return c.Actor != nil
 }
 
-// NewTokenManager creates a TokenManager. Both secrets must be non-empty.
-// accessTTL and refreshTTL define token lifetimes.
-func NewTokenManager(accessSecret, refreshSecret string, accessTTL, refreshTTL time.Duration, logger *logrus.Logger) (*TokenManager, error) {
-	if accessSecret == "" || refreshSecret == "" {
-		return nil, errors.New("access and refresh secrets must be provided")
+// IsRestricted reports whether the token belongs to a restricted account
+// recovery session, which may only be used to reset credentials.
+func (c *ClaimsPayload) IsRestricted() bool {
+	return c.HasScope(ScopeAccountRecovery)
+}
+
+// IsMachine reports whether the token was issued to a service through the
+// client credentials grant. Such tokens have no user and no session.
+func (c *ClaimsPayload) IsMachine() bool {
+	return c.SID == "" && c.ClientID != "" && c.UserID == MachineSubjectPrefix+c.ClientID
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key.
+func (c *ClaimsPayload) IsAPIKey() bool {
+	return c.APIKeyID != ""
+}
+
+// HasScope reports whether the token was granted scope.
+func (c *ClaimsPayload) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// NewTokenManager creates a TokenManager. The refresh secret is always required;
+// the access token needs either a secret (HS256) or a private key (RS256/ES256/EdDSA).
+func NewTokenManager(cfg TokenConfig, logger *logrus.Logger) (*TokenManager, error) {
+	alg, err := NormalizeAlgorithm(cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RefreshSecret == "" {
+		return nil, errors.New("refresh secret must be provided")
 	}
+
+	var accessSeed *SigningKey
+	if alg == AlgHS256 {
+		if cfg.AccessSecret == "" {
+			return nil, errors.New("access secret must be provided for HS256")
+		}
+		accessSeed, err = NewHMACKey([]byte(cfg.AccessSecret))
+	} else {
+		if len(cfg.PrivateKeyPEM) == 0 {
+			return nil, errors.New("private key must be provided for " + alg)
+		}
+		accessSeed, err = ParsePrivateKeyPEM(alg, cfg.PrivateKeyPEM)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refreshSeed, err := NewHMACKey([]byte(cfg.RefreshSecret))
+	if err != nil {
+		return nil, err
+	}
+
 	if logger == nil {
 		// if not provided, create a minimal logger
 		logger = logrus.New()
 	}
+
+	// retired keys must outlive the longest token they signed
+	accessKeys, err := newKeyring(signingkey.PurposeAccess, accessSeed, cfg.AccessTTL+tokenLeeway, cfg.KeyStore, cfg.KeyCipher, logger)
+	if err != nil {
+		return nil, err
+	}
+	refreshKeys, err := newKeyring(signingkey.PurposeRefresh, refreshSeed, cfg.RefreshTTL+tokenLeeway, cfg.KeyStore, cfg.KeyCipher, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TokenManager{
-		accessSecret:  []byte(accessSecret),
-		refreshSecret: []byte(refreshSecret),
-		accessTTL:     accessTTL,
-		refreshTTL:    refreshTTL,
-		logger:        logger,
+		accessKeys:  accessKeys,
+		refreshKeys: refreshKeys,
+		accessTTL:   cfg.AccessTTL,
+		refreshTTL:  cfg.RefreshTTL,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		audiences:   cfg.Audiences,
+		logger:      logger,
 	}, nil
 }
 
 // GenerateTokenPair issues an access + refresh token for the given subject details.
 // userID: string identifier (uuid). roles: list of roles (eg "user","admin").
 // Returns TokenPair where JTI is access token id and SID is session id (refresh).
-func (t *TokenManager) GenerateTokenPair(userID, email, username string, roles []string) (*TokenPair, error) {
+func (t *TokenManager) GenerateTokenPair(userID, email, username string, roles []string, opts ...TokenOption) (*TokenPair, error) {
+	o := applyTokenOptions(opts)
+	aud, err := t.resolveAudience(o.audience)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now().UTC()
 	accessExp := now.Add(t.accessTTL)
 	refreshExp := now.Add(t.refreshTTL)
 
 	jti := uuid.NewString() // unique id for access token
-	sid := uuid.NewString() // session id for refresh token
-
-	// Access token claims
-	accessClaims := jwt.MapClaims{
-		"sub":        userID,
-		"email":      email,
-		"username":   username,
-		"roles":      roles,
-		"jti":        jti,
-		"session_id": sid,
-		"iat":        now.Unix(),
-		"exp":        accessExp.Unix(),
-		"nbf":        now.Unix(),
+	sid := o.sessionID      // session id for refresh token
+	if sid == "" {
+		sid = uuid.NewString()
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessStr, err := accessToken.SignedString(t.accessSecret)
+	accessStr, err := signTyped(t.accessKeys, t.accessClaims(userID, email, username, roles, jti, sid, aud, now, accessExp, o), accessTokenType)
 	if err != nil {
 		t.logger.WithError(err).Error("failed to sign access token")
 		return nil, err
 	}
 
-	// Refresh token claims - keep minimal, but include sid so we can tie it to session
+	// Refresh token claims - keep minimal, but include sid so we can tie it to session.
+	// aud is carried over so a refresh re-issues for the same audience.
 	refreshClaims := jwt.MapClaims{
 		"sub":        userID,
 		"session_id": sid,
@@ -98,49 +233,152 @@ func (t *TokenManager) GenerateTokenPair(userID, email, username string, roles [
 		"iat":        now.Unix(),
 		"exp":        refreshExp.Unix(),
 	}
+	if t.issuer != "" {
+		refreshClaims["iss"] = t.issuer
+	}
+	if aud != "" {
+		refreshClaims["aud"] = aud
+	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshStr, err := refreshToken.SignedString(t.refreshSecret)
+	refreshStr, err := sign(t.refreshKeys, refreshClaims)
 	if err != nil {
 		t.logger.WithError(err).Error("failed to sign refresh token")
 		return nil, err
 	}
 
-	return &TokenPair{
+	pair := &TokenPair{
 		AccessToken:  accessStr,
 		RefreshToken: refreshStr,
 		AccessExp:    accessExp,
 		RefreshExp:   refreshExp,
 		JTI:          jti,
 		SID:          sid,
+	}
+
+	if o.idToken != nil {
+		pair.IDToken, err = t.signIDToken(userID, email, username, sid, now, accessExp, o.idToken)
+		if err != nil {
+			t.logger.WithError(err).Error("failed to sign id token")
+			return nil, err
+		}
+	}
+
+	return pair, nil
+}
+
+// GenerateAccessToken issues a standalone access token without a refresh
+// token, e.g. for impersonation. The session id must be supplied with
+// WithSessionID when the token should be tied to a session.
+func (t *TokenManager) GenerateAccessToken(userID, email, username string, roles []string, opts ...TokenOption) (*TokenPair, error) {
+	o := applyTokenOptions(opts)
+	aud, err := t.resolveAudience(o.audience)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	ttl := t.accessTTL
+	if o.accessTTL > 0 {
+		ttl = o.accessTTL
+	}
+	accessExp := now.Add(ttl)
+	jti := uuid.NewString()
+
+	accessStr, err := signTyped(t.accessKeys, t.accessClaims(userID, email, username, roles, jti, o.sessionID, aud, now, accessExp, o), accessTokenType)
+	if err != nil {
+		t.logger.WithError(err).Error("failed to sign access token")
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken: accessStr,
+		AccessExp:   accessExp,
+		JTI:         jti,
+		SID:         o.sessionID,
 	}, nil
 }
 
+// resolveAudience returns the audience to issue for, rejecting audiences
+// that were not configured.
+func (t *TokenManager) resolveAudience(requested string) (string, error) {
+	if requested == "" || requested == t.audience {
+		return t.audience, nil
+	}
+	if !slices.Contains(t.audiences, requested) {
+		return "", ErrAudienceNotAllowed
+	}
+	return requested, nil
+}
+
+// AllowsAudience reports whether tokens may be issued for aud.
+func (t *TokenManager) AllowsAudience(aud string) bool {
+	_, err := t.resolveAudience(aud)
+	return err == nil
+}
+
+// accessClaims builds the claim set shared by every access token we issue.
+func (t *TokenManager) accessClaims(userID, email, username string, roles []string, jti, sid, aud string, now, exp time.Time, o *tokenOptions) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"sub":      userID,
+		"email":    email,
+		"username": username,
+		"roles":    roles,
+		"jti":      jti,
+		"iat":      now.Unix(),
+		"exp":      exp.Unix(),
+		"nbf":      now.Unix(),
+	}
+	if t.issuer != "" {
+		claims["iss"] = t.issuer
+	}
+	if aud != "" {
+		claims["aud"] = aud
+	}
+	if sid != "" {
+		claims["session_id"] = sid
+	}
+	if o.jkt != "" {
+		claims["cnf"] = map[string]interface{}{"jkt": o.jkt}
+	}
+	if o.scope != "" {
+		claims["scope"] = o.scope
+	}
+	if o.clientID != "" {
+		claims["client_id"] = o.clientID
+	}
+	if o.actor != nil {
+		claims["act"] = map[string]interface{}{
+			"sub":      o.actor.Subject,
+			"username": o.actor.Username,
+		}
+	}
+	return claims
+}
+
 // ParseAccessToken parses and validates an access token and returns ClaimsPayload.
-func (t *TokenManager) ParseAccessToken(tokenStr string) (*ClaimsPayload, error) {
+// The issuer and the at+jwt type are always checked, so ID tokens are refused;
+// when audiences are given the token's aud must contain at least one of them.
+func (t *TokenManager) ParseAccessToken(tokenStr string, audiences ...string) (*ClaimsPayload, error) {
 	if tokenStr == "" {
 		return nil, errors.New("token empty")
 	}
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		// only accept HMAC signing
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return t.accessSecret, nil
-	}, jwt.WithLeeway(5*time.Second))
+	var opts []jwt.ParserOption
+	if t.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(t.issuer))
+	}
+	claims, err := parseTyped(t.accessKeys, tokenStr, accessTokenType, opts...)
 	if err != nil {
 		return nil, err
 	}
-	if !token.Valid {
-		return nil, errors.New("invalid token")
+	payload, err := claimsToPayload(claims)
+	if err != nil {
+		return nil, err
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid claims")
+	if len(audiences) > 0 && !slices.ContainsFunc(payload.Audience, func(aud string) bool {
+		return slices.Contains(audiences, aud)
+	}) {
+		return nil, ErrInvalidAudience
 	}
-
-	return claimsToPayload(claims)
+	return payload, nil
 }
 
 // ParseRefreshToken parses and validates a refresh token.
@@ -148,12 +386,195 @@ func (t *TokenManager) ParseRefreshToken(tokenStr string) (*ClaimsPayload, error
 	if tokenStr == "" {
 		return nil, errors.New("token empty")
 	}
+	claims, err := parse(t.refreshKeys, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	// challenges share the refresh keyring but are not refresh tokens
+	if _, ok := claims[tokenUseClaim]; ok {
+		return nil, errors.New("not a refresh token")
+	}
+	return claimsToPayload(claims)
+}
+
+// Algorithm returns the algorithm used to sign access tokens.
+func (t *TokenManager) Algorithm() string {
+	return t.accessKeys.Active().Algorithm
+}
+
+// JWKS returns the public keys that verify access tokens, including retired
+// keys that are still inside their verification window. Symmetric keys are
+// never published, so the set is empty when running with HS256.
+func (t *TokenManager) JWKS() *JWKSet {
+	set := &JWKSet{Keys: []JWK{}}
+	for _, key := range t.accessKeys.verificationKeys() {
+		if key.IsSymmetric() {
+			continue
+		}
+		jwk, err := PublicJWK(key.verifyKey)
+		if err != nil {
+			t.logger.WithError(err).Error("failed to encode public key as JWK")
+			continue
+		}
+		jwk.Use = "sig"
+		jwk.Alg = key.Algorithm
+		jwk.Kid = key.ID
+		set.Keys = append(set.Keys, *jwk)
+	}
+	return set
+}
+
+// Keys describes the access and refresh keyrings.
+func (t *TokenManager) Keys() []KeyInfo {
+	return append(t.accessKeys.Info(), t.refreshKeys.Info()...)
+}
+
+// RotateKeys rotates the keyring for purpose (signingkey.PurposeAccess or
+// signingkey.PurposeRefresh), or both when purpose is empty.
+func (t *TokenManager) RotateKeys(purpose string) error {
+	if purpose == "" || purpose == signingkey.PurposeAccess {
+		if err := rotateNow(t.accessKeys); err != nil {
+			return err
+		}
+	}
+	if purpose == "" || purpose == signingkey.PurposeRefresh {
+		if err := rotateNow(t.refreshKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateNow rotates ring, retrying once on a fresh view of the store when
+// another replica changed the active key since the last sync.
+func rotateNow(ring *Keyring) error {
+	_, err := ring.Rotate()
+	if !errors.Is(err, signingkey.ErrActiveKeyChanged) {
+		return err
+	}
+	if err := ring.Reload(); err != nil {
+		return err
+	}
+	_, err = ring.Rotate()
+	return err
+}
+
+// RunKeyMaintenance blocks until ctx is done. Every syncEvery it reloads the
+// keyrings from the store, prunes expired keys and, when rotateEvery > 0,
+// rotates any keyring whose active key is older than rotateEvery. When
+// several replicas rotate at once, the store keeps the first new key.
+func (t *TokenManager) RunKeyMaintenance(ctx context.Context, rotateEvery, syncEvery time.Duration) {
+	if syncEvery <= 0 {
+		syncEvery = time.Minute
+	}
+	ticker := time.NewTicker(syncEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, ring := range []*Keyring{t.accessKeys, t.refreshKeys} {
+			if err := ring.Reload(); err != nil {
+				t.logger.WithError(err).Warn("failed to reload signing keys")
+				continue
+			}
+			if err := ring.Prune(); err != nil {
+				t.logger.WithError(err).Warn("failed to prune signing keys")
+			}
+			if rotateEvery > 0 && time.Since(ring.ActiveSince()) >= rotateEvery {
+				_, err := ring.Rotate()
+				if errors.Is(err, signingkey.ErrActiveKeyChanged) {
+					// another replica rotated first; the next sync picks it up
+					continue
+				}
+				if err != nil {
+					t.logger.WithError(err).Error("scheduled key rotation failed")
+				}
+			}
+		}
+	}
+}
+
+// Issuer returns the configured OIDC issuer identifier.
+func (t *TokenManager) Issuer() string {
+	return t.issuer
+}
+
+// Audience returns the default access token audience.
+func (t *TokenManager) Audience() string {
+	return t.audience
+}
+
+// Audiences returns every audience access tokens may be issued for, the
+// default first. Unset entries are left out: an empty audience would accept
+// tokens without one.
+func (t *TokenManager) Audiences() []string {
+	audiences := make([]string, 0, len(t.audiences)+1)
+	for _, aud := range append([]string{t.audience}, t.audiences...) {
+		if aud != "" {
+			audiences = append(audiences, aud)
+		}
+	}
+	return audiences
+}
+
+func (t *TokenManager) AccessTTL() time.Duration {
+	return t.accessTTL
+}
+
+func (t *TokenManager) RefreshTTL() time.Duration {
+	return t.refreshTTL
+}
+
+// accessTokenType is the JOSE typ of access tokens (RFC 9068). ID tokens are
+// signed with the same keys, so the header is what tells them apart.
+const accessTokenType = "at+jwt"
+
+// sign signs claims with the ring's active key and stamps its kid in the header.
+func sign(ring *Keyring, claims jwt.MapClaims) (string, error) {
+	return signTyped(ring, claims, "")
+}
+
+// signTyped is sign with an explicit typ header instead of the default JWT.
+func signTyped(ring *Keyring, claims jwt.MapClaims, typ string) (string, error) {
+	key := ring.Active()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.signKey)
+}
+
+// parse verifies tokenStr against the key named by its kid header. Tokens
+// issued before kids were introduced fall back to the active key. The
+// algorithm must match the selected key, never whatever the header claims.
+func parse(ring *Keyring, tokenStr string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	return parseTyped(ring, tokenStr, "", opts...)
+}
+
+// parseTyped is parse for tokens that must carry typ in their header.
+func parseTyped(ring *Keyring, tokenStr, typ string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if typ != "" && !hasTokenType(token, typ) {
+			return nil, errors.New("unexpected token type")
+		}
+		key := ring.Active()
+		if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+			var err error
+			if key, err = ring.Lookup(kid); err != nil {
+				return nil, err
+			}
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("unexpected signing method")
 		}
-		return t.refreshSecret, nil
-	}, jwt.WithLeeway(5*time.Second))
+		return key.verifyKey, nil
+	}, append(opts, jwt.WithLeeway(tokenLeeway))...)
 	if err != nil {
 		return nil, err
 	}
@@ -165,16 +586,14 @@ func (t *TokenManager) ParseRefreshToken(tokenStr string) (*ClaimsPayload, error
 	if !ok {
 		return nil, errors.New("invalid claims")
 	}
-
-	return claimsToPayload(claims)
+	return claims, nil
 }
 
-func (t *TokenManager) AccessTTL() time.Duration {
-	return t.accessTTL
-}
-
-func (t *TokenManager) RefreshTTL() time.Duration {
-	return t.refreshTTL
+// hasTokenType compares the typ header, which RFC 7515 lets carry an
+// "application/" prefix.
+func hasTokenType(token *jwt.Token, typ string) bool {
+	got, _ := token.Header["typ"].(string)
+	return strings.EqualFold(strings.TrimPrefix(strings.ToLower(got), "application/"), typ)
 }
 
 // claimsToPayload converts MapClaims into our ClaimsPayload structure.
@@ -206,6 +625,22 @@ func claimsToPayload(claims jwt.MapClaims) (*ClaimsPayload, error) {
 	if sid, ok := claims["session_id"].(string); ok {
 		cp.SID = sid
 	}
+	cp.Issuer, _ = claims.GetIssuer()
+	// aud may be a single string or an array
+	cp.Audience, _ = claims.GetAudience()
+	if cnf, ok := claims["cnf"].(map[string]interface{}); ok {
+		cp.JKT, _ = cnf["jkt"].(string)
+	}
+	cp.Scope, _ = claims["scope"].(string)
+	cp.ClientID, _ = claims["client_id"].(string)
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		actor := &Actor{}
+		actor.Subject, _ = act["sub"].(string)
+		actor.Username, _ = act["username"].(string)
+		if actor.Subject != "" {
+			cp.Actor = actor
+		}
+	}
 	if roles, ok := claims["roles"]; ok {
 		switch r := roles.(type) {
 		case []string:
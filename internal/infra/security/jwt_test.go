@@ -0,0 +1,311 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "web"
+)
+
+func newTestTokenManager(t *testing.T, alg string) *TokenManager {
+	t.Helper()
+	cfg := TokenConfig{
+		Algorithm:     alg,
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+		Audiences:     []string{"mobile"},
+	}
+	if alg != AlgHS256 {
+		cfg.PrivateKeyPEM = testPrivateKeyPEM(t, alg)
+	}
+	tm, err := NewTokenManager(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewTokenManager(%s) error = %v", alg, err)
+	}
+	return tm
+}
+
+func testPrivateKeyPEM(t *testing.T, alg string) []byte {
+	t.Helper()
+	var (
+		priv any
+		err  error
+	)
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestTokenManagerAlgorithms(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			tm := newTestTokenManager(t, alg)
+			pair, err := tm.GenerateTokenPair("user-1", "jane@example.com", "jane", []string{"user"}, WithSessionID("session-1"))
+			if err != nil {
+				t.Fatalf("GenerateTokenPair() error = %v", err)
+			}
+
+			header := tokenHeader(t, pair.AccessToken)
+			if header["alg"] != alg || header["typ"] != accessTokenType || header["kid"] != tm.accessKeys.Active().ID {
+				t.Errorf("access token header = %v", header)
+			}
+
+			claims, err := tm.ParseAccessToken(pair.AccessToken, testAudience)
+			if err != nil {
+				t.Fatalf("ParseAccessToken() error = %v", err)
+			}
+			if claims.UserID != "user-1" || claims.SID != "session-1" || claims.JTI != pair.JTI ||
+				claims.Issuer != testIssuer || !slices.Equal(claims.Audience, []string{testAudience}) {
+				t.Errorf("ParseAccessToken() = %+v", claims)
+			}
+
+			if _, err := tm.ParseRefreshToken(pair.RefreshToken); err != nil {
+				t.Errorf("ParseRefreshToken() error = %v", err)
+			}
+			if _, err := tm.ParseRefreshToken(pair.AccessToken); err == nil {
+				t.Error("ParseRefreshToken() accepted an access token")
+			}
+			if _, err := tm.ParseAccessToken(pair.RefreshToken); err == nil {
+				t.Error("ParseAccessToken() accepted a refresh token")
+			}
+
+			// only public keys are published, under the kid tokens carry
+			jwks := tm.JWKS()
+			if alg == AlgHS256 {
+				if len(jwks.Keys) != 0 {
+					t.Errorf("JWKS() published %d HMAC keys", len(jwks.Keys))
+				}
+				return
+			}
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != header["kid"] || jwks.Keys[0].Alg != alg || jwks.Keys[0].Use != "sig" {
+				t.Fatalf("JWKS() = %+v", jwks.Keys)
+			}
+			thumbprint, err := jwks.Keys[0].Thumbprint()
+			if err != nil || thumbprint != jwks.Keys[0].Kid {
+				t.Errorf("kid %q is not the key thumbprint %q (%v)", jwks.Keys[0].Kid, thumbprint, err)
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenRejects(t *testing.T) {
+	tm := newTestTokenManager(t, AlgES256)
+	other := newTestTokenManager(t, AlgES256)
+	foreignIssuer := newTestTokenManager(t, AlgES256)
+	foreignIssuer.issuer = "https://evil.example.net"
+	foreignIssuer.accessKeys = tm.accessKeys
+
+	valid := func(tm *TokenManager) string {
+		pair, err := tm.GenerateTokenPair("user-1", "jane@example.com", "jane", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair.AccessToken
+	}
+	withIDToken := func() string {
+		pair, err := tm.GenerateTokenPair("user-1", "jane@example.com", "jane", nil, WithIDToken(IDTokenClaims{Audience: testAudience}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pair.IDToken
+	}
+	resign := func(mutate func(tok *jwt.Token)) string {
+		key := tm.accessKeys.Active()
+		tok := jwt.NewWithClaims(key.method, jwt.MapClaims{
+			"sub": "user-1", "iss": testIssuer, "aud": testAudience, "jti": "j",
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+		})
+		tok.Header["kid"] = key.ID
+		tok.Header["typ"] = accessTokenType
+		mutate(tok)
+		signed, err := tok.SignedString(key.signKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	hmacWithPublicKey := func() string {
+		// the classic confusion: an HMAC token keyed with the public key
+		tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "user-1", "iss": testIssuer, "exp": time.Now().Add(time.Minute).Unix(),
+		})
+		tok.Header["kid"] = tm.accessKeys.Active().ID
+		tok.Header["typ"] = accessTokenType
+		pub, _ := x509.MarshalPKIXPublicKey(tm.accessKeys.Active().PublicKey())
+		signed, err := tok.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		audiences []string
+		wantErr   error
+	}{
+		{name: "other audience", token: valid(tm), audiences: []string{"mobile"}, wantErr: ErrInvalidAudience},
+		{name: "signed by another service", token: valid(other), wantErr: ErrUnknownKey},
+		{name: "other issuer", token: valid(foreignIssuer), wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "expired beyond the leeway", token: resign(func(tok *jwt.Token) {
+			tok.Claims.(jwt.MapClaims)["exp"] = time.Now().Add(-tokenLeeway - time.Second).Unix()
+		}), wantErr: jwt.ErrTokenExpired},
+		{name: "ID token", token: withIDToken()},
+		{name: "missing typ", token: resign(func(tok *jwt.Token) { delete(tok.Header, "typ") })},
+		{name: "unknown kid", token: resign(func(tok *jwt.Token) { tok.Header["kid"] = "nope" }), wantErr: ErrUnknownKey},
+		{name: "HMAC keyed with the public key", token: hmacWithPublicKey()},
+		{name: "empty", token: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tm.ParseAccessToken(tt.token, tt.audiences...)
+			if err == nil {
+				t.Fatal("ParseAccessToken() succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenAudiences(t *testing.T) {
+	tm := newTestTokenManager(t, AlgHS256)
+
+	tests := []struct {
+		name      string
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{name: "default", want: testAudience},
+		{name: "default by name", requested: testAudience, want: testAudience},
+		{name: "additional audience", requested: "mobile", want: "mobile"},
+		{name: "unknown audience", requested: "billing", wantErr: ErrAudienceNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tm.AllowsAudience(tt.requested); got != (tt.wantErr == nil) {
+				t.Errorf("AllowsAudience() = %v", got)
+			}
+			pair, err := tm.GenerateTokenPair("user-1", "", "", nil, WithAudience(tt.requested))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateTokenPair() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// accepted by any matching audience, refused by others
+			if _, err := tm.ParseAccessToken(pair.AccessToken, "billing", tt.want); err != nil {
+				t.Errorf("ParseAccessToken(%s) error = %v", tt.want, err)
+			}
+			if _, err := tm.ParseAccessToken(pair.AccessToken, "billing"); !errors.Is(err, ErrInvalidAudience) {
+				t.Errorf("ParseAccessToken(billing) error = %v, want %v", err, ErrInvalidAudience)
+			}
+		})
+	}
+
+	if got := tm.Audiences(); !slices.Contains(got, testAudience) || !slices.Contains(got, "mobile") {
+		t.Errorf("Audiences() = %v", got)
+	}
+}
+
+func TestAudiencesSkipsUnsetDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []TokenOption
+		wantErr error
+	}{
+		{name: "listed audience", opts: []TokenOption{WithAudience("mobile")}},
+		{name: "no audience claim", wantErr: ErrInvalidAudience},
+	}
+
+	tm, err := NewTokenManager(TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		Audiences:     []string{"mobile"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tm.Audiences(); !slices.Equal(got, []string{"mobile"}) {
+		t.Fatalf("Audiences() = %q, want [mobile]", got)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := tm.GenerateTokenPair("user-1", "", "", nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tm.ParseAccessToken(pair.AccessToken, tm.Audiences()...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTokenManagerRequiresKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TokenConfig
+	}{
+		{name: "no refresh secret", cfg: TokenConfig{AccessSecret: "a"}},
+		{name: "no access secret", cfg: TokenConfig{RefreshSecret: "r"}},
+		{name: "no private key", cfg: TokenConfig{Algorithm: "RS256", RefreshSecret: "r"}},
+		{name: "private key of another type", cfg: TokenConfig{Algorithm: "RS256", RefreshSecret: "r", PrivateKeyPEM: testPrivateKeyPEM(t, AlgES256)}},
+		{name: "unsupported algorithm", cfg: TokenConfig{Algorithm: "none", AccessSecret: "a", RefreshSecret: "r"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTokenManager(tt.cfg, nil); err == nil {
+				t.Fatal("NewTokenManager() succeeded")
+			}
+		})
+	}
+}
+
+func tokenHeader(t *testing.T, token string) map[string]any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Header
+}
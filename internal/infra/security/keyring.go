@@ -0,0 +1,422 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
+)
+
+// minReloadInterval throttles store reloads triggered by unknown kids so a
+// flood of forged tokens cannot hammer the database.
+const minReloadInterval = 10 * time.Second
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeyInfo is the public description of a key in the ring.
+type KeyInfo struct {
+	ID          string     `json:"kid"`
+	Purpose     string     `json:"purpose"`
+	Algorithm   string     `json:"alg"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	VerifyUntil *time.Time `json:"verify_until,omitempty"`
+}
+
+type ringEntry struct {
+	key         *SigningKey
+	createdAt   time.Time
+	verifyUntil *time.Time
+	configured  bool
+}
+
+// Keyring holds the active signing key for one purpose plus retired keys that
+// still verify tokens issued before the last rotation. When a store is
+// configured the ring is shared between replicas through the database.
+type Keyring struct {
+	mu         sync.RWMutex
+	purpose    string
+	algorithm  string
+	retention  time.Duration
+	store      signingkey.Repository
+	cipher     *Cipher
+	logger     *logrus.Logger
+	active     string
+	keys       map[string]*ringEntry
+	lastReload time.Time
+}
+
+func newKeyring(purpose string, seed *SigningKey, retention time.Duration, store signingkey.Repository, cipher *Cipher, logger *logrus.Logger) (*Keyring, error) {
+	k := &Keyring{
+		purpose:   purpose,
+		algorithm: seed.Algorithm,
+		retention: retention,
+		store:     store,
+		cipher:    cipher,
+		logger:    logger,
+		keys:      make(map[string]*ringEntry),
+	}
+
+	if store == nil {
+		assignKeyID(seed)
+		k.keys[seed.ID] = &ringEntry{key: seed, createdAt: time.Now().UTC()}
+		k.active = seed.ID
+		return k, nil
+	}
+
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	if k.needsImport(seed) {
+		// first boot, or the configured key changed since it was last
+		// imported: make it active so the new setting takes effect
+		if k.active != "" {
+			k.logger.WithField("purpose", purpose).Warn("configured signing key changed, activating it")
+		}
+		if err := k.activate(seed, true); err != nil {
+			if !errors.Is(err, signingkey.ErrActiveKeyChanged) {
+				return nil, err
+			}
+			// another replica imported it first
+			if err := k.Reload(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return k, nil
+}
+
+// needsImport reports whether seed differs from the key last imported from
+// configuration. A rotated-away configured key is not imported again.
+func (k *Keyring) needsImport(seed *SigningKey) bool {
+	if k.active == "" {
+		return true
+	}
+	var last *ringEntry
+	for _, entry := range k.keys {
+		if entry.configured && (last == nil || entry.createdAt.After(last.createdAt)) {
+			last = entry
+		}
+	}
+	if last != nil && k.sameKey(last.key, seed) {
+		return false
+	}
+	if last == nil {
+		// stores written before keys were marked: accept the seed anywhere
+		// in the ring
+		for _, entry := range k.keys {
+			if k.sameKey(entry.key, seed) {
+				return false
+			}
+		}
+	}
+	if _, ok := k.keys[seed.ID]; ok && !seed.IsSymmetric() {
+		// the kid is the thumbprint, so an earlier key cannot be stored twice
+		k.logger.WithField("purpose", k.purpose).Warn("configured signing key is an earlier key still in the store, rotate instead")
+		return false
+	}
+	return true
+}
+
+// sameKey compares key material, not ids: HMAC keys get random ids.
+func (k *Keyring) sameKey(a, b *SigningKey) bool {
+	if a.Algorithm != b.Algorithm {
+		return false
+	}
+	ra, err := rawMaterial(a)
+	if err != nil {
+		return false
+	}
+	rb, err := rawMaterial(b)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(ra, rb) == 1
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[k.active].key
+}
+
+// ActiveSince reports when the active key was created.
+func (k *Keyring) ActiveSince() time.Time {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[k.active].createdAt
+}
+
+// Lookup returns the verification key for kid. Retired keys are returned until
+// their verification window has passed.
+func (k *Keyring) Lookup(kid string) (*SigningKey, error) {
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+
+	// another replica may have rotated since our last sync
+	k.mu.RLock()
+	canReload := k.store != nil && time.Since(k.lastReload) > minReloadInterval
+	k.mu.RUnlock()
+	if canReload {
+		if err := k.Reload(); err != nil {
+			k.logger.WithError(err).Warn("failed to reload signing keys")
+		}
+		if key, ok := k.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (k *Keyring) lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	entry, ok := k.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	if entry.verifyUntil != nil && time.Now().After(*entry.verifyUntil) {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// Rotate generates a fresh key with the ring's algorithm, makes it active and
+// demotes the previous key to verify-only.
+func (k *Keyring) Rotate() (*SigningKey, error) {
+	key, err := GenerateSigningKey(k.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.activate(key, false); err != nil {
+		return nil, err
+	}
+	k.logger.WithFields(logrus.Fields{"purpose": k.purpose, "kid": key.ID}).Info("signing key rotated")
+	return key, nil
+}
+
+func (k *Keyring) activate(key *SigningKey, configured bool) error {
+	assignKeyID(key)
+	now := time.Now().UTC()
+	verifyUntil := now.Add(k.retention)
+
+	if k.store != nil {
+		material, err := k.encodeMaterial(key)
+		if err != nil {
+			return err
+		}
+		record := &signingkey.Key{
+			ID:         key.ID,
+			Purpose:    k.purpose,
+			Algorithm:  key.Algorithm,
+			Material:   material,
+			Configured: configured,
+			CreatedAt:  now,
+		}
+		k.mu.RLock()
+		replaces := k.active
+		k.mu.RUnlock()
+		if err := k.store.Activate(record, replaces, verifyUntil); err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if prev, ok := k.keys[k.active]; ok {
+		prev.verifyUntil = &verifyUntil
+	}
+	k.keys[key.ID] = &ringEntry{key: key, createdAt: now, configured: configured}
+	k.active = key.ID
+	return nil
+}
+
+// Reload replaces the in-memory ring with the keys in the store.
+func (k *Keyring) Reload() error {
+	if k.store == nil {
+		return nil
+	}
+	records, err := k.store.ListByPurpose(k.purpose)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*ringEntry, len(records))
+	active := ""
+	for _, rec := range records {
+		key, err := k.decodeMaterial(rec)
+		if err != nil {
+			k.logger.WithError(err).WithField("kid", rec.ID).Error("skipping unreadable signing key")
+			continue
+		}
+		keys[rec.ID] = &ringEntry{key: key, createdAt: rec.CreatedAt, verifyUntil: rec.VerifyUntil, configured: rec.Configured}
+		// records are newest first; the newest active one wins
+		if rec.Active && active == "" {
+			active = rec.ID
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastReload = time.Now()
+	if active == "" {
+		// keep signing with what we have rather than failing closed
+		return nil
+	}
+	k.keys = keys
+	k.active = active
+	return nil
+}
+
+// Prune drops retired keys whose verification window has passed.
+func (k *Keyring) Prune() error {
+	now := time.Now().UTC()
+	k.mu.Lock()
+	for kid, entry := range k.keys {
+		if kid != k.active && entry.verifyUntil != nil && now.After(*entry.verifyUntil) {
+			delete(k.keys, kid)
+		}
+	}
+	k.mu.Unlock()
+
+	if k.store != nil {
+		return k.store.DeleteExpired(now)
+	}
+	return nil
+}
+
+// Info lists the keys in the ring, newest first.
+func (k *Keyring) Info() []KeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	infos := make([]KeyInfo, 0, len(k.keys))
+	for kid, entry := range k.keys {
+		infos = append(infos, KeyInfo{
+			ID:          kid,
+			Purpose:     k.purpose,
+			Algorithm:   entry.key.Algorithm,
+			Active:      kid == k.active,
+			CreatedAt:   entry.createdAt,
+			VerifyUntil: entry.verifyUntil,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.After(infos[j].CreatedAt) })
+	return infos
+}
+
+// verificationKeys returns every key that can still verify a token.
+func (k *Keyring) verificationKeys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	keys := make([]*SigningKey, 0, len(k.keys))
+	for _, entry := range k.keys {
+		if entry.verifyUntil != nil && now.After(*entry.verifyUntil) {
+			continue
+		}
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+func (k *Keyring) encodeMaterial(key *SigningKey) ([]byte, error) {
+	raw, err := rawMaterial(key)
+	if err != nil {
+		return nil, err
+	}
+	if k.cipher == nil {
+		return raw, nil
+	}
+	return k.cipher.Encrypt(raw)
+}
+
+// rawMaterial returns the HMAC secret or the PKCS#8 PEM private key.
+func rawMaterial(key *SigningKey) ([]byte, error) {
+	if key.IsSymmetric() {
+		return key.signKey.([]byte), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.signKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *Keyring) decodeMaterial(rec *signingkey.Key) (*SigningKey, error) {
+	raw := rec.Material
+	if k.cipher != nil {
+		var err error
+		if raw, err = k.cipher.Decrypt(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		key *SigningKey
+		err error
+	)
+	if rec.Algorithm == AlgHS256 {
+		key, err = NewHMACKey(raw)
+	} else {
+		key, err = ParsePrivateKeyPEM(rec.Algorithm, raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.ID = rec.ID
+	return key, nil
+}
+
+// assignKeyID keeps the RFC 7638 thumbprint as kid for asymmetric keys. HMAC
+// keys get a random id: deriving it from the secret would leak a hash of it.
+func assignKeyID(key *SigningKey) {
+	if key.IsSymmetric() || key.ID == "" {
+		key.ID = uuid.NewString()
+	}
+}
+
+// GenerateSigningKey creates new random key material for alg.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(secret)
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsymmetricKey(alg, priv)
+	case AlgES256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsymmetricKey(alg, priv)
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsymmetricKey(alg, priv)
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
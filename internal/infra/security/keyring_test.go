@@ -0,0 +1,319 @@
+package security
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/signingkey"
+)
+
+func TestRotationKeepsOldTokensVerifiable(t *testing.T) {
+	tm := newTestTokenManager(t, AlgES256)
+	before, err := tm.GenerateTokenPair("user-1", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKid := tm.accessKeys.Active().ID
+
+	if err := tm.RotateKeys(""); err != nil {
+		t.Fatalf("RotateKeys() error = %v", err)
+	}
+	after, err := tm.GenerateTokenPair("user-1", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenHeader(t, after.AccessToken)["kid"]; kid == oldKid {
+		t.Fatal("token signed after rotation still uses the old kid")
+	}
+	for name, token := range map[string]string{"before": before.AccessToken, "after": after.AccessToken} {
+		if _, err := tm.ParseAccessToken(token); err != nil {
+			t.Errorf("ParseAccessToken(%s rotation) error = %v", name, err)
+		}
+	}
+	if _, err := tm.ParseRefreshToken(before.RefreshToken); err != nil {
+		t.Errorf("ParseRefreshToken(before rotation) error = %v", err)
+	}
+	if n := len(tm.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS() has %d keys, want the active and the retired one", n)
+	}
+
+	// once its window passes the retired key is gone
+	past := time.Now().Add(-time.Second)
+	tm.accessKeys.keys[oldKid].verifyUntil = &past
+	if _, err := tm.ParseAccessToken(before.AccessToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseAccessToken() after the window error = %v, want %v", err, ErrUnknownKey)
+	}
+	if err := tm.accessKeys.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tm.JWKS().Keys); n != 1 {
+		t.Errorf("JWKS() after Prune has %d keys, want 1", n)
+	}
+}
+
+func TestKeyringSharesKeysThroughTheStore(t *testing.T) {
+	store := newMemoryKeyStore()
+	seed := mustSigningKey(t, AlgES256)
+
+	a := newTestKeyring(t, store, copyKey(seed))
+	b := newTestKeyring(t, store, copyKey(seed))
+	if n := len(store.list()); n != 1 {
+		t.Fatalf("store has %d keys after two boots with one configured key, want 1", n)
+	}
+	if a.Active().ID != b.Active().ID || a.Active().ID != seed.ID {
+		t.Fatalf("replicas sign with %s and %s, want %s", a.Active().ID, b.Active().ID, seed.ID)
+	}
+
+	rotated, err := a.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	// b still believes the seed is active: its rotation loses the race
+	if _, err := b.Rotate(); !errors.Is(err, signingkey.ErrActiveKeyChanged) {
+		t.Fatalf("stale Rotate() error = %v, want %v", err, signingkey.ErrActiveKeyChanged)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Active().ID != rotated.ID {
+		t.Fatalf("after Reload b signs with %s, want %s", b.Active().ID, rotated.ID)
+	}
+	if _, err := b.Lookup(seed.ID); err != nil {
+		t.Errorf("Lookup(retired seed) error = %v", err)
+	}
+}
+
+func TestConcurrentRotationsProduceOneKey(t *testing.T) {
+	store := newMemoryKeyStore()
+	seed := mustSigningKey(t, AlgHS256)
+	const replicas = 8
+	rings := make([]*Keyring, replicas)
+	for i := range rings {
+		rings[i] = newTestKeyring(t, store, copyKey(seed))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, replicas)
+	for i, ring := range rings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = ring.Rotate()
+		}()
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, signingkey.ErrActiveKeyChanged):
+			t.Fatalf("Rotate() error = %v", err)
+		}
+	}
+	active := 0
+	for _, k := range store.list() {
+		if k.Active {
+			active++
+		}
+	}
+	if won != 1 || active != 1 || len(store.list()) != 2 {
+		t.Fatalf("%d rotations won, %d keys active, %d stored; want 1, 1, 2", won, active, len(store.list()))
+	}
+
+	// rotateNow recovers from a lost race by reloading
+	for _, ring := range rings {
+		if err := rotateNow(ring); err != nil {
+			t.Fatalf("rotateNow() error = %v", err)
+		}
+	}
+}
+
+func TestKeyringImportsChangedConfiguredKey(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  string
+	}{
+		{"HMAC", AlgHS256},
+		{"ES256", AlgES256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryKeyStore()
+			first := mustSigningKey(t, tt.alg)
+			second := mustSigningKey(t, tt.alg)
+
+			ring := newTestKeyring(t, store, copyKey(first))
+			firstID := ring.Active().ID
+
+			// same setting: nothing to import
+			ring = newTestKeyring(t, store, copyKey(first))
+			if ring.Active().ID != firstID || len(store.list()) != 1 {
+				t.Fatalf("reboot with the same key changed the ring: active %s, %d stored", ring.Active().ID, len(store.list()))
+			}
+
+			// changed setting: the new key takes over, the old one verifies
+			ring = newTestKeyring(t, store, copyKey(second))
+			if !ring.sameKey(ring.Active(), second) {
+				t.Fatal("changed configured key was not activated")
+			}
+			if _, err := ring.Lookup(firstID); err != nil {
+				t.Errorf("Lookup(previous configured key) error = %v", err)
+			}
+
+			// a rotated-away configured key is not imported again, even
+			// after its window passed and the store was pruned
+			rotated, err := ring.Rotate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.expireRetired()
+			if err := ring.Prune(); err != nil {
+				t.Fatal(err)
+			}
+			ring = newTestKeyring(t, store, copyKey(second))
+			if ring.Active().ID != rotated.ID {
+				t.Fatalf("reboot re-imported the rotated-away configured key")
+			}
+		})
+	}
+}
+
+func TestKeyringIgnoresEarlierConfiguredKey(t *testing.T) {
+	store := newMemoryKeyStore()
+	first := mustSigningKey(t, AlgES256)
+	newTestKeyring(t, store, copyKey(first))
+	second := newTestKeyring(t, store, copyKey(mustSigningKey(t, AlgES256))).Active().ID
+
+	// rolling back the setting while the earlier key is still stored
+	ring := newTestKeyring(t, store, copyKey(first))
+	if ring.Active().ID != second {
+		t.Fatalf("active = %s, want %s kept", ring.Active().ID, second)
+	}
+}
+
+func newTestKeyring(t *testing.T, store signingkey.Repository, seed *SigningKey) *Keyring {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ring, err := newKeyring(signingkey.PurposeAccess, seed, time.Hour, store, nil, logger)
+	if err != nil {
+		t.Fatalf("newKeyring() error = %v", err)
+	}
+	return ring
+}
+
+func mustSigningKey(t *testing.T, alg string) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignKeyID(key)
+	return key
+}
+
+// copyKey returns the key as a fresh boot would load it from configuration.
+func copyKey(k *SigningKey) *SigningKey {
+	c := *k
+	if c.IsSymmetric() {
+		c.ID = ""
+	}
+	return &c
+}
+
+// memoryKeyStore implements signingkey.Repository the way the database does:
+// activations are serialized and checked against the key they replace.
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []*signingkey.Key
+}
+
+func newMemoryKeyStore() *memoryKeyStore {
+	return &memoryKeyStore{}
+}
+
+func (s *memoryKeyStore) ListByPurpose(purpose string) ([]*signingkey.Key, error) {
+	var out []*signingkey.Key
+	for _, k := range s.list() {
+		if k.Purpose == purpose {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryKeyStore) Activate(k *signingkey.Key, replaces string, verifyUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current []string
+	for _, stored := range s.keys {
+		if stored.Purpose == k.Purpose && stored.Active {
+			current = append(current, stored.ID)
+		}
+	}
+	if (replaces == "" && len(current) > 0) || (replaces != "" && !slices.Contains(current, replaces)) {
+		return signingkey.ErrActiveKeyChanged
+	}
+	now := time.Now().UTC()
+	for _, stored := range s.keys {
+		if stored.Purpose == k.Purpose && stored.Active {
+			stored.Active = false
+			stored.RetiredAt = &now
+			stored.VerifyUntil = &verifyUntil
+		}
+	}
+	stored := *k
+	stored.Active = true
+	s.keys = append(s.keys, &stored)
+	return nil
+}
+
+func (s *memoryKeyStore) DeleteExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	newestConfigured := map[string]*signingkey.Key{}
+	for _, k := range s.keys {
+		if n, ok := newestConfigured[k.Purpose]; k.Configured && (!ok || k.CreatedAt.After(n.CreatedAt)) {
+			newestConfigured[k.Purpose] = k
+		}
+	}
+	s.keys = slices.DeleteFunc(s.keys, func(k *signingkey.Key) bool {
+		return !k.Active && k.VerifyUntil != nil && k.VerifyUntil.Before(now) && newestConfigured[k.Purpose] != k
+	})
+	return nil
+}
+
+// list returns copies of the stored keys, newest first.
+func (s *memoryKeyStore) list() []*signingkey.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*signingkey.Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		c := *k
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// expireRetired ends the verification window of every retired key.
+func (s *memoryKeyStore) expireRetired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	past := time.Now().Add(-time.Second)
+	for _, k := range s.keys {
+		if !k.Active {
+			k.VerifyUntil = &past
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+// SigningKey wraps the key material used to sign and verify tokens for one algorithm.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey returns an HS256 signing key for the given shared secret.
+func NewHMACKey(secret []byte) (*SigningKey, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("hmac secret must not be empty")
+	}
+	return &SigningKey{
+		Algorithm: AlgHS256,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}, nil
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1)
+// and returns a SigningKey for the given asymmetric algorithm.
+func ParsePrivateKeyPEM(alg string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+
+	var (
+		priv any
+		err  error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	return NewAsymmetricKey(alg, priv)
+}
+
+// NewAsymmetricKey builds a SigningKey from an already parsed private key.
+func NewAsymmetricKey(alg string, priv any) (*SigningKey, error) {
+	key := &SigningKey{Algorithm: alg, signKey: priv}
+
+	switch alg {
+	case AlgRS256:
+		k, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 requires an RSA private key")
+		}
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		key.method = jwt.SigningMethodRS256
+		key.verifyKey = &k.PublicKey
+	case AlgES256:
+		k, ok := priv.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 ECDSA private key")
+		}
+		key.method = jwt.SigningMethodES256
+		key.verifyKey = &k.PublicKey
+	case AlgEdDSA:
+		k, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		key.method = jwt.SigningMethodEdDSA
+		key.verifyKey = k.Public()
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	jwk, err := PublicJWK(key.verifyKey)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = thumbprint
+	return key, nil
+}
+
+// IsSymmetric reports whether the key is a shared secret that must never be published.
+func (k *SigningKey) IsSymmetric() bool {
+	return k.Algorithm == AlgHS256
+}
+
+// PublicKey returns the verification key. For HMAC keys this is the shared secret.
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.verifyKey
+}
+
+// NormalizeAlgorithm maps user supplied algorithm names onto the supported set.
+func NormalizeAlgorithm(alg string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(alg)) {
+	case "", "HS256":
+		return AlgHS256, nil
+	case "RS256":
+		return AlgRS256, nil
+	case "ES256":
+		return AlgES256, nil
+	case "EDDSA", "ED25519":
+		return AlgEdDSA, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+}
@@ -0,0 +1,61 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+var (
+	ErrPasswordMismatch    = errors.New("password does not match")
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes new passwords with one configured algorithm and
+// verifies hashes made by any supported one, so stored hashes keep working
+// after the algorithm changes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Compare returns nil when password matches hash and
+	// ErrPasswordMismatch when it does not.
+	Compare(hash, password string) error
+	// NeedsRehash reports whether hash was made with another algorithm or
+	// other parameters than Hash would use now.
+	NeedsRehash(hash string) bool
+}
+
+// PasswordHashConfig selects the algorithm and cost of new hashes.
+type PasswordHashConfig struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+func NewPasswordHasher(cfg PasswordHashConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case PasswordHashBcrypt:
+		return NewBcryptHasher(cfg.BcryptCost), nil
+	case PasswordHashArgon2id, "":
+		return NewArgon2idHasher(cfg.Argon2), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+func comparePasswordHash(hash, password string) error {
+	if hash == "" || password == "" {
+		return fmt.Errorf("hash and password must be provided")
+	}
+	switch {
+	case isArgon2idHash(hash):
+		return compareArgon2id(hash, password)
+	case isBcryptHash(hash):
+		return compareBcrypt(hash, password)
+	default:
+		return ErrUnknownPasswordHash
+	}
+}
@@ -0,0 +1,167 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// referenceArgon2id is the output of the argon2 reference implementation for
+// `echo -n password | argon2 somesalt -id -t 2 -m 16 -p 1`.
+const referenceArgon2id = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+// testArgon2Params keep the tests fast.
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestComparePasswordHash(t *testing.T) {
+	bcryptHash, err := NewBcryptHasher(4).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := NewArgon2idHasher(testArgon2Params).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		wantErr  error
+	}{
+		{name: "argon2 reference vector", hash: referenceArgon2id, password: "password"},
+		{name: "argon2 reference vector, wrong password", hash: referenceArgon2id, password: "Password", wantErr: ErrPasswordMismatch},
+		{name: "argon2id", hash: argonHash, password: "password"},
+		{name: "argon2id, wrong password", hash: argonHash, password: "passwore", wantErr: ErrPasswordMismatch},
+		{name: "bcrypt", hash: bcryptHash, password: "password"},
+		{name: "bcrypt, wrong password", hash: bcryptHash, password: "passwore", wantErr: ErrPasswordMismatch},
+		{name: "argon2i is not accepted", hash: strings.Replace(referenceArgon2id, "argon2id", "argon2i", 1), password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "argon2 version 0x10", hash: strings.Replace(referenceArgon2id, "v=19", "v=16", 1), password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "missing version", hash: "$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "bad parameters", hash: strings.Replace(referenceArgon2id, "m=65536,t=2,p=1", "m=x,t=2,p=1", 1), password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "parallelism overflow", hash: strings.Replace(referenceArgon2id, "p=1", "p=256", 1), password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "padded salt", hash: strings.Replace(referenceArgon2id, "c29tZXNhbHQ", "c29tZXNhbHQ=", 1), password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "empty key", hash: "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$", password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "truncated", hash: "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ", password: "password", wantErr: ErrUnknownPasswordHash},
+		{name: "plain text", hash: "password", password: "password", wantErr: ErrUnknownPasswordHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := comparePasswordHash(tt.hash, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("comparePasswordHash() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComparePasswordHashRequiresBoth(t *testing.T) {
+	if err := comparePasswordHash("", "password"); err == nil {
+		t.Error("comparePasswordHash() with no hash succeeded")
+	}
+	if err := comparePasswordHash(referenceArgon2id, ""); err == nil {
+		t.Error("comparePasswordHash() with no password succeeded")
+	}
+}
+
+func TestParseArgon2id(t *testing.T) {
+	p, salt, key, err := parseArgon2id(referenceArgon2id)
+	if err != nil {
+		t.Fatalf("parseArgon2id() error = %v", err)
+	}
+	if want := (Argon2Params{Memory: 65536, Iterations: 2, Parallelism: 1}); p != want {
+		t.Errorf("params = %+v, want %+v", p, want)
+	}
+	if string(salt) != "somesalt" || len(key) != argon2KeyBytes {
+		t.Errorf("salt = %q, key length = %d", salt, len(key))
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := NewArgon2idHasher(testArgon2Params)
+	argonHash, err := argon.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := NewBcryptHasher(4).Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{name: "argon2id with the same parameters", hasher: argon, hash: argonHash, want: false},
+		{name: "argon2id with more memory configured", hasher: NewArgon2idHasher(Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1}), hash: argonHash, want: true},
+		{name: "argon2id with more passes configured", hasher: NewArgon2idHasher(Argon2Params{Memory: 64, Iterations: 2, Parallelism: 1}), hash: argonHash, want: true},
+		{name: "bcrypt to argon2id", hasher: argon, hash: bcryptHash, want: true},
+		{name: "unparseable hash", hasher: argon, hash: "$argon2id$garbage", want: true},
+		{name: "bcrypt with the same cost", hasher: NewBcryptHasher(4), hash: bcryptHash, want: false},
+		{name: "bcrypt with a higher cost configured", hasher: NewBcryptHasher(5), hash: bcryptHash, want: true},
+		{name: "argon2id to bcrypt", hasher: NewBcryptHasher(4), hash: argonHash, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		wantHash  string
+		wantErr   bool
+	}{
+		{algorithm: "", wantHash: "$argon2id$"},
+		{algorithm: PasswordHashArgon2id, wantHash: "$argon2id$"},
+		{algorithm: PasswordHashBcrypt, wantHash: "$2a$04$"},
+		{algorithm: "md5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			h, err := NewPasswordHasher(PasswordHashConfig{Algorithm: tt.algorithm, BcryptCost: 4, Argon2: testArgon2Params})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewPasswordHasher() succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash, err := h.Hash("password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tt.wantHash) {
+				t.Errorf("Hash() = %q, want prefix %q", hash, tt.wantHash)
+			}
+			if err := h.Compare(hash, "password"); err != nil {
+				t.Errorf("Compare() error = %v", err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("NeedsRehash() of a fresh hash = true")
+			}
+		})
+	}
+}
+
+func TestArgon2idHashIsSalted(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params)
+	a, _ := h.Hash("password")
+	b, _ := h.Hash("password")
+	if a == b {
+		t.Fatal("two hashes of the same password are equal")
+	}
+	if _, err := h.Hash(""); err == nil {
+		t.Fatal("Hash() of an empty password succeeded")
+	}
+}
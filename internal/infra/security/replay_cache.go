@@ -0,0 +1,48 @@
+package security
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers one-time identifiers (e.g. DPoP proof jti) until they
+// expire.
+type ReplayCache interface {
+	// Remember stores key until expiresAt and reports false when the key was
+	// already present, i.e. the value is being replayed.
+	Remember(key string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryReplayCache is an in-process ReplayCache. Each replica only sees its
+// own traffic, so it is only suitable for single-replica deployments and
+// tests; replay.Service shares spent values between replicas.
+type MemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{entries: make(map[string]time.Time)}
+}
+
+func (m *MemoryReplayCache) Remember(key string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, exp := range m.entries {
+			if now.After(exp) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	if exp, ok := m.entries[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.entries[key] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,97 @@
+package security
+
+import "time"
+
+// TokenOption customises the tokens produced by GenerateTokenPair.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	idToken   *IDTokenClaims
+	sessionID string
+	actor     *Actor
+	accessTTL time.Duration
+	jkt       string
+	audience  string
+	scope     string
+	clientID  string
+}
+
+// IDTokenClaims carries the OpenID Connect claims that are not already part of
+// the access token subject.
+type IDTokenClaims struct {
+	// Audience is the client the ID token is issued to.
+	Audience      string
+	Name          string
+	EmailVerified bool
+	Nonce         string
+	// AuthTime is when the user actually authenticated, which stays fixed
+	// across refreshes.
+	AuthTime time.Time
+}
+
+// WithIDToken additionally issues an OIDC ID token next to the token pair.
+func WithIDToken(claims IDTokenClaims) TokenOption {
+	return func(o *tokenOptions) {
+		o.idToken = &claims
+	}
+}
+
+// WithSessionID reuses an existing session id instead of starting a new
+// session, which is what a refresh must do.
+func WithSessionID(sid string) TokenOption {
+	return func(o *tokenOptions) {
+		o.sessionID = sid
+	}
+}
+
+// WithActor records the admin acting on behalf of the subject in an "act" claim.
+func WithActor(actor Actor) TokenOption {
+	return func(o *tokenOptions) {
+		o.actor = &actor
+	}
+}
+
+// WithAccessTTL overrides the access token lifetime for GenerateAccessToken.
+func WithAccessTTL(ttl time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.accessTTL = ttl
+	}
+}
+
+// WithConfirmation binds the access token to a DPoP key via cnf.jkt.
+func WithConfirmation(jkt string) TokenOption {
+	return func(o *tokenOptions) {
+		o.jkt = jkt
+	}
+}
+
+// WithAudience issues the access token for aud instead of the default
+// audience. aud must be one of the configured audiences.
+func WithAudience(aud string) TokenOption {
+	return func(o *tokenOptions) {
+		o.audience = aud
+	}
+}
+
+// WithScope sets the access token's space separated "scope" claim.
+func WithScope(scope string) TokenOption {
+	return func(o *tokenOptions) {
+		o.scope = scope
+	}
+}
+
+// WithClientID records the OAuth client the token was issued to in a
+// "client_id" claim (RFC 9068).
+func WithClientID(clientID string) TokenOption {
+	return func(o *tokenOptions) {
+		o.clientID = clientID
+	}
+}
+
+func applyTokenOptions(opts []TokenOption) *tokenOptions {
+	o := &tokenOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,66 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 appendix B vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// the RFC lists 8 digit codes; ours are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step := TOTPStep(time.Unix(tt.unix, 0))
+		if got := TOTPCode(rfc6238Secret, step); got != tt.want {
+			t.Errorf("TOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", TOTPCode(rfc6238Secret, current), current, true},
+		{"previous step within skew", TOTPCode(rfc6238Secret, current-1), current - 1, true},
+		{"next step within skew", TOTPCode(rfc6238Secret, current+1), current + 1, true},
+		{"two steps old", TOTPCode(rfc6238Secret, current-2), 0, false},
+		{"two steps ahead", TOTPCode(rfc6238Secret, current+2), 0, false},
+		{"too short", "12345", 0, false},
+		{"too long", "1234567", 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP() = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("Acme Auth", "jane@example.com", rfc6238Secret)
+	want := "otpauth://totp/Acme%20Auth:jane@example.com?algorithm=SHA1&digits=6&issuer=Acme%20Auth&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	if got != want {
+		t.Errorf("TOTPURI() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxCBORDepth bounds nesting so a hostile attestation cannot exhaust the stack.
+const maxCBORDepth = 16
+
+var errCBOR = errors.New("webauthn: malformed CBOR")
+
+// decodeCBOR decodes the first CBOR item in data and returns it together with
+// the bytes that follow it. Only the subset WebAuthn uses is supported:
+// integers, byte and text strings, arrays, maps and simple values. Integers
+// decode to int64, maps to map[interface{}]interface{} with int64 or string
+// keys.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, nil, errCBOR
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		default:
+			// floats and indefinite lengths never appear in WebAuthn structures
+			return nil, nil, errCBOR
+		}
+	}
+
+	arg, data, err := decodeArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		b := data[:arg]
+		if major == 3 {
+			return string(b), data[arg:], nil
+		}
+		return append([]byte(nil), b...), data[arg:], nil
+	case 4:
+		// every element takes at least one byte
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var v interface{}
+			if v, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, v)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, errCBOR
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var k, v interface{}
+			if k, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if _, dup := m[k]; dup {
+				return nil, nil, errCBOR
+			}
+			if v, data, err = decodeItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[k] = v
+		}
+		return m, data, nil
+	default:
+		// tags (major type 6) are not used by WebAuthn
+		return nil, nil, errCBOR
+	}
+}
+
+func decodeArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errCBOR
+	}
+}
@@ -0,0 +1,195 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Examples from RFC 8949 appendix A that fall in the supported subset.
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte(nil)},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"62c3bc", "ü"},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			got, rest, err := decodeCBOR(mustHex(t, tt.hex))
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("decodeCBOR() left %x", rest)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORReturnsTrailingBytes(t *testing.T) {
+	got, rest, err := decodeCBOR(mustHex(t, "0102"))
+	if err != nil || got != int64(1) || !reflect.DeepEqual(rest, []byte{2}) {
+		t.Fatalf("decodeCBOR() = %v, %x, %v, want 1, 02, nil", got, rest, err)
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"empty input", ""},
+		{"half float", "f93c00"},
+		{"double", "fb3ff199999999999a"},
+		{"undefined simple value", "f0"},
+		{"indefinite byte string", "5f42010243030405ff"},
+		{"indefinite array", "9f018202039f0405ffff"},
+		{"tag", "c11a514b67b0"},
+		{"unsigned beyond int64", "1bffffffffffffffff"},
+		{"negative beyond int64", "3bffffffffffffffff"},
+		{"reserved additional info", "1c"},
+		{"truncated argument", "19e8"},
+		{"truncated byte string", "44010203"},
+		{"truncated text string", "6449455446"[:8]},
+		{"array longer than input", "830102"},
+		{"map longer than input", "a301020304"},
+		{"map missing value", "a10102"[:4]},
+		{"byte string map key", "a14101f5"},
+		{"array map key", "a18001"},
+		{"duplicate map key", "a201020103"},
+		{"duplicate text map key", "a2616101616102"},
+		{"nested too deep", strings.Repeat("81", maxCBORDepth+1) + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, _, err := decodeCBOR(mustHex(t, tt.hex)); !errors.Is(err, errCBOR) {
+				t.Fatalf("decodeCBOR() = %#v, %v, want %v", v, err, errCBOR)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORDepthLimit(t *testing.T) {
+	nested := strings.Repeat("81", maxCBORDepth) + "00"
+	if _, _, err := decodeCBOR(mustHex(t, nested)); err != nil {
+		t.Fatalf("decodeCBOR() at the depth limit error = %v", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encodeCBOR is a minimal encoder for building test fixtures. Map keys are
+// written in a fixed order so fixtures are deterministic.
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		keys := make([][]byte, 0, len(v))
+		for k := range v {
+			keys = append(keys, encodeCBOR(k))
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) })
+		out := cborHead(5, uint64(len(v)))
+		for _, k := range keys {
+			key, _, _ := decodeCBOR(k)
+			out = append(out, k...)
+			out = append(out, encodeCBOR(v[key])...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	default:
+		panic("encodeCBOR: unsupported type")
+	}
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	v := map[interface{}]interface{}{
+		"fmt":     "none",
+		int64(-2): []byte{1, 2, 3},
+		int64(3):  int64(-257),
+		"list":    []interface{}{int64(70000), "x", true, nil},
+	}
+	got, rest, err := decodeCBOR(encodeCBOR(v))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR() error = %v, rest %x", err, rest)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("round trip = %#v, want %#v", got, v)
+	}
+}
@@ -0,0 +1,111 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers we accept, in order of preference.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+
+	// minRSABits rejects keys too weak to trust as a login factor.
+	minRSABits = 2048
+)
+
+var (
+	ErrUnsupportedKey = errors.New("webauthn: unsupported credential public key")
+	ErrBadSignature   = errors.New("webauthn: signature verification failed")
+)
+
+// publicKey is a parsed COSE_Key (RFC 9052 section 7).
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+func parsePublicKey(cose []byte) (*publicKey, error) {
+	v, rest, err := decodeCBOR(cose)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrUnsupportedKey
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: pub}, nil
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		exp := new(big.Int).SetBytes(e)
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if pub.N.BitLen() < minRSABits || pub.E < 3 {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg: alg, key: pub}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+func (p *publicKey) verify(data, sig []byte) error {
+	switch key := p.key.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(data)
+		if ecdsa.VerifyASN1(key, sum[:], sig) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
@@ -0,0 +1,166 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ec2 := func(edit func(m map[interface{}]interface{})) []byte {
+		m := coseEC2(&ecKey.PublicKey)
+		if edit != nil {
+			edit(m)
+		}
+		return encodeCBOR(m)
+	}
+
+	tests := []struct {
+		name    string
+		cose    []byte
+		wantAlg int64
+		wantErr bool
+	}{
+		{name: "ES256", cose: ec2(nil), wantAlg: AlgES256},
+		{name: "EdDSA", cose: encodeCBOR(coseOKP(edPub)), wantAlg: AlgEdDSA},
+		{name: "RS256", cose: encodeCBOR(coseRSA(&rsaKey.PublicKey)), wantAlg: AlgRS256},
+		{name: "ES256 on another curve", cose: ec2(func(m map[interface{}]interface{}) { m[int64(-1)] = int64(2) }), wantErr: true},
+		{name: "ES256 point off the curve", cose: ec2(func(m map[interface{}]interface{}) { m[int64(-3)] = make([]byte, 32) }), wantErr: true},
+		{name: "ES256 short coordinate", cose: ec2(func(m map[interface{}]interface{}) { m[int64(-2)] = make([]byte, 31) }), wantErr: true},
+		{name: "ES256 missing y", cose: ec2(func(m map[interface{}]interface{}) { delete(m, int64(-3)) }), wantErr: true},
+		{name: "EC2 key with another algorithm", cose: ec2(func(m map[interface{}]interface{}) { m[int64(3)] = int64(-35) }), wantErr: true},
+		{name: "EC2 key labelled EdDSA", cose: ec2(func(m map[interface{}]interface{}) { m[int64(3)] = AlgEdDSA }), wantErr: true},
+		{name: "RSA below 2048 bits", cose: encodeCBOR(coseRSA(&weakRSA.PublicKey)), wantErr: true},
+		{name: "RSA exponent 1", cose: encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeRSA), int64(3): AlgRS256,
+			int64(-1): rsaKey.PublicKey.N.Bytes(), int64(-2): []byte{1},
+		}), wantErr: true},
+		{name: "RSA oversized exponent", cose: encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeRSA), int64(3): AlgRS256,
+			int64(-1): rsaKey.PublicKey.N.Bytes(), int64(-2): []byte{1, 0, 0, 0, 1},
+		}), wantErr: true},
+		{name: "Ed448", cose: encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeOKP), int64(3): AlgEdDSA, int64(-1): int64(7), int64(-2): make([]byte, 57),
+		}), wantErr: true},
+		{name: "not a map", cose: encodeCBOR([]interface{}{int64(2)}), wantErr: true},
+		{name: "trailing bytes", cose: append(ec2(nil), 0x00), wantErr: true},
+		{name: "malformed CBOR", cose: []byte{0xa5, 0x01}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePublicKey(tt.cose)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsupportedKey) {
+					t.Fatalf("parsePublicKey() error = %v, want %v", err, ErrUnsupportedKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePublicKey() error = %v", err)
+			}
+			if key.alg != tt.wantAlg {
+				t.Errorf("alg = %d, want %d", key.alg, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestPublicKeyVerify(t *testing.T) {
+	data := []byte("authenticator data || client data hash")
+	sum := sha256.Sum256(data)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cose map[interface{}]interface{}
+		sig  []byte
+	}{
+		{"ES256", coseEC2(&ecKey.PublicKey), ecSig},
+		{"EdDSA", coseOKP(edPub), ed25519.Sign(edPriv, data)},
+		{"RS256", coseRSA(&rsaKey.PublicKey), rsaSig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parsePublicKey(encodeCBOR(tt.cose))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := key.verify(data, tt.sig); err != nil {
+				t.Errorf("verify() error = %v", err)
+			}
+			if err := key.verify([]byte("other data"), tt.sig); !errors.Is(err, ErrBadSignature) {
+				t.Errorf("verify() of other data error = %v, want %v", err, ErrBadSignature)
+			}
+			tampered := append([]byte(nil), tt.sig...)
+			tampered[len(tampered)-1] ^= 0x01
+			if err := key.verify(data, tampered); !errors.Is(err, ErrBadSignature) {
+				t.Errorf("verify() of a tampered signature error = %v, want %v", err, ErrBadSignature)
+			}
+		})
+	}
+}
+
+func coseEC2(pub *ecdsa.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(1):  int64(coseKeyTypeEC2),
+		int64(3):  AlgES256,
+		int64(-1): int64(coseCurveP256),
+		int64(-2): pub.X.FillBytes(make([]byte, 32)),
+		int64(-3): pub.Y.FillBytes(make([]byte, 32)),
+	}
+}
+
+func coseOKP(pub ed25519.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(1):  int64(coseKeyTypeOKP),
+		int64(3):  AlgEdDSA,
+		int64(-1): int64(coseCurveEd25519),
+		int64(-2): []byte(pub),
+	}
+}
+
+func coseRSA(pub *rsa.PublicKey) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		int64(1):  int64(coseKeyTypeRSA),
+		int64(3):  AlgRS256,
+		int64(-1): pub.N.Bytes(),
+		int64(-2): big.NewInt(int64(pub.E)).Bytes(),
+	}
+}
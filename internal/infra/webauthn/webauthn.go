@@ -0,0 +1,410 @@
+// Package webauthn implements the relying party side of the WebAuthn Level 2
+// registration and authentication ceremonies for passkeys.
+//
+// Attestation is requested as "none" and attestation statements are not
+// verified: we trust the key the user registers while signed in, not the
+// make of their authenticator.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	challengeSize = 32
+
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagBackupEligible   = 0x08
+	flagBackupState      = 0x10
+	flagAttestedCredData = 0x40
+
+	// authDataMinLen is rpIdHash(32) + flags(1) + signCount(4).
+	authDataMinLen = 37
+	// maxCredentialIDLen is the limit from the WebAuthn spec.
+	maxCredentialIDLen = 1023
+)
+
+var (
+	ErrInvalidResponse = errors.New("webauthn: malformed authenticator response")
+	ErrChallenge       = errors.New("webauthn: challenge mismatch")
+	ErrOrigin          = errors.New("webauthn: origin not allowed")
+	ErrRPID            = errors.New("webauthn: relying party id mismatch")
+	ErrUserNotVerified = errors.New("webauthn: user verification required")
+	// ErrSignCount means the authenticator's counter went backwards, which is
+	// how a cloned authenticator shows up.
+	ErrSignCount = errors.New("webauthn: signature counter did not increase")
+)
+
+// Config describes this relying party.
+type Config struct {
+	// RPID is the registrable domain credentials are scoped to.
+	RPID   string
+	RPName string
+	// Origins are the exact origins (scheme://host[:port]) allowed to run
+	// ceremonies, e.g. the web app.
+	Origins []string
+	Timeout time.Duration
+}
+
+// RelyingParty builds ceremony options and verifies authenticator responses.
+type RelyingParty struct {
+	cfg      Config
+	rpIDHash [32]byte
+}
+
+func New(cfg Config) *RelyingParty {
+	return &RelyingParty{cfg: cfg, rpIDHash: sha256.Sum256([]byte(cfg.RPID))}
+}
+
+// URLEncodedBytes is binary data carried as unpadded base64url in JSON, the
+// encoding browsers use for WebAuthn buffers.
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return ErrInvalidResponse
+	}
+	*b = decoded
+	return nil
+}
+
+// User is the account a credential is created for.
+type User struct {
+	// ID is the opaque user handle stored on the authenticator.
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies an existing credential.
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	ID         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptions for
+// navigator.credentials.create().
+type CreationOptions struct {
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	Challenge              URLEncodedBytes        `json:"challenge"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptions for
+// navigator.credentials.get().
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON form of the PublicKeyCredential returned
+// by navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of the PublicKeyCredential returned by
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// CredentialID returns rawId, falling back to the base64url id for clients
+// that only send the latter.
+func (r *AssertionResponse) CredentialID() []byte {
+	if len(r.RawID) > 0 {
+		return r.RawID
+	}
+	id, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(r.ID, "="))
+	if err != nil {
+		return nil
+	}
+	return id
+}
+
+// Credential is a verified new credential, ready to be stored.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE_Key exactly as the authenticator sent it.
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// Assertion is the verified result of an authentication ceremony.
+type Assertion struct {
+	SignCount uint32
+	BackedUp  bool
+}
+
+// NewChallenge returns a fresh random ceremony challenge.
+func NewChallenge() ([]byte, error) {
+	c := make([]byte, challengeSize)
+	if _, err := rand.Read(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreationOptions asks for a discoverable, user-verified credential so it can
+// later be used for usernameless sign-in. exclude lists the user's existing
+// credentials so the same authenticator is not registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, u User, exclude []CredentialDescriptor) *CreationOptions {
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		RP:        rpEntity{ID: rp.cfg.RPID, Name: rp.cfg.RPName},
+		User:      userEntity{ID: u.ID, Name: u.Name, DisplayName: u.DisplayName},
+		Challenge: challenge,
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            rp.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions starts a usernameless sign-in: no credentials are listed,
+// the authenticator offers whatever passkeys it holds for this RP.
+func (rp *RelyingParty) RequestOptions(challenge []byte) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.cfg.Timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: "required",
+	}
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it (WebAuthn section 7.1).
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, resp *RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrInvalidResponse
+	}
+	att, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+	rawAuthData, ok := att["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	ad, err := rp.parseAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttestedCredData == 0 || len(ad.credentialID) == 0 {
+		return nil, ErrInvalidResponse
+	}
+	if len(resp.RawID) > 0 && !bytes.Equal(resp.RawID, ad.credentialID) {
+		return nil, ErrInvalidResponse
+	}
+	if _, err := parsePublicKey(ad.publicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             ad.credentialID,
+		PublicKey:      ad.publicKey,
+		SignCount:      ad.signCount,
+		AAGUID:         ad.aaguid,
+		Transports:     resp.Response.Transports,
+		BackupEligible: ad.flags&flagBackupEligible != 0,
+		BackedUp:       ad.flags&flagBackupState != 0,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response against the challenge and
+// the stored credential (WebAuthn section 7.2). storedCount is the last sign
+// count seen for the credential.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, resp *AssertionResponse, publicKeyCOSE []byte, storedCount uint32) (*Assertion, error) {
+	if resp.Type != "public-key" {
+		return nil, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return nil, err
+	}
+
+	ad, err := rp.parseAuthData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(publicKeyCOSE)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := make([]byte, 0, len(resp.Response.AuthenticatorData)+len(clientDataHash))
+	signed = append(signed, resp.Response.AuthenticatorData...)
+	signed = append(signed, clientDataHash[:]...)
+	if err := key.verify(signed, resp.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// authenticators that do not count always report zero
+	if (ad.signCount != 0 || storedCount != 0) && ad.signCount <= storedCount {
+		return nil, ErrSignCount
+	}
+	return &Assertion{SignCount: ad.signCount, BackedUp: ad.flags&flagBackupState != 0}, nil
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return ErrInvalidResponse
+	}
+	if cd.Type != ceremony {
+		return ErrInvalidResponse
+	}
+	got, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrChallenge
+	}
+	if cd.CrossOrigin || !slices.Contains(rp.cfg.Origins, cd.Origin) {
+		return ErrOrigin
+	}
+	return nil
+}
+
+type authData struct {
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (rp *RelyingParty) parseAuthData(raw []byte) (*authData, error) {
+	if len(raw) < authDataMinLen {
+		return nil, ErrInvalidResponse
+	}
+	if subtle.ConstantTimeCompare(raw[:32], rp.rpIDHash[:]) != 1 {
+		return nil, ErrRPID
+	}
+	ad := &authData{
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if ad.flags&flagUserPresent == 0 || ad.flags&flagUserVerified == 0 {
+		return nil, ErrUserNotVerified
+	}
+
+	if ad.flags&flagAttestedCredData != 0 {
+		rest := raw[authDataMinLen:]
+		if len(rest) < 18 {
+			return nil, ErrInvalidResponse
+		}
+		ad.aaguid = append([]byte(nil), rest[:16]...)
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLen || idLen > len(rest) {
+			return nil, ErrInvalidResponse
+		}
+		ad.credentialID = append([]byte(nil), rest[:idLen]...)
+		rest = rest[idLen:]
+
+		// the key is followed only by extensions, which we do not request
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrInvalidResponse
+		}
+		ad.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	}
+	return ad, nil
+}
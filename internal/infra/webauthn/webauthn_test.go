@@ -0,0 +1,355 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+var testAAGUID = []byte("0123456789abcdef")
+
+func newTestRP() *RelyingParty {
+	return New(Config{RPID: testRPID, RPName: "Example", Origins: []string{testOrigin}})
+}
+
+// authenticator is a software passkey producing the responses a browser
+// would relay.
+type authenticator struct {
+	t      *testing.T
+	key    *ecdsa.PrivateKey
+	credID []byte
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{t: t, key: key, credID: []byte("credential-1")}
+}
+
+func (a *authenticator) publicKeyCOSE() []byte {
+	return encodeCBOR(coseEC2(&a.key.PublicKey))
+}
+
+func (a *authenticator) authData(rpID string, flags byte, count uint32, attested bool) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	out := append(hash[:], flags)
+	out = binary.BigEndian.AppendUint32(out, count)
+	if attested {
+		out = append(out, testAAGUID...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.publicKeyCOSE()...)
+	}
+	return out
+}
+
+func clientDataJSON(t *testing.T, typ string, challenge []byte, origin string, crossOrigin bool) []byte {
+	t.Helper()
+	raw, err := json.Marshal(clientData{
+		Type:        typ,
+		Challenge:   base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:      origin,
+		CrossOrigin: crossOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func (a *authenticator) register(challenge []byte) *RegistrationResponse {
+	flags := byte(flagUserPresent | flagUserVerified | flagAttestedCredData | flagBackupEligible)
+	resp := &RegistrationResponse{ID: base64.RawURLEncoding.EncodeToString(a.credID), RawID: a.credID, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientDataJSON(a.t, "webauthn.create", challenge, testOrigin, false)
+	resp.Response.AttestationObject = attestation(a.authData(testRPID, flags, 0, true))
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+func (a *authenticator) assert(challenge []byte, count uint32) *AssertionResponse {
+	resp := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.credID), RawID: a.credID, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientDataJSON(a.t, "webauthn.get", challenge, testOrigin, false)
+	resp.Response.AuthenticatorData = a.authData(testRPID, flagUserPresent|flagUserVerified|flagBackupState, count, false)
+	a.sign(resp)
+	return resp
+}
+
+// sign (re)computes the assertion signature after a test edited the response.
+func (a *authenticator) sign(resp *AssertionResponse) {
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	sum := sha256.Sum256(append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, sum[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	resp.Response.Signature = sig
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	challenge := []byte("registration-challenge-32-bytes!")
+	a := newAuthenticator(t)
+
+	tests := []struct {
+		name    string
+		edit    func(*RegistrationResponse)
+		wantErr error
+	}{
+		{name: "valid none attestation"},
+		{name: "wrong credential type", edit: func(r *RegistrationResponse) { r.Type = "password" }, wantErr: ErrInvalidResponse},
+		{
+			name: "assertion client data",
+			edit: func(r *RegistrationResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.get", challenge, testOrigin, false)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "other challenge",
+			edit: func(r *RegistrationResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.create", []byte("stale"), testOrigin, false)
+			},
+			wantErr: ErrChallenge,
+		},
+		{
+			name: "foreign origin",
+			edit: func(r *RegistrationResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.create", challenge, "https://evil.example.net", false)
+			},
+			wantErr: ErrOrigin,
+		},
+		{
+			name: "cross-origin iframe",
+			edit: func(r *RegistrationResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.create", challenge, testOrigin, true)
+			},
+			wantErr: ErrOrigin,
+		},
+		{
+			name: "credential scoped to another RP",
+			edit: func(r *RegistrationResponse) {
+				r.Response.AttestationObject = attestation(a.authData("evil.example.net", flagUserPresent|flagUserVerified|flagAttestedCredData, 0, true))
+			},
+			wantErr: ErrRPID,
+		},
+		{
+			name: "user not verified",
+			edit: func(r *RegistrationResponse) {
+				r.Response.AttestationObject = attestation(a.authData(testRPID, flagUserPresent|flagAttestedCredData, 0, true))
+			},
+			wantErr: ErrUserNotVerified,
+		},
+		{
+			name: "no attested credential",
+			edit: func(r *RegistrationResponse) {
+				r.Response.AttestationObject = attestation(a.authData(testRPID, flagUserPresent|flagUserVerified, 0, false))
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{name: "rawId differs from the attested id", edit: func(r *RegistrationResponse) { r.RawID = []byte("other") }, wantErr: ErrInvalidResponse},
+		{
+			name: "truncated authenticator data",
+			edit: func(r *RegistrationResponse) {
+				ad := a.authData(testRPID, flagUserPresent|flagUserVerified|flagAttestedCredData, 0, true)
+				r.Response.AttestationObject = attestation(ad[:authDataMinLen+20])
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "attestation object without authData",
+			edit: func(r *RegistrationResponse) {
+				r.Response.AttestationObject = encodeCBOR(map[interface{}]interface{}{"fmt": "none"})
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name: "trailing bytes after the attestation object",
+			edit: func(r *RegistrationResponse) {
+				r.Response.AttestationObject = append(r.Response.AttestationObject, 0x00)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := a.register(challenge)
+			if tt.edit != nil {
+				tt.edit(resp)
+			}
+			cred, err := newTestRP().VerifyRegistration(challenge, resp)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyRegistration() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyRegistration() error = %v", err)
+			}
+			if string(cred.ID) != string(a.credID) {
+				t.Errorf("credential id = %q, want %q", cred.ID, a.credID)
+			}
+			if string(cred.PublicKey) != string(a.publicKeyCOSE()) {
+				t.Error("stored public key differs from the attested COSE key")
+			}
+			if string(cred.AAGUID) != string(testAAGUID) {
+				t.Errorf("aaguid = %x, want %x", cred.AAGUID, testAAGUID)
+			}
+			if !cred.BackupEligible || cred.BackedUp {
+				t.Errorf("backup flags = %v/%v, want eligible and not backed up", cred.BackupEligible, cred.BackedUp)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	challenge := []byte("assertion-challenge-of-32-bytes!")
+	a := newAuthenticator(t)
+	other := newAuthenticator(t)
+
+	tests := []struct {
+		name        string
+		count       uint32
+		storedCount uint32
+		edit        func(*AssertionResponse)
+		key         []byte
+		wantErr     error
+	}{
+		{name: "valid", count: 5, storedCount: 4},
+		{name: "authenticator without a counter", count: 0, storedCount: 0},
+		{name: "counter did not move", count: 4, storedCount: 4, wantErr: ErrSignCount},
+		{name: "counter went backwards", count: 3, storedCount: 4, wantErr: ErrSignCount},
+		{name: "counter reset to zero", count: 0, storedCount: 4, wantErr: ErrSignCount},
+		{name: "signed by another key", count: 1, key: other.publicKeyCOSE(), wantErr: ErrBadSignature},
+		{
+			name:  "authenticator data changed after signing",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				binary.BigEndian.PutUint32(r.Response.AuthenticatorData[33:], 99)
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name:  "registration client data",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.create", challenge, testOrigin, false)
+				a.sign(r)
+			},
+			wantErr: ErrInvalidResponse,
+		},
+		{
+			name:  "replayed challenge",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.get", []byte("an-earlier-challenge"), testOrigin, false)
+				a.sign(r)
+			},
+			wantErr: ErrChallenge,
+		},
+		{
+			name:  "phishing origin",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.ClientDataJSON = clientDataJSON(t, "webauthn.get", challenge, "https://example.com.evil.net", false)
+				a.sign(r)
+			},
+			wantErr: ErrOrigin,
+		},
+		{
+			name:  "signed for another RP",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.AuthenticatorData = a.authData("evil.example.net", flagUserPresent|flagUserVerified, 1, false)
+				a.sign(r)
+			},
+			wantErr: ErrRPID,
+		},
+		{
+			name:  "presence without verification",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.AuthenticatorData = a.authData(testRPID, flagUserPresent, 1, false)
+				a.sign(r)
+			},
+			wantErr: ErrUserNotVerified,
+		},
+		{
+			name:  "short authenticator data",
+			count: 1,
+			edit: func(r *AssertionResponse) {
+				r.Response.AuthenticatorData = r.Response.AuthenticatorData[:authDataMinLen-1]
+			},
+			wantErr: ErrInvalidResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := a.assert(challenge, tt.count)
+			if tt.edit != nil {
+				tt.edit(resp)
+			}
+			key := tt.key
+			if key == nil {
+				key = a.publicKeyCOSE()
+			}
+			got, err := newTestRP().VerifyAssertion(challenge, resp, key, tt.storedCount)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion() error = %v", err)
+			}
+			if got.SignCount != tt.count || !got.BackedUp {
+				t.Errorf("VerifyAssertion() = %+v, want count %d and backed up", got, tt.count)
+			}
+		})
+	}
+}
+
+func TestAssertionResponseCredentialID(t *testing.T) {
+	resp := &AssertionResponse{ID: "Y3JlZA"}
+	if got := string(resp.CredentialID()); got != "cred" {
+		t.Errorf("CredentialID() from id = %q, want %q", got, "cred")
+	}
+	resp.RawID = []byte("raw")
+	if got := string(resp.CredentialID()); got != "raw" {
+		t.Errorf("CredentialID() = %q, want rawId %q", got, "raw")
+	}
+}
+
+func TestURLEncodedBytesAcceptsPadding(t *testing.T) {
+	var b URLEncodedBytes
+	if err := json.Unmarshal([]byte(`"Y3JlZA=="`), &b); err != nil || string(b) != "cred" {
+		t.Fatalf("Unmarshal() = %q, %v, want %q", b, err, "cred")
+	}
+	if err := json.Unmarshal([]byte(`"not base64!"`), &b); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("Unmarshal() of garbage error = %v, want %v", err, ErrInvalidResponse)
+	}
+}
+
+func attestation(authData []byte) []byte {
+	return encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+}
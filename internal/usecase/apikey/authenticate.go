@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// AuthenticateUseCase turns an API key into the claims the auth middleware
+// works with. Keys are checked against the database on every request, so a
+// revoked key stops working immediately.
+type AuthenticateUseCase interface {
+	Execute(ctx context.Context, rawKey, ip string) (*security.ClaimsPayload, error)
+}
+
+type authenticateUseCase struct {
+	apiKeyService apikey.Service
+	userService   user.Service
+}
+
+func NewAuthenticateUseCase(apiKeyService apikey.Service, userService user.Service) AuthenticateUseCase {
+	return &authenticateUseCase{
+		apiKeyService: apiKeyService,
+		userService:   userService,
+	}
+}
+
+func (uc *authenticateUseCase) Execute(ctx context.Context, rawKey, ip string) (*security.ClaimsPayload, error) {
+	k, err := uc.apiKeyService.Authenticate(rawKey, ip)
+	if err != nil {
+		return nil, err
+	}
+	u, err := uc.userService.Get(k.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, apikey.ErrInvalidKey
+		}
+		return nil, err
+	}
+
+	claims := &security.ClaimsPayload{
+		UserID:   u.ID,
+		Email:    u.Email,
+		Username: u.Username,
+		Roles:    []string{string(keyRole(k, u))},
+		Scope:    k.Scopes,
+		APIKeyID: k.ID,
+	}
+	if k.ExpiresAt != nil {
+		claims.Expiry = *k.ExpiresAt
+	}
+	return claims, nil
+}
+
+// keyRole is the role a key acts with: an admin's key is an ordinary user
+// key unless it was created with the admin scope.
+func keyRole(k *apikey.APIKey, u *user.User) user.Role {
+	if u.Role == user.RoleAdmin && !slices.Contains(k.ScopeList(), apikey.ScopeAdmin) {
+		return user.RoleUser
+	}
+	return u.Role
+}
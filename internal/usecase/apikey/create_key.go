@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// CreateKeyUseCase issues a new API key to a user. The key itself is in the
+// result and is never shown again. tokenRoles are the roles of the access
+// token the request came with.
+type CreateKeyUseCase interface {
+	Execute(ctx context.Context, userID string, tokenRoles []string, req *apikey.CreateAPIKeyRequest, ip, userAgent string) (*apikey.CreatedAPIKey, error)
+}
+
+type createKeyUseCase struct {
+	apiKeyService  apikey.Service
+	userService    user.Service
+	securityEvents securityevent.Service
+}
+
+func NewCreateKeyUseCase(apiKeyService apikey.Service, userService user.Service, securityEvents securityevent.Service) CreateKeyUseCase {
+	return &createKeyUseCase{
+		apiKeyService:  apiKeyService,
+		userService:    userService,
+		securityEvents: securityEvents,
+	}
+}
+
+func (uc *createKeyUseCase) Execute(ctx context.Context, userID string, tokenRoles []string, req *apikey.CreateAPIKeyRequest, ip, userAgent string) (*apikey.CreatedAPIKey, error) {
+	if slices.Contains(req.Scopes, apikey.ScopeAdmin) {
+		// the token must carry the role too: an admin's account reached
+		// through a token without it cannot mint admin keys
+		if !slices.Contains(tokenRoles, string(user.RoleAdmin)) {
+			return nil, fmt.Errorf("%w: %s requires an admin session", apikey.ErrInvalidScope, apikey.ScopeAdmin)
+		}
+		u, err := uc.userService.Get(userID)
+		if err != nil {
+			return nil, err
+		}
+		if u.Role != user.RoleAdmin {
+			return nil, fmt.Errorf("%w: %s requires the admin role", apikey.ErrInvalidScope, apikey.ScopeAdmin)
+		}
+	}
+
+	created, err := uc.apiKeyService.Create(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeAPIKeyCreated,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   created.ID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record api key creation")
+	}
+	return created, nil
+}
@@ -0,0 +1,24 @@
+package apikey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+)
+
+// ListKeysUseCase returns a user's unrevoked API keys with their last use.
+type ListKeysUseCase interface {
+	Execute(ctx context.Context, userID string) ([]apikey.APIKey, error)
+}
+
+type listKeysUseCase struct {
+	apiKeyService apikey.Service
+}
+
+func NewListKeysUseCase(apiKeyService apikey.Service) ListKeysUseCase {
+	return &listKeysUseCase{apiKeyService: apiKeyService}
+}
+
+func (uc *listKeysUseCase) Execute(ctx context.Context, userID string) ([]apikey.APIKey, error) {
+	return uc.apiKeyService.ListByUser(userID)
+}
@@ -0,0 +1,44 @@
+package apikey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/apikey"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// RevokeKeyUseCase revokes one of the user's own API keys. It stops working
+// on the next request.
+type RevokeKeyUseCase interface {
+	Execute(ctx context.Context, userID, keyID, ip, userAgent string) error
+}
+
+type revokeKeyUseCase struct {
+	apiKeyService  apikey.Service
+	securityEvents securityevent.Service
+}
+
+func NewRevokeKeyUseCase(apiKeyService apikey.Service, securityEvents securityevent.Service) RevokeKeyUseCase {
+	return &revokeKeyUseCase{
+		apiKeyService:  apiKeyService,
+		securityEvents: securityEvents,
+	}
+}
+
+func (uc *revokeKeyUseCase) Execute(ctx context.Context, userID, keyID, ip, userAgent string) error {
+	if err := uc.apiKeyService.Revoke(userID, keyID); err != nil {
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeAPIKeyRevoked,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   keyID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record api key revocation")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// CompleteRecoveryUseCase resets credentials from a recovery session: a new
+// password and, on request, no authenticator app. Every session of the user,
+// the recovery session included, is revoked afterwards.
+type CompleteRecoveryUseCase interface {
+	Execute(ctx context.Context, userID string, req *auth.CompleteRecoveryRequest) error
+}
+
+type completeRecoveryUseCase struct {
+	userService    user.Service
+	mfaService     mfa.Service
+	sessionService session.Service
+	securityEvents securityevent.Service
+	lockouts       lockout.Service
+}
+
+func NewCompleteRecoveryUseCase(userService user.Service, mfaService mfa.Service, sessionService session.Service, securityEvents securityevent.Service, lockouts lockout.Service) CompleteRecoveryUseCase {
+	return &completeRecoveryUseCase{
+		userService:    userService,
+		mfaService:     mfaService,
+		sessionService: sessionService,
+		securityEvents: securityEvents,
+		lockouts:       lockouts,
+	}
+}
+
+func (uc *completeRecoveryUseCase) Execute(ctx context.Context, userID string, req *auth.CompleteRecoveryRequest) error {
+	account, err := uc.userService.Get(userID)
+	if err != nil {
+		return err
+	}
+	if err := uc.userService.CheckPassword(req.Password, account); err != nil {
+		return err
+	}
+
+	hash, err := uc.userService.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+	updated, err := uc.userService.Update(userID, &user.User{PasswordHash: hash})
+	if err != nil {
+		return err
+	}
+	// a new password ends any lockout the old one was under
+	if err := uc.lockouts.Reset(updated.Email); err != nil {
+		logger.Log.WithError(err).Error("failed to reset login failures")
+	}
+
+	details := "password reset"
+	if req.ResetMFA {
+		if err := uc.mfaService.RemoveTOTP(userID); err != nil && !errors.Is(err, mfa.ErrNotEnrolled) {
+			return err
+		}
+		details += ", authenticator app removed"
+	}
+
+	revoked, err := uc.sessionService.InvalidateUserSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeAccountRecovered,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Details:   details + ", sessions revoked: " + strconv.FormatInt(revoked, 10),
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record account recovery")
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+)
+
+// ConsumeMagicLinkUseCase redeems a sign-in link and returns its user. The
+// link proves control of the mailbox, so the address counts as verified.
+type ConsumeMagicLinkUseCase interface {
+	Execute(ctx context.Context, req *auth.ConsumeMagicLinkRequest) (*user.User, error)
+}
+
+type consumeMagicLinkUseCase struct {
+	verifications verification.Service
+	userService   user.Service
+	authService   auth.Service
+}
+
+func NewConsumeMagicLinkUseCase(verifications verification.Service, userService user.Service, authService auth.Service) ConsumeMagicLinkUseCase {
+	return &consumeMagicLinkUseCase{
+		verifications: verifications,
+		userService:   userService,
+		authService:   authService,
+	}
+}
+
+func (uc *consumeMagicLinkUseCase) Execute(ctx context.Context, req *auth.ConsumeMagicLinkRequest) (*user.User, error) {
+	token, err := uc.verifications.ConsumeBound(req.Token, verification.PurposeMagicLink, req.Binding)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := uc.userService.Get(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !u.EmailVerified {
+		if u, err = uc.userService.MarkEmailVerified(u.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := uc.authService.CheckLoginAllowed(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+)
+
+// decoyUserID owns the tokens issued for unknown addresses. No account has
+// it, so its tokens cannot be redeemed, and they are never mailed anyway.
+const decoyUserID = "00000000-0000-0000-0000-000000000000"
+
+// issueDecoy does the database work of a throttle check and a token issue
+// for an address without an account, so the response takes as long as for
+// a real one. The count runs on a fresh id so the decoy is never throttled.
+func issueDecoy(verifications verification.Service, purpose string, ttl time.Duration, binding string) error {
+	if _, err := verifications.IssuedSince(uuid.NewString(), purpose, time.Now().UTC().Add(-verification.ThrottleWindow)); err != nil {
+		return err
+	}
+	_, err := verifications.IssueBound(decoyUserID, purpose, ttl, binding)
+	return err
+}
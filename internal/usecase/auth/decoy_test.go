@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+func TestUnknownAddressesAnswerLikeKnownOnes(t *testing.T) {
+	logger.Init()
+
+	tests := []struct {
+		name    string
+		purpose string
+		request func(f *fixture, email string) (string, error)
+	}{
+		{name: "forgot password", purpose: verification.PurposePasswordReset, request: func(f *fixture, email string) (string, error) {
+			uc := NewForgotPasswordUseCase(f.userService, f.verifications, f.mailer, testAppURL, time.Hour)
+			return "", uc.Execute(context.Background(), &auth.ForgotPasswordRequest{Email: email})
+		}},
+		{name: "magic link", purpose: verification.PurposeMagicLink, request: func(f *fixture, email string) (string, error) {
+			uc := NewRequestMagicLinkUseCase(f.userService, f.verifications, f.mailer, testAppURL, time.Hour, true)
+			return uc.Execute(context.Background(), &auth.MagicLinkRequest{Email: email})
+		}},
+		{name: "resend verification", purpose: verification.PurposeEmailVerification, request: func(f *fixture, email string) (string, error) {
+			send := NewSendVerificationUseCase(f.verifications, f.mailer, testAppURL, time.Hour)
+			uc := NewResendVerificationUseCase(f.userService, f.verifications, send, time.Hour)
+			return "", uc.Execute(context.Background(), &auth.ResendVerificationRequest{Email: email})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			known, knownErr := tt.request(f, "jane@example.com")
+			unknown, unknownErr := tt.request(f, "nobody@example.com")
+			if knownErr != nil || unknownErr != nil {
+				t.Fatalf("errors = %v, %v, want none", knownErr, unknownErr)
+			}
+			if (known == "") != (unknown == "") {
+				t.Fatalf("bindings %q and %q tell the addresses apart", known, unknown)
+			}
+			if _, ok := f.mailer.Last("nobody@example.com"); ok || len(f.mailer.Messages()) != 1 {
+				t.Fatalf("mailed %d messages, want only the one to the account", len(f.mailer.Messages()))
+			}
+
+			// the unknown address costs a token write too, and is never
+			// throttled however often it is asked for
+			for i := 0; i < maxResetRequests+maxMagicLinkRequests; i++ {
+				if _, err := tt.request(f, "nobody@example.com"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			decoys, err := f.tokens.CountIssuedSince(decoyUserID, tt.purpose, time.Now().Add(-time.Minute))
+			if err != nil || decoys != int64(1+maxResetRequests+maxMagicLinkRequests) {
+				t.Fatalf("issued %d decoy tokens, %v", decoys, err)
+			}
+		})
+	}
+}
+
+func TestResendVerificationSilentBranchesDoDecoyWork(t *testing.T) {
+	logger.Init()
+
+	tests := []struct {
+		name     string
+		verified bool
+		requests int
+		// wantMails is how many requests reach the account owner
+		wantMails int
+	}{
+		{name: "already verified", verified: true, requests: 2},
+		{name: "within the cooldown", requests: 2, wantMails: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.users.byID["user-1"].EmailVerified = tt.verified
+			send := NewSendVerificationUseCase(f.verifications, f.mailer, testAppURL, time.Hour)
+			uc := NewResendVerificationUseCase(f.userService, f.verifications, send, time.Hour)
+			for i := 0; i < tt.requests; i++ {
+				if err := uc.Execute(context.Background(), &auth.ResendVerificationRequest{Email: "jane@example.com"}); err != nil {
+					t.Fatalf("request %d: Execute() error = %v", i+1, err)
+				}
+			}
+			if got := len(f.mailer.Messages()); got != tt.wantMails {
+				t.Fatalf("mailed %d messages, want %d", got, tt.wantMails)
+			}
+			decoys, err := f.tokens.CountIssuedSince(decoyUserID, verification.PurposeEmailVerification, time.Now().Add(-time.Minute))
+			if err != nil || decoys != int64(tt.requests-tt.wantMails) {
+				t.Fatalf("issued %d decoy tokens, %v; want %d", decoys, err, tt.requests-tt.wantMails)
+			}
+		})
+	}
+}
+
+func TestRegisterTakenEmail(t *testing.T) {
+	logger.Init()
+
+	tests := []struct {
+		name         string
+		hideAccounts bool
+		wantErr      error
+		wantNotice   bool
+	}{
+		{name: "hidden", hideAccounts: true, wantNotice: true},
+		{name: "revealed", wantErr: user.ErrEmailTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			uc := NewRegisterUseCase(emailTaken{}, f.userService, nil, f.mailer, testAppURL, tt.hideAccounts)
+			u, err := uc.Execute(context.Background(), &auth.RegisterRequest{
+				Username: "jane2", Password: "another-password", Fullname: "Jane", Email: "JANE@example.com",
+			})
+			if !errors.Is(err, tt.wantErr) || u != nil {
+				t.Fatalf("Execute() = %v, %v, want no user and %v", u, err, tt.wantErr)
+			}
+			if _, ok := f.mailer.Last("jane@example.com"); ok != tt.wantNotice {
+				t.Fatalf("owner notified = %v, want %v", ok, tt.wantNotice)
+			}
+		})
+	}
+}
+
+type emailTaken struct {
+	auth.Service
+}
+
+func (emailTaken) RegisterUser(*auth.RegisterRequest) (*user.User, error) {
+	return nil, user.ErrEmailTaken
+}
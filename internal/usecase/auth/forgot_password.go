@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
+)
+
+// maxResetRequests caps how many reset emails one address receives per
+// resetRequestWindow.
+const (
+	maxResetRequests   = 3
+	resetRequestWindow = verification.ThrottleWindow
+)
+
+// ForgotPasswordUseCase mails a password reset link. Unknown addresses and
+// throttled requests succeed silently so callers cannot tell them apart.
+type ForgotPasswordUseCase interface {
+	Execute(ctx context.Context, req *auth.ForgotPasswordRequest) error
+}
+
+type forgotPasswordUseCase struct {
+	userService   user.Service
+	verifications verification.Service
+	mailer        mailer.Mailer
+	appBaseURL    string
+	ttl           time.Duration
+}
+
+func NewForgotPasswordUseCase(userService user.Service, verifications verification.Service, m mailer.Mailer, appBaseURL string, ttl time.Duration) ForgotPasswordUseCase {
+	return &forgotPasswordUseCase{
+		userService:   userService,
+		verifications: verifications,
+		mailer:        m,
+		appBaseURL:    appBaseURL,
+		ttl:           ttl,
+	}
+}
+
+func (uc *forgotPasswordUseCase) Execute(ctx context.Context, req *auth.ForgotPasswordRequest) error {
+	u, err := uc.userService.GetByEmail(req.Email)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return issueDecoy(uc.verifications, verification.PurposePasswordReset, uc.ttl, "")
+	}
+
+	recent, err := uc.verifications.IssuedSince(u.ID, verification.PurposePasswordReset, time.Now().UTC().Add(-resetRequestWindow))
+	if err != nil {
+		return err
+	}
+	if recent >= maxResetRequests {
+		logger.Log.WithField("user_id", u.ID).Warn("password reset requests throttled")
+		return nil
+	}
+
+	token, err := uc.verifications.Issue(u.ID, verification.PurposePasswordReset, uc.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := uc.appBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	return uc.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\nThe link can be used once and expires in %s. If this wasn't you, you can ignore this email; your password stays unchanged.\n",
+			u.Fullname, link, uc.ttl,
+		),
+	})
+}
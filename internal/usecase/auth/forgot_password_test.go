@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/password"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const testAppURL = "https://app.example.com"
+
+func TestForgotPasswordThrottles(t *testing.T) {
+	logger.Init()
+	f := newFixture(t)
+	uc := NewForgotPasswordUseCase(f.userService, f.verifications, f.mailer, testAppURL, time.Hour)
+
+	for i := 1; i <= maxResetRequests+2; i++ {
+		if err := uc.Execute(context.Background(), &auth.ForgotPasswordRequest{Email: "JANE@example.com"}); err != nil {
+			t.Fatalf("request %d: Execute() error = %v", i, err)
+		}
+	}
+	if got := len(f.mailer.Messages()); got != maxResetRequests {
+		t.Fatalf("mailed %d reset links, want %d", got, maxResetRequests)
+	}
+
+	// only the newest link works
+	first := linkToken(t, f.mailer.Messages()[0])
+	if _, err := f.verifications.Peek(first, verification.PurposePasswordReset); err == nil {
+		t.Error("an older reset link still works")
+	}
+	if _, err := f.verifications.Peek(f.lastLink(t, "jane@example.com"), verification.PurposePasswordReset); err != nil {
+		t.Errorf("the newest reset link does not work: %v", err)
+	}
+}
+
+// fixture wires the usecases of this package to in-memory stores.
+type fixture struct {
+	users         *memoryUsers
+	userService   user.Service
+	verifications verification.Service
+	tokens        *memoryVerifications
+	mailer        *mailer.MemoryMailer
+	sessions      *memorySessions
+	events        *recordedEvents
+	lockouts      *memoryLockouts
+	hasher        security.PasswordHasher
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	policy, err := password.NewPolicy(password.Rules{MinLength: 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := security.NewBcryptHasher(4)
+	hash, err := hasher.Hash("old-password-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUsers{byID: map[string]*user.User{
+		"user-1": {ID: "user-1", Username: "jane", Fullname: "Jane Doe", Email: "jane@example.com", PasswordHash: hash, Role: user.RoleUser},
+	}}
+	tokens := &memoryVerifications{tokens: map[string]*verification.Token{}}
+	return &fixture{
+		users:         users,
+		userService:   user.NewService(users, policy, hasher),
+		verifications: verification.NewService(tokens),
+		tokens:        tokens,
+		mailer:        mailer.NewMemoryMailer(),
+		sessions:      &memorySessions{},
+		events:        &recordedEvents{},
+		lockouts:      &memoryLockouts{},
+		hasher:        hasher,
+	}
+}
+
+var linkPattern = regexp.MustCompile(`token=(\S+)`)
+
+// lastLink returns the token of the newest link mailed to to.
+func (f *fixture) lastLink(t *testing.T, to string) string {
+	t.Helper()
+	msg, ok := f.mailer.Last(to)
+	if !ok {
+		t.Fatalf("nothing was mailed to %s", to)
+	}
+	return linkToken(t, msg)
+}
+
+func linkToken(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	m := linkPattern.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no link in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// memoryUsers is a user.Repository matching emails the way the database
+// does, case-insensitively.
+type memoryUsers struct {
+	user.Repository
+	byID map[string]*user.User
+}
+
+func (m *memoryUsers) GetByEmail(email string) (*user.User, error) {
+	for _, u := range m.byID {
+		if user.NormalizeEmail(u.Email) == user.NormalizeEmail(email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryUsers) Get(id string) (*user.User, error) {
+	if u, ok := m.byID[id]; ok {
+		return u, nil
+	}
+	return nil, user.ErrNotFound
+}
+
+func (m *memoryUsers) Update(id string, u *user.User) (*user.User, error) {
+	existing, ok := m.byID[id]
+	if !ok {
+		return nil, user.ErrNotFound
+	}
+	if u.PasswordHash != "" {
+		existing.PasswordHash = u.PasswordHash
+	}
+	if u.EmailVerified {
+		existing.EmailVerified, existing.EmailVerifiedAt = true, u.EmailVerifiedAt
+	}
+	return existing, nil
+}
+
+type memoryVerifications struct {
+	tokens map[string]*verification.Token
+}
+
+func (m *memoryVerifications) Create(t *verification.Token) error {
+	c := *t
+	m.tokens[t.ID] = &c
+	return nil
+}
+
+func (m *memoryVerifications) GetByHash(hash string) (*verification.Token, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash {
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryVerifications) MarkUsed(id string, now time.Time) (bool, error) {
+	t, ok := m.tokens[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &now
+	return true, nil
+}
+
+func (m *memoryVerifications) InvalidateForUser(userID, purpose string, now time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *memoryVerifications) CountIssuedSince(userID, purpose string, since time.Time) (int64, error) {
+	var n int64
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && !t.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryVerifications) DeleteExpired(now, issuedBefore time.Time) error {
+	return nil
+}
+
+type memorySessions struct {
+	session.Service
+	invalidated []string
+}
+
+func (m *memorySessions) InvalidateUserSessions(userID string) (int64, error) {
+	m.invalidated = append(m.invalidated, userID)
+	return 2, nil
+}
+
+type recordedEvents struct {
+	securityevent.Service
+	events []*securityevent.Event
+}
+
+func (r *recordedEvents) Record(e *securityevent.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+type memoryLockouts struct {
+	lockout.Service
+	reset []string
+}
+
+func (m *memoryLockouts) Reset(email string) error {
+	m.reset = append(m.reset, email)
+	return nil
+}
+
+func (m *memoryLockouts) Check(string) error {
+	return nil
+}
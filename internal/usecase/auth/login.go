@@ -2,29 +2,120 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
 	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
 	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
 )
 
+// ipFailureLimit is generous because many users can share an address
+// behind NAT. Failures are counted in the shared rate limit store, so the
+// budget holds across replicas.
+var ipFailureLimit = ratelimit.Limit{Burst: 20, Per: 15 * time.Minute}
+
 type LoginUseCase interface {
 	Execute(ctx context.Context, req *auth.LoginRequest) (*user.User, error)
 }
 
 type loginUseCase struct {
-	authService auth.Service
+	authService    auth.Service
+	userService    user.Service
+	mfaService     mfa.Service
+	lockouts       lockout.Service
+	securityEvents securityevent.Service
+	limits         ratelimit.Service
 }
 
-func NewLoginUseCase(authService auth.Service) LoginUseCase {
+// NewLoginUseCase checks passwords behind per-account lockout and a
+// per-address failure limit, so guessing is slow whichever of the two an
+// attacker varies.
+func NewLoginUseCase(authService auth.Service, userService user.Service, mfaService mfa.Service, lockouts lockout.Service, securityEvents securityevent.Service, limits ratelimit.Service) LoginUseCase {
 	return &loginUseCase{
-		authService: authService,
+		authService:    authService,
+		userService:    userService,
+		mfaService:     mfaService,
+		lockouts:       lockouts,
+		securityEvents: securityEvents,
+		limits:         limits,
 	}
 }
 
 func (uc *loginUseCase) Execute(ctx context.Context, req *auth.LoginRequest) (*user.User, error) {
+	res, err := uc.limits.Check(ipFailureKey(req.RemoteIP), ipFailureLimit)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Allowed {
+		return nil, &lockout.RetryAfterError{Err: lockout.ErrThrottled, RetryAfter: res.RetryAfter}
+	}
+	if err := uc.lockouts.Check(req.Email); err != nil {
+		return nil, err
+	}
+
 	userRecord, err := uc.authService.LoginUser(req)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		uc.recordFailure(req)
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// with a second factor the login is not over yet: VerifyMFA resets the
+	// lockout once the code checks out
+	enrolled, err := uc.mfaService.IsEnrolled(userRecord.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		if err := uc.lockouts.Reset(req.Email); err != nil {
+			logger.Log.WithError(err).Error("failed to reset login failures")
+		}
+	}
 	return userRecord, nil
 }
+
+func (uc *loginUseCase) recordFailure(req *auth.LoginRequest) {
+	if _, err := uc.limits.Allow(ipFailureKey(req.RemoteIP), ipFailureLimit); err != nil {
+		logger.Log.WithError(err).Error("failed to count login failure")
+	}
+
+	locked, err := uc.lockouts.Fail(req.Email)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to record login failure")
+		return
+	}
+	if !locked {
+		return
+	}
+
+	// unknown addresses lock too, but only real accounts get an event
+	u, err := uc.userService.GetByEmail(req.Email)
+	if err != nil || u == nil {
+		return
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"user_id": u.ID,
+		"ip":      req.RemoteIP,
+	}).Warn("account locked after repeated failed logins")
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    u.ID,
+		Type:      securityevent.TypeAccountLocked,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record account lock")
+	}
+}
+
+func ipFailureKey(ip string) string {
+	return "login-fail-ip:" + ip
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	mfausecase "mikhailjbs/user-auth-service/internal/usecase/mfa"
+)
+
+// RecoverySessionTTL is how long a recovery session may be used to reset
+// credentials.
+const RecoverySessionTTL = 15 * time.Minute
+
+// recoveryAttemptLimit allows five wrong codes per address, one more every
+// three minutes. Wrong codes for a real account also count against the
+// user's shared code budget.
+var recoveryAttemptLimit = ratelimit.Limit{Burst: 5, Per: 15 * time.Minute}
+
+// RecoveryLoginUseCase trades an email address and a recovery code for a
+// restricted session. The access token carries the account_recovery scope,
+// which the middleware refuses everywhere but the credential reset route.
+// No refresh token is issued.
+type RecoveryLoginUseCase interface {
+	Execute(ctx context.Context, req *auth.RecoveryLoginRequest) (*user.User, *security.TokenPair, error)
+}
+
+type recoveryLoginUseCase struct {
+	userService    user.Service
+	mfaService     mfa.Service
+	sessionService session.Service
+	tokenManager   *security.TokenManager
+	reportRecovery mfausecase.ReportRecoveryCodeUseCase
+	limits         ratelimit.Service
+	failures       mfausecase.CodeFailures
+}
+
+func NewRecoveryLoginUseCase(userService user.Service, mfaService mfa.Service, sessionService session.Service, tokenManager *security.TokenManager, reportRecovery mfausecase.ReportRecoveryCodeUseCase, limits ratelimit.Service) RecoveryLoginUseCase {
+	return &recoveryLoginUseCase{
+		userService:    userService,
+		mfaService:     mfaService,
+		sessionService: sessionService,
+		tokenManager:   tokenManager,
+		reportRecovery: reportRecovery,
+		limits:         limits,
+		failures:       mfausecase.NewCodeFailures(limits),
+	}
+}
+
+func (uc *recoveryLoginUseCase) Execute(ctx context.Context, req *auth.RecoveryLoginRequest) (*user.User, *security.TokenPair, error) {
+	key := recoveryAttemptKey(req.Email)
+	res, err := uc.limits.Check(key, recoveryAttemptLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !res.Allowed {
+		return nil, nil, auth.ErrTooManyAttempts
+	}
+
+	u, err := uc.userService.GetByEmail(req.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil {
+		uc.recordFailure(key)
+		return nil, nil, auth.ErrInvalidCredentials
+	}
+	if err := uc.failures.Check(u.ID); err != nil {
+		if errors.Is(err, mfa.ErrTooManyAttempts) {
+			return nil, nil, auth.ErrTooManyAttempts
+		}
+		return nil, nil, err
+	}
+
+	remaining, err := uc.mfaService.UseRecoveryCode(u.ID, req.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			uc.recordFailure(key)
+			uc.failures.Record(u.ID, err)
+			return nil, nil, auth.ErrInvalidCredentials
+		}
+		return nil, nil, err
+	}
+	uc.reportRecovery.Execute(ctx, u.ID, remaining, "account recovery sign-in", req.IPAddress, req.UserAgent)
+
+	now := time.Now().UTC()
+	sess := &session.Session{
+		ID:        uuid.NewString(),
+		UserID:    u.ID,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Valid:     true,
+		ExpiresAt: now.Add(RecoverySessionTTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := uc.sessionService.CreateSession(sess); err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := uc.tokenManager.GenerateAccessToken(
+		u.ID,
+		u.Email,
+		u.Username,
+		[]string{string(u.Role)},
+		security.WithSessionID(sess.ID),
+		security.WithScope(security.ScopeAccountRecovery),
+		security.WithAccessTTL(RecoverySessionTTL),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, pair, nil
+}
+
+func (uc *recoveryLoginUseCase) recordFailure(key string) {
+	if _, err := uc.limits.Allow(key, recoveryAttemptLimit); err != nil {
+		logger.Log.WithError(err).Error("failed to count recovery login failure")
+	}
+}
+
+func recoveryAttemptKey(email string) string {
+	return "recovery-login:" + lockout.Key(email)
+}
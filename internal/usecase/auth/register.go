@@ -2,29 +2,81 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
+
 	"mikhailjbs/user-auth-service/internal/domain/auth"
 	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
 )
 
+// RegisterUseCase signs a user up. With hideAccounts set, signing up with a
+// registered email returns no user and no error, and the owner of the
+// address is told by email instead, so the answer does not reveal which
+// emails have accounts.
 type RegisterUseCase interface {
 	Execute(ctx context.Context, req *auth.RegisterRequest) (*user.User, error)
 }
 
 type registerUseCase struct {
-	authService auth.Service
+	authService      auth.Service
+	userService      user.Service
+	sendVerification SendVerificationUseCase
+	mailer           mailer.Mailer
+	appBaseURL       string
+	hideAccounts     bool
 }
 
-func NewRegisterUseCase(authService auth.Service) RegisterUseCase {
+func NewRegisterUseCase(authService auth.Service, userService user.Service, sendVerification SendVerificationUseCase, m mailer.Mailer, appBaseURL string, hideAccounts bool) RegisterUseCase {
 	return &registerUseCase{
-		authService: authService,
+		authService:      authService,
+		userService:      userService,
+		sendVerification: sendVerification,
+		mailer:           m,
+		appBaseURL:       appBaseURL,
+		hideAccounts:     hideAccounts,
 	}
 }
 
 func (uc *registerUseCase) Execute(ctx context.Context, req *auth.RegisterRequest) (*user.User, error) {
 	userRecord, err := uc.authService.RegisterUser(req)
+	if errors.Is(err, user.ErrEmailTaken) && uc.hideAccounts {
+		return nil, uc.notifyOwner(ctx, req)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// the account exists either way; the user can ask for a new link
+	if err := uc.sendVerification.Execute(ctx, userRecord); err != nil {
+		logger.Log.WithError(err).WithField("user_id", userRecord.ID).Error("failed to send verification email")
+	}
+
 	return userRecord, nil
-}
\ No newline at end of file
+}
+
+// notifyOwner tells the owner of a registered address that someone tried to
+// sign up with it. The password is hashed and thrown away so the request
+// takes as long as a real sign-up.
+func (uc *registerUseCase) notifyOwner(ctx context.Context, req *auth.RegisterRequest) error {
+	if _, err := uc.userService.HashPassword(req.Password); err != nil {
+		return err
+	}
+	owner, err := uc.userService.GetByEmail(req.Email)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	if err := uc.mailer.Send(ctx, mailer.Message{
+		To:      owner.Email,
+		Subject: "Sign-up attempt with your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone tried to create an account with this email address, but you already have one. If it was you, sign in at:\n\n%s\n\nIf you forgot your password, you can reset it at:\n\n%s\n\nIf it wasn't you, you can ignore this email; nothing has changed.\n",
+			owner.Fullname, uc.appBaseURL+"/login", uc.appBaseURL+"/forgot-password",
+		),
+	}); err != nil {
+		logger.Log.WithError(err).WithField("user_id", owner.ID).Error("failed to send sign-up attempt notice")
+	}
+	return nil
+}
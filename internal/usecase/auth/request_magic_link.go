@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
+)
+
+// maxMagicLinkRequests caps how many sign-in links one address receives per
+// magicLinkRequestWindow.
+const (
+	maxMagicLinkRequests   = 5
+	magicLinkRequestWindow = verification.ThrottleWindow
+)
+
+// RequestMagicLinkUseCase mails a single-use sign-in link. When links are
+// bound to the requesting browser it returns the binding secret, which the
+// caller hands to that browser as a cookie. Unknown addresses and throttled
+// requests succeed silently, with a binding all the same, so callers cannot
+// tell them apart.
+type RequestMagicLinkUseCase interface {
+	Execute(ctx context.Context, req *auth.MagicLinkRequest) (binding string, err error)
+}
+
+type requestMagicLinkUseCase struct {
+	userService   user.Service
+	verifications verification.Service
+	mailer        mailer.Mailer
+	appBaseURL    string
+	ttl           time.Duration
+	sameBrowser   bool
+}
+
+func NewRequestMagicLinkUseCase(userService user.Service, verifications verification.Service, m mailer.Mailer, appBaseURL string, ttl time.Duration, sameBrowser bool) RequestMagicLinkUseCase {
+	return &requestMagicLinkUseCase{
+		userService:   userService,
+		verifications: verifications,
+		mailer:        m,
+		appBaseURL:    appBaseURL,
+		ttl:           ttl,
+		sameBrowser:   sameBrowser,
+	}
+}
+
+func (uc *requestMagicLinkUseCase) Execute(ctx context.Context, req *auth.MagicLinkRequest) (string, error) {
+	var binding string
+	if uc.sameBrowser {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return "", err
+		}
+		binding = base64.RawURLEncoding.EncodeToString(secret)
+	}
+
+	u, err := uc.userService.GetByEmail(req.Email)
+	if err != nil {
+		return "", err
+	}
+	if u == nil {
+		if err := issueDecoy(uc.verifications, verification.PurposeMagicLink, uc.ttl, binding); err != nil {
+			return "", err
+		}
+		return binding, nil
+	}
+
+	recent, err := uc.verifications.IssuedSince(u.ID, verification.PurposeMagicLink, time.Now().UTC().Add(-magicLinkRequestWindow))
+	if err != nil {
+		return "", err
+	}
+	if recent >= maxMagicLinkRequests {
+		logger.Log.WithField("user_id", u.ID).Warn("magic link requests throttled")
+		return binding, nil
+	}
+
+	token, err := uc.verifications.IssueBound(u.ID, verification.PurposeMagicLink, uc.ttl, binding)
+	if err != nil {
+		return "", err
+	}
+
+	note := ""
+	if uc.sameBrowser {
+		note = " Open it in the same browser you asked for it from."
+	}
+	link := uc.appBaseURL + "/magic-link?token=" + url.QueryEscape(token)
+	if err := uc.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to sign in:\n\n%s\n\nThe link can be used once and expires in %s.%s If you didn't ask for it, you can ignore this email.\n",
+			u.Fullname, link, uc.ttl, note,
+		),
+	}); err != nil {
+		return "", err
+	}
+	return binding, nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+func TestMagicLink(t *testing.T) {
+	logger.Init()
+	errSuspended := errors.New("account suspended")
+
+	tests := []struct {
+		name        string
+		sameBrowser bool
+		binding     func(issued string) string
+		loginErr    error
+		wantErr     error
+	}{
+		{name: "unbound link"},
+		{name: "bound link in the same browser", sameBrowser: true, binding: func(issued string) string { return issued }},
+		{name: "bound link in another browser", sameBrowser: true, binding: func(string) string { return "" }, wantErr: verification.ErrWrongBinding},
+		{name: "bound link with a forged binding", sameBrowser: true, binding: func(string) string { return "forged" }, wantErr: verification.ErrWrongBinding},
+		{name: "login refused", loginErr: errSuspended, wantErr: errSuspended},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			request := NewRequestMagicLinkUseCase(f.userService, f.verifications, f.mailer, testAppURL, 15*time.Minute, tt.sameBrowser)
+			consume := NewConsumeMagicLinkUseCase(f.verifications, f.userService, loginCheck{err: tt.loginErr})
+
+			issued, err := request.Execute(context.Background(), &auth.MagicLinkRequest{Email: "Jane@Example.com"})
+			if err != nil {
+				t.Fatalf("request Execute() error = %v", err)
+			}
+			if (issued != "") != tt.sameBrowser {
+				t.Fatalf("binding = %q, want one only for bound links", issued)
+			}
+			req := &auth.ConsumeMagicLinkRequest{Token: f.lastLink(t, "jane@example.com")}
+			if tt.binding != nil {
+				req.Binding = tt.binding(issued)
+			}
+
+			u, err := consume.Execute(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("consume Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// the link proves the mailbox
+			if u.ID != "user-1" || !u.EmailVerified {
+				t.Errorf("consume Execute() = %+v", u)
+			}
+			if _, err := consume.Execute(context.Background(), req); !errors.Is(err, verification.ErrInvalidToken) {
+				t.Errorf("second consume Execute() error = %v, want %v", err, verification.ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestMagicLinkThrottles(t *testing.T) {
+	logger.Init()
+	f := newFixture(t)
+	request := NewRequestMagicLinkUseCase(f.userService, f.verifications, f.mailer, testAppURL, 15*time.Minute, true)
+
+	for i := 1; i <= maxMagicLinkRequests+2; i++ {
+		binding, err := request.Execute(context.Background(), &auth.MagicLinkRequest{Email: "jane@example.com"})
+		if err != nil || binding == "" {
+			t.Fatalf("request %d: Execute() = %q, %v", i, binding, err)
+		}
+	}
+	if got := len(f.mailer.Messages()); got != maxMagicLinkRequests {
+		t.Fatalf("mailed %d links, want %d", got, maxMagicLinkRequests)
+	}
+}
+
+type loginCheck struct {
+	auth.Service
+	err error
+}
+
+func (l loginCheck) CheckLoginAllowed(*user.User) error {
+	return l.err
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+)
+
+// resendCooldown is the minimum time between two verification emails to the
+// same user, so the endpoint cannot be used to flood an inbox.
+const resendCooldown = time.Minute
+
+// ResendVerificationUseCase mails a new verification link. It succeeds
+// silently for unknown or already verified addresses so callers cannot probe
+// which emails are registered, and does decoy work for them so the response
+// time does not tell either.
+type ResendVerificationUseCase interface {
+	Execute(ctx context.Context, req *auth.ResendVerificationRequest) error
+}
+
+type resendVerificationUseCase struct {
+	userService      user.Service
+	verifications    verification.Service
+	sendVerification SendVerificationUseCase
+	ttl              time.Duration
+}
+
+func NewResendVerificationUseCase(userService user.Service, verifications verification.Service, sendVerification SendVerificationUseCase, ttl time.Duration) ResendVerificationUseCase {
+	return &resendVerificationUseCase{
+		userService:      userService,
+		verifications:    verifications,
+		sendVerification: sendVerification,
+		ttl:              ttl,
+	}
+}
+
+func (uc *resendVerificationUseCase) Execute(ctx context.Context, req *auth.ResendVerificationRequest) error {
+	u, err := uc.userService.GetByEmail(req.Email)
+	if err != nil {
+		return err
+	}
+	if u == nil || u.EmailVerified {
+		return issueDecoy(uc.verifications, verification.PurposeEmailVerification, uc.ttl, "")
+	}
+
+	recent, err := uc.verifications.IssuedSince(u.ID, verification.PurposeEmailVerification, time.Now().UTC().Add(-resendCooldown))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		// the count is done; the decoy token stands in for the real one
+		_, err := uc.verifications.IssueBound(decoyUserID, verification.PurposeEmailVerification, uc.ttl, "")
+		return err
+	}
+
+	return uc.sendVerification.Execute(ctx, u)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// ResetPasswordUseCase sets a new password from a reset token and signs the
+// user out everywhere.
+type ResetPasswordUseCase interface {
+	Execute(ctx context.Context, req *auth.ResetPasswordRequest) error
+}
+
+type resetPasswordUseCase struct {
+	verifications  verification.Service
+	userService    user.Service
+	sessionService session.Service
+	securityEvents securityevent.Service
+	lockouts       lockout.Service
+}
+
+func NewResetPasswordUseCase(verifications verification.Service, userService user.Service, sessionService session.Service, securityEvents securityevent.Service, lockouts lockout.Service) ResetPasswordUseCase {
+	return &resetPasswordUseCase{
+		verifications:  verifications,
+		userService:    userService,
+		sessionService: sessionService,
+		securityEvents: securityEvents,
+		lockouts:       lockouts,
+	}
+}
+
+func (uc *resetPasswordUseCase) Execute(ctx context.Context, req *auth.ResetPasswordRequest) error {
+	// the link stays usable until a password passes the policy
+	pending, err := uc.verifications.Peek(req.Token, verification.PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	account, err := uc.userService.Get(pending.UserID)
+	if err != nil {
+		return err
+	}
+	if err := uc.userService.CheckPassword(req.Password, account); err != nil {
+		return err
+	}
+
+	token, err := uc.verifications.Consume(req.Token, verification.PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hash, err := uc.userService.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+	updated, err := uc.userService.Update(token.UserID, &user.User{PasswordHash: hash})
+	if err != nil {
+		return err
+	}
+	// a new password ends any lockout the old one was under
+	if err := uc.lockouts.Reset(updated.Email); err != nil {
+		logger.Log.WithError(err).Error("failed to reset login failures")
+	}
+
+	revoked, err := uc.sessionService.InvalidateUserSessions(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    token.UserID,
+		Type:      securityevent.TypePasswordReset,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Details:   "sessions revoked: " + strconv.FormatInt(revoked, 10),
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record password reset")
+	}
+	return nil
+}
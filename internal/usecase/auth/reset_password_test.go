@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/password"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+func TestResetPassword(t *testing.T) {
+	logger.Init()
+	f := newFixture(t)
+	forgot := NewForgotPasswordUseCase(f.userService, f.verifications, f.mailer, testAppURL, time.Hour)
+	reset := NewResetPasswordUseCase(f.verifications, f.userService, f.sessions, f.events, f.lockouts)
+	if err := forgot.Execute(context.Background(), &auth.ForgotPasswordRequest{Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	link := f.lastLink(t, "jane@example.com")
+	magicLink, err := f.verifications.Issue("user-1", verification.PurposeMagicLink, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		req        auth.ResetPasswordRequest
+		wantErr    error
+		wantPolicy bool
+		wantDone   bool
+	}{
+		{name: "unknown token", req: auth.ResetPasswordRequest{Token: "nope", Password: "new-password-2"}, wantErr: verification.ErrInvalidToken},
+		{name: "token of another purpose", req: auth.ResetPasswordRequest{Token: magicLink, Password: "new-password-2"}, wantErr: verification.ErrInvalidToken},
+		{name: "password rejected by the policy keeps the link", req: auth.ResetPasswordRequest{Token: link, Password: "short"}, wantPolicy: true},
+		{name: "reset", req: auth.ResetPasswordRequest{Token: link, Password: "new-password-2", IPAddress: "203.0.113.7"}, wantDone: true},
+		{name: "link used twice", req: auth.ResetPasswordRequest{Token: link, Password: "new-password-3"}, wantErr: verification.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reset.Execute(context.Background(), &tt.req)
+			var policyErr *password.PolicyError
+			if tt.wantPolicy != errors.As(err, &policyErr) {
+				t.Fatalf("Execute() error = %v, want a policy error %v", err, tt.wantPolicy)
+			}
+			if !tt.wantPolicy && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if !tt.wantDone {
+				return
+			}
+			if err := f.hasher.Compare(f.users.byID["user-1"].PasswordHash, tt.req.Password); err != nil {
+				t.Errorf("password was not changed: %v", err)
+			}
+			if !slices.Equal(f.sessions.invalidated, []string{"user-1"}) {
+				t.Errorf("invalidated sessions of %v, want user-1", f.sessions.invalidated)
+			}
+			if !slices.Equal(f.lockouts.reset, []string{"jane@example.com"}) {
+				t.Errorf("reset lockouts of %v, want jane@example.com", f.lockouts.reset)
+			}
+			if len(f.events.events) != 1 || f.events.events[0].Type != securityevent.TypePasswordReset || f.events.events[0].IPAddress != "203.0.113.7" {
+				t.Errorf("recorded events = %+v", f.events.events)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
+)
+
+// SendVerificationUseCase mails a fresh email verification link to a user.
+type SendVerificationUseCase interface {
+	Execute(ctx context.Context, u *user.User) error
+}
+
+type sendVerificationUseCase struct {
+	verifications verification.Service
+	mailer        mailer.Mailer
+	appBaseURL    string
+	ttl           time.Duration
+}
+
+func NewSendVerificationUseCase(verifications verification.Service, m mailer.Mailer, appBaseURL string, ttl time.Duration) SendVerificationUseCase {
+	return &sendVerificationUseCase{
+		verifications: verifications,
+		mailer:        m,
+		appBaseURL:    appBaseURL,
+		ttl:           ttl,
+	}
+}
+
+func (uc *sendVerificationUseCase) Execute(ctx context.Context, u *user.User) error {
+	token, err := uc.verifications.Issue(u.ID, verification.PurposeEmailVerification, uc.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := uc.appBaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	return uc.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not create an account, you can ignore this email.\n",
+			u.Fullname, link, uc.ttl,
+		),
+	})
+}
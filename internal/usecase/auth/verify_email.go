@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/domain/verification"
+)
+
+type VerifyEmailUseCase interface {
+	Execute(ctx context.Context, req *auth.VerifyEmailRequest) (*user.User, error)
+}
+
+type verifyEmailUseCase struct {
+	verifications verification.Service
+	userService   user.Service
+}
+
+func NewVerifyEmailUseCase(verifications verification.Service, userService user.Service) VerifyEmailUseCase {
+	return &verifyEmailUseCase{
+		verifications: verifications,
+		userService:   userService,
+	}
+}
+
+func (uc *verifyEmailUseCase) Execute(ctx context.Context, req *auth.VerifyEmailRequest) (*user.User, error) {
+	token, err := uc.verifications.Consume(req.Token, verification.PurposeEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.userService.MarkEmailVerified(token.UserID)
+}
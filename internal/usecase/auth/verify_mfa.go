@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/lockout"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	mfausecase "mikhailjbs/user-auth-service/internal/usecase/mfa"
+)
+
+// challengeAttemptLimit is how many wrong codes one challenge tolerates
+// before the user has to start over with their password. Starting over does
+// not buy more guesses: every wrong code also counts against the user.
+var challengeAttemptLimit = ratelimit.Limit{Burst: 5, Per: 15 * time.Minute}
+
+// VerifyMFAUseCase completes a login that stopped at the MFA step. It returns
+// the user and the challenge so the caller can restore the login context.
+type VerifyMFAUseCase interface {
+	Execute(ctx context.Context, req *auth.VerifyMFARequest) (*user.User, *security.Challenge, error)
+}
+
+type verifyMFAUseCase struct {
+	tokenManager   *security.TokenManager
+	mfaService     mfa.Service
+	userService    user.Service
+	lockouts       lockout.Service
+	reportRecovery mfausecase.ReportRecoveryCodeUseCase
+	limits         ratelimit.Service
+	failures       mfausecase.CodeFailures
+	used           security.ReplayCache
+}
+
+// NewVerifyMFAUseCase counts wrong codes per challenge and per user in the
+// shared rate limit store and burns challenges in the shared replay store,
+// so neither a new challenge nor another replica resets the count.
+func NewVerifyMFAUseCase(tokenManager *security.TokenManager, mfaService mfa.Service, userService user.Service, lockouts lockout.Service, reportRecovery mfausecase.ReportRecoveryCodeUseCase, limits ratelimit.Service, used security.ReplayCache) VerifyMFAUseCase {
+	return &verifyMFAUseCase{
+		tokenManager:   tokenManager,
+		mfaService:     mfaService,
+		userService:    userService,
+		lockouts:       lockouts,
+		reportRecovery: reportRecovery,
+		limits:         limits,
+		failures:       mfausecase.NewCodeFailures(limits),
+		used:           used,
+	}
+}
+
+func (uc *verifyMFAUseCase) Execute(ctx context.Context, req *auth.VerifyMFARequest) (*user.User, *security.Challenge, error) {
+	challenge, err := uc.tokenManager.ParseChallenge(req.MFAToken, security.ChallengePurposeMFA)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge.Subject == "" {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+	res, err := uc.limits.Check(challengeAttemptKey(challenge.ID), challengeAttemptLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !res.Allowed {
+		return nil, nil, auth.ErrTooManyAttempts
+	}
+	if err := uc.failures.Check(challenge.Subject); err != nil {
+		return nil, nil, err
+	}
+
+	if err := uc.checkFactor(ctx, challenge.Subject, req); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrCodeReused) {
+			if _, err := uc.limits.Allow(challengeAttemptKey(challenge.ID), challengeAttemptLimit); err != nil {
+				logger.Log.WithError(err).Error("failed to count mfa challenge failure")
+			}
+		}
+		uc.failures.Record(challenge.Subject, err)
+		return nil, nil, err
+	}
+
+	// a challenge completes exactly one login
+	fresh, err := uc.used.Remember(challenge.ID, challenge.ExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !fresh {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+
+	u, err := uc.userService.Get(challenge.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	// only now is the login complete; Login leaves the lockout in place
+	if err := uc.lockouts.Reset(u.Email); err != nil {
+		logger.Log.WithError(err).Error("failed to reset login failures")
+	}
+	return u, challenge, nil
+}
+
+func (uc *verifyMFAUseCase) checkFactor(ctx context.Context, userID string, req *auth.VerifyMFARequest) error {
+	if req.RecoveryCode == "" {
+		return uc.mfaService.VerifyTOTP(userID, req.Code)
+	}
+	remaining, err := uc.mfaService.UseRecoveryCode(userID, req.RecoveryCode)
+	if err != nil {
+		return err
+	}
+	uc.reportRecovery.Execute(ctx, userID, remaining, "sign-in second factor", req.IPAddress, req.UserAgent)
+	return nil
+}
+
+func challengeAttemptKey(challengeID string) string {
+	return "mfa-challenge:" + challengeID
+}
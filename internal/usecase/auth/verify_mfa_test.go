@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/replay"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const validTOTP = "123456"
+
+func TestVerifyMFALimitsWrongCodes(t *testing.T) {
+	logger.Init()
+	wrong := func(n int) []string { return slices.Repeat([]string{"000000"}, n) }
+	tests := []struct {
+		name string
+		// newChallenge logs in with the password again before every code
+		newChallenge bool
+		codes        []string
+		want         []error
+	}{
+		{
+			name:  "right code after wrong ones",
+			codes: append(wrong(2), validTOTP),
+			want:  []error{mfa.ErrInvalidCode, mfa.ErrInvalidCode, nil},
+		},
+		{
+			name:  "five wrong codes end the challenge",
+			codes: append(wrong(5), validTOTP),
+			want:  append(slices.Repeat([]error{mfa.ErrInvalidCode}, 5), auth.ErrTooManyAttempts),
+		},
+		{
+			name:         "new challenges do not buy more guesses",
+			newChallenge: true,
+			codes:        append(wrong(5), validTOTP),
+			want:         append(slices.Repeat([]error{mfa.ErrInvalidCode}, 5), mfa.ErrTooManyAttempts),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMFAFixture(t)
+			token := f.challenge(t)
+			for i, code := range tt.codes {
+				if tt.newChallenge {
+					token = f.challenge(t)
+				}
+				// alternate replicas: the counts live in the shared stores
+				_, _, err := f.replicas[i%2].Execute(context.Background(), &auth.VerifyMFARequest{MFAToken: token, Code: code})
+				if !errors.Is(err, tt.want[i]) {
+					t.Fatalf("attempt %d: error = %v, want %v", i+1, err, tt.want[i])
+				}
+			}
+			succeeded := tt.want[len(tt.want)-1] == nil
+			if reset := slices.Contains(f.lockouts.reset, "jane@example.com"); reset != succeeded {
+				t.Errorf("lockout reset = %v, want %v", reset, succeeded)
+			}
+		})
+	}
+}
+
+func TestVerifyMFAChallengeCompletesOneLogin(t *testing.T) {
+	logger.Init()
+	f := newMFAFixture(t)
+	token := f.challenge(t)
+	req := &auth.VerifyMFARequest{MFAToken: token, Code: validTOTP}
+
+	if _, _, err := f.replicas[0].Execute(context.Background(), req); err != nil {
+		t.Fatalf("first Execute() error = %v", err)
+	}
+	if _, _, err := f.replicas[1].Execute(context.Background(), req); !errors.Is(err, security.ErrInvalidChallenge) {
+		t.Errorf("replayed Execute() on another replica error = %v, want %v", err, security.ErrInvalidChallenge)
+	}
+}
+
+func TestLoginKeepsLockoutUntilSecondFactor(t *testing.T) {
+	logger.Init()
+	tests := []struct {
+		name      string
+		enrolled  bool
+		wantReset bool
+	}{
+		{name: "password only", wantReset: true},
+		{name: "second factor pending", enrolled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			limits := ratelimit.NewService(repository.NewMemoryRateLimitRepository())
+			uc := NewLoginUseCase(passwordOK{u: f.users.byID["user-1"]}, f.userService, &totpFactor{enrolled: tt.enrolled}, f.lockouts, f.events, limits)
+			if _, err := uc.Execute(context.Background(), &auth.LoginRequest{Email: "jane@example.com", Password: "old-password-1"}); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if reset := len(f.lockouts.reset) > 0; reset != tt.wantReset {
+				t.Errorf("lockout reset = %v, want %v", reset, tt.wantReset)
+			}
+		})
+	}
+}
+
+// mfaFixture is two replicas of the verify usecase sharing their stores.
+type mfaFixture struct {
+	*fixture
+	tokens   *security.TokenManager
+	replicas [2]VerifyMFAUseCase
+}
+
+func newMFAFixture(t *testing.T) *mfaFixture {
+	t.Helper()
+	f := newFixture(t)
+	tokens, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := ratelimit.NewService(repository.NewMemoryRateLimitRepository())
+	used := replay.NewService(repository.NewMemoryReplayRepository())
+	factor := &totpFactor{enrolled: true}
+	m := &mfaFixture{fixture: f, tokens: tokens}
+	for i := range m.replicas {
+		m.replicas[i] = NewVerifyMFAUseCase(tokens, factor, f.userService, f.lockouts, nil, limits, used)
+	}
+	return m
+}
+
+// challenge is what a correct password earns: a new MFA challenge token.
+func (m *mfaFixture) challenge(t *testing.T) string {
+	t.Helper()
+	token, _, err := m.tokens.IssueChallenge(security.ChallengePurposeMFA, "user-1", 5*time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+type totpFactor struct {
+	mfa.Service
+	enrolled bool
+}
+
+func (f *totpFactor) IsEnrolled(string) (bool, error) {
+	return f.enrolled, nil
+}
+
+func (f *totpFactor) VerifyTOTP(_, code string) error {
+	if code != validTOTP {
+		return mfa.ErrInvalidCode
+	}
+	return nil
+}
+
+type passwordOK struct {
+	auth.Service
+	u *user.User
+}
+
+func (p passwordOK) LoginUser(*auth.LoginRequest) (*user.User, error) {
+	return p.u, nil
+}
@@ -0,0 +1,169 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/idp"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// CompleteLoginUseCase handles the provider's callback: it redeems the code,
+// finds or creates the local user and returns it together with the flow,
+// whose Data holds the state passed to StartLoginUseCase.
+//
+// An unlinked external account is linked to the local user with the same
+// email only when both sides have verified the address. Otherwise a new user
+// is created, already verified since the provider vouches for the address.
+type CompleteLoginUseCase interface {
+	Execute(ctx context.Context, req *identity.CallbackRequest, ip, userAgent string) (*user.User, *security.Challenge, error)
+}
+
+type completeLoginUseCase struct {
+	providers       *idp.Registry
+	identityService identity.Service
+	userService     user.Service
+	authService     auth.Service
+	securityEvents  securityevent.Service
+	tokenManager    *security.TokenManager
+	baseURL         string
+	used            security.ReplayCache
+}
+
+func NewCompleteLoginUseCase(providers *idp.Registry, identityService identity.Service, userService user.Service, authService auth.Service, securityEvents securityevent.Service, tokenManager *security.TokenManager, baseURL string, used security.ReplayCache) CompleteLoginUseCase {
+	return &completeLoginUseCase{
+		providers:       providers,
+		identityService: identityService,
+		userService:     userService,
+		authService:     authService,
+		securityEvents:  securityEvents,
+		tokenManager:    tokenManager,
+		baseURL:         baseURL,
+		used:            used,
+	}
+}
+
+func (uc *completeLoginUseCase) Execute(ctx context.Context, req *identity.CallbackRequest, ip, userAgent string) (*user.User, *security.Challenge, error) {
+	flow, err := uc.tokenManager.ParseChallenge(req.FlowToken, security.ChallengePurposeExternalLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if flow.Data[providerKey] != req.Provider || req.State == "" ||
+		subtle.ConstantTimeCompare([]byte(flow.Data[stateKey]), []byte(req.State)) != 1 {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+	// a flow gets exactly one code exchange, successful or not
+	fresh, err := uc.used.Remember(flow.ID, flow.ExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !fresh {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+
+	p, err := uc.providers.Get(req.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	ext, err := p.Exchange(ctx, req.Code, flow.Data[verifierKey], callbackURL(uc.baseURL, p.Name()), flow.Data[nonceKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range []string{providerKey, stateKey, nonceKey, verifierKey} {
+		delete(flow.Data, k)
+	}
+
+	u, err := uc.resolveUser(ext, ip, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := uc.authService.CheckLoginAllowed(u); err != nil {
+		return nil, nil, err
+	}
+	return u, flow, nil
+}
+
+func (uc *completeLoginUseCase) resolveUser(ext *idp.Identity, ip, userAgent string) (*user.User, error) {
+	email := strings.TrimSpace(ext.Email)
+
+	linked, err := uc.identityService.Find(ext.Provider, ext.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		if !ext.EmailVerified {
+			email = ""
+		}
+		if err := uc.identityService.Touch(linked, email); err != nil {
+			logger.Log.WithError(err).Warn("failed to record external sign-in")
+		}
+		return uc.userService.Get(linked.UserID)
+	}
+
+	if email == "" || !ext.EmailVerified {
+		return nil, identity.ErrEmailNotVerified
+	}
+
+	u, err := uc.userService.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil && !u.EmailVerified {
+		return nil, identity.ErrAccountConflict
+	}
+	if u == nil {
+		if u, err = uc.createUser(ext, email); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := uc.identityService.Link(u.ID, ext.Provider, ext.Subject, email); err != nil {
+		return nil, err
+	}
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    u.ID,
+		Type:      securityevent.TypeIdentityLinked,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   ext.Provider,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record identity link")
+	}
+	return u, nil
+}
+
+// createUser signs up a user from the provider's profile. The random
+// password is never shown; the user can set one through password reset.
+func (uc *completeLoginUseCase) createUser(ext *idp.Identity, email string) (*user.User, error) {
+	password, err := idp.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	username := ext.Username
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+	fullname := ext.Name
+	if fullname == "" {
+		fullname = username
+	}
+
+	u, err := uc.userService.Create(&user.CreateUserRequest{
+		Username:  username,
+		Fullname:  fullname,
+		Email:     email,
+		Password:  password,
+		Role:      string(user.RoleUser),
+		Generated: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uc.userService.MarkEmailVerified(u.ID)
+}
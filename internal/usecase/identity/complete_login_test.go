@@ -0,0 +1,277 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/idp"
+	"mikhailjbs/user-auth-service/internal/infra/idp/mockoidc"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+const (
+	testBaseURL  = "https://auth.example.com"
+	testClientID = "user-auth-service"
+)
+
+func TestCompleteLoginCallback(t *testing.T) {
+	verifiedAt := time.Now()
+	tests := []struct {
+		name          string
+		claims        map[string]interface{}
+		existing      *user.User
+		wantErr       error
+		wantEmail     string
+		wantExistingU bool
+	}{
+		{
+			name:      "creates a verified user with a normalized email",
+			claims:    providerUser("sub-1", "New.User@Example.COM", true),
+			wantEmail: "new.user@example.com",
+		},
+		{
+			name:   "links to the verified account with the same email in another case",
+			claims: providerUser("sub-2", "Jane@Example.com", true),
+			existing: &user.User{
+				ID: "user-jane", Username: "jane", Email: "jane@example.com",
+				EmailVerified: true, EmailVerifiedAt: &verifiedAt,
+			},
+			wantEmail:     "jane@example.com",
+			wantExistingU: true,
+		},
+		{
+			name:   "refuses an unverified local account with the same email",
+			claims: providerUser("sub-3", "JOE@example.com", true),
+			existing: &user.User{
+				ID: "user-joe", Username: "joe", Email: "joe@example.com",
+			},
+			wantErr: identity.ErrAccountConflict,
+		},
+		{
+			name:    "refuses an email the provider did not verify",
+			claims:  providerUser("sub-4", "someone@example.com", false),
+			wantErr: identity.ErrEmailNotVerified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := newMemoryUsers()
+			if tt.existing != nil {
+				users.byID[tt.existing.ID] = tt.existing
+			}
+			start, complete := newLoginFlow(t, tt.claims, users)
+
+			req := startAndAuthorize(t, start)
+			u, flow, err := complete.Execute(context.Background(), req, "203.0.113.7", "test")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if u.Email != tt.wantEmail {
+				t.Errorf("user email = %q, want %q", u.Email, tt.wantEmail)
+			}
+			if !u.EmailVerified {
+				t.Error("user email not verified")
+			}
+			if tt.wantExistingU && u.ID != tt.existing.ID {
+				t.Errorf("user id = %q, want the existing %q", u.ID, tt.existing.ID)
+			}
+			if flow.Data["return_to"] != "/home" {
+				t.Errorf("flow state = %v, want return_to kept", flow.Data)
+			}
+			if _, ok := flow.Data[verifierKey]; ok {
+				t.Error("flow still carries the PKCE verifier")
+			}
+
+			// a flow redeems exactly one code
+			if _, _, err := complete.Execute(context.Background(), req, "203.0.113.7", "test"); !errors.Is(err, security.ErrInvalidChallenge) {
+				t.Errorf("replayed callback error = %v, want %v", err, security.ErrInvalidChallenge)
+			}
+		})
+	}
+}
+
+func TestCompleteLoginRejectsWrongState(t *testing.T) {
+	start, complete := newLoginFlow(t, providerUser("sub-1", "a@example.com", true), newMemoryUsers())
+	req := startAndAuthorize(t, start)
+	req.State = "forged"
+	if _, _, err := complete.Execute(context.Background(), req, "", ""); !errors.Is(err, security.ErrInvalidChallenge) {
+		t.Fatalf("Execute() error = %v, want %v", err, security.ErrInvalidChallenge)
+	}
+}
+
+func providerUser(sub, email string, verified bool) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":            sub,
+		"email":          email,
+		"email_verified": verified,
+		"name":           "Test User",
+	}
+}
+
+// newLoginFlow serves a mock provider signing everyone in with claims and
+// returns the use cases wired against it.
+func newLoginFlow(t *testing.T, claims map[string]interface{}, users *memoryUsers) (StartLoginUseCase, CompleteLoginUseCase) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	provider, err := mockoidc.New("http://"+srv.Listener.Addr().String(), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.Handler = provider
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	p, err := idp.New(idp.Config{Name: "mock", Type: idp.TypeOIDC, ClientID: testClientID, Issuer: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := idp.NewRegistry(p)
+	tokens, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		Issuer:        testBaseURL,
+		Audience:      testClientID,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := NewStartLoginUseCase(registry, tokens, testBaseURL)
+	complete := NewCompleteLoginUseCase(registry, identity.NewService(newMemoryIdentities()),
+		user.NewService(users, nil, security.NewBcryptHasher(4)), allowAll{}, discardEvents{}, tokens, testBaseURL, security.NewMemoryReplayCache())
+	return start, complete
+}
+
+// startAndAuthorize begins a login, lets the browser visit the provider and
+// returns the callback request it is redirected back with.
+func startAndAuthorize(t *testing.T, start StartLoginUseCase) *identity.CallbackRequest {
+	t.Helper()
+	login, err := start.Execute(context.Background(), "mock", map[string]string{"return_to": "/home"})
+	if err != nil {
+		t.Fatalf("start login: %v", err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(login.RedirectURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := callbackURL(testBaseURL, "mock"); callback.Scheme+"://"+callback.Host+callback.Path != want {
+		t.Fatalf("redirected to %s, want %s", callback, want)
+	}
+
+	return &identity.CallbackRequest{
+		Provider:  "mock",
+		Code:      callback.Query().Get("code"),
+		State:     callback.Query().Get("state"),
+		FlowToken: login.FlowToken,
+	}
+}
+
+// memoryUsers is a user.Repository matching emails the way the database
+// does, case-insensitively.
+type memoryUsers struct {
+	user.Repository
+	byID map[string]*user.User
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{byID: map[string]*user.User{}}
+}
+
+func (m *memoryUsers) Create(u *user.User) (*user.User, error) {
+	m.byID[u.ID] = u
+	return u, nil
+}
+
+func (m *memoryUsers) GetByEmail(email string) (*user.User, error) {
+	for _, u := range m.byID {
+		if user.NormalizeEmail(u.Email) == user.NormalizeEmail(email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryUsers) Get(id string) (*user.User, error) {
+	if u, ok := m.byID[id]; ok {
+		return u, nil
+	}
+	return nil, user.ErrNotFound
+}
+
+func (m *memoryUsers) Update(id string, u *user.User) (*user.User, error) {
+	existing, ok := m.byID[id]
+	if !ok {
+		return nil, user.ErrNotFound
+	}
+	if u.EmailVerified {
+		existing.EmailVerified, existing.EmailVerifiedAt = true, u.EmailVerifiedAt
+	}
+	return existing, nil
+}
+
+type memoryIdentities struct {
+	byKey map[string]*identity.ExternalIdentity
+}
+
+func newMemoryIdentities() *memoryIdentities {
+	return &memoryIdentities{byKey: map[string]*identity.ExternalIdentity{}}
+}
+
+func (m *memoryIdentities) Create(i *identity.ExternalIdentity) error {
+	m.byKey[i.Provider+"|"+i.Subject] = i
+	return nil
+}
+
+func (m *memoryIdentities) Find(provider, subject string) (*identity.ExternalIdentity, error) {
+	return m.byKey[provider+"|"+subject], nil
+}
+
+func (m *memoryIdentities) Touch(string, string, time.Time) error {
+	return nil
+}
+
+type allowAll struct {
+	auth.Service
+}
+
+func (allowAll) CheckLoginAllowed(*user.User) error {
+	return nil
+}
+
+type discardEvents struct {
+	securityevent.Service
+}
+
+func (discardEvents) Record(*securityevent.Event) error {
+	return nil
+}
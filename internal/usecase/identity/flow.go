@@ -0,0 +1,24 @@
+package identity
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FlowTTL is how long the user has to sign in at the provider.
+const FlowTTL = 10 * time.Minute
+
+// Keys holding the provider flow in the flow token's data, next to the
+// caller's login state.
+const (
+	providerKey = "idp"
+	stateKey    = "idp_state"
+	nonceKey    = "idp_nonce"
+	verifierKey = "idp_verifier"
+)
+
+// callbackURL is the redirect URI registered with every provider.
+func callbackURL(baseURL, provider string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/api/v1/auth/idp/" + url.PathEscape(provider) + "/callback"
+}
@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/identity"
+	"mikhailjbs/user-auth-service/internal/infra/idp"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// StartLoginUseCase begins sign-in with an external provider. The PKCE
+// verifier, state and nonce travel in a signed flow token together with
+// state, which CompleteLoginUseCase hands back.
+type StartLoginUseCase interface {
+	Execute(ctx context.Context, provider string, state map[string]string) (*identity.LoginStart, error)
+}
+
+type startLoginUseCase struct {
+	providers    *idp.Registry
+	tokenManager *security.TokenManager
+	baseURL      string
+}
+
+func NewStartLoginUseCase(providers *idp.Registry, tokenManager *security.TokenManager, baseURL string) StartLoginUseCase {
+	return &startLoginUseCase{providers: providers, tokenManager: tokenManager, baseURL: baseURL}
+}
+
+func (uc *startLoginUseCase) Execute(ctx context.Context, provider string, state map[string]string) (*identity.LoginStart, error) {
+	p, err := uc.providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, challenge, err := idp.NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	oauthState, err := idp.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := idp.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL, err := p.AuthCodeURL(ctx, idp.AuthRequest{
+		State:         oauthState,
+		Nonce:         nonce,
+		CodeChallenge: challenge,
+		RedirectURI:   callbackURL(uc.baseURL, p.Name()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(state)+4)
+	for k, v := range state {
+		data[k] = v
+	}
+	data[providerKey] = p.Name()
+	data[stateKey] = oauthState
+	data[nonceKey] = nonce
+	data[verifierKey] = verifier
+
+	flowToken, _, err := uc.tokenManager.IssueChallenge(security.ChallengePurposeExternalLogin, "", FlowTTL, data)
+	if err != nil {
+		return nil, err
+	}
+	return &identity.LoginStart{RedirectURL: redirectURL, FlowToken: flowToken}, nil
+}
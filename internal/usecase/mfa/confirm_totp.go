@@ -0,0 +1,51 @@
+package mfa
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// ConfirmTOTPUseCase finishes enrollment with a first code from the app.
+// Wrong codes count towards the user's limit shared with DisableTOTPUseCase.
+type ConfirmTOTPUseCase interface {
+	Execute(ctx context.Context, userID string, req *mfa.CodeRequest, ip, userAgent string) error
+}
+
+type confirmTOTPUseCase struct {
+	mfaService     mfa.Service
+	securityEvents securityevent.Service
+	failures       CodeFailures
+}
+
+func NewConfirmTOTPUseCase(mfaService mfa.Service, securityEvents securityevent.Service, limits ratelimit.Service) ConfirmTOTPUseCase {
+	return &confirmTOTPUseCase{
+		mfaService:     mfaService,
+		securityEvents: securityEvents,
+		failures:       NewCodeFailures(limits),
+	}
+}
+
+func (uc *confirmTOTPUseCase) Execute(ctx context.Context, userID string, req *mfa.CodeRequest, ip, userAgent string) error {
+	if err := uc.failures.Check(userID); err != nil {
+		return err
+	}
+	if err := uc.mfaService.ConfirmTOTP(userID, req.Code); err != nil {
+		uc.failures.Record(userID, err)
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeMFAEnabled,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   "totp",
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record mfa enrollment")
+	}
+	return nil
+}
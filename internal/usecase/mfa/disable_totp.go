@@ -0,0 +1,77 @@
+package mfa
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// DisableTOTPUseCase removes the authenticator app after checking a current
+// code, or a recovery code when the app itself was lost. Wrong codes of
+// either kind count towards the user's limit.
+type DisableTOTPUseCase interface {
+	Execute(ctx context.Context, userID string, req *mfa.CodeRequest, ip, userAgent string) error
+}
+
+type disableTOTPUseCase struct {
+	mfaService     mfa.Service
+	securityEvents securityevent.Service
+	reportRecovery ReportRecoveryCodeUseCase
+	failures       CodeFailures
+}
+
+func NewDisableTOTPUseCase(mfaService mfa.Service, securityEvents securityevent.Service, reportRecovery ReportRecoveryCodeUseCase, limits ratelimit.Service) DisableTOTPUseCase {
+	return &disableTOTPUseCase{
+		mfaService:     mfaService,
+		securityEvents: securityEvents,
+		reportRecovery: reportRecovery,
+		failures:       NewCodeFailures(limits),
+	}
+}
+
+func (uc *disableTOTPUseCase) Execute(ctx context.Context, userID string, req *mfa.CodeRequest, ip, userAgent string) error {
+	if err := uc.failures.Check(userID); err != nil {
+		return err
+	}
+	if req.RecoveryCode != "" {
+		if err := uc.disableWithRecoveryCode(ctx, userID, req.RecoveryCode, ip, userAgent); err != nil {
+			uc.failures.Record(userID, err)
+			return err
+		}
+	} else if err := uc.mfaService.DisableTOTP(userID, req.Code); err != nil {
+		uc.failures.Record(userID, err)
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeMFADisabled,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   "totp",
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record mfa removal")
+	}
+	return nil
+}
+
+func (uc *disableTOTPUseCase) disableWithRecoveryCode(ctx context.Context, userID, code, ip, userAgent string) error {
+	// do not burn a code when there is nothing to disable
+	enrolled, err := uc.mfaService.IsEnrolled(userID)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return mfa.ErrNotEnrolled
+	}
+
+	remaining, err := uc.mfaService.UseRecoveryCode(userID, code)
+	if err != nil {
+		return err
+	}
+	uc.reportRecovery.Execute(ctx, userID, remaining, "disable authenticator app", ip, userAgent)
+	return uc.mfaService.RemoveTOTP(userID)
+}
@@ -0,0 +1,61 @@
+package mfa
+
+import (
+	"context"
+	"encoding/base64"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/qrcode"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// qrScale is the pixel size of one QR module in the enrollment PNG.
+const qrScale = 6
+
+// EnrollTOTPUseCase starts authenticator app enrollment for a user.
+type EnrollTOTPUseCase interface {
+	Execute(ctx context.Context, userID string) (*mfa.TOTPEnrollment, error)
+}
+
+type enrollTOTPUseCase struct {
+	mfaService  mfa.Service
+	userService user.Service
+	issuer      string
+}
+
+func NewEnrollTOTPUseCase(mfaService mfa.Service, userService user.Service, issuer string) EnrollTOTPUseCase {
+	return &enrollTOTPUseCase{
+		mfaService:  mfaService,
+		userService: userService,
+		issuer:      issuer,
+	}
+}
+
+func (uc *enrollTOTPUseCase) Execute(ctx context.Context, userID string) (*mfa.TOTPEnrollment, error) {
+	u, err := uc.userService.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := uc.mfaService.BeginTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	uri := security.TOTPURI(uc.issuer, u.Email, secret)
+	code, err := qrcode.Encode([]byte(uri))
+	if err != nil {
+		return nil, err
+	}
+	png, err := code.PNG(qrScale)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mfa.TOTPEnrollment{
+		Secret: security.EncodeTOTPSecret(secret),
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package mfa
+
+import (
+	"errors"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+)
+
+// codeFailureLimit allows five wrong codes per user, one more every three
+// minutes. Six digits with a few valid steps would otherwise fall to a
+// stolen access token in hours.
+var codeFailureLimit = ratelimit.Limit{Burst: 5, Per: 15 * time.Minute}
+
+// CodeFailures counts wrong codes per user in the shared rate limit store,
+// so every replica sees the same count. Sign-in, enrollment and removal of
+// a factor all draw on the same budget.
+type CodeFailures struct {
+	limits ratelimit.Service
+}
+
+func NewCodeFailures(limits ratelimit.Service) CodeFailures {
+	return CodeFailures{limits: limits}
+}
+
+// Check refuses the attempt once the user has no failures left.
+func (f CodeFailures) Check(userID string) error {
+	res, err := f.limits.Check(f.key(userID), codeFailureLimit)
+	if err != nil {
+		return err
+	}
+	if !res.Allowed {
+		return mfa.ErrTooManyAttempts
+	}
+	return nil
+}
+
+// Record counts err against the user when it is a wrong code.
+func (f CodeFailures) Record(userID string, err error) {
+	if errors.Is(err, mfa.ErrInvalidCode) || errors.Is(err, mfa.ErrCodeReused) {
+		// the count is best effort; Check already refused over-limit users
+		_, _ = f.limits.Allow(f.key(userID), codeFailureLimit)
+	}
+}
+
+func (f CodeFailures) key(userID string) string {
+	return "mfa-code:" + userID
+}
@@ -0,0 +1,116 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/ratelimit"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+)
+
+func TestConfirmTOTPLimitsWrongCodes(t *testing.T) {
+	logger.Init()
+	tests := []struct {
+		name    string
+		results []error
+		want    []error
+	}{
+		{
+			name:    "right code first time",
+			results: []error{nil},
+			want:    []error{nil},
+		},
+		{
+			name:    "right code after four wrong ones",
+			results: []error{mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrCodeReused, mfa.ErrInvalidCode, nil},
+			want:    []error{mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrCodeReused, mfa.ErrInvalidCode, nil},
+		},
+		{
+			name: "sixth attempt refused after five wrong codes",
+			results: []error{
+				mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode,
+			},
+			want: []error{
+				mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode, mfa.ErrInvalidCode,
+				mfa.ErrTooManyAttempts,
+			},
+		},
+		{
+			name:    "other failures are not counted",
+			results: []error{mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled},
+			want:    []error{mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled, mfa.ErrNotEnrolled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := &scriptedMFA{results: tt.results}
+			uc := NewConfirmTOTPUseCase(codes, discardEvents{}, ratelimit.NewService(repository.NewMemoryRateLimitRepository()))
+			for i, want := range tt.want {
+				err := uc.Execute(context.Background(), "user-1", &mfa.CodeRequest{Code: "123456"}, "", "")
+				if !errors.Is(err, want) {
+					t.Fatalf("attempt %d: error = %v, want %v", i+1, err, want)
+				}
+			}
+			if codes.calls != len(tt.results) {
+				t.Errorf("codes checked %d times, want %d", codes.calls, len(tt.results))
+			}
+		})
+	}
+}
+
+func TestWrongCodesAreCountedPerUser(t *testing.T) {
+	logger.Init()
+	limits := ratelimit.NewService(repository.NewMemoryRateLimitRepository())
+	codes := &scriptedMFA{always: mfa.ErrInvalidCode}
+	confirm := NewConfirmTOTPUseCase(codes, discardEvents{}, limits)
+	disable := NewDisableTOTPUseCase(codes, discardEvents{}, nil, limits)
+
+	// confirm and disable share one budget
+	for i := 0; i < 3; i++ {
+		_ = confirm.Execute(context.Background(), "user-1", &mfa.CodeRequest{Code: "000000"}, "", "")
+		_ = disable.Execute(context.Background(), "user-1", &mfa.CodeRequest{Code: "000000"}, "", "")
+	}
+	if err := disable.Execute(context.Background(), "user-1", &mfa.CodeRequest{Code: "000000"}, "", ""); !errors.Is(err, mfa.ErrTooManyAttempts) {
+		t.Errorf("user-1 error = %v, want %v", err, mfa.ErrTooManyAttempts)
+	}
+	if err := confirm.Execute(context.Background(), "user-2", &mfa.CodeRequest{Code: "000000"}, "", ""); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Errorf("user-2 error = %v, want %v", err, mfa.ErrInvalidCode)
+	}
+}
+
+// scriptedMFA answers code checks with results in order, then with always.
+type scriptedMFA struct {
+	mfa.Service
+	results []error
+	always  error
+	calls   int
+}
+
+func (s *scriptedMFA) next() error {
+	s.calls++
+	if s.calls <= len(s.results) {
+		return s.results[s.calls-1]
+	}
+	return s.always
+}
+
+func (s *scriptedMFA) ConfirmTOTP(string, string) error {
+	return s.next()
+}
+
+func (s *scriptedMFA) DisableTOTP(string, string) error {
+	return s.next()
+}
+
+type discardEvents struct {
+	securityevent.Service
+}
+
+func (discardEvents) Record(*securityevent.Event) error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package mfa
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/mfa"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// GenerateRecoveryCodesUseCase issues a new set of recovery codes. The
+// previous set stops working.
+type GenerateRecoveryCodesUseCase interface {
+	Execute(ctx context.Context, userID, ip, userAgent string) (*mfa.RecoveryCodes, error)
+}
+
+type generateRecoveryCodesUseCase struct {
+	mfaService     mfa.Service
+	securityEvents securityevent.Service
+}
+
+func NewGenerateRecoveryCodesUseCase(mfaService mfa.Service, securityEvents securityevent.Service) GenerateRecoveryCodesUseCase {
+	return &generateRecoveryCodesUseCase{
+		mfaService:     mfaService,
+		securityEvents: securityEvents,
+	}
+}
+
+func (uc *generateRecoveryCodesUseCase) Execute(ctx context.Context, userID, ip, userAgent string) (*mfa.RecoveryCodes, error) {
+	codes, err := uc.mfaService.GenerateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeRecoveryCodesGenerated,
+		IPAddress: ip,
+		UserAgent: userAgent,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record recovery code generation")
+	}
+	return &mfa.RecoveryCodes{Codes: codes}, nil
+}
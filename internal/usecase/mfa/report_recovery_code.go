@@ -0,0 +1,60 @@
+package mfa
+
+import (
+	"context"
+	"fmt"
+
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/mailer"
+)
+
+// ReportRecoveryCodeUseCase records that a recovery code was used and tells
+// the user by email, so a stolen code does not go unnoticed. Failures are
+// logged rather than returned: the code is already spent.
+type ReportRecoveryCodeUseCase interface {
+	Execute(ctx context.Context, userID string, remaining int64, usedFor, ip, userAgent string)
+}
+
+type reportRecoveryCodeUseCase struct {
+	userService    user.Service
+	securityEvents securityevent.Service
+	mailer         mailer.Mailer
+}
+
+func NewReportRecoveryCodeUseCase(userService user.Service, securityEvents securityevent.Service, m mailer.Mailer) ReportRecoveryCodeUseCase {
+	return &reportRecoveryCodeUseCase{
+		userService:    userService,
+		securityEvents: securityEvents,
+		mailer:         m,
+	}
+}
+
+func (uc *reportRecoveryCodeUseCase) Execute(ctx context.Context, userID string, remaining int64, usedFor, ip, userAgent string) {
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypeRecoveryCodeUsed,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   fmt.Sprintf("%s, %d remaining", usedFor, remaining),
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record recovery code use")
+	}
+
+	u, err := uc.userService.Get(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("failed to load user for recovery code notice")
+		return
+	}
+	if err := uc.mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "A recovery code was used on your account",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOne of your recovery codes was just used (%s) from %s. You have %d unused codes left.\n\nIf this wasn't you, sign in, change your password and generate new recovery codes right away.\n",
+			u.Fullname, usedFor, ip, remaining,
+		),
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to send recovery code notice")
+	}
+}
@@ -0,0 +1,108 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// AuthorizationCodeGrantUseCase redeems an authorization code for tokens
+// (RFC 6749 section 4.1.3) after checking the PKCE verifier.
+type AuthorizationCodeGrantUseCase interface {
+	Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error)
+}
+
+type authorizationCodeGrantUseCase struct {
+	oauthService   oauth.Service
+	sessionService session.Service
+	userService    user.Service
+	tokenManager   *security.TokenManager
+}
+
+func NewAuthorizationCodeGrantUseCase(oauthService oauth.Service, sessionService session.Service, userService user.Service, tokenManager *security.TokenManager) AuthorizationCodeGrantUseCase {
+	return &authorizationCodeGrantUseCase{
+		oauthService:   oauthService,
+		sessionService: sessionService,
+		userService:    userService,
+		tokenManager:   tokenManager,
+	}
+}
+
+func (uc *authorizationCodeGrantUseCase) Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	client, err := uc.oauthService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := uc.oauthService.RedeemCode(client.ID, req.Code, req.RedirectURI, req.CodeVerifier)
+	if errors.Is(err, oauth.ErrCodeReused) {
+		// the code leaked: revoke whatever it was exchanged for
+		if code.SessionID != "" {
+			if err := uc.sessionService.InvalidateSession(code.SessionID); err != nil {
+				return nil, err
+			}
+		}
+		logger.Log.WithFields(logrus.Fields{
+			"client_id":  client.ID,
+			"session_id": code.SessionID,
+		}).Warn("authorization code reused")
+		return nil, oauth.ErrCodeReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := uc.userService.Get(code.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, fmt.Errorf("%w: user no longer exists", oauth.ErrInvalidGrant)
+		}
+		return nil, err
+	}
+
+	opts := clientTokenOptions(client, code.Scope)
+	if hasOpenID(code.Scope) {
+		opts = append(opts, security.WithIDToken(security.IDTokenClaims{
+			Audience:      client.ID,
+			Name:          u.Fullname,
+			EmailVerified: u.EmailVerified,
+			Nonce:         code.Nonce,
+			AuthTime:      code.AuthTime,
+		}))
+	}
+	pair, err := uc.tokenManager.GenerateTokenPair(u.ID, u.Email, u.Username, clientRoles(client, u), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := uc.sessionService.CreateSession(&session.Session{
+		ID:               pair.SID,
+		UserID:           u.ID,
+		IPAddress:        req.IPAddress,
+		UserAgent:        req.UserAgent,
+		Valid:            true,
+		ExpiresAt:        pair.RefreshExp,
+		RefreshTokenHash: security.HashToken(pair.RefreshToken),
+		ClientID:         client.ID,
+		Scope:            code.Scope,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}); err != nil {
+		return nil, err
+	}
+	if err := uc.oauthService.AttachSession(code.ID, pair.SID); err != nil {
+		logger.Log.WithError(err).Error("failed to link authorization code to its session")
+	}
+
+	return tokenResponse(pair, code.Scope), nil
+}
@@ -0,0 +1,161 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// ConsentTTL is how long the user has to answer a consent prompt.
+const ConsentTTL = 10 * time.Minute
+
+// codeChallengeLength is the length of a base64url encoded SHA-256 digest.
+const codeChallengeLength = 43
+
+// AuthorizeUseCase handles the authorization endpoint. userID is the
+// signed-in user, empty when nobody is signed in yet.
+//
+// Problems with the client or its redirect URI are returned as errors and
+// must be shown to the user; everything after that is reported to the
+// client through AuthorizeResponse.RedirectTo (RFC 6749 section 4.1.2.1).
+type AuthorizeUseCase interface {
+	Execute(ctx context.Context, req *oauth.AuthorizeRequest, userID string) (*oauth.AuthorizeResponse, error)
+}
+
+type authorizeUseCase struct {
+	oauthService oauth.Service
+	tokenManager *security.TokenManager
+}
+
+func NewAuthorizeUseCase(oauthService oauth.Service, tokenManager *security.TokenManager) AuthorizeUseCase {
+	return &authorizeUseCase{oauthService: oauthService, tokenManager: tokenManager}
+}
+
+func (uc *authorizeUseCase) Execute(ctx context.Context, req *oauth.AuthorizeRequest, userID string) (*oauth.AuthorizeResponse, error) {
+	client, err := uc.oauthService.GetClient(req.ClientID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrClientNotFound) {
+			return nil, fmt.Errorf("%w: unknown client_id", oauth.ErrInvalidRequest)
+		}
+		return nil, err
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered for this client", oauth.ErrInvalidRequest)
+	}
+
+	fail := func(err error) (*oauth.AuthorizeResponse, error) {
+		return &oauth.AuthorizeResponse{RedirectTo: uc.errorRedirect(req.RedirectURI, req.State, err)}, nil
+	}
+	if req.ResponseType != oauth.ResponseTypeCode {
+		return fail(oauth.ErrUnsupportedResponseType)
+	}
+	if req.CodeChallengeMethod != oauth.CodeChallengeMethodS256 || len(req.CodeChallenge) != codeChallengeLength {
+		return fail(fmt.Errorf("%w: PKCE with code_challenge_method S256 is required", oauth.ErrInvalidRequest))
+	}
+	scope, err := uc.oauthService.ResolveScope(client, req.Scope)
+	if err != nil {
+		return fail(err)
+	}
+
+	if userID == "" {
+		return &oauth.AuthorizeResponse{LoginRequired: true}, nil
+	}
+
+	if !client.FirstParty {
+		granted, err := uc.oauthService.HasConsent(userID, client.ID, scope)
+		if err != nil {
+			return nil, err
+		}
+		if !granted {
+			return uc.promptConsent(client, req, scope, userID)
+		}
+	}
+
+	return issueCodeRedirect(uc.oauthService, uc.tokenManager.Issuer(), &oauth.CodeGrant{
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      time.Now().UTC(),
+	}, req.State)
+}
+
+// promptConsent carries the validated request in a signed consent token, so
+// the decision can be posted back without server-side state.
+func (uc *authorizeUseCase) promptConsent(client *oauth.Client, req *oauth.AuthorizeRequest, scope, userID string) (*oauth.AuthorizeResponse, error) {
+	token, ch, err := uc.tokenManager.IssueChallenge(security.ChallengePurposeOAuthConsent, userID, ConsentTTL, map[string]string{
+		"client_id":      client.ID,
+		"redirect_uri":   req.RedirectURI,
+		"scope":          scope,
+		"state":          req.State,
+		"code_challenge": req.CodeChallenge,
+		"nonce":          req.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &oauth.AuthorizeResponse{Consent: &oauth.ConsentPrompt{
+		ConsentToken: token,
+		ClientID:     client.ID,
+		ClientName:   client.Name,
+		Scopes:       strings.Fields(scope),
+		ExpiresAt:    ch.ExpiresAt,
+	}}, nil
+}
+
+func (uc *authorizeUseCase) errorRedirect(redirectURI, state string, err error) string {
+	return errorRedirect(redirectURI, uc.tokenManager.Issuer(), state, err)
+}
+
+// issueCodeRedirect issues an authorization code and returns the redirect
+// that delivers it to the client.
+func issueCodeRedirect(oauthService oauth.Service, issuer string, grant *oauth.CodeGrant, state string) (*oauth.AuthorizeResponse, error) {
+	code, err := oauthService.IssueCode(grant)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{"code": {code}}
+	return &oauth.AuthorizeResponse{RedirectTo: redirectWith(grant.RedirectURI, issuer, state, params)}, nil
+}
+
+// errorRedirect reports err to the client. The error code is the wrapped
+// RFC 6749 error, the rest of the message the description.
+func errorRedirect(redirectURI, issuer, state string, err error) string {
+	code, description := oauth.ErrAccessDenied.Error(), ""
+	if msg := err.Error(); msg != "" {
+		code, description, _ = strings.Cut(msg, ": ")
+	}
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	return redirectWith(redirectURI, issuer, state, params)
+}
+
+// redirectWith adds params, state and iss (RFC 9207) to the redirect URI.
+func redirectWith(redirectURI, issuer, state string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	if issuer != "" {
+		q.Set("iss", issuer)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// ClientCredentialsGrantUseCase issues a machine client an access token for
+// itself (RFC 6749 section 4.4). There is no user, session or refresh token:
+// the service simply asks again when the token expires. Deleting the client
+// stops new tokens; ones already issued run until they expire.
+type ClientCredentialsGrantUseCase interface {
+	Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error)
+}
+
+type clientCredentialsGrantUseCase struct {
+	oauthService oauth.Service
+	tokenManager *security.TokenManager
+}
+
+func NewClientCredentialsGrantUseCase(oauthService oauth.Service, tokenManager *security.TokenManager) ClientCredentialsGrantUseCase {
+	return &clientCredentialsGrantUseCase{
+		oauthService: oauthService,
+		tokenManager: tokenManager,
+	}
+}
+
+func (uc *clientCredentialsGrantUseCase) Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	client, err := uc.oauthService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Machine {
+		return nil, fmt.Errorf("%w: client is not allowed to use client credentials", oauth.ErrUnauthorizedClient)
+	}
+
+	scope, err := uc.oauthService.ResolveScope(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := uc.tokenManager.GenerateAccessToken(
+		security.MachineSubjectPrefix+client.ID, "", client.Name, []string{},
+		clientTokenOptions(client, scope)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"client_id": client.ID,
+		"scope":     scope,
+		"jti":       pair.JTI,
+	}).Info("client credentials token issued")
+
+	return tokenResponse(pair, scope), nil
+}
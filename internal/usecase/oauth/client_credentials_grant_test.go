@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestClientCredentialsGrant(t *testing.T) {
+	logger.Init()
+	tm := newTestTokenManager(t)
+	clients := &memoryClients{clients: map[string]*oauth.Client{
+		"svc":    {ID: "svc", Name: "billing", SecretHash: security.HashToken("svc-secret"), Scopes: "users:read users:write", Machine: true},
+		"web":    {ID: "web", Name: "web app", SecretHash: security.HashToken("web-secret"), Scopes: "openid profile", FirstParty: true},
+		"mobile": {ID: "mobile", Name: "mobile app", Scopes: "openid", Public: true},
+	}}
+	uc := NewClientCredentialsGrantUseCase(oauth.NewService(clients), tm)
+
+	tests := []struct {
+		name      string
+		req       oauth.TokenRequest
+		wantScope string
+		wantErr   error
+	}{
+		{name: "every allowed scope by default", req: oauth.TokenRequest{ClientID: "svc", ClientSecret: "svc-secret"}, wantScope: "users:read users:write"},
+		{name: "requested scope", req: oauth.TokenRequest{ClientID: "svc", ClientSecret: "svc-secret", Scope: "users:read users:read"}, wantScope: "users:read"},
+		{name: "scope the client was not given", req: oauth.TokenRequest{ClientID: "svc", ClientSecret: "svc-secret", Scope: "users:delete"}, wantErr: oauth.ErrInvalidScope},
+		{name: "wrong secret", req: oauth.TokenRequest{ClientID: "svc", ClientSecret: "web-secret"}, wantErr: oauth.ErrInvalidClient},
+		{name: "no secret", req: oauth.TokenRequest{ClientID: "svc"}, wantErr: oauth.ErrInvalidClient},
+		{name: "unknown client", req: oauth.TokenRequest{ClientID: "nope", ClientSecret: "x"}, wantErr: oauth.ErrInvalidClient},
+		{name: "user-facing client", req: oauth.TokenRequest{ClientID: "web", ClientSecret: "web-secret"}, wantErr: oauth.ErrUnauthorizedClient},
+		{name: "public client", req: oauth.TokenRequest{ClientID: "mobile"}, wantErr: oauth.ErrUnauthorizedClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := uc.Execute(context.Background(), &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Scope != tt.wantScope || resp.RefreshToken != "" || resp.IDToken != "" || resp.TokenType != "Bearer" {
+				t.Errorf("Execute() = %+v", resp)
+			}
+			claims, err := tm.ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !claims.IsMachine() || claims.ClientID != "svc" || claims.Scope != tt.wantScope || len(claims.Roles) != 0 {
+				t.Errorf("machine token claims = %+v", claims)
+			}
+		})
+	}
+}
+
+type memoryClients struct {
+	oauth.Repository
+	clients map[string]*oauth.Client
+}
+
+func (m *memoryClients) GetClient(id string) (*oauth.Client, error) {
+	return m.clients[id], nil
+}
@@ -0,0 +1,81 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// ConsentUseCase records the user's answer to a consent prompt and sends
+// them back to the client with a code or with access_denied.
+type ConsentUseCase interface {
+	Execute(ctx context.Context, req *oauth.ConsentRequest, userID string) (*oauth.AuthorizeResponse, error)
+}
+
+type consentUseCase struct {
+	oauthService oauth.Service
+	tokenManager *security.TokenManager
+	used         security.ReplayCache
+}
+
+func NewConsentUseCase(oauthService oauth.Service, tokenManager *security.TokenManager, used security.ReplayCache) ConsentUseCase {
+	return &consentUseCase{
+		oauthService: oauthService,
+		tokenManager: tokenManager,
+		used:         used,
+	}
+}
+
+func (uc *consentUseCase) Execute(ctx context.Context, req *oauth.ConsentRequest, userID string) (*oauth.AuthorizeResponse, error) {
+	prompt, err := uc.tokenManager.ParseChallenge(req.ConsentToken, security.ChallengePurposeOAuthConsent)
+	if err != nil {
+		return nil, err
+	}
+	// the prompt belongs to whoever was signed in when it was shown
+	if prompt.Subject != userID {
+		return nil, security.ErrInvalidChallenge
+	}
+	fresh, err := uc.used.Remember(prompt.ID, prompt.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, security.ErrInvalidChallenge
+	}
+
+	client, err := uc.oauthService.GetClient(prompt.Data["client_id"])
+	if err != nil {
+		if errors.Is(err, oauth.ErrClientNotFound) {
+			return nil, fmt.Errorf("%w: unknown client_id", oauth.ErrInvalidRequest)
+		}
+		return nil, err
+	}
+	redirectURI, state := prompt.Data["redirect_uri"], prompt.Data["state"]
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered for this client", oauth.ErrInvalidRequest)
+	}
+
+	if !req.Approve {
+		return &oauth.AuthorizeResponse{
+			RedirectTo: errorRedirect(redirectURI, uc.tokenManager.Issuer(), state, fmt.Errorf("%w: the user declined", oauth.ErrAccessDenied)),
+		}, nil
+	}
+
+	scope := prompt.Data["scope"]
+	if err := uc.oauthService.GrantConsent(userID, client.ID, scope); err != nil {
+		return nil, err
+	}
+	return issueCodeRedirect(uc.oauthService, uc.tokenManager.Issuer(), &oauth.CodeGrant{
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		CodeChallenge: prompt.Data["code_challenge"],
+		Nonce:         prompt.Data["nonce"],
+		AuthTime:      time.Now().UTC(),
+	}, state)
+}
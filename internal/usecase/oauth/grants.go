@@ -0,0 +1,44 @@
+package oauth
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// clientTokenOptions are the claims every token issued to an OAuth client
+// carries.
+func clientTokenOptions(client *oauth.Client, scope string) []security.TokenOption {
+	return []security.TokenOption{
+		security.WithScope(scope),
+		security.WithClientID(client.ID),
+	}
+}
+
+// clientRoles returns the roles placed in tokens for client. Third-party
+// clients get none, so role-gated routes never accept their tokens.
+func clientRoles(client *oauth.Client, u *user.User) []string {
+	if !client.FirstParty {
+		return []string{}
+	}
+	return []string{string(u.Role)}
+}
+
+func hasOpenID(scope string) bool {
+	return slices.Contains(strings.Fields(scope), oauth.ScopeOpenID)
+}
+
+func tokenResponse(pair *security.TokenPair, scope string) *oauth.TokenResponse {
+	return &oauth.TokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(pair.AccessExp).Seconds()),
+		RefreshToken: pair.RefreshToken,
+		Scope:        scope,
+		IDToken:      pair.IDToken,
+	}
+}
@@ -0,0 +1,161 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+type IntrospectUseCase interface {
+	Execute(ctx context.Context, req *oauth.IntrospectRequest) (*oauth.IntrospectionResponse, error)
+}
+
+type introspectUseCase struct {
+	tokenManager   *security.TokenManager
+	sessionService session.Service
+	revocations    revocation.Service
+	userService    user.Service
+}
+
+func NewIntrospectUseCase(
+	tokenManager *security.TokenManager,
+	sessionService session.Service,
+	revocations revocation.Service,
+	userService user.Service,
+) IntrospectUseCase {
+	return &introspectUseCase{
+		tokenManager:   tokenManager,
+		sessionService: sessionService,
+		revocations:    revocations,
+		userService:    userService,
+	}
+}
+
+// Execute reports whether the token is active. Errors are only returned for
+// infrastructure failures; anything wrong with the token itself is inactive.
+func (uc *introspectUseCase) Execute(ctx context.Context, req *oauth.IntrospectRequest) (*oauth.IntrospectionResponse, error) {
+	token := strings.TrimSpace(req.Token)
+	inactive := &oauth.IntrospectionResponse{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
+
+	// the hint only decides which type we try first
+	order := []string{oauth.TokenTypeHintAccess, oauth.TokenTypeHintRefresh}
+	if req.TokenTypeHint == oauth.TokenTypeHintRefresh {
+		order = []string{oauth.TokenTypeHintRefresh, oauth.TokenTypeHintAccess}
+	}
+
+	for _, tokenType := range order {
+		var (
+			resp *oauth.IntrospectionResponse
+			err  error
+		)
+		if tokenType == oauth.TokenTypeHintAccess {
+			resp, err = uc.introspectAccess(token)
+		} else {
+			resp, err = uc.introspectRefresh(token)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			return resp, nil
+		}
+	}
+
+	return inactive, nil
+}
+
+// introspectAccess returns nil when the token is not a usable access token.
+func (uc *introspectUseCase) introspectAccess(token string) (*oauth.IntrospectionResponse, error) {
+	// ParseAccessToken also refuses ID tokens and other token types
+	claims, err := uc.tokenManager.ParseAccessToken(token, uc.tokenManager.Audiences()...)
+	if err != nil {
+		return nil, nil
+	}
+
+	revoked, err := uc.revocations.IsRevoked(claims.JTI)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, nil
+	}
+
+	// only client credentials tokens are issued without a session
+	if claims.SID == "" {
+		if !claims.IsMachine() {
+			return nil, nil
+		}
+	} else {
+		sess, err := uc.sessionService.GetSessionByID(claims.SID)
+		if err != nil {
+			return nil, err
+		}
+		if !isLive(sess, claims.UserID) {
+			return nil, nil
+		}
+	}
+
+	return uc.activeResponse(claims, oauth.TokenTypeHintAccess), nil
+}
+
+func (uc *introspectUseCase) introspectRefresh(token string) (*oauth.IntrospectionResponse, error) {
+	claims, err := uc.tokenManager.ParseRefreshToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	sess, err := uc.sessionService.GetSessionByID(claims.SID)
+	if err != nil {
+		return nil, err
+	}
+	// a rotated-away refresh token is no longer active
+	if !isLive(sess, claims.UserID) || !security.CompareTokenHash(sess.RefreshTokenHash, token) {
+		return nil, nil
+	}
+
+	return uc.activeResponse(claims, oauth.TokenTypeHintRefresh), nil
+}
+
+func (uc *introspectUseCase) activeResponse(claims *security.ClaimsPayload, tokenType string) *oauth.IntrospectionResponse {
+	username := claims.Username
+	if username == "" {
+		// refresh tokens carry no profile claims
+		if u, err := uc.userService.Get(claims.UserID); err == nil && u != nil {
+			username = u.Username
+		}
+	}
+
+	resp := &oauth.IntrospectionResponse{
+		Active:    true,
+		Sub:       claims.UserID,
+		Username:  username,
+		Exp:       claims.Expiry.Unix(),
+		SessionID: claims.SID,
+		TokenType: tokenType,
+		JTI:       claims.JTI,
+		Iss:       claims.Issuer,
+		Aud:       claims.Audience,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+	}
+	if claims.JKT != "" {
+		resp.Cnf = &oauth.Confirmation{JKT: claims.JKT}
+	}
+	if claims.Actor != nil {
+		resp.Act = &oauth.Actor{Sub: claims.Actor.Subject, Username: claims.Actor.Username}
+	}
+	return resp
+}
+
+func isLive(sess *session.Session, userID string) bool {
+	return sess != nil && sess.Valid && sess.UserID == userID && time.Now().Before(sess.ExpiresAt)
+}
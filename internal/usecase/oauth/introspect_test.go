@@ -0,0 +1,151 @@
+package oauth
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestIntrospect(t *testing.T) {
+	tm := newTestTokenManager(t)
+	sessions := &memorySessions{sessions: map[string]*session.Session{}}
+	revocations := revocation.NewService(repository.NewMemoryRevokedTokenRepository())
+	uc := NewIntrospectUseCase(tm, sessions, revocations, &memoryUsers{users: map[string]*user.User{"user-1": {ID: "user-1", Username: "jane"}}})
+
+	login := func(sid string, valid bool, opts ...security.TokenOption) *security.TokenPair {
+		pair, err := tm.GenerateTokenPair("user-1", "jane@example.com", "jane", []string{"user"}, append(opts, security.WithSessionID(sid))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions.sessions[sid] = &session.Session{
+			ID: sid, UserID: "user-1", Valid: valid, ExpiresAt: time.Now().Add(time.Hour),
+			RefreshTokenHash: security.HashToken(pair.RefreshToken),
+		}
+		return pair
+	}
+	live := login("live", true, security.WithScope("profile"), security.WithClientID("app"))
+	dead := login("dead", false)
+	rotated := login("rotated", true)
+	sessions.sessions["rotated"].RefreshTokenHash = security.HashToken("newer")
+	revoked := login("revoked-jti", true)
+	if err := revocations.Revoke(revoked.JTI, revoked.AccessExp, revocation.ReasonLogout); err != nil {
+		t.Fatal(err)
+	}
+	withID := login("id-token", true, security.WithIDToken(security.IDTokenClaims{Audience: "app"}))
+	machine, err := tm.GenerateAccessToken(security.MachineSubjectPrefix+"svc", "", "svc", nil, security.WithClientID("svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionless, err := tm.GenerateAccessToken("user-1", "jane@example.com", "jane", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		req           oauth.IntrospectRequest
+		wantTokenType string
+		wantSub       string
+	}{
+		{name: "access token", req: oauth.IntrospectRequest{Token: live.AccessToken}, wantTokenType: oauth.TokenTypeHintAccess, wantSub: "user-1"},
+		{name: "access token with a refresh hint", req: oauth.IntrospectRequest{Token: live.AccessToken, TokenTypeHint: oauth.TokenTypeHintRefresh}, wantTokenType: oauth.TokenTypeHintAccess, wantSub: "user-1"},
+		{name: "refresh token", req: oauth.IntrospectRequest{Token: live.RefreshToken}, wantTokenType: oauth.TokenTypeHintRefresh, wantSub: "user-1"},
+		{name: "machine token", req: oauth.IntrospectRequest{Token: machine.AccessToken}, wantTokenType: oauth.TokenTypeHintAccess, wantSub: security.MachineSubjectPrefix + "svc"},
+		{name: "empty", req: oauth.IntrospectRequest{Token: "  "}},
+		{name: "garbage", req: oauth.IntrospectRequest{Token: "not-a-token"}},
+		{name: "revoked session", req: oauth.IntrospectRequest{Token: dead.AccessToken}},
+		{name: "refresh token of a revoked session", req: oauth.IntrospectRequest{Token: dead.RefreshToken}},
+		{name: "rotated-away refresh token", req: oauth.IntrospectRequest{Token: rotated.RefreshToken}},
+		{name: "denylisted access token", req: oauth.IntrospectRequest{Token: revoked.AccessToken}},
+		{name: "ID token", req: oauth.IntrospectRequest{Token: withID.IDToken}},
+		{name: "user token without a session", req: oauth.IntrospectRequest{Token: sessionless.AccessToken}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := uc.Execute(context.Background(), &tt.req)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if resp.Active != (tt.wantTokenType != "") {
+				t.Fatalf("Execute() active = %v", resp.Active)
+			}
+			if !resp.Active {
+				if !reflect.DeepEqual(resp, &oauth.IntrospectionResponse{}) {
+					t.Errorf("inactive response leaks %+v", resp)
+				}
+				return
+			}
+			if resp.TokenType != tt.wantTokenType || resp.Sub != tt.wantSub || resp.Iss != testIssuer || resp.Exp == 0 {
+				t.Errorf("Execute() = %+v", resp)
+			}
+		})
+	}
+
+	// refresh tokens carry no profile, the username comes from the user
+	resp, err := uc.Execute(context.Background(), &oauth.IntrospectRequest{Token: live.RefreshToken})
+	if err != nil || resp.Username != "jane" {
+		t.Fatalf("Execute(refresh) = %+v, %v, want username jane", resp, err)
+	}
+	resp, err = uc.Execute(context.Background(), &oauth.IntrospectRequest{Token: live.AccessToken})
+	if err != nil || resp.Scope != "profile" || resp.ClientID != "app" || !slices.Equal(resp.Aud, []string{testAudience}) {
+		t.Fatalf("Execute(access) = %+v, %v", resp, err)
+	}
+}
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "web"
+)
+
+func newTestTokenManager(t *testing.T) *security.TokenManager {
+	t.Helper()
+	tm, err := security.NewTokenManager(security.TokenConfig{
+		AccessSecret:  "access-secret-for-tests",
+		RefreshSecret: "refresh-secret-for-tests",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		Issuer:        testIssuer,
+		Audience:      testAudience,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+type memorySessions struct {
+	session.Service
+	sessions map[string]*session.Session
+}
+
+func (m *memorySessions) GetSessionByID(id string) (*session.Session, error) {
+	return m.sessions[id], nil
+}
+
+func (m *memorySessions) CreateSession(sess *session.Session) error {
+	m.sessions[sess.ID] = sess
+	return nil
+}
+
+type memoryUsers struct {
+	user.Service
+	users map[string]*user.User
+}
+
+func (m *memoryUsers) Get(id string) (*user.User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, user.ErrNotFound
+	}
+	return u, nil
+}
@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// RefreshTokenGrantUseCase rotates the refresh token of a session opened
+// through the authorization endpoint (RFC 6749 section 6). Reuse detection
+// and the grace window work as for first-party refreshes.
+type RefreshTokenGrantUseCase interface {
+	Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error)
+}
+
+type refreshTokenGrantUseCase struct {
+	oauthService   oauth.Service
+	sessionService session.Service
+	userService    user.Service
+	securityEvents securityevent.Service
+	tokenManager   *security.TokenManager
+	refreshGrace   time.Duration
+}
+
+func NewRefreshTokenGrantUseCase(
+	oauthService oauth.Service,
+	sessionService session.Service,
+	userService user.Service,
+	securityEvents securityevent.Service,
+	tokenManager *security.TokenManager,
+	refreshGrace time.Duration,
+) RefreshTokenGrantUseCase {
+	return &refreshTokenGrantUseCase{
+		oauthService:   oauthService,
+		sessionService: sessionService,
+		userService:    userService,
+		securityEvents: securityEvents,
+		tokenManager:   tokenManager,
+		refreshGrace:   refreshGrace,
+	}
+}
+
+func (uc *refreshTokenGrantUseCase) Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	client, err := uc.oauthService.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if req.RefreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh_token is required", oauth.ErrInvalidRequest)
+	}
+
+	payload, err := uc.tokenManager.ParseRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid refresh token", oauth.ErrInvalidGrant)
+	}
+	sess, err := uc.sessionService.GetSessionByID(payload.SID)
+	if err != nil {
+		return nil, err
+	}
+	// first-party sessions and other clients' sessions are off limits
+	if !isLive(sess, payload.UserID) || sess.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: session expired or revoked", oauth.ErrInvalidGrant)
+	}
+
+	scope := sess.Scope
+	if req.Scope != "" {
+		// a refresh may narrow the scope, never widen it (RFC 6749 section 6)
+		granted := strings.Fields(sess.Scope)
+		for _, s := range strings.Fields(req.Scope) {
+			if !slices.Contains(granted, s) {
+				return nil, fmt.Errorf("%w: %s was not granted", oauth.ErrInvalidScope, s)
+			}
+		}
+		scope = strings.Join(strings.Fields(req.Scope), " ")
+	}
+
+	state := uc.sessionService.ClassifyRefreshToken(sess, req.RefreshToken, uc.refreshGrace)
+	if state == session.RefreshTokenReused {
+		return nil, uc.revokeFamily(sess, req)
+	}
+
+	u, err := uc.userService.Get(sess.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, fmt.Errorf("%w: user no longer exists", oauth.ErrInvalidGrant)
+		}
+		return nil, err
+	}
+
+	if state == session.RefreshTokenGrace {
+		return uc.accessOnly(client, sess, u, scope)
+	}
+
+	opts := append(clientTokenOptions(client, scope), security.WithSessionID(sess.ID))
+	if hasOpenID(scope) {
+		opts = append(opts, security.WithIDToken(security.IDTokenClaims{
+			Audience:      client.ID,
+			Name:          u.Fullname,
+			EmailVerified: u.EmailVerified,
+			AuthTime:      sess.CreatedAt,
+		}))
+	}
+	pair, err := uc.tokenManager.GenerateTokenPair(u.ID, u.Email, u.Username, clientRoles(client, u), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.sessionService.RotateRefreshToken(sess, req.RefreshToken, pair.RefreshToken, pair.RefreshExp, req.IPAddress, req.UserAgent)
+	if errors.Is(err, session.ErrRotationConflict) {
+		// lost the race against a concurrent refresh of the same token
+		return uc.accessOnly(client, sess, u, scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tokenResponse(pair, scope), nil
+}
+
+// accessOnly answers a refresh that raced a rotation with an access token
+// and no new refresh token; the client keeps the one the winner received.
+func (uc *refreshTokenGrantUseCase) accessOnly(client *oauth.Client, sess *session.Session, u *user.User, scope string) (*oauth.TokenResponse, error) {
+	opts := append(clientTokenOptions(client, scope), security.WithSessionID(sess.ID))
+	pair, err := uc.tokenManager.GenerateAccessToken(u.ID, u.Email, u.Username, clientRoles(client, u), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return tokenResponse(pair, scope), nil
+}
+
+// revokeFamily handles a replayed refresh token by revoking the session.
+func (uc *refreshTokenGrantUseCase) revokeFamily(sess *session.Session, req *oauth.TokenRequest) error {
+	if err := uc.sessionService.InvalidateSession(sess.ID); err != nil {
+		return err
+	}
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    sess.UserID,
+		SessionID: sess.ID,
+		Type:      securityevent.TypeRefreshTokenReuse,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+		Details:   sess.ClientID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record refresh token reuse")
+	}
+	return fmt.Errorf("%w: refresh token reuse detected", oauth.ErrInvalidGrant)
+}
@@ -0,0 +1,182 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+// TokenExchangeUseCase lets an admin trade their access token for a
+// short-lived token acting as another user (RFC 8693 impersonation). A DPoP
+// bound subject token is only exchanged with a proof from its key, and the
+// impersonation token is bound to the same key.
+type TokenExchangeUseCase interface {
+	Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error)
+}
+
+type tokenExchangeUseCase struct {
+	tokenManager     *security.TokenManager
+	sessionService   session.Service
+	revocations      revocation.Service
+	userService      user.Service
+	impersonationTTL time.Duration
+}
+
+func NewTokenExchangeUseCase(
+	tokenManager *security.TokenManager,
+	sessionService session.Service,
+	revocations revocation.Service,
+	userService user.Service,
+	impersonationTTL time.Duration,
+) TokenExchangeUseCase {
+	return &tokenExchangeUseCase{
+		tokenManager:     tokenManager,
+		sessionService:   sessionService,
+		revocations:      revocations,
+		userService:      userService,
+		impersonationTTL: impersonationTTL,
+	}
+}
+
+func (uc *tokenExchangeUseCase) Execute(ctx context.Context, req *oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	if req.SubjectToken == "" || req.RequestedSubject == "" {
+		return nil, fmt.Errorf("%w: subject_token and requested_subject are required", oauth.ErrInvalidRequest)
+	}
+	if req.SubjectTokenType != "" && req.SubjectTokenType != oauth.TokenTypeAccessToken {
+		return nil, fmt.Errorf("%w: unsupported subject_token_type", oauth.ErrInvalidRequest)
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != oauth.TokenTypeAccessToken {
+		return nil, fmt.Errorf("%w: unsupported requested_token_type", oauth.ErrInvalidRequest)
+	}
+
+	admin, err := uc.authenticateAdmin(req.SubjectToken, req.DPoPJKT)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := uc.userService.Get(req.RequestedSubject)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown requested_subject", oauth.ErrInvalidRequest)
+		}
+		return nil, err
+	}
+	if target.Role == user.RoleAdmin {
+		return nil, fmt.Errorf("%w: administrators cannot be impersonated", oauth.ErrAccessDenied)
+	}
+
+	now := time.Now().UTC()
+	impersonatorID := admin.UserID
+	sess := &session.Session{
+		ID:             uuid.NewString(),
+		UserID:         target.ID,
+		IPAddress:      req.IPAddress,
+		UserAgent:      req.UserAgent,
+		Valid:          true,
+		ExpiresAt:      now.Add(uc.impersonationTTL),
+		ImpersonatorID: &impersonatorID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := uc.sessionService.CreateSession(sess); err != nil {
+		return nil, err
+	}
+
+	opts := []security.TokenOption{
+		security.WithSessionID(sess.ID),
+		security.WithActor(security.Actor{Subject: admin.UserID, Username: admin.Username}),
+		security.WithAccessTTL(uc.impersonationTTL),
+	}
+	tokenType := "Bearer"
+	if admin.JKT != "" {
+		opts = append(opts, security.WithConfirmation(admin.JKT))
+		tokenType = "DPoP"
+	}
+	pair, err := uc.tokenManager.GenerateAccessToken(
+		target.ID,
+		target.Email,
+		target.Username,
+		[]string{string(target.Role)},
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"actor_id":   admin.UserID,
+		"subject_id": target.ID,
+		"session_id": sess.ID,
+	}).Warn("impersonation session started")
+
+	return &oauth.TokenResponse{
+		AccessToken:     pair.AccessToken,
+		IssuedTokenType: oauth.TokenTypeAccessToken,
+		TokenType:       tokenType,
+		ExpiresIn:       int64(time.Until(pair.AccessExp).Seconds()),
+	}, nil
+}
+
+// authenticateAdmin validates the subject token as a live, non-impersonated
+// admin access token. jkt is the key of the request's DPoP proof.
+func (uc *tokenExchangeUseCase) authenticateAdmin(token, jkt string) (*security.ClaimsPayload, error) {
+	// only tokens issued for our own API may drive an exchange
+	claims, err := uc.tokenManager.ParseAccessToken(token, uc.tokenManager.Audience())
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject_token", oauth.ErrInvalidGrant)
+	}
+	// a stolen bound token must not turn into an unbound one
+	if claims.JKT != "" && claims.JKT != jkt {
+		return nil, fmt.Errorf("%w: subject_token is DPoP bound, a proof from its key is required", oauth.ErrInvalidDPoPProof)
+	}
+
+	revoked, err := uc.revocations.IsRevoked(claims.JTI)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: subject_token revoked", oauth.ErrInvalidGrant)
+	}
+
+	sess, err := uc.sessionService.GetSessionByID(claims.SID)
+	if err != nil {
+		return nil, err
+	}
+	if !isLive(sess, claims.UserID) {
+		return nil, fmt.Errorf("%w: session expired or revoked", oauth.ErrInvalidGrant)
+	}
+
+	// no chained impersonation
+	if claims.IsImpersonated() {
+		return nil, fmt.Errorf("%w: impersonated tokens cannot be exchanged", oauth.ErrAccessDenied)
+	}
+	if claims.IsRestricted() {
+		return nil, fmt.Errorf("%w: recovery sessions cannot be exchanged", oauth.ErrAccessDenied)
+	}
+	// clients and API keys act with the admin's role, not as the admin
+	if claims.ClientID != "" || claims.APIKeyID != "" {
+		return nil, fmt.Errorf("%w: only a direct admin login can impersonate", oauth.ErrAccessDenied)
+	}
+	isAdmin := false
+	for _, role := range claims.Roles {
+		if role == string(user.RoleAdmin) {
+			isAdmin = true
+		}
+	}
+	if !isAdmin {
+		return nil, fmt.Errorf("%w: impersonation requires the admin role", oauth.ErrAccessDenied)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/domain/oauth"
+	"mikhailjbs/user-auth-service/internal/domain/revocation"
+	"mikhailjbs/user-auth-service/internal/domain/session"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/repository"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+)
+
+func TestTokenExchange(t *testing.T) {
+	logger.Init()
+	tm := newTestTokenManager(t)
+	sessions := &memorySessions{sessions: map[string]*session.Session{}}
+	revocations := revocation.NewService(repository.NewMemoryRevokedTokenRepository())
+	users := &memoryUsers{users: map[string]*user.User{
+		"user-1":  {ID: "user-1", Username: "jane", Email: "jane@example.com", Role: user.RoleUser},
+		"admin-2": {ID: "admin-2", Username: "root", Role: user.RoleAdmin},
+	}}
+	uc := NewTokenExchangeUseCase(tm, sessions, revocations, users, 10*time.Minute)
+
+	subject := func(sid string, valid bool, role user.Role, opts ...security.TokenOption) string {
+		pair, err := tm.GenerateAccessToken("admin-1", "admin@example.com", "admin", []string{string(role)}, append(opts, security.WithSessionID(sid))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions.sessions[sid] = &session.Session{ID: sid, UserID: "admin-1", Valid: valid, ExpiresAt: time.Now().Add(time.Hour)}
+		return pair.AccessToken
+	}
+	admin := subject("admin", true, user.RoleAdmin)
+	denylisted, err := tm.GenerateAccessToken("admin-1", "", "admin", []string{"admin"}, security.WithSessionID("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.Revoke(denylisted.JTI, denylisted.AccessExp, revocation.ReasonAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		req           oauth.TokenRequest
+		wantErr       error
+		wantTokenType string
+	}{
+		{name: "admin", req: oauth.TokenRequest{SubjectToken: admin, RequestedSubject: "user-1"}, wantTokenType: "Bearer"},
+		{name: "explicit token types", req: oauth.TokenRequest{SubjectToken: admin, SubjectTokenType: oauth.TokenTypeAccessToken, RequestedTokenType: oauth.TokenTypeAccessToken, RequestedSubject: "user-1"}, wantTokenType: "Bearer"},
+		{name: "bound subject token with its proof", req: oauth.TokenRequest{SubjectToken: subject("bound", true, user.RoleAdmin, security.WithConfirmation("jkt")), RequestedSubject: "user-1", DPoPJKT: "jkt"}, wantTokenType: "DPoP"},
+		{name: "bound subject token without a proof", req: oauth.TokenRequest{SubjectToken: subject("bound", true, user.RoleAdmin, security.WithConfirmation("jkt")), RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidDPoPProof},
+		{name: "missing subject token", req: oauth.TokenRequest{RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidRequest},
+		{name: "missing requested subject", req: oauth.TokenRequest{SubjectToken: admin}, wantErr: oauth.ErrInvalidRequest},
+		{name: "unsupported subject token type", req: oauth.TokenRequest{SubjectToken: admin, SubjectTokenType: "urn:ietf:params:oauth:token-type:id_token", RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidRequest},
+		{name: "garbage subject token", req: oauth.TokenRequest{SubjectToken: "not-a-token", RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidGrant},
+		{name: "denylisted subject token", req: oauth.TokenRequest{SubjectToken: denylisted.AccessToken, RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidGrant},
+		{name: "revoked session", req: oauth.TokenRequest{SubjectToken: subject("revoked", false, user.RoleAdmin), RequestedSubject: "user-1"}, wantErr: oauth.ErrInvalidGrant},
+		{name: "not an admin", req: oauth.TokenRequest{SubjectToken: subject("user", true, user.RoleUser), RequestedSubject: "user-1"}, wantErr: oauth.ErrAccessDenied},
+		{name: "chained impersonation", req: oauth.TokenRequest{SubjectToken: subject("chained", true, user.RoleAdmin, security.WithActor(security.Actor{Subject: "admin-0"})), RequestedSubject: "user-1"}, wantErr: oauth.ErrAccessDenied},
+		{name: "token issued to an OAuth client", req: oauth.TokenRequest{SubjectToken: subject("client", true, user.RoleAdmin, security.WithClientID("first-party-app")), RequestedSubject: "user-1"}, wantErr: oauth.ErrAccessDenied},
+		{name: "recovery session", req: oauth.TokenRequest{SubjectToken: subject("recovery", true, user.RoleAdmin, security.WithScope(security.ScopeAccountRecovery)), RequestedSubject: "user-1"}, wantErr: oauth.ErrAccessDenied},
+		{name: "another admin", req: oauth.TokenRequest{SubjectToken: admin, RequestedSubject: "admin-2"}, wantErr: oauth.ErrAccessDenied},
+		{name: "unknown user", req: oauth.TokenRequest{SubjectToken: admin, RequestedSubject: "nobody"}, wantErr: oauth.ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := uc.Execute(context.Background(), &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if resp.TokenType != tt.wantTokenType || resp.IssuedTokenType != oauth.TokenTypeAccessToken || resp.RefreshToken != "" {
+				t.Errorf("Execute() = %+v", resp)
+			}
+			if resp.ExpiresIn <= 0 || resp.ExpiresIn > int64((10*time.Minute).Seconds()) {
+				t.Errorf("ExpiresIn = %d, want at most the impersonation TTL", resp.ExpiresIn)
+			}
+
+			claims, err := tm.ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != "user-1" || claims.Actor == nil || claims.Actor.Subject != "admin-1" || claims.JKT != tt.req.DPoPJKT {
+				t.Errorf("impersonation claims = %+v", claims)
+			}
+			sess := sessions.sessions[claims.SID]
+			if sess == nil || sess.UserID != "user-1" || sess.ImpersonatorID == nil || *sess.ImpersonatorID != "admin-1" {
+				t.Errorf("impersonation session = %+v", sess)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package passkey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// BeginLoginUseCase starts a usernameless passkey sign-in. state is carried
+// through the ceremony and handed back by FinishLoginUseCase.
+type BeginLoginUseCase interface {
+	Execute(ctx context.Context, state map[string]string) (*passkey.LoginOptions, error)
+}
+
+type beginLoginUseCase struct {
+	tokenManager *security.TokenManager
+	rp           *webauthn.RelyingParty
+}
+
+func NewBeginLoginUseCase(tokenManager *security.TokenManager, rp *webauthn.RelyingParty) BeginLoginUseCase {
+	return &beginLoginUseCase{tokenManager: tokenManager, rp: rp}
+}
+
+func (uc *beginLoginUseCase) Execute(ctx context.Context, state map[string]string) (*passkey.LoginOptions, error) {
+	token, challenge, err := beginCeremony(uc.tokenManager, security.ChallengePurposeWebAuthnLogin, "", state)
+	if err != nil {
+		return nil, err
+	}
+	return &passkey.LoginOptions{
+		CeremonyToken: token,
+		PublicKey:     uc.rp.RequestOptions(challenge),
+	}, nil
+}
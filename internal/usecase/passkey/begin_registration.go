@@ -0,0 +1,60 @@
+package passkey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// BeginRegistrationUseCase starts adding a passkey to a signed-in user.
+type BeginRegistrationUseCase interface {
+	Execute(ctx context.Context, userID string) (*passkey.RegistrationOptions, error)
+}
+
+type beginRegistrationUseCase struct {
+	passkeyService passkey.Service
+	userService    user.Service
+	tokenManager   *security.TokenManager
+	rp             *webauthn.RelyingParty
+}
+
+func NewBeginRegistrationUseCase(passkeyService passkey.Service, userService user.Service, tokenManager *security.TokenManager, rp *webauthn.RelyingParty) BeginRegistrationUseCase {
+	return &beginRegistrationUseCase{
+		passkeyService: passkeyService,
+		userService:    userService,
+		tokenManager:   tokenManager,
+		rp:             rp,
+	}
+}
+
+func (uc *beginRegistrationUseCase) Execute(ctx context.Context, userID string) (*passkey.RegistrationOptions, error) {
+	u, err := uc.userService.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := uc.passkeyService.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, c := range existing {
+		exclude = append(exclude, webauthn.CredentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+
+	token, challenge, err := beginCeremony(uc.tokenManager, security.ChallengePurposeWebAuthnRegister, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkey.RegistrationOptions{
+		CeremonyToken: token,
+		PublicKey: uc.rp.CreationOptions(challenge, webauthn.User{
+			ID:          []byte(u.ID),
+			Name:        u.Email,
+			DisplayName: u.Fullname,
+		}, exclude),
+	}, nil
+}
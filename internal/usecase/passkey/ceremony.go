@@ -0,0 +1,59 @@
+package passkey
+
+import (
+	"encoding/base64"
+	"time"
+
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// CeremonyTTL is how long the browser has to complete a WebAuthn ceremony.
+const CeremonyTTL = 5 * time.Minute
+
+// challengeKey holds the WebAuthn challenge in the ceremony token's data.
+const challengeKey = "webauthn_challenge"
+
+// beginCeremony creates a WebAuthn challenge and a signed ceremony token that
+// carries it, so no server-side state is needed between the two steps.
+func beginCeremony(tokenManager *security.TokenManager, purpose, subject string, data map[string]string) (string, []byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", nil, err
+	}
+
+	state := make(map[string]string, len(data)+1)
+	for k, v := range data {
+		state[k] = v
+	}
+	state[challengeKey] = base64.RawURLEncoding.EncodeToString(challenge)
+
+	token, _, err := tokenManager.IssueChallenge(purpose, subject, CeremonyTTL, state)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, challenge, nil
+}
+
+// finishCeremony verifies a ceremony token and burns it: a ceremony gets
+// exactly one attempt, successful or not.
+func finishCeremony(tokenManager *security.TokenManager, used security.ReplayCache, token, purpose string) (*security.Challenge, []byte, error) {
+	ceremony, err := tokenManager.ParseChallenge(token, purpose)
+	if err != nil {
+		return nil, nil, err
+	}
+	fresh, err := used.Remember(ceremony.ID, ceremony.ExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !fresh {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(ceremony.Data[challengeKey])
+	if err != nil || len(challenge) == 0 {
+		return nil, nil, security.ErrInvalidChallenge
+	}
+	delete(ceremony.Data, challengeKey)
+	return ceremony, challenge, nil
+}
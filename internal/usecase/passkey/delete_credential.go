@@ -0,0 +1,43 @@
+package passkey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+)
+
+// DeleteCredentialUseCase removes one of the user's own passkeys.
+type DeleteCredentialUseCase interface {
+	Execute(ctx context.Context, userID, credentialID, ip, userAgent string) error
+}
+
+type deleteCredentialUseCase struct {
+	passkeyService passkey.Service
+	securityEvents securityevent.Service
+}
+
+func NewDeleteCredentialUseCase(passkeyService passkey.Service, securityEvents securityevent.Service) DeleteCredentialUseCase {
+	return &deleteCredentialUseCase{
+		passkeyService: passkeyService,
+		securityEvents: securityEvents,
+	}
+}
+
+func (uc *deleteCredentialUseCase) Execute(ctx context.Context, userID, credentialID, ip, userAgent string) error {
+	if err := uc.passkeyService.Delete(userID, credentialID); err != nil {
+		return err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypePasskeyRemoved,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   credentialID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record passkey removal")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+
+	"mikhailjbs/user-auth-service/internal/domain/auth"
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/domain/user"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// FinishLoginUseCase verifies a passkey assertion and returns the user it
+// belongs to together with the ceremony, whose Data holds the state passed to
+// BeginLoginUseCase. A user-verified passkey is both factors at once, so no
+// further MFA step follows.
+type FinishLoginUseCase interface {
+	Execute(ctx context.Context, req *passkey.LoginFinishRequest, ip, userAgent string) (*user.User, *security.Challenge, error)
+}
+
+type finishLoginUseCase struct {
+	passkeyService passkey.Service
+	userService    user.Service
+	authService    auth.Service
+	securityEvents securityevent.Service
+	tokenManager   *security.TokenManager
+	rp             *webauthn.RelyingParty
+	used           security.ReplayCache
+}
+
+func NewFinishLoginUseCase(passkeyService passkey.Service, userService user.Service, authService auth.Service, securityEvents securityevent.Service, tokenManager *security.TokenManager, rp *webauthn.RelyingParty, used security.ReplayCache) FinishLoginUseCase {
+	return &finishLoginUseCase{
+		passkeyService: passkeyService,
+		userService:    userService,
+		authService:    authService,
+		securityEvents: securityEvents,
+		tokenManager:   tokenManager,
+		rp:             rp,
+		used:           used,
+	}
+}
+
+func (uc *finishLoginUseCase) Execute(ctx context.Context, req *passkey.LoginFinishRequest, ip, userAgent string) (*user.User, *security.Challenge, error) {
+	ceremony, challenge, err := finishCeremony(uc.tokenManager, uc.used, req.CeremonyToken, security.ChallengePurposeWebAuthnLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cred, err := uc.passkeyService.GetByCredentialID(req.Credential.CredentialID())
+	if err != nil {
+		return nil, nil, err
+	}
+	// the user handle is what the authenticator stored at registration
+	if handle := req.Credential.Response.UserHandle; len(handle) > 0 && string(handle) != cred.UserID {
+		return nil, nil, passkey.ErrNotFound
+	}
+
+	assertion, err := uc.rp.VerifyAssertion(challenge, &req.Credential, cred.PublicKey, uint32(cred.SignCount))
+	if errors.Is(err, webauthn.ErrSignCount) {
+		uc.recordClone(cred, ip, userAgent)
+		return nil, nil, passkey.ErrCloned
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := uc.passkeyService.RecordUse(cred, assertion); err != nil {
+		if errors.Is(err, passkey.ErrCloned) {
+			uc.recordClone(cred, ip, userAgent)
+		}
+		return nil, nil, err
+	}
+
+	u, err := uc.userService.Get(cred.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := uc.authService.CheckLoginAllowed(u); err != nil {
+		return nil, nil, err
+	}
+	return u, ceremony, nil
+}
+
+func (uc *finishLoginUseCase) recordClone(cred *passkey.Credential, ip, userAgent string) {
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    cred.UserID,
+		Type:      securityevent.TypePasskeyCloned,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   cred.ID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record passkey clone warning")
+	}
+}
@@ -0,0 +1,66 @@
+package passkey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+	"mikhailjbs/user-auth-service/internal/domain/securityevent"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	"mikhailjbs/user-auth-service/internal/infra/security"
+	"mikhailjbs/user-auth-service/internal/infra/webauthn"
+)
+
+// FinishRegistrationUseCase verifies the authenticator's response and stores
+// the new passkey.
+type FinishRegistrationUseCase interface {
+	Execute(ctx context.Context, userID string, req *passkey.RegistrationFinishRequest, ip, userAgent string) (*passkey.Credential, error)
+}
+
+type finishRegistrationUseCase struct {
+	passkeyService passkey.Service
+	securityEvents securityevent.Service
+	tokenManager   *security.TokenManager
+	rp             *webauthn.RelyingParty
+	used           security.ReplayCache
+}
+
+func NewFinishRegistrationUseCase(passkeyService passkey.Service, securityEvents securityevent.Service, tokenManager *security.TokenManager, rp *webauthn.RelyingParty, used security.ReplayCache) FinishRegistrationUseCase {
+	return &finishRegistrationUseCase{
+		passkeyService: passkeyService,
+		securityEvents: securityEvents,
+		tokenManager:   tokenManager,
+		rp:             rp,
+		used:           used,
+	}
+}
+
+func (uc *finishRegistrationUseCase) Execute(ctx context.Context, userID string, req *passkey.RegistrationFinishRequest, ip, userAgent string) (*passkey.Credential, error) {
+	ceremony, challenge, err := finishCeremony(uc.tokenManager, uc.used, req.CeremonyToken, security.ChallengePurposeWebAuthnRegister)
+	if err != nil {
+		return nil, err
+	}
+	// the ceremony must be finished by the user who started it
+	if ceremony.Subject != userID {
+		return nil, security.ErrInvalidChallenge
+	}
+
+	verified, err := uc.rp.VerifyRegistration(challenge, &req.Credential)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := uc.passkeyService.Register(userID, req.Name, verified)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.securityEvents.Record(&securityevent.Event{
+		UserID:    userID,
+		Type:      securityevent.TypePasskeyAdded,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Details:   cred.ID,
+	}); err != nil {
+		logger.Log.WithError(err).Error("failed to record passkey registration")
+	}
+	return cred, nil
+}
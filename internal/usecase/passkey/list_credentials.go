@@ -0,0 +1,24 @@
+package passkey
+
+import (
+	"context"
+
+	"mikhailjbs/user-auth-service/internal/domain/passkey"
+)
+
+// ListCredentialsUseCase returns the passkeys registered to a user.
+type ListCredentialsUseCase interface {
+	Execute(ctx context.Context, userID string) ([]passkey.Credential, error)
+}
+
+type listCredentialsUseCase struct {
+	passkeyService passkey.Service
+}
+
+func NewListCredentialsUseCase(passkeyService passkey.Service) ListCredentialsUseCase {
+	return &listCredentialsUseCase{passkeyService: passkeyService}
+}
+
+func (uc *listCredentialsUseCase) Execute(ctx context.Context, userID string) ([]passkey.Credential, error) {
+	return uc.passkeyService.ListByUser(userID)
+}
@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"mikhailjbs/user-auth-service/internal/domain/user"
-
-	"golang.org/x/crypto/bcrypt"
+	"mikhailjbs/user-auth-service/internal/infra/logger"
+	authusecase "mikhailjbs/user-auth-service/internal/usecase/auth"
 )
 
 type UpdateUserUseCase interface {
@@ -14,11 +14,14 @@ type UpdateUserUseCase interface {
 }
 
 type updateUserUseCase struct {
-	service user.Service
+	service          user.Service
+	sendVerification authusecase.SendVerificationUseCase
 }
 
-func NewUpdateUserUseCase(service user.Service) UpdateUserUseCase {
-	return &updateUserUseCase{service: service}
+// NewUpdateUserUseCase updates a profile. A changed email address loses its
+// verified flag and is sent a new verification link.
+func NewUpdateUserUseCase(service user.Service, sendVerification authusecase.SendVerificationUseCase) UpdateUserUseCase {
+	return &updateUserUseCase{service: service, sendVerification: sendVerification}
 }
 
 func (uc *updateUserUseCase) Execute(ctx context.Context, id string, req *user.UpdateUserRequest) (*user.User, error) {
@@ -35,19 +38,36 @@ func (uc *updateUserUseCase) Execute(ctx context.Context, id string, req *user.U
 		existingUser.Fullname = *req.Fullname
 	}
 
-	if req.Email != nil {
-		existingUser.Email = *req.Email
+	emailChanged := req.Email != nil && user.NormalizeEmail(*req.Email) != user.NormalizeEmail(existingUser.Email)
+	if emailChanged {
+		existingUser.Email = user.NormalizeEmail(*req.Email)
 	}
 
 	if req.Password != nil {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		// checked against the username and email the user ends up with
+		if err := uc.service.CheckPassword(*req.Password, existingUser); err != nil {
+			return nil, err
+		}
+		hashedPassword, err := uc.service.HashPassword(*req.Password)
 		if err != nil {
 			return nil, err
 		}
-		existingUser.PasswordHash = string(hashedPassword)
+		existingUser.PasswordHash = hashedPassword
 	}
 
 	existingUser.UpdatedAt = time.Now()
 
-	return uc.service.Update(id, existingUser)
+	updated, err := uc.service.Update(id, existingUser)
+	if err != nil || !emailChanged {
+		return updated, err
+	}
+
+	if updated, err = uc.service.MarkEmailUnverified(id); err != nil {
+		return nil, err
+	}
+	// the change is saved either way; the user can ask for a new link
+	if err := uc.sendVerification.Execute(ctx, updated); err != nil {
+		logger.Log.WithError(err).WithField("user_id", id).Error("failed to send verification email")
+	}
+	return updated, nil
 }